@@ -0,0 +1,150 @@
+package main
+
+import "sort"
+
+// BrokenLink struct represents a link of the crawled pages which could not be followed. Status is the status code
+// of the response, zero if there was none, e.g. because of a timeout, Class the kind of the failure and Error
+// its description. External links are only verified, not crawled. Pages are the pages referring to the link.
+type BrokenLink struct {
+	Url      string     `json:"url"`
+	Status   int        `json:"status,omitempty"`
+	Class    ErrorClass `json:"class"`
+	Error    string     `json:"error"`
+	External bool       `json:"external,omitempty"`
+	Pages    []string   `json:"pages"`
+}
+
+// GetBrokenLinks returns the internal and external links of the crawled pages which failed with a 4xx or 5xx
+// response, a timeout or any other error, sorted by URL. Only the links of the crawls with CheckLinks are checked.
+func (c *Crawler) GetBrokenLinks() []BrokenLink {
+	c.mulink.Lock()
+	links := make([]BrokenLink, 0, len(c.brokenLinks))
+	for _, link := range c.brokenLinks {
+		l := *link
+		l.Pages = append([]string(nil), c.linkRefs[link.Url]...)
+		sort.Strings(l.Pages)
+
+		links = append(links, l)
+	}
+	c.mulink.Unlock()
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Url < links[j].Url
+	})
+
+	return links
+}
+
+// checkLinks records the page as referring to its links and verifies its links out of the crawled domains,
+// each of them once, with the task t. The links within the crawled domains are checked by crawling them.
+func (c *Crawler) checkLinks(t *task, page string, links, external []string) {
+	if !c.linkChecks {
+		return
+	}
+
+	all := make([]string, 0, len(links)+len(external))
+	all = append(append(all, links...), external...)
+
+	for _, link := range all {
+		c.addLinkRef(link, page)
+	}
+
+	for _, link := range all {
+		if c.inScope(link) || !c.claimLinkCheck(link) {
+			continue
+		}
+
+		if status, err := c.verifyLink(t, link, page); err != nil {
+			c.markBrokenLink(link, true, status, err)
+
+			c.addFinding(Finding{
+				Category: CategoryBrokenLink,
+				Severity: SeverityWarning,
+				Url:      link,
+				Page:     page,
+				Message:  err.Error(),
+			})
+		}
+	}
+}
+
+// verifyLink checks the link on behalf of the page with the downloader, if it is a verifier,
+// returning the status code of the response
+func (c *Crawler) verifyLink(t *task, link, page string) (int, error) {
+	c.throttle(t, link)
+
+	resume := c.pauseTask(t)
+	admitted := c.ctx.Err() == nil && (c.slots == nil || c.slots.acquire(hostOfURL(link), c.ctx.Done()))
+	resume()
+
+	if !admitted {
+		return 0, nil
+	}
+	defer c.releaseSlot(link)
+
+	c.nextStep(t)
+
+	response := new(responseInfo)
+	ctx := withResponseInfo(c.ctx, response)
+
+	var err error
+
+	switch v := c.downloader.(type) {
+	case ContextDownloader:
+		err = v.VerifyContext(ctx, link, page)
+	case RefererVerifier:
+		err = v.VerifyFrom(link, page)
+	case Verifier:
+		err = v.Verify(link)
+	}
+
+	// The links left unchecked because the crawl was stopped are not broken
+	if c.ctx.Err() != nil {
+		return 0, nil
+	}
+
+	return response.status, err
+}
+
+func (c *Crawler) addLinkRef(link, page string) {
+	c.mulink.Lock()
+	defer c.mulink.Unlock()
+
+	for _, p := range c.linkRefs[link] {
+		if p == page {
+			return
+		}
+	}
+
+	c.linkRefs[link] = append(c.linkRefs[link], page)
+}
+
+// claimLinkCheck reports whether the link is yet to be verified, marking it as verified
+func (c *Crawler) claimLinkCheck(link string) bool {
+	c.mulink.Lock()
+	defer c.mulink.Unlock()
+
+	if _, ok := c.checkedLinks[link]; ok {
+		return false
+	}
+
+	c.checkedLinks[link] = struct{}{}
+	return true
+}
+
+// markBrokenLink records the link as broken, status being the status code of the response, if there was one
+func (c *Crawler) markBrokenLink(link string, external bool, status int, err error) {
+	if !c.linkChecks {
+		return
+	}
+
+	c.mulink.Lock()
+	c.brokenLinks[link] = &BrokenLink{
+		Url:      link,
+		Status:   status,
+		Class:    classifyError(err),
+		Error:    err.Error(),
+		External: external,
+	}
+	c.mulink.Unlock()
+}
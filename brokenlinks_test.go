@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCrawlerReportsBrokenLinks(t *testing.T) {
+	var (
+		external *httptest.Server
+		mu       sync.Mutex
+		verified = make(map[string]int)
+	)
+
+	external = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		verified[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer external.Close()
+
+	// The external server is reached through localhost, so that it is out of the crawled domain
+	elsewhere := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/missing\nexternal %s/gone\nexternal %s/ok\n", server.URL, server.URL, elsewhere, elsewhere)
+		case "/a":
+			fmt.Fprintf(w, "%s/missing\nexternal %s/gone\n", server.URL, elsewhere)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Extractor:    externalExtractor{},
+		IgnoreRobots: true,
+		CheckLinks:   true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	pages := []string{server.URL + "/", server.URL + "/a"}
+	expected := []BrokenLink{
+		{Url: server.URL + "/missing", Status: http.StatusNotFound, Class: ClassBadResponse, Error: ErrBadResponse.Error(), Pages: pages},
+		{Url: elsewhere + "/gone", Status: http.StatusGone, Class: ClassBadResponse, Error: ErrBadResponse.Error(), External: true, Pages: pages},
+	}
+
+	if links := c.GetBrokenLinks(); !reflect.DeepEqual(links, expected) {
+		t.Errorf("Unexpected broken links: %+v\n", links)
+	}
+
+	mu.Lock()
+	if verified["HEAD /gone"] != 1 || verified["HEAD /ok"] != 1 || len(verified) != 2 {
+		t.Errorf("Unexpected verification requests: %v\n", verified)
+	}
+	mu.Unlock()
+
+	if f := c.GetFindings().ByCategory(CategoryBrokenLink); len(f) != 1 || f[0].Url != elsewhere+"/gone" {
+		t.Errorf("Unexpected broken link findings: %v\n", f)
+	}
+}
+
+func TestCrawlerChecksNoLinksByDefault(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/missing\nexternal http://localhost:1/gone\n", server.URL)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    externalExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if links := c.GetBrokenLinks(); len(links) != 0 {
+		t.Errorf("Unexpected broken links: %+v\n", links)
+	}
+}
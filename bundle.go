@@ -0,0 +1,172 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime/pprof"
+	"strings"
+	"time"
+)
+
+// maxBundleLogs is the number of the latest errors kept for the debug bundle
+const maxBundleLogs = 1000
+
+// bundleCheckpoint is the name of the checkpoint within the debug bundle
+const bundleCheckpoint = "checkpoint.json"
+
+// bundleLog collects the latest errors of the crawl for the debug bundle.
+type bundleLog struct {
+	lines   []string
+	dropped int
+}
+
+func (l *bundleLog) add(err error) {
+	if len(l.lines) == maxBundleLogs {
+		l.lines = l.lines[1:]
+		l.dropped++
+	}
+
+	l.lines = append(l.lines, fmt.Sprintf("%s %s", time.Now().Format(time.RFC3339), err.Error()))
+}
+
+func (l *bundleLog) bytes() []byte {
+	var b bytes.Buffer
+	if l.dropped > 0 {
+		fmt.Fprintf(&b, "%d earlier errors dropped\n", l.dropped)
+	}
+
+	for _, line := range l.lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
+
+// writeDebugBundle packages everything needed to investigate a stuck or wrong crawl into a .tar.gz archive:
+// the manifest with the config and the stats, the errors, the findings, the fetchers and workers still running
+// along with the goroutines of the crawler and the checkpoint holding the partial sitemap and the frontier,
+// from which the crawl can be resumed.
+func writeDebugBundle(path string, c *Crawler, m *Manifest, log *bundleLog) error {
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := c.marshalCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	findings, err := json.Marshal(c.GetFindings())
+	if err != nil {
+		return err
+	}
+
+	workers, err := json.MarshalIndent(c.Workers(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var goroutines bytes.Buffer
+	if err = pprof.Lookup("goroutine").WriteTo(&goroutines, 1); err != nil {
+		return err
+	}
+
+	var summary bytes.Buffer
+	for _, stat := range c.GetErrorSummary() {
+		fmt.Fprintf(&summary, "%s | %s | %d times (e.g. %s)\n", stat.Host, stat.Class, stat.Count, stat.Sample.Error())
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"manifest.json", manifest},
+		{bundleCheckpoint, checkpoint},
+		{"findings.json", findings},
+		{"errors.log", log.bytes()},
+		{"errors-summary.txt", summary.Bytes()},
+		{"workers.json", workers},
+		{"goroutines.txt", goroutines.Bytes()},
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0644,
+			Size:    int64(len(file.data)),
+			ModTime: m.FinishedAt,
+		}
+
+		if err = tw.WriteHeader(header); err == nil {
+			_, err = tw.Write(file.data)
+		}
+
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err = tw.Close(); err == nil {
+		err = gz.Close()
+	}
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// isDebugBundle reports whether the file under path is a debug bundle rather than a plain checkpoint
+func isDebugBundle(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// readBundleCheckpoint returns the checkpoint packaged in the debug bundle file.
+func readBundleCheckpoint(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrNoCheckpoint
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if path.Clean(header.Name) == bundleCheckpoint {
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugBundleCanBeResumed(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\n%s/b\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		MaxPages:     2,
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	log := &bundleLog{}
+	log.add(errors.New("sample error"))
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err = writeDebugBundle(path, c, newManifest(c.seeds, time.Now(), c), log); err != nil {
+		t.Fatalf("Writing bundle fails with error: %s\n", err.Error())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Opening bundle fails with error: %s\n", err.Error())
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Bundle is not gzipped: %s\n", err.Error())
+	}
+
+	names := make([]string, 0)
+	tr := tar.NewReader(gz)
+	for header, err := tr.Next(); err == nil; header, err = tr.Next() {
+		names = append(names, header.Name)
+	}
+
+	if expected := "manifest.json,checkpoint.json,findings.json,errors.log,errors-summary.txt,workers.json,goroutines.txt"; strings.Join(names, ",") != expected {
+		t.Errorf("Unexpected bundle content: %v\n", names)
+	}
+
+	resumed, err := ResumeFromCheckpoint(path)
+	if err != nil {
+		t.Fatalf("Resuming from bundle fails with error: %s\n", err.Error())
+	}
+
+	if resumed.checkpointPath != "" {
+		t.Errorf("Checkpoint would overwrite the bundle: %s\n", resumed.checkpointPath)
+	}
+
+	if len(resumed.resumeFrom.Frontier) != 1 || resumed.resumeFrom.Pages != 2 {
+		t.Errorf("Unexpected checkpoint in bundle: %v\n", resumed.resumeFrom)
+	}
+}
+
+func TestBundleLogKeepsLatestErrors(t *testing.T) {
+	log := &bundleLog{}
+	for i := 0; i < maxBundleLogs+2; i++ {
+		log.add(fmt.Errorf("error %d", i))
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(log.bytes())), "\n")
+	if len(lines) != maxBundleLogs+1 || lines[0] != "2 earlier errors dropped" || !strings.HasSuffix(lines[1], "error 2") {
+		t.Errorf("Unexpected log: %v\n", lines[:2])
+	}
+}
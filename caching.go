@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCachingExamples is the number of example URLs kept for each path prefix of the caching audit
+const maxCachingExamples = 3
+
+// CachingPrefix struct represents the caching headers served with the pages and assets under a path prefix,
+// the host and the first directory of the path, e.g. example.com/blog/. Pages and Assets count the fetched ones,
+// NoValidators those served without ETag and Last-Modified, which cannot be revalidated, and NoCacheControl those
+// served without Cache-Control. UncachedPages and UncachedAssets count those served without any of them or Expires,
+// whose caching is left to the heuristics of browsers and proxies, and Examples holds a few of them.
+type CachingPrefix struct {
+	Prefix         string   `json:"prefix"`
+	Pages          int      `json:"pages"`
+	Assets         int      `json:"assets"`
+	NoValidators   int      `json:"no_validators"`
+	NoCacheControl int      `json:"no_cache_control"`
+	UncachedPages  int      `json:"uncached_pages"`
+	UncachedAssets int      `json:"uncached_assets"`
+	Examples       []string `json:"examples"`
+}
+
+// GetCachingAudit returns the caching headers of the pages and assets fetched so far by path prefix, the prefixes
+// with the most uncached pages and assets first. Only the responses of the default downloader are audited,
+// and only the assets verified or downloaded according to their policy.
+func (c *Crawler) GetCachingAudit() []CachingPrefix {
+	c.mucache.Lock()
+	prefixes := make([]CachingPrefix, 0, len(c.caching))
+	for _, p := range c.caching {
+		prefix := *p
+		prefix.Examples = make([]string, len(p.Examples))
+		copy(prefix.Examples, p.Examples)
+
+		prefixes = append(prefixes, prefix)
+	}
+	c.mucache.Unlock()
+
+	sort.Slice(prefixes, func(i, j int) bool {
+		ui := prefixes[i].UncachedPages + prefixes[i].UncachedAssets
+		uj := prefixes[j].UncachedPages + prefixes[j].UncachedAssets
+
+		if ui != uj {
+			return ui > uj
+		}
+
+		return prefixes[i].Prefix < prefixes[j].Prefix
+	})
+
+	return prefixes
+}
+
+// auditCaching counts the caching headers of the response with the page or asset under the URL
+func (c *Crawler) auditCaching(url string, asset bool, response *responseInfo) {
+	if response.protocol == "" {
+		return
+	}
+
+	var (
+		prefix    = pathPrefix(url)
+		validated = response.validators.ETag != "" || response.validators.LastModified != ""
+		uncached  = !validated && response.cacheControl == "" && response.expires == ""
+	)
+
+	c.mucache.Lock()
+	defer c.mucache.Unlock()
+
+	p, ok := c.caching[prefix]
+	if !ok {
+		p = &CachingPrefix{Prefix: prefix, Examples: make([]string, 0, maxCachingExamples)}
+		c.caching[prefix] = p
+	}
+
+	if asset {
+		p.Assets++
+	} else {
+		p.Pages++
+	}
+
+	if !validated {
+		p.NoValidators++
+	}
+
+	if response.cacheControl == "" {
+		p.NoCacheControl++
+	}
+
+	if !uncached {
+		return
+	}
+
+	if asset {
+		p.UncachedAssets++
+	} else {
+		p.UncachedPages++
+	}
+
+	if len(p.Examples) < maxCachingExamples {
+		p.Examples = append(p.Examples, url)
+	}
+}
+
+// pathPrefix returns the host of the URL followed by the first directory of its path, or the root if it has none
+func pathPrefix(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return url
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return u.Host + "/" + path[:i+1]
+	}
+
+	return u.Host + "/"
+}
+
+// Lifetimes of responses in the caches, from the least to the most cacheable. LifetimeUnspecified is
+// the lifetime of the responses without Cache-Control max-age or Expires, left to the heuristics of the caches.
+const (
+	LifetimeNoStore     = "no-store"
+	LifetimeNoCache     = "no-cache"
+	LifetimeUnspecified = "unspecified"
+	LifetimeMinute      = "under a minute"
+	LifetimeHour        = "under an hour"
+	LifetimeDay         = "under a day"
+	LifetimeWeek        = "under a week"
+	LifetimeYear        = "under a year"
+	LifetimeLonger      = "a year or more"
+)
+
+var lifetimeOrder = []string{
+	LifetimeNoStore, LifetimeNoCache, LifetimeUnspecified, LifetimeMinute, LifetimeHour,
+	LifetimeDay, LifetimeWeek, LifetimeYear, LifetimeLonger,
+}
+
+// CacheLifetimes struct represents how long the fetched pages and assets may be cached, as the number of them
+// with each lifetime, e.g. under an hour or no-store, from the least to the most cacheable.
+type CacheLifetimes struct {
+	Pages  []LifetimeCount `json:"pages"`
+	Assets []LifetimeCount `json:"assets"`
+}
+
+type LifetimeCount struct {
+	Lifetime string `json:"lifetime"`
+	Count    int    `json:"count"`
+}
+
+// GetCacheLifetimes returns the lifetimes of the pages and assets fetched so far, audited as with GetCachingAudit.
+func (c *Crawler) GetCacheLifetimes() CacheLifetimes {
+	c.mucache.Lock()
+	defer c.mucache.Unlock()
+
+	return CacheLifetimes{
+		Pages:  lifetimeCounts(c.pageLifetimes),
+		Assets: lifetimeCounts(c.assetLifetimes),
+	}
+}
+
+func lifetimeCounts(counts map[string]int) []LifetimeCount {
+	lifetimes := make([]LifetimeCount, 0, len(counts))
+	for _, lifetime := range lifetimeOrder {
+		if n := counts[lifetime]; n > 0 {
+			lifetimes = append(lifetimes, LifetimeCount{Lifetime: lifetime, Count: n})
+		}
+	}
+
+	return lifetimes
+}
+
+// cacheLifetime returns how long the response may be cached by browsers and its lifetime bucket. The lifetime is
+// given by max-age of Cache-Control or else by Expires relative to Date, it is zero if the response must not be
+// cached or must be revalidated every time (no-store or no-cache) and negative if it is unspecified.
+func cacheLifetime(response *responseInfo) (time.Duration, string) {
+	directives := make(map[string]string)
+	for _, d := range strings.Split(response.cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+
+	if _, ok := directives["no-store"]; ok {
+		return 0, LifetimeNoStore
+	}
+
+	if _, ok := directives["no-cache"]; ok {
+		return 0, LifetimeNoCache
+	}
+
+	maxAge := time.Duration(-1)
+	if value, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+			maxAge = time.Duration(seconds) * time.Second
+		}
+	}
+
+	// An invalid Expires, e.g. 0, means the response has already expired
+	if maxAge < 0 && response.expires != "" {
+		maxAge = 0
+
+		if expires, err := http.ParseTime(response.expires); err == nil {
+			date, err := http.ParseTime(response.date)
+			if err != nil {
+				date = time.Now()
+			}
+
+			if expires.After(date) {
+				maxAge = expires.Sub(date)
+			}
+		}
+	}
+
+	return maxAge, lifetimeBucket(maxAge)
+}
+
+func lifetimeBucket(lifetime time.Duration) string {
+	switch {
+	case lifetime < 0:
+		return LifetimeUnspecified
+	case lifetime < time.Minute:
+		return LifetimeMinute
+	case lifetime < time.Hour:
+		return LifetimeHour
+	case lifetime < 24*time.Hour:
+		return LifetimeDay
+	case lifetime < 7*24*time.Hour:
+		return LifetimeWeek
+	case lifetime < 365*24*time.Hour:
+		return LifetimeYear
+	default:
+		return LifetimeLonger
+	}
+}
+
+// checkCacheLifetime counts the lifetime of the response with the page or asset under the URL. Pages which must
+// not be stored are reported, and so are static assets cached for less than the threshold, if there is one.
+// The resource hints are not static assets.
+func (c *Crawler) checkCacheLifetime(url, page string, asset *Asset, response *responseInfo) {
+	if response.protocol == "" {
+		return
+	}
+
+	lifetime, bucket := cacheLifetime(response)
+
+	c.mucache.Lock()
+	if asset != nil {
+		c.assetLifetimes[bucket]++
+	} else {
+		c.pageLifetimes[bucket]++
+	}
+	c.mucache.Unlock()
+
+	switch {
+	case asset == nil && bucket == LifetimeNoStore:
+		c.addFinding(Finding{
+			Category: CategoryNoStorePage,
+			Severity: SeverityWarning,
+			Url:      url,
+			Message:  "Served with Cache-Control no-store, which keeps it out of every cache including the back/forward cache",
+		})
+	case asset != nil && asset.Type != Hint && c.lifetimeThreshold > 0 && lifetime >= 0 && lifetime < c.lifetimeThreshold:
+		c.addFinding(Finding{
+			Category: CategoryShortLivedAsset,
+			Severity: SeverityWarning,
+			Url:      url,
+			Page:     page,
+			Message:  fmt.Sprintf("Cached for %s (%s), less than %s", lifetime, bucket, c.lifetimeThreshold),
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPathPrefixes(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com":                  "example.com/",
+		"https://example.com/about":            "example.com/",
+		"https://example.com/blog/":            "example.com/blog/",
+		"https://example.com/blog/2024/post?a": "example.com/blog/",
+		"http://cdn.example.com:8080/js/a.js":  "cdn.example.com:8080/js/",
+	}
+
+	for url, expected := range cases {
+		if prefix := pathPrefix(url); prefix != expected {
+			t.Errorf("Unexpected prefix of %s: %s\n", url, prefix)
+		}
+	}
+}
+
+func TestCrawlerAuditsCaching(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("ETag", `"root"`)
+			fmt.Fprintf(w, "%s/blog/a\n%s/blog/b\nimage %s/img/logo.png\nimage %s/img/photo.png\n", server.URL, server.URL, server.URL, server.URL)
+		case "/blog/b":
+			w.Header().Set("Cache-Control", "no-cache")
+		case "/img/logo.png":
+			w.Header().Set("Expires", "Thu, 01 Dec 2044 16:00:00 GMT")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:    1,
+		MaxRetries:    1,
+		Extractor:     assetExtractor{},
+		AssetPolicies: map[AssetType]AssetPolicy{Image: PolicyVerify},
+		IgnoreRobots:  true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	audit := c.GetCachingAudit()
+	if len(audit) != 3 {
+		t.Fatalf("Unexpected prefixes: %v\n", audit)
+	}
+
+	host := server.Listener.Addr().String()
+
+	expected := []CachingPrefix{
+		{Prefix: host + "/blog/", Pages: 2, NoValidators: 2, NoCacheControl: 1, UncachedPages: 1},
+		{Prefix: host + "/img/", Assets: 2, NoValidators: 2, NoCacheControl: 2, UncachedAssets: 1},
+		{Prefix: host + "/", Pages: 1, NoCacheControl: 1},
+	}
+
+	for i, e := range expected {
+		a := audit[i]
+		if a.Prefix != e.Prefix || a.Pages != e.Pages || a.Assets != e.Assets || a.NoValidators != e.NoValidators ||
+			a.NoCacheControl != e.NoCacheControl || a.UncachedPages != e.UncachedPages || a.UncachedAssets != e.UncachedAssets {
+			t.Errorf("Unexpected audit of %s: %v\n", e.Prefix, a)
+		}
+	}
+
+	if examples := audit[0].Examples; len(examples) != 1 || examples[0] != server.URL+"/blog/a" {
+		t.Errorf("Unexpected examples: %v\n", examples)
+	}
+}
+
+func TestCacheLifetimesAreParsed(t *testing.T) {
+	cases := []struct {
+		response responseInfo
+		lifetime time.Duration
+		bucket   string
+	}{
+		{responseInfo{}, -1, LifetimeUnspecified},
+		{responseInfo{cacheControl: "public, max-age=31536000, immutable"}, 365 * 24 * time.Hour, LifetimeLonger},
+		{responseInfo{cacheControl: `Max-Age="600"`}, 10 * time.Minute, LifetimeHour},
+		{responseInfo{cacheControl: "private, no-store, max-age=600"}, 0, LifetimeNoStore},
+		{responseInfo{cacheControl: "no-cache"}, 0, LifetimeNoCache},
+		{responseInfo{cacheControl: "max-age=30", expires: "Thu, 01 Dec 2044 16:00:00 GMT"}, 30 * time.Second, LifetimeMinute},
+		{responseInfo{expires: "Tue, 03 Dec 2024 16:00:00 GMT", date: "Sun, 01 Dec 2024 16:00:00 GMT"}, 48 * time.Hour, LifetimeWeek},
+		{responseInfo{expires: "0"}, 0, LifetimeMinute},
+		{responseInfo{cacheControl: "max-age=-5"}, -1, LifetimeUnspecified},
+	}
+
+	for _, tc := range cases {
+		if lifetime, bucket := cacheLifetime(&tc.response); lifetime != tc.lifetime || bucket != tc.bucket {
+			t.Errorf("Unexpected lifetime of %v: %s, %s\n", tc.response, lifetime, bucket)
+		}
+	}
+}
+
+func TestCrawlerChecksCacheLifetimes(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Cache-Control", "max-age=60")
+			fmt.Fprintf(w, "%s/account\nimage %s/logo.png\nimage %s/banner.png\n", server.URL, server.URL, server.URL)
+		case "/account":
+			w.Header().Set("Cache-Control", "private, no-store")
+		case "/logo.png":
+			w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+		case "/banner.png":
+			w.Header().Set("Cache-Control", "max-age=300")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:             1,
+		MaxRetries:             1,
+		Extractor:              assetExtractor{},
+		AssetPolicies:          map[AssetType]AssetPolicy{Image: PolicyVerify},
+		IgnoreRobots:           true,
+		AssetLifetimeThreshold: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	lifetimes := c.GetCacheLifetimes()
+
+	pages := []LifetimeCount{{LifetimeNoStore, 1}, {LifetimeHour, 1}}
+	if len(lifetimes.Pages) != 2 || lifetimes.Pages[0] != pages[0] || lifetimes.Pages[1] != pages[1] {
+		t.Errorf("Unexpected lifetimes of pages: %v\n", lifetimes.Pages)
+	}
+
+	assets := []LifetimeCount{{LifetimeHour, 1}, {LifetimeLonger, 1}}
+	if len(lifetimes.Assets) != 2 || lifetimes.Assets[0] != assets[0] || lifetimes.Assets[1] != assets[1] {
+		t.Errorf("Unexpected lifetimes of assets: %v\n", lifetimes.Assets)
+	}
+
+	findings := c.GetFindings()
+
+	if noStore := findings.ByCategory(CategoryNoStorePage); len(noStore) != 1 || noStore[0].Url != server.URL+"/account" {
+		t.Errorf("Unexpected no-store pages: %v\n", noStore)
+	}
+
+	if short := findings.ByCategory(CategoryShortLivedAsset); len(short) != 1 || short[0].Url != server.URL+"/banner.png" || short[0].Page != server.URL+"/" {
+		t.Errorf("Unexpected short-lived assets: %v\n", short)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TrackingParams are the query parameters of the links which only track the visits, e.g. of campaigns,
+// clicks of ads or sessions, and do not change the page (see URLNormalization).
+var TrackingParams = []string{
+	"utm_*", "gclid", "gbraid", "wbraid", "dclid", "fbclid", "msclkid", "yclid", "twclid", "mc_cid", "mc_eid",
+	"_ga", "_gl", "_hsenc", "_hsmi", "igshid", "jsessionid", "phpsessid", "aspsessionid*", "sid", "sessionid",
+}
+
+// URLNormalization struct defines the optional rules of URL canonicalization, applied on top of the ones
+// which always are: the scheme and host in lower case, no default port, no fragment (except routes if they
+// are crawled), dot segments resolved and an empty path written as /.
+// TrailingSlash strips the trailing slash of paths other than /, so that /a/ and /a are the same page,
+// SortQuery sorts the query parameters by name, so that ?a=1&b=2 and ?b=2&a=1 are the same page.
+// StripParams drops the query parameters matching any of its patterns, e.g. utm_* or the TrackingParams,
+// and KeepParams, if not empty, drops the ones matching none of its patterns instead, so that the URLs
+// differing only in them are the same page. The patterns are globs of the names, matched regardless of case.
+type URLNormalization struct {
+	TrailingSlash bool
+	SortQuery     bool
+	StripParams   []string
+	KeepParams    []string
+}
+
+// canonicalURL returns the canonical form of the absolute URL, relative and malformed URLs are returned as they are
+func canonicalURL(address string, n URLNormalization, routeFragments bool) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return address
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = hostOf(u)
+
+	if !routeFragments || !(strings.HasPrefix(u.Fragment, "!/") || strings.HasPrefix(u.Fragment, "/")) {
+		u.Fragment, u.RawFragment = "", ""
+	}
+
+	// Resolving the URL against an empty base removes its dot segments, and nothing else
+	u = (&url.URL{}).ResolveReference(u)
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	if n.TrailingSlash && len(u.Path) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	}
+
+	if len(n.StripParams) > 0 || len(n.KeepParams) > 0 {
+		u.RawQuery = n.filterQuery(u.RawQuery)
+		u.ForceQuery = false
+	}
+
+	if n.SortQuery {
+		u.RawQuery = u.Query().Encode()
+		u.ForceQuery = false
+	}
+
+	return u.String()
+}
+
+// filterQuery drops the parameters of the raw query according to StripParams and KeepParams,
+// keeping the others as they are and in order
+func (n URLNormalization) filterQuery(query string) string {
+	if query == "" {
+		return query
+	}
+
+	kept := make([]string, 0)
+	for _, param := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if param == "" || matchesParam(n.StripParams, name) {
+			continue
+		}
+
+		if len(n.KeepParams) > 0 && !matchesParam(n.KeepParams, name) {
+			continue
+		}
+
+		kept = append(kept, param)
+	}
+
+	return strings.Join(kept, "&")
+}
+
+func matchesParam(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canonical returns the canonical form of the URL according to the normalization of the crawler
+func (c *Crawler) canonical(url string) string {
+	return canonicalURL(url, c.normalization, c.routeFragments)
+}
+
+// canonicalLinks returns the canonical forms of the links once rewritten, each of them once
+func (c *Crawler) canonicalLinks(links []string) []string {
+	seen := make(map[string]struct{}, len(links))
+	canonical := make([]string, 0, len(links))
+
+	for _, link := range links {
+		if link = c.rewritten(link); link == "" {
+			continue
+		}
+
+		if _, ok := seen[link]; !ok {
+			seen[link] = struct{}{}
+			canonical = append(canonical, link)
+		}
+	}
+
+	return canonical
+}
+
+// canonicalOf returns the canonical URL the page of the result declares, provided the extractor
+// of the page implements CanonicalExtractor. It is empty if the page declares none or itself.
+func (c *Crawler) canonicalOf(r *result) string {
+	if r.cached != nil {
+		return r.cached.Canonical
+	}
+
+	e, ok := c.extractorFor(r.url).(CanonicalExtractor)
+	if !ok {
+		return ""
+	}
+
+	canonical, err := e.ExtractCanonical(r.body)
+	if err != nil || canonical == "" {
+		return ""
+	}
+
+	if canonical = c.canonical(canonical); canonical == r.pageURL() {
+		return ""
+	}
+
+	return canonical
+}
+
+// applyCanonical relates the pages declaring another crawled page canonical to it according to the policy.
+// Only a page which does not declare another one canonical itself can be canonical, chains are not followed.
+// The merged pages are replaced with their canonical page in the links of the others, each link kept once.
+func (c *Crawler) applyCanonical() {
+	if c.canonicalPolicy == CanonicalRecord {
+		return
+	}
+
+	c.mus.Lock()
+	defer c.mus.Unlock()
+
+	urls := make([]string, 0, len(c.sites))
+	for url := range c.sites {
+		urls = append(urls, url)
+	}
+
+	sort.Strings(urls)
+
+	merged := make(map[*Page]*Page)
+	for _, url := range urls {
+		page := c.sites[url]
+
+		target, ok := c.sites[page.Canonical]
+		if page.Canonical == "" || !ok || target.Canonical != "" {
+			continue
+		}
+
+		target.Aliases = append(target.Aliases, url)
+
+		if c.canonicalPolicy == CanonicalMerge {
+			target.LinksTo = append(target.LinksTo, page.LinksTo...)
+			target.LinkedFrom = append(target.LinkedFrom, page.LinkedFrom...)
+			merged[page] = target
+
+			delete(c.sites, url)
+		}
+	}
+
+	if len(merged) == 0 {
+		return
+	}
+
+	for _, page := range c.sites {
+		page.LinksTo = relink(page, page.LinksTo, merged)
+		page.LinkedFrom = relink(page, page.LinkedFrom, merged)
+	}
+
+	// A link is recorded either in LinksTo of the linking page or in LinkedFrom of the linked one,
+	// the merge may have recorded it in both
+	for _, page := range c.sites {
+		linkedFrom := page.LinkedFrom[:0]
+		for _, linking := range page.LinkedFrom {
+			if !linksTo(linking, page) {
+				linkedFrom = append(linkedFrom, linking)
+			}
+		}
+
+		page.LinkedFrom = linkedFrom
+	}
+}
+
+// relink returns the pages with the merged ones replaced by their canonical page, without duplicates
+// and without the page itself
+func relink(page *Page, pages []*Page, merged map[*Page]*Page) []*Page {
+	seen := make(map[*Page]struct{}, len(pages))
+	relinked := make([]*Page, 0, len(pages))
+
+	for _, p := range pages {
+		if target, ok := merged[p]; ok {
+			p = target
+		}
+
+		if _, ok := seen[p]; ok || p == page {
+			continue
+		}
+
+		seen[p] = struct{}{}
+		relinked = append(relinked, p)
+	}
+
+	return relinked
+}
+
+func linksTo(from, to *Page) bool {
+	for _, p := range from.LinksTo {
+		if p == to {
+			return true
+		}
+	}
+
+	return false
+}
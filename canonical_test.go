@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	cases := []struct {
+		address  string
+		n        URLNormalization
+		routes   bool
+		expected string
+	}{
+		{"HTTP://Example.COM:80", URLNormalization{}, false, "http://example.com/"},
+		{"https://example.com.:443/a/#top", URLNormalization{}, false, "https://example.com/a/"},
+		{"http://example.com/a/./b/../c", URLNormalization{}, false, "http://example.com/a/c"},
+		{"http://example.com:8080/a/", URLNormalization{TrailingSlash: true}, false, "http://example.com:8080/a"},
+		{"http://example.com/", URLNormalization{TrailingSlash: true}, false, "http://example.com/"},
+		{"http://example.com/?b=2&a=1&a=0", URLNormalization{}, false, "http://example.com/?b=2&a=1&a=0"},
+		{"http://example.com/?b=2&a=1&a=0", URLNormalization{SortQuery: true}, false, "http://example.com/?a=1&a=0&b=2"},
+		{"http://example.com/a?", URLNormalization{SortQuery: true}, false, "http://example.com/a"},
+		{"http://example.com/#!/users/1", URLNormalization{}, true, "http://example.com/#!/users/1"},
+		{"/relative#top", URLNormalization{TrailingSlash: true}, false, "/relative#top"},
+		{"http://example.com/a?utm_source=x&id=1&UTM_Medium=y&gclid=z", URLNormalization{StripParams: TrackingParams}, false, "http://example.com/a?id=1"},
+		{"http://example.com/a?utm%5Fsource=x&utm_campaign", URLNormalization{StripParams: []string{"utm_*"}}, false, "http://example.com/a"},
+		{"http://example.com/a?page=2&sort=asc&id=3&", URLNormalization{KeepParams: []string{"page", "ID"}}, false, "http://example.com/a?page=2&id=3"},
+		{"http://example.com/a?b=2&utm_x=1&a=1", URLNormalization{StripParams: []string{"utm_*"}, SortQuery: true}, false, "http://example.com/a?a=1&b=2"},
+		{"http://example.com/a?q=%20x", URLNormalization{StripParams: []string{"ref"}}, false, "http://example.com/a?q=%20x"},
+	}
+
+	for _, tc := range cases {
+		if canonical := canonicalURL(tc.address, tc.n, tc.routes); canonical != tc.expected {
+			t.Errorf("Unexpected canonical URL of %s: %s\n", tc.address, canonical)
+		}
+	}
+}
+
+func TestCrawlerCrawlsEquivalentURLsOnce(t *testing.T) {
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\n%s/a/#top\n%s/b/../a/\n", server.URL, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL, &Options{
+		MaxWorkers:    2,
+		MaxRetries:    1,
+		Extractor:     lineExtractor{},
+		IgnoreRobots:  true,
+		Normalization: URLNormalization{TrailingSlash: true},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if requests["/a"] != 1 || len(requests) != 2 {
+		t.Errorf("Unexpected requests: %v\n", requests)
+	}
+
+	if _, ok := c.GetSiteMap().Get(server.URL + "/"); !ok {
+		t.Errorf("Root URL not canonicalized\n")
+	}
+}
+
+// canonicalExtractor treats the line of the body prefixed with canonical as the canonical URL and the others as links
+type canonicalExtractor struct {
+	lineExtractor
+}
+
+func (e canonicalExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	title, links, assets, err := e.lineExtractor.Extract(body)
+
+	filtered := make([]string, 0, len(links))
+	for _, link := range links {
+		if !strings.HasPrefix(link, "canonical ") {
+			filtered = append(filtered, link)
+		}
+	}
+
+	return title, filtered, assets, err
+}
+
+func (canonicalExtractor) ExtractCanonical(body []byte) (string, error) {
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "canonical ") {
+			return strings.TrimPrefix(line, "canonical "), nil
+		}
+	}
+
+	return "", nil
+}
+
+func TestCrawlerAppliesCanonicalPolicy(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RequestURI() {
+		case "/":
+			fmt.Fprintf(w, "%s/a?utm_source=x\n%s/a\n", server.URL, server.URL)
+		case "/a":
+			fmt.Fprintf(w, "canonical %s/a\n%s/b\n", server.URL, server.URL)
+		case "/a?utm_source=x":
+			fmt.Fprintf(w, "canonical %s/a\n%s/c\n%s/\n", server.URL, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	for _, policy := range []CanonicalPolicy{CanonicalRecord, CanonicalAlias, CanonicalMerge} {
+		var (
+			mu         sync.Mutex
+			violations []error
+		)
+
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:      2,
+			MaxRetries:      1,
+			Downloader:      unpooledDownloader{},
+			Extractor:       canonicalExtractor{},
+			IgnoreRobots:    true,
+			Canonical:       policy,
+			CheckInvariants: true,
+			OnError: func(err CrawlError) {
+				mu.Lock()
+				violations = append(violations, err)
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, _ := c.Crawl()
+		<-done
+
+		if len(violations) != 0 {
+			t.Errorf("Unexpected errors with policy %d: %v\n", policy, violations)
+		}
+
+		sitemap := c.GetSiteMap()
+
+		canonical, _ := sitemap.Get(server.URL + "/a")
+		if canonical == nil || canonical.Canonical != "" {
+			t.Fatalf("Canonical page not crawled with policy %d\n", policy)
+		}
+
+		duplicate, ok := sitemap.Get(server.URL + "/a?utm_source=x")
+
+		switch policy {
+		case CanonicalRecord:
+			if !ok || duplicate.Canonical != server.URL+"/a" || len(canonical.Aliases) != 0 {
+				t.Errorf("Canonical URL not recorded\n")
+			}
+		case CanonicalAlias:
+			if !ok || !reflect.DeepEqual(canonical.Aliases, []string{server.URL + "/a?utm_source=x"}) {
+				t.Errorf("Unexpected aliases: %v\n", canonical.Aliases)
+			}
+		case CanonicalMerge:
+			if ok || sitemap.Len() != 4 || !reflect.DeepEqual(canonical.Aliases, []string{server.URL + "/a?utm_source=x"}) {
+				t.Errorf("Duplicate page not merged: %d, %v\n", sitemap.Len(), canonical.Aliases)
+			}
+
+			links := sitemap.outLinks()
+			sort.Strings(links[server.URL+"/a"])
+
+			if expected := []string{server.URL + "/", server.URL + "/b", server.URL + "/c"}; !reflect.DeepEqual(links[server.URL+"/a"], expected) {
+				t.Errorf("Unexpected links of the merged page: %v\n", links[server.URL+"/a"])
+			}
+
+			if expected := []string{server.URL + "/a"}; !reflect.DeepEqual(links[server.URL+"/"], expected) {
+				t.Errorf("Unexpected links to the merged page: %v\n", links[server.URL+"/"])
+			}
+		}
+	}
+}
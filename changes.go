@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// ChangeStatus defines how a page changed since the crawl of the baseline.
+type ChangeStatus string
+
+const (
+	ChangeUnchanged ChangeStatus = "unchanged"
+	ChangeModified  ChangeStatus = "modified"
+	ChangeNew       ChangeStatus = "new"
+	ChangeRemoved   ChangeStatus = "removed"
+)
+
+// PageChange struct represents how the page under Url changed since the crawl of the baseline.
+type PageChange struct {
+	Url    string       `json:"url"`
+	Status ChangeStatus `json:"status"`
+}
+
+// contentHash returns the hash of the page's content, which tells whether it changed between crawls
+func contentHash(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// hashOf returns the hash of the content of the result's page, taken from the baseline if it was not modified
+func (c *Crawler) hashOf(r *result) string {
+	if r.cached != nil {
+		return r.cached.ContentHash
+	}
+
+	return contentHash(r.body)
+}
+
+// changeOf returns how the result's page changed since the baseline, empty if there is none. A page fetched again
+// is modified only if its content differs, so that servers ignoring conditional requests are handled as well.
+func (c *Crawler) changeOf(r *result, hash string) ChangeStatus {
+	if c.baseline == nil {
+		return ""
+	}
+
+	if r.cached != nil {
+		return ChangeUnchanged
+	}
+
+	previous, ok := c.baseline.Get(r.pageURL())
+
+	switch {
+	case !ok:
+		return ChangeNew
+	case previous.ContentHash != "" && previous.ContentHash == hash:
+		return ChangeUnchanged
+	default:
+		return ChangeModified
+	}
+}
+
+// GetChanges returns how the pages changed since the baseline, sorted by URL: the crawled pages are unchanged,
+// modified or new and the pages of the baseline which were not crawled again are removed. The pages of the baseline
+// left uncrawled because of the limits of the crawl, or redirecting to a crawled page, are left out.
+func (c *Crawler) GetChanges() []PageChange {
+	if c.baseline == nil {
+		return []PageChange{}
+	}
+
+	uncrawled := make(map[string]struct{})
+	for _, u := range c.GetUncrawled() {
+		uncrawled[u.Url] = struct{}{}
+	}
+
+	sitemap := c.GetSiteMap()
+
+	changes := make([]PageChange, 0, sitemap.Len())
+	for _, page := range sitemap.Pages() {
+		changes = append(changes, PageChange{Url: page.Url, Status: page.Change})
+	}
+
+	c.muxr.Lock()
+	for _, page := range c.baseline.Pages() {
+		_, crawled := sitemap.Get(page.Url)
+		_, skipped := uncrawled[page.Url]
+		_, redirected := c.redirected[page.Url]
+
+		if !crawled && !skipped && !redirected {
+			changes = append(changes, PageChange{Url: page.Url, Status: ChangeRemoved})
+		}
+	}
+	c.muxr.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Url < changes[j].Url
+	})
+
+	return changes
+}
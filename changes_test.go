@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCrawlerDetectsChanges(t *testing.T) {
+	var (
+		server  *httptest.Server
+		mu      sync.Mutex
+		recrawl bool
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		second := recrawl
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/":
+			if second {
+				fmt.Fprintf(w, "%s/tagged\n%s/static\n%s/edited\n%s/added\n", server.URL, server.URL, server.URL, server.URL)
+			} else {
+				fmt.Fprintf(w, "%s/tagged\n%s/static\n%s/edited\n%s/deleted\n", server.URL, server.URL, server.URL, server.URL)
+			}
+		case "/tagged":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("ETag", `"v1"`)
+		case "/edited":
+			if second {
+				fmt.Fprint(w, "edited")
+			}
+		case "/deleted":
+			if second {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case "/added":
+			if !second {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}))
+	defer server.Close()
+
+	crawl := func(baseline *SiteMap) *Crawler {
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:   2,
+			MaxRetries:   1,
+			Extractor:    lineExtractor{},
+			IgnoreRobots: true,
+			Baseline:     baseline,
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, errs := c.Crawl()
+		for range errs {
+		}
+		<-done
+
+		return c
+	}
+
+	first := crawl(nil)
+	if changes := first.GetChanges(); len(changes) != 0 {
+		t.Errorf("Changes reported without a baseline: %v\n", changes)
+	}
+
+	mu.Lock()
+	recrawl = true
+	mu.Unlock()
+
+	expected := []PageChange{
+		{Url: server.URL + "/", Status: ChangeModified},
+		{Url: server.URL + "/added", Status: ChangeNew},
+		{Url: server.URL + "/deleted", Status: ChangeRemoved},
+		{Url: server.URL + "/edited", Status: ChangeModified},
+		{Url: server.URL + "/static", Status: ChangeUnchanged},
+		{Url: server.URL + "/tagged", Status: ChangeUnchanged},
+	}
+
+	second := crawl(first.GetSiteMap())
+	if changes := second.GetChanges(); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes: %v\n", changes)
+	}
+
+	if page, ok := second.GetSiteMap().Get(server.URL + "/added"); !ok || page.Change != ChangeNew {
+		t.Errorf("Change not recorded in the page: %v\n", page)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// checkpointVersion is the version of the checkpoint file format, checkpoints of other versions are rejected
+const checkpointVersion = 1
+
+// checkpoint struct represents the state of an interrupted crawl: the pages crawled so far,
+// the frontier of URLs scheduled or discovered but not crawled yet and the budgets used up.
+// Seeds are only saved if there is more than one, the root URL being the first of them.
+type checkpoint struct {
+	Version  int            `json:"version"`
+	Url      string         `json:"url"`
+	Seeds    []string       `json:"seeds,omitempty"`
+	SiteMap  *SiteMap       `json:"sitemap"`
+	Frontier []UncrawledURL `json:"frontier"`
+	Pages    int            `json:"pages"`
+	Bytes    int64          `json:"bytes"`
+}
+
+// ResumeFromCheckpoint returns a crawler with the default options which continues the crawl saved in the checkpoint
+// file under path. The checkpoint keeps being written to the same file, unless it is a debug bundle.
+func ResumeFromCheckpoint(path string) (*Crawler, error) {
+	cp, err := readCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	options := defaultOptions
+	if !isDebugBundle(path) {
+		options.CheckpointPath = path
+	}
+
+	c, err := NewCrawlerWithSeeds(cp.seeds(), &options)
+	if err != nil {
+		return nil, err
+	}
+
+	c.resumeFrom = cp
+
+	return c, nil
+}
+
+// ResumeFromCheckpointWithOptions returns a crawler with given options which continues the crawl saved in
+// the checkpoint file or debug bundle under path. The crawled pages are not crawled again and the URLs of the frontier are
+// scheduled instead of the root URL, subject to the limits of the options.
+func ResumeFromCheckpointWithOptions(path string, options *Options) (*Crawler, error) {
+	cp, err := readCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewCrawlerWithSeeds(cp.seeds(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	c.resumeFrom = cp
+
+	return c, nil
+}
+
+func (cp *checkpoint) seeds() []string {
+	if len(cp.Seeds) > 0 {
+		return cp.Seeds
+	}
+
+	return []string{cp.Url}
+}
+
+func readCheckpoint(path string) (*checkpoint, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if isDebugBundle(path) {
+		data, err = readBundleCheckpoint(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &checkpoint{SiteMap: NewSiteMap()}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+
+	if cp.Version != checkpointVersion {
+		return nil, ErrUnsupportedSchema
+	}
+
+	return cp, nil
+}
+
+// writeCheckpoint saves the state of the crawl to the checkpoint file. The workers are held off while
+// the state is captured, so that it is consistent. The file is replaced atomically, so that
+// a crash while writing does not destroy the previous checkpoint.
+func (c *Crawler) writeCheckpoint() error {
+	data, err := c.marshalCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	tmp := c.checkpointPath + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.checkpointPath)
+}
+
+// marshalCheckpoint captures the state of the crawl as JSON, holding off the workers meanwhile
+func (c *Crawler) marshalCheckpoint() ([]byte, error) {
+	c.mucp.Lock()
+	defer c.mucp.Unlock()
+
+	return json.Marshal(c.checkpoint())
+}
+
+// checkpoint captures the state of the crawl, the pseudo-page of the root is left out.
+// The frontier consists of the URLs being crawled and the URLs which were not crawled.
+func (c *Crawler) checkpoint() *checkpoint {
+	c.mus.RLock()
+	pages := make(map[string]*Page, len(c.sites))
+	for url, page := range c.sites {
+		if url != "<root>" {
+			pages[url] = page
+		}
+	}
+	c.mus.RUnlock()
+
+	c.mupend.Lock()
+	frontier := make([]UncrawledURL, 0, len(c.pending))
+	for _, u := range c.pending {
+		frontier = append(frontier, u)
+	}
+	c.mupend.Unlock()
+
+	frontier = append(frontier, c.GetUncrawled()...)
+
+	c.mub.Lock()
+	bytesRead := c.bytesRead
+	c.mub.Unlock()
+
+	var seeds []string
+	if len(c.seeds) > 1 {
+		seeds = c.seeds
+	}
+
+	return &checkpoint{
+		Version:  checkpointVersion,
+		Url:      c.url,
+		Seeds:    seeds,
+		SiteMap:  newSiteMapFrom(pages),
+		Frontier: frontier,
+		Pages:    len(pages),
+		Bytes:    bytesRead,
+	}
+}
+
+// writeCheckpoints writes the checkpoint every interval until the stop channel is closed
+func (c *Crawler) writeCheckpoints(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.writeCheckpoint(); err != nil {
+				c.reportError(CrawlError{Url: c.checkpointPath, Phase: PhaseOutput, Err: err})
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// resume restores the crawled pages of the checkpoint and schedules its frontier
+func (c *Crawler) resume(cp *checkpoint) {
+	c.mus.Lock()
+	for url, page := range cp.SiteMap.pages {
+		c.sites[url] = page
+		c.visited.Add(url)
+		c.markRedirected(page.RedirectedFrom, url)
+	}
+	c.mus.Unlock()
+
+	c.mub.Lock()
+	c.pages, c.bytesRead = cp.Pages, cp.Bytes
+	if c.maxHostPages > 0 {
+		for url := range cp.SiteMap.pages {
+			c.hostPages[hostOfURL(url)]++
+		}
+	}
+	c.mub.Unlock()
+
+	for _, u := range cp.Frontier {
+		if !c.hasVisited(u.Url) && !c.isBeingProcessed(u.Url) {
+			c.schedule(u.Url, u.From, u.Depth, ViaCheckpoint)
+		}
+	}
+}
+
+func (c *Crawler) addPending(url, from string, depth int) {
+	c.mupend.Lock()
+	c.pending[url] = UncrawledURL{Url: url, From: from, Depth: depth}
+	c.mupend.Unlock()
+}
+
+func (c *Crawler) removePending(url string) {
+	c.mupend.Lock()
+	delete(c.pending, url)
+	c.mupend.Unlock()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCrawlerResumesFromCheckpoint(t *testing.T) {
+	var (
+		server *httptest.Server
+
+		mu        sync.Mutex
+		requested = make(map[string]int)
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/b\n", server.URL, server.URL)
+		case "/a":
+			fmt.Fprintf(w, "%s/a/c\n", server.URL)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:     2,
+		MaxRetries:     1,
+		MaxDepth:       1,
+		Downloader:     unpooledDownloader{},
+		Extractor:      lineExtractor{},
+		IgnoreRobots:   true,
+		CheckpointPath: path,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if c.GetSiteMap().Len() != 3 {
+		t.Fatalf("Unexpected sitemap length: %d\n", c.GetSiteMap().Len())
+	}
+
+	c, err = ResumeFromCheckpointWithOptions(path, &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Resuming fails with error: %s\n", err.Error())
+	}
+
+	done, errs = c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	s := c.GetSiteMap()
+	if s.Len() != 4 {
+		t.Errorf("Unexpected sitemap length after resuming: %d\n", s.Len())
+	}
+
+	if page, ok := s.Get(server.URL + "/a/c"); !ok || page.Depth != 2 || len(page.LinkedFrom) != 0 {
+		t.Errorf("Frontier of the checkpoint not crawled: %v\n", page)
+	}
+
+	if page, _ := s.Get(server.URL + "/a"); len(page.LinksTo) != 1 || page.LinksTo[0].Url != server.URL+"/a/c" {
+		t.Errorf("Resumed page not linked: %v\n", page.LinksTo)
+	}
+
+	if requested["/"] != 1 || requested["/a"] != 1 || requested["/a/c"] != 1 {
+		t.Errorf("Unexpected requests: %v\n", requested)
+	}
+}
+
+func TestCrawlerRejectsUnsupportedCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	if err := os.WriteFile(path, []byte(`{"version": 0, "url": "http://example.com/"}`), 0644); err != nil {
+		t.Fatalf("Writing checkpoint fails with error: %s\n", err.Error())
+	}
+
+	if _, err := ResumeFromCheckpoint(path); err != ErrUnsupportedSchema {
+		t.Errorf("Unsupported checkpoint accepted: %v\n", err)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configMigration upgrades a config of version from to version to in place and returns warnings for the user.
+type configMigration struct {
+	from, to string
+	migrate  func(config map[string]interface{}) []string
+}
+
+// configMigrations holds the migrations between subsequent versions of the YAML config file schema, ordered
+// by version. The keys of the config are the names of the command line flags, e.g. workers: 10, and flags given
+// on the command line take precedence. The schema is versioned semantically: compatible additions, such as
+// the keys of new flags, raise the minor version and need no migration, while incompatible changes raise
+// the major version and append a migration from the previous version.
+var configMigrations = []configMigration{
+	{from: "1.0.0", to: "2.0.0", migrate: migrateHeaders},
+}
+
+// configSchemaVersion returns the current version of the config file schema.
+func configSchemaVersion() string {
+	if len(configMigrations) == 0 {
+		return "1.0.0"
+	}
+
+	return configMigrations[len(configMigrations)-1].to
+}
+
+// loadConfig reads the YAML config file and migrates it to the current schema version.
+// The returned warnings describe the changes made by the migrations.
+func loadConfig(path string) (map[string]interface{}, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := make(map[string]interface{})
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, err
+	}
+
+	warnings, err := migrateConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, warnings, nil
+}
+
+func migrateConfig(config map[string]interface{}) ([]string, error) {
+	var (
+		warnings = make([]string, 0)
+		version  = "1.0.0"
+	)
+
+	if v, ok := config["version"]; ok {
+		// Configs of the first schema were versioned with a bare integer, e.g. version: 1
+		if i, ok := v.(int); ok && i > 0 {
+			v = fmt.Sprintf("%d.0.0", i)
+		}
+
+		if version, ok = v.(string); !ok || parseSchemaVersion(version) == nil {
+			return nil, ErrInvalidConfigVersion
+		}
+	} else {
+		warnings = append(warnings, "config has no version, assuming version 1.0.0")
+	}
+
+	if compareSchemaVersions(version, configSchemaVersion()) > 0 {
+		return nil, ErrInvalidConfigVersion
+	}
+
+	for _, m := range configMigrations {
+		if compareSchemaVersions(version, m.to) >= 0 {
+			continue
+		}
+
+		warnings = append(warnings, m.migrate(config)...)
+		warnings = append(warnings, fmt.Sprintf("config migrated from version %s to %s", version, m.to))
+		version = m.to
+	}
+
+	delete(config, "version")
+
+	return warnings, nil
+}
+
+// parseSchemaVersion returns the major, minor and patch numbers of the version, nil if it is not of the form 1.2.3
+func parseSchemaVersion(version string) []int {
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil
+		}
+
+		numbers[i] = n
+	}
+
+	return numbers
+}
+
+// compareSchemaVersions returns -1, 0 or 1 if the version a is older than, equal to or newer than b
+func compareSchemaVersions(a, b string) int {
+	va, vb := parseSchemaVersion(a), parseSchemaVersion(b)
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// migrateHeaders turns the header list of the 1.0.0 schema, e.g. - "X-Token: abc", into the list of the names
+// of the headers and their values of the 2.0.0 schema, e.g. - X-Token: abc. The headers keep the order they were
+// given in, including the ones given repeatedly, e.g. several Cookie headers.
+func migrateHeaders(config map[string]interface{}) []string {
+	value, ok := config["header"]
+	if !ok {
+		return nil
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+
+	headers := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		kv := strings.SplitN(fmt.Sprint(v), ":", 2)
+		if len(kv) != 2 {
+			return []string{fmt.Sprintf("header %q could not be migrated, expected name: value", fmt.Sprint(v))}
+		}
+
+		headers = append(headers, map[string]interface{}{strings.TrimSpace(kv[0]): strings.TrimSpace(kv[1])})
+	}
+
+	config["header"] = headers
+
+	return []string{"header is a list of the names of the headers and their values, e.g. - X-Token: abc"}
+}
+
+// applyConfig sets the flags from the config, except the ones given explicitly on the command line.
+// Lists are applied element by element, so that repeatable flags such as header can be configured.
+// Mappings are applied as comma separated key=value pairs, e.g. the hosts and addresses of resolve,
+// except the names of the headers and their values, applied as a header flag each (see headerPairs).
+func applyConfig(fs *flag.FlagSet, config map[string]interface{}) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for key, value := range config {
+		if fs.Lookup(key) == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownConfigKey, key)
+		}
+
+		if explicit[key] {
+			continue
+		}
+
+		if _, ok := fs.Lookup(key).Value.(*headerList); ok {
+			value = headerPairs(value)
+		} else if pairs, ok := value.(map[string]interface{}); ok {
+			value = joinPairs(pairs)
+		}
+
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+
+		for _, v := range values {
+			if err := fs.Set(key, fmt.Sprint(v)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// joinPairs returns the mapping as comma separated key=value pairs, ordered by the key
+func joinPairs(pairs map[string]interface{}) string {
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	joined := make([]string, len(keys))
+	for i, k := range keys {
+		joined[i] = fmt.Sprintf("%s=%v", k, pairs[k])
+	}
+
+	return strings.Join(joined, ",")
+}
+
+// headerPairs returns the headers of the config as name: value pairs in the order they were given, e.g. those
+// of the list - X-Token: abc. The headers given as one mapping, e.g. X-Token: abc, are ordered by the name,
+// and a list of values of a name yields a pair for each of them, in order. Values given as name: value already,
+// e.g. in a list of strings, are kept as they are.
+func headerPairs(value interface{}) []interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+
+	headers := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		pairs, ok := v.(map[string]interface{})
+		if !ok {
+			headers = append(headers, v)
+			continue
+		}
+
+		names := make([]string, 0, len(pairs))
+		for name := range pairs {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			values, ok := pairs[name].([]interface{})
+			if !ok {
+				values = []interface{}{pairs[name]}
+			}
+
+			for _, v := range values {
+				headers = append(headers, fmt.Sprintf("%s: %v", name, v))
+			}
+		}
+	}
+
+	return headers
+}
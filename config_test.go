@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigIsMigrated(t *testing.T) {
+	config, warnings, err := loadConfig(filepath.Join("testdata", "config-1.yaml"))
+	if err != nil {
+		t.Fatalf("Loading config fails with error: %s\n", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"workers": 20,
+		"retries": 4,
+		"header": []interface{}{
+			map[string]interface{}{"X-Token": "abc"},
+			map[string]interface{}{"Cookie": "a=1"},
+			map[string]interface{}{"Cookie": "b=2"},
+		},
+	}
+
+	if !reflect.DeepEqual(config, expected) || len(warnings) != 2 || warnings[1] != "config migrated from version 1.0.0 to 2.0.0" {
+		t.Errorf("Unexpected migration: %v, %v\n", config, warnings)
+	}
+
+	var (
+		fs      = flag.NewFlagSet("test", flag.ContinueOnError)
+		headers headerList
+	)
+
+	fs.Int("workers", 10, "")
+	fs.Int("retries", 2, "")
+	fs.Var(&headers, "header", "")
+
+	if err = applyConfig(fs, config); err != nil {
+		t.Fatalf("Applying config fails with error: %s\n", err.Error())
+	}
+
+	if headers.String() != "X-Token: abc, Cookie: a=1, Cookie: b=2" {
+		t.Errorf("Unexpected headers: %s\n", headers.String())
+	}
+}
+
+func TestConfigKeepsOrderOfRepeatedHeaders(t *testing.T) {
+	config := map[string]interface{}{
+		"version": 1,
+		"header":  []interface{}{"Cookie: a=1", "X-Token: abc", "Cookie: b=2", "Cookie: a=1"},
+	}
+
+	if _, err := migrateConfig(config); err != nil {
+		t.Fatalf("Migrating config fails with error: %s\n", err.Error())
+	}
+
+	var (
+		fs      = flag.NewFlagSet("test", flag.ContinueOnError)
+		headers headerList
+	)
+
+	fs.Var(&headers, "header", "")
+
+	if err := applyConfig(fs, config); err != nil {
+		t.Fatalf("Applying config fails with error: %s\n", err.Error())
+	}
+
+	if headers.String() != "Cookie: a=1, X-Token: abc, Cookie: b=2, Cookie: a=1" {
+		t.Errorf("Unexpected headers: %s\n", headers.String())
+	}
+}
+
+func TestConfigVersionIsChecked(t *testing.T) {
+	if warnings, err := migrateConfig(map[string]interface{}{"version": configSchemaVersion()}); err != nil || len(warnings) != 0 {
+		t.Errorf("Unexpected migration of current version: %v, %v\n", warnings, err)
+	}
+
+	if warnings, err := migrateConfig(map[string]interface{}{"version": "2.1.0"}); err != ErrInvalidConfigVersion {
+		t.Errorf("Migration does not fail for future version: %v\n", warnings)
+	}
+
+	for _, version := range []interface{}{"2", "2.0", "v2.0.0", "2.0.x", 1.5, 0} {
+		if _, err := migrateConfig(map[string]interface{}{"version": version}); err != ErrInvalidConfigVersion {
+			t.Errorf("Migration does not fail for version %v\n", version)
+		}
+	}
+}
+
+func TestConfigDoesNotOverrideExplicitFlags(t *testing.T) {
+	var (
+		fs      = flag.NewFlagSet("test", flag.ContinueOnError)
+		workers = fs.Int("workers", 10, "")
+		retries = fs.Int("retries", 2, "")
+		headers headerList
+	)
+
+	fs.Var(&headers, "header", "")
+
+	if err := fs.Parse([]string{"-workers=5"}); err != nil {
+		t.Fatalf("Parsing fails with error: %s\n", err.Error())
+	}
+
+	err := applyConfig(fs, map[string]interface{}{
+		"workers": 20,
+		"retries": 4,
+		"header":  []interface{}{"X-A: 1", "X-B: 2"},
+	})
+	if err != nil {
+		t.Fatalf("Applying config fails with error: %s\n", err.Error())
+	}
+
+	if *workers != 5 || *retries != 4 || len(headers) != 2 {
+		t.Errorf("Unexpected flags: %d, %d, %v\n", *workers, *retries, headers)
+	}
+
+	resolve := fs.String("resolve", "", "")
+
+	err = applyConfig(fs, map[string]interface{}{
+		"resolve": map[string]interface{}{"www.example.com": "10.0.0.5", "api.example.com:443": "10.0.0.6:8443"},
+	})
+	if err != nil || *resolve != "api.example.com:443=10.0.0.6:8443,www.example.com=10.0.0.5" {
+		t.Errorf("Unexpected mapping flag: %s, %v\n", *resolve, err)
+	}
+
+	if err = applyConfig(fs, map[string]interface{}{"unknown": 1}); err == nil {
+		t.Errorf("Applying config does not fail for unknown key\n")
+	}
+}
@@ -1,36 +1,282 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Options struct represents list of optional parameters to the Crawler.
 // MaxWorker defines the number of goroutines spawned to process the downloaded websites,
+// MaxFetchers defines the number of goroutines downloading the websites of the frontier (four times MaxWorkers
+// if zero, since downloading mostly waits for the network), they wait while all of the workers are busy,
 // MaxRetries defined how many times should the crawler try to reach any website,
+// RetryBackoff defines how long the crawler waits before each retry (retrying immediately if zero),
 // Downloader and Extractor are two depencies on which the Crawler relies,
-// Callback is a reference to the function called upon discovering new URL.
+// Frontier is the queue of URLs scheduled to be crawled (in memory and first in first out by default),
+// a SharedFrontier such as RedisFrontier lets several crawlers share one crawl, each of them recording the pages
+// it crawled (along with a VisitedSet they share, e.g. RedisVisitedSet),
+// Callback is called with every URL discovered on a page which was not scheduled yet, whether it is scheduled now
+// or skipped and why (see DiscoveryEvent), in a goroutine of its own, so the events may arrive out of order,
+// AssetPolicies defines how assets of each type are handled (PolicyRecord by default),
+// MaxDuration limits for how long new URLs are scheduled (no limit if zero),
+// Baseline is a sitemap of a previous crawl whose pages are reused if their content has not changed, every page
+// being marked as unchanged, modified or new compared to it (see GetChanges),
+// Shuffle randomizes the order in which discovered links are scheduled, reproducibly for the same Seed,
+// RequestHeaders shapes the requests of the default downloader (ignored if Downloader is set),
+// CookieJar stores the cookies set by the crawled sites and sends them back with the following requests of the
+// default downloader, e.g. for the sites gating their content behind a consent or session cookie (ignored if
+// Downloader is set, no cookies are kept if nil),
+// RouteFragments makes the default extractor crawl #!/ and #/ routes as distinct pages (ignored if Extractor is set),
+// Normalization defines how the seeds and the discovered URLs are canonicalized before they are crawled,
+// so that the equivalent URLs are crawled once (see URLNormalization),
+// RewriteURL rewrites every URL discovered by the crawl before it is canonicalized and scheduled, e.g. to force https
+// or map a staging host onto production (see RewriteRules), the URLs it returns empty being dropped (seeds are not
+// rewritten),
+// Canonical defines what becomes of the pages declaring another crawled page canonical with <link rel="canonical">,
+// e.g. the same page with tracking parameters (only recorded in the page by default, see CanonicalPolicy),
+// RespectNofollow makes the crawler not follow the links with rel="nofollow" and the links of the pages
+// with the robots meta tag nofollow, RespectNoindex leaves the pages with the robots meta tag noindex
+// out of the sitemap (they are crawled and their links followed though). Both need the extractor to implement
+// RobotsExtractor,
+// ReportVariants defines which variations of the links to the same page are counted (none if zero, see VariantReport),
+// MaxRedirects limits how many redirects the default downloader follows for one page (10 if zero), the page being
+// recorded under the URL the last one leads to, ExternalRedirects makes it follow the redirects out of
+// the crawled domains as well, which are otherwise reported as external-redirect findings and not crawled,
+// HeadFirst makes the default downloader request every page with HEAD before GET, skipping those whose content type
+// is none of PageContentTypes (text/html and application/xhtml+xml if empty) or whose Content-Length exceeds
+// MaxPageSize (not checked if zero) without downloading them, which are reported as uncrawled instead
+// (the HEAD and GET requests are spaced by the politeness delay and Crawl-delay like any other requests to the host),
+// VisitedSet holds the URLs scheduled so far, so that each URL is crawled once (an exact in-memory set if nil),
+// e.g. a BloomVisitedSet for crawls of millions of URLs, which can be persisted between crawls,
+// CompareSitemap makes the crawler read the sitemaps declared by robots.txt (or /sitemap.xml) of the seeds' hosts
+// once the crawl is done, reporting the pages they list which were not discovered and the crawled pages they miss,
+// SeedFromSitemap makes it read the same sitemaps (gzipped ones and sitemap indexes included) before the crawl
+// and schedule the pages they list within the crawled domains as seeds, so that the pages no link leads to are crawled,
+// FollowFeeds makes it fetch the RSS and Atom feeds the pages declare with <link rel="alternate"> and crawl the pages
+// they list as if the declaring page linked to them, e.g. blog posts unreachable by pagination, provided the extractor
+// implements FeedExtractor. Each feed is fetched once, the feeds of pages not modified since the baseline are not,
+// Sink receives every page as soon as it is crawled and is closed when the crawl is done,
+// OnError is called synchronously with every error instead of sending it on the errors channel,
+// RequestTimeout bounds every request of the default downloader, rounded up to whole seconds (2 seconds if zero),
+// and is best raised when crawling through proxies (ignored if Downloader is set),
+// Proxy routes the requests of the default downloader through a chain of SOCKS5 proxies (ignored if Downloader is set),
+// Resolve makes the default downloader connect to other addresses than the hosts of the URLs resolve to, keeping
+// the Host header and TLS server name, e.g. to crawl a staging server before DNS cutover (see DownloaderOptions,
+// ignored if Downloader is set),
+// PinnedKeys are the pins (sha256/ followed by the base64 SHA-256 hash of the public key) of the certificates
+// the hosts of the seeds must present one of, the leaf or a CA, otherwise the crawl is stopped with
+// a pin-mismatch finding (ignored if Downloader is set),
+// HTTP3 makes the default downloader try HTTP/3 (QUIC) first and fall back to HTTP/2 for the hosts it fails with,
+// which is experimental and cannot be combined with Proxy (ignored if Downloader is set),
+// WarmConnections is the number of keep-alive connections opened to the host of the root URL before the crawl starts,
+// so that the first requests of the workers do not wait for TLS handshakes one after another. They are opened with
+// HEAD requests to the root of the host, not spaced by PolitenessDelay (none if zero, ignored if Downloader is set
+// and does not implement Warmer),
+// MaxDepth limits how many links away from the root URL the crawler goes (no limit if zero),
+// HostAffinity makes the pages of the same host always processed by the same worker, chosen by the hash of the host,
+// so that the connections used to fetch their assets are reused,
+// CheckIntegrity makes the crawler fetch the scripts and stylesheets with Subresource Integrity metadata to verify it,
+// reporting mismatches and the scripts and stylesheets of other origins without any,
+// CheckCSP makes the crawler report the assets of the pages which their Content-Security-Policy header would block,
+// and those their Content-Security-Policy-Report-Only header would report (the policies of meta tags are not checked),
+// CheckLinks makes the crawler verify the links of the pages out of the crawled domains without following them,
+// and record the pages referring to every link, so that GetBrokenLinks reports the broken ones,
+// SimHash makes the crawler compute the SimHash of the text of every page, so that SiteMap.Duplicates groups
+// the pages with near-identical content too, not only the ones with identical content,
+// IncludeSubdomains makes the default extractor follow the links to the subdomains of the seeds as well,
+// e.g. blog.example.com for example.com or www.example.com (ignored if Extractor is set),
+// IncludePatterns and ExcludePatterns restrict which discovered URLs are crawled, those matching none of the include
+// patterns (if there are any) or any of the exclude patterns are not, e.g. /docs/* or re:[?&]sort= (see newURLFilter),
+// MaxPages limits how many pages are fetched and MaxTotalBytes how many bytes of responses are read (no limit if zero),
+// MaxTotalBytes being the total byte budget of the crawl rather than a limit of each response (see MaxPageSize with HeadFirst for that):
+// the pages and assets downloaded so far count towards it, and once it is used up no new pages are scheduled
+// and the assets are no longer downloaded either,
+// MaxBytes is the former name of MaxTotalBytes, only used if MaxTotalBytes is zero. It means the same, so callers setting it
+// migrate by renaming the field,
+// MaxPagesPerHost limits how many pages of each host are fetched, so that no host of a crawl of several seeds
+// or subdomains uses up the whole MaxPages (no limit if zero),
+// IgnoreRobots makes the crawler disregard robots.txt, otherwise discovered URLs it disallows are not crawled
+// and the requests to each host are spaced by its Crawl-delay (the root URL is always crawled),
+// SlowThreshold is the time to first byte past which a page is reported as slow (measured only if the downloader
+// implements ContextDownloader, not reported if zero),
+// AssetLifetimeThreshold is how long the scripts, stylesheets, images and videos verified or downloaded according
+// to their policy must be cacheable for, by Cache-Control max-age or Expires, the ones cacheable for less being reported
+// (not reported if zero, nor if their lifetime is unspecified). The pages served with no-store are always reported,
+// MaxConcurrencyPerHost limits how many requests to each host are in flight at once (no limit if zero), a fetcher
+// downloading the URLs of other hosts rather than waiting while a host has as many requests in flight,
+// PolitenessDelay and RequestsPerSecond space the requests to each host, the longer interval of the two applying
+// (no limit if zero),
+// CheckpointPath is the file the state of the crawl is saved to every CheckpointInterval and once it is done,
+// so that it can be continued with ResumeFromCheckpoint (not saved if empty, only saved once done if the interval is zero),
+// InlineThreshold is the number of bytes of inline scripts or stylesheets past which a page is reported
+// (not reported if zero, sizes are only measured if the extractor implements InlineExtractor),
+// StallTimeout is how long a fetcher may download or a worker process one page before it is considered stuck,
+// reported with ErrStalled and replaced, its request being cancelled if the downloader implements ContextDownloader
+// (not watched if zero). The time waited for PolitenessDelay, Crawl-delay or MaxConcurrencyPerHost does not count, and each asset
+// or link checked on behalf of a page gets its own timeout. The crawl still waits for a stuck worker to finish the page,
+// Hooks are called at the steps of the crawl, e.g. before every request and with every page (see Hooks),
+// OnStats is called with the progress of the crawl every StatsInterval and once it is done (not called if the interval is zero),
+// CheckInvariants makes the crawler verify its bookkeeping once the crawl is done, reporting violations
+// with ErrInvariant, which is meant for debugging the crawler itself.
 type Options struct {
 	MaxWorkers, MaxRetries int
+	MaxFetchers            int
+	RetryBackoff           RetryBackoff
 	Downloader             Downloader
 	Extractor              Extractor
-	Callback               func(string)
+	Frontier               Frontier
+	Callback               func(DiscoveryEvent)
+	AssetPolicies          map[AssetType]AssetPolicy
+	MaxDuration            time.Duration
+	Baseline               *SiteMap
+	Shuffle                bool
+	Seed                   int64
+	RequestHeaders         *RequestHeaders
+	CookieJar              http.CookieJar
+	RouteFragments         bool
+	Normalization          URLNormalization
+	RewriteURL             func(string) string
+	Canonical              CanonicalPolicy
+	ReportVariants         VariantReport
+	RespectNofollow        bool
+	RespectNoindex         bool
+	MaxRedirects           int
+	ExternalRedirects      bool
+	HeadFirst              bool
+	PageContentTypes       []string
+	MaxPageSize            int64
+	CompareSitemap         bool
+	SeedFromSitemap        bool
+	FollowFeeds            bool
+	VisitedSet             VisitedSet
+	Sink                   Sink
+	OnError                func(CrawlError)
+	RequestTimeout         time.Duration
+	Proxy                  *ProxyOptions
+	Resolve                map[string]string
+	PinnedKeys             []string
+	HTTP3                  bool
+	WarmConnections        int
+	MaxDepth               int
+	HostAffinity           bool
+	IncludeSubdomains      bool
+	CheckIntegrity         bool
+	CheckCSP               bool
+	CheckLinks             bool
+	SimHash                bool
+	IncludePatterns        []string
+	ExcludePatterns        []string
+	MaxPages               int
+	MaxPagesPerHost        int
+	MaxConcurrencyPerHost  int
+	MaxTotalBytes          int64
+	MaxBytes               int64
+	IgnoreRobots           bool
+	SlowThreshold          time.Duration
+	AssetLifetimeThreshold time.Duration
+	PolitenessDelay        time.Duration
+	RequestsPerSecond      float64
+	CheckpointPath         string
+	CheckpointInterval     time.Duration
+	InlineThreshold        int
+	StallTimeout           time.Duration
+	CheckInvariants        bool
+	Hooks                  *Hooks
+	OnStats                func(Stats)
+	StatsInterval          time.Duration
 }
 
+// defaultRequestTimeout is the number of seconds the requests of the default downloader time out after
+// if no RequestTimeout is given
+const defaultRequestTimeout = 2
+
 var defaultOptions = Options{
-	MaxWorkers: 10,
-	MaxRetries: 2,
+	MaxWorkers:         10,
+	MaxRetries:         2,
+	RetryBackoff:       defaultRetryBackoff,
+	CheckpointInterval: time.Minute,
 }
 
 // Crawler struct represents the web crawler which takes the root url, a list of parameters and produces a sitemap.
 type Crawler struct {
-	// Root URL
-	url string
+	// Root URL, the first of the seeds
+	url   string
+	seeds []string
+
+	maxRetries, maxWorkers, maxDepth int
 
-	maxRetries, maxWorkers int
+	// Failed URLs are pushed back to the frontier after the backoff
+	retryBackoff RetryBackoff
 
 	downloader Downloader
 	extractor  Extractor
 
+	// URLs scheduled to be crawled, downloaded by the fetchers. A fetcher waiting for URLs is woken up
+	// through the channel once one is pushed
+	frontier    Frontier
+	wake        chan struct{}
+	maxFetchers int
+
+	// A frontier shared with other crawlers, which may pop the URLs this one pushes. Once it is drained,
+	// no more URLs are popped from it
+	shared  SharedFrontier
+	mudrain sync.Mutex
+	drained bool
+
+	// Links to the subdomains of the seeds are followed too
+	includeSubdomains bool
+
+	// Keep-alive connections opened to the host of the root URL before the crawl starts
+	warmConnections int
+
+	// Scripts and stylesheets are checked for Subresource Integrity
+	integrity bool
+
+	// Assets are checked against the Content-Security-Policy of their pages
+	csp bool
+
+	// Links are checked, the pages referring to them and the broken ones recorded.
+	// The links out of the crawled domains are verified once, recorded in checkedLinks
+	linkChecks   bool
+	mulink       sync.Mutex
+	linkRefs     map[string][]string
+	brokenLinks  map[string]*BrokenLink
+	checkedLinks map[string]struct{}
+
+	// The SimHash of the text of the pages is computed to find near-duplicates
+	simhash bool
+
+	// The bookkeeping is verified once the crawl is done
+	invariants bool
+
+	// Include and exclude patterns of the discovered URLs, nil if there are none
+	filter *urlFilter
+
+	// The seeds and the discovered URLs are canonicalized before they are crawled
+	normalization  URLNormalization
+	rewriteURL     func(string) string
+	routeFragments bool
+
+	// The pages declaring another crawled page canonical are related to it once the crawl is done
+	canonicalPolicy CanonicalPolicy
+
+	// Default extractors of the hosts of the seeds and, if subdomains are included, of their subdomains,
+	// so that each seed is crawled within its own domain. The options are nil if the extractor is custom.
+	// Since the extractors of subdomains are added by multiple goroutines, the map is guarded with a mutex
+	muext            sync.Mutex
+	extractors       map[string]Extractor
+	extractorOptions *ExtractorOptions
+
 	// Waitgroups for controlling termination of the main program and the goroutines
 	wg, wgStop sync.WaitGroup
 
@@ -42,41 +288,210 @@ type Crawler struct {
 	mur     sync.RWMutex
 	retries map[string]int
 
-	// Since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	// Discovered URLs disallowed by robots.txt, since this map can be accessed by multiple goroutines,
+	// it is guarded with a mutex
+	mubl    sync.Mutex
+	blocked map[string]*BlockedURL
+
+	// URLs being downloaded, since this map can be accessed by multiple goroutines, it is guarded with a mutex
 	mup       sync.RWMutex
 	processed map[string]bool
 
-	// internal channels for communicating crawler results and terminating workers
-	results chan *result
-	quit    []chan struct{}
+	// URLs scheduled so far, a new map unless the set is given in the options (which Reset does not clear)
+	visited    VisitedSet
+	visitedSet VisitedSet
+
+	// internal channels for communicating crawler results and terminating workers,
+	// with host affinity the results are sent to the inbox of the worker chosen for their host instead
+	results      chan *result
+	inboxes      []chan *result
+	quit         []chan struct{}
+	hostAffinity bool
 
 	// external channels for signalling crawler termination and errors
 	done   chan struct{}
-	errors chan error
+	errors chan CrawlError
+
+	// errors aggregated by host and class
+	errorStats *errorStats
+
+	// Since the findings can be accessed by multiple goroutines, they are guarded with a mutex
+	muf      sync.Mutex
+	findings Findings
+
+	callback func(DiscoveryEvent)
+	onError  func(CrawlError)
+	hooks    Hooks
+
+	// Progress of the crawl, since the counters can be accessed by multiple goroutines, they are guarded with a mutex
+	mustat                sync.Mutex
+	fetched, retried      int
+	startedAt, finishedAt time.Time
+	onStats               func(Stats)
+	statsInterval         time.Duration
+
+	assetPolicies map[AssetType]AssetPolicy
+
+	// Past the deadline or once the context is cancelled no new URLs are scheduled, zero value means no deadline
+	maxDuration time.Duration
+	deadline    time.Time
+	ctx         context.Context
 
-	callback func(string)
+	// Since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	muu       sync.Mutex
+	uncrawled map[string]UncrawledURL
+
+	// External domains referenced by the pages, since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	mutp       sync.Mutex
+	thirdParty map[string]*ThirdPartyDomain
+
+	// Pages fetched over each HTTP protocol and with each content encoding, since these maps can be accessed
+	// by multiple goroutines, they are guarded with a mutex
+	muproto   sync.Mutex
+	protocols map[string]int
+	encodings map[string]int
+
+	// Caching headers of the pages and assets by path prefix, since this map can be accessed by multiple goroutines,
+	// it is guarded with a mutex
+	mucache        sync.Mutex
+	caching        map[string]*CachingPrefix
+	pageLifetimes  map[string]int
+	assetLifetimes map[string]int
+
+	// Static assets cached for less than it are reported, zero value means they are not
+	lifetimeThreshold time.Duration
+
+	// The robots meta tags and rel="nofollow" are respected. The pages which must not be indexed
+	// are removed once the crawl is done, since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	respectNofollow bool
+	respectNoindex  bool
+	muni            sync.Mutex
+	noindex         map[string]struct{}
+
+	// Variants of the links to each page, since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	muvar         sync.Mutex
+	variants      map[string]map[string]*LinkVariant
+	variantReport VariantReport
+
+	// In-scope URLs redirecting out of scope, to the location they redirect to, and the crawled URLs redirecting
+	// to a page, to the URL of the page. Since these maps can be accessed by multiple goroutines, they are guarded with a mutex
+	muxr           sync.Mutex
+	redirects      map[string]string
+	redirected     map[string]string
+	maxRedirects   int
+	followExternal bool
+
+	// Pages requested with HEAD first are skipped if it rejects them, nil if they are not
+	headCheck *headCheck
+
+	// The pages are compared with the sitemaps declared by the site once the crawl is done,
+	// or the pages they list are crawled as seeds
+	compareSitemap bool
+	seedSitemap    bool
+
+	// The feeds declared by the pages are fetched once and the pages they list crawled,
+	// since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	followFeeds bool
+	mufeed      sync.Mutex
+	feeds       map[string]struct{}
+
+	// Once either budget is used up no new URLs are scheduled, zero value means no limit.
+	// Since the counters can be accessed by multiple goroutines, they are guarded with a mutex
+	mub                 sync.Mutex
+	maxPages, pages     int
+	maxBytes, bytesRead int64
+
+	// Pages scheduled on each host, only counted if their number is limited
+	maxHostPages int
+	hostPages    map[string]int
+
+	// Requests in flight to each host, only counted if their number is limited
+	maxHostConns int
+	slots        *hostSlots
+
+	// Pages whose time to first byte exceeds it are reported as slow, zero value means they are not
+	slowThreshold time.Duration
+
+	// Pages whose inline scripts or stylesheets exceed it are reported, zero value means they are not
+	inlineThreshold int
+
+	// Fetchers and workers busy with one URL for longer than it are replaced, zero value means they are not.
+	// Since the tasks are watched by another goroutine, they are guarded with a mutex. The live ones are
+	// reported by Workers, each numbered after the tasks of its kind started before it
+	stallTimeout time.Duration
+	mutask       sync.Mutex
+	tasks        map[*task]struct{}
+	live         map[*task]struct{}
+	taskCounts   map[string]int
+
+	// Pages of the previous crawl and the URLs they link to
+	baseline      *SiteMap
+	baselineLinks map[string][]string
+
+	// Since the random source can be accessed by multiple goroutines, it is guarded with a mutex
+	murand sync.Mutex
+	rand   *rand.Rand
+
+	sink Sink
+
+	// Rules of robots.txt of each host, nil if they are ignored
+	ignoreRobots bool
+	robots       *robotsCache
+
+	// Spaces the requests to each host
+	limiter *hostLimiter
+
+	// The pages are processed holding the read lock, so that the checkpoint captured holding the write lock is consistent
+	mucp               sync.RWMutex
+	checkpointPath     string
+	checkpointInterval time.Duration
+	resumeFrom         *checkpoint
+
+	// URLs scheduled but not processed yet, since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	mupend  sync.Mutex
+	pending map[string]UncrawledURL
+
+	// Since Crawl, Stop and Reset can be called from multiple goroutines, the state is guarded with a mutex.
+	// The stop channel is closed once the crawl is stopped.
+	mustate sync.Mutex
+	state   crawlState
+	stop    chan struct{}
+	stopped bool
+
+	// Since Pause and Resume can be called from multiple goroutines, the gate is guarded with a mutex.
+	// It is closed while the crawl is running and open while it is paused.
+	mupause sync.Mutex
+	gate    chan struct{}
+	paused  bool
 }
 
+// crawlState defines whether the crawler is ready to crawl, crawling or done and in need of Reset
+type crawlState uint8
+
+const (
+	stateIdle     crawlState = iota
+	stateRunning  crawlState = iota
+	stateFinished crawlState = iota
+)
+
 func NewCrawler(url string) (*Crawler, error) {
 	c := &Crawler{
-		url: url,
+		url:   url,
+		seeds: []string{url},
 
 		maxRetries: defaultOptions.MaxRetries,
 		maxWorkers: defaultOptions.MaxWorkers,
 
-		downloader: NewDefaultDownloader(2, NewBufferPool(10, 1024)),
-
-		results: make(chan *result, defaultOptions.MaxWorkers),
-		quit:    make([]chan struct{}, 0, defaultOptions.MaxWorkers),
+		retryBackoff: defaultOptions.RetryBackoff,
 
-		done:   make(chan struct{}),
-		errors: make(chan error, 100),
+		downloader: NewDefaultDownloader(2, NewBufferPool(10, 1024)),
+		frontier:   NewMemoryFrontier(),
 
-		sites:     make(map[string]*Page),
-		retries:   make(map[string]int),
-		processed: make(map[string]bool),
+		limiter: newHostLimiter(0, 0),
 	}
 
+	c.reset()
+
 	if extractor, err := NewDefaultExtractor(url); err == nil {
 		c.extractor = extractor
 	} else {
@@ -87,36 +502,151 @@ func NewCrawler(url string) (*Crawler, error) {
 }
 
 func NewCrawlerWithOptions(url string, options *Options) (*Crawler, error) {
+	return NewCrawlerWithSeeds([]string{url}, options)
+}
+
+// NewCrawlerWithSeeds returns a crawler starting from all of the seed URLs and merging their pages into one sitemap,
+// e.g. for a site spanning several subdomains. Unless Extractor is set, the links of each seed are followed
+// only within its own domain.
+func NewCrawlerWithSeeds(seeds []string, options *Options) (*Crawler, error) {
+	if len(seeds) == 0 {
+		return nil, ErrNoArgument
+	}
+
+	canonical := make([]string, len(seeds))
+	for i, seed := range seeds {
+		canonical[i] = canonicalURL(seed, options.Normalization, options.RouteFragments)
+	}
+
+	seeds = canonical
+
 	c := &Crawler{
-		url: url,
+		url:   seeds[0],
+		seeds: seeds,
 
 		maxRetries: options.MaxRetries,
 		maxWorkers: options.MaxWorkers,
+		maxDepth:   options.MaxDepth,
+		maxPages:   options.MaxPages,
+		maxBytes:   options.MaxTotalBytes,
 
-		results: make(chan *result, options.MaxWorkers),
-		quit:    make([]chan struct{}, 0, options.MaxWorkers),
+		maxHostPages: options.MaxPagesPerHost,
+		maxHostConns: options.MaxConcurrencyPerHost,
 
-		done:   make(chan struct{}),
-		errors: make(chan error, 100),
+		retryBackoff: options.RetryBackoff,
+		maxFetchers:  options.MaxFetchers,
 
-		sites:     make(map[string]*Page),
-		retries:   make(map[string]int),
-		processed: make(map[string]bool),
+		hostAffinity:      options.HostAffinity,
+		ignoreRobots:      options.IgnoreRobots,
+		includeSubdomains: options.IncludeSubdomains,
+		integrity:         options.CheckIntegrity,
+		csp:               options.CheckCSP,
+		linkChecks:        options.CheckLinks,
+		simhash:           options.SimHash,
+		invariants:        options.CheckInvariants,
+
+		limiter: newHostLimiter(options.PolitenessDelay, options.RequestsPerSecond),
+
+		checkpointPath:     options.CheckpointPath,
+		checkpointInterval: options.CheckpointInterval,
+
+		normalization:  options.Normalization,
+		rewriteURL:     options.RewriteURL,
+		routeFragments: options.RouteFragments,
+
+		canonicalPolicy: options.Canonical,
+		variantReport:   options.ReportVariants,
+
+		respectNofollow: options.RespectNofollow,
+		respectNoindex:  options.RespectNoindex,
+
+		maxRedirects:   options.MaxRedirects,
+		followExternal: options.ExternalRedirects,
+		headCheck:      newHeadCheck(options),
+		compareSitemap: options.CompareSitemap,
+		seedSitemap:    options.SeedFromSitemap,
+		followFeeds:    options.FollowFeeds,
+
+		visitedSet: options.VisitedSet,
 	}
 
+	c.reset()
+
+	if c.maxBytes == 0 {
+		c.maxBytes = options.MaxBytes
+	}
+
+	filter, err := newURLFilter(options.IncludePatterns, options.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	c.filter = filter
+
 	if options.Downloader != nil {
 		c.downloader = options.Downloader
 	} else {
-		c.downloader = NewDefaultDownloader(2, NewBufferPool(10, 1024))
+		var pins map[string][]string
+		if len(options.PinnedKeys) > 0 {
+			pins = seedPins(seeds, options.PinnedKeys)
+		}
+
+		timeout := defaultRequestTimeout
+		if options.RequestTimeout > 0 {
+			timeout = int(math.Ceil(options.RequestTimeout.Seconds()))
+		}
+
+		d, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+			Timeout: timeout,
+			Pool:    NewBufferPool(10, 1024),
+			Headers: options.RequestHeaders,
+			Proxy:   options.Proxy,
+			Resolve: options.Resolve,
+			Pins:    pins,
+			HTTP3:   options.HTTP3,
+			Jar:     options.CookieJar,
+
+			WarmConnections: options.WarmConnections,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		c.downloader = d
+	}
+
+	if options.Frontier != nil {
+		c.frontier = options.Frontier
+		c.shared, _ = options.Frontier.(SharedFrontier)
+	} else {
+		c.frontier = NewMemoryFrontier()
 	}
 
 	if options.Extractor != nil {
 		c.extractor = options.Extractor
 	} else {
-		if ext, err := NewDefaultExtractor(url); err == nil {
-			c.extractor = ext
-		} else {
-			return nil, err
+		c.extractors = make(map[string]Extractor, len(seeds))
+		c.extractorOptions = &ExtractorOptions{
+			RouteFragments:    options.RouteFragments,
+			IncludeSubdomains: options.IncludeSubdomains,
+			KeepFragments:     options.ReportVariants.Fragment,
+		}
+
+		for _, seed := range seeds {
+			ext, err := NewDefaultExtractorWithOptions(seed, c.extractorOptions)
+			if err != nil {
+				return nil, err
+			}
+
+			if c.extractor == nil {
+				c.extractor = ext
+			}
+
+			if u, err := neturl.Parse(seed); err == nil {
+				if _, ok := c.extractors[hostOf(u)]; !ok {
+					c.extractors[hostOf(u)] = ext
+				}
+			}
 		}
 	}
 
@@ -124,140 +654,1040 @@ func NewCrawlerWithOptions(url string, options *Options) (*Crawler, error) {
 		c.callback = options.Callback
 	}
 
+	if options.OnError != nil {
+		c.onError = options.OnError
+	}
+
+	if options.Hooks != nil {
+		c.hooks = *options.Hooks
+	}
+
+	c.onStats = options.OnStats
+	c.statsInterval = options.StatsInterval
+
+	if options.AssetPolicies != nil {
+		c.assetPolicies = options.AssetPolicies
+	}
+
+	c.maxDuration = options.MaxDuration
+	c.slowThreshold = options.SlowThreshold
+	c.lifetimeThreshold = options.AssetLifetimeThreshold
+	c.warmConnections = options.WarmConnections
+	c.inlineThreshold = options.InlineThreshold
+	c.stallTimeout = options.StallTimeout
+	c.sink = options.Sink
+
+	if options.Shuffle {
+		c.rand = rand.New(rand.NewSource(options.Seed))
+	}
+
+	if options.Baseline != nil {
+		c.baseline = options.Baseline
+		c.baselineLinks = options.Baseline.outLinks()
+	}
+
 	return c, nil
 }
 
-func (c *Crawler) Crawl() (chan struct{}, chan error) {
+// Crawl starts crawling from the root URL and returns the channels signalling termination and errors.
+// Each error tells the URL, the phase of the crawl and the attempt it was encountered in, and whether
+// the URL will be retried. It is equivalent to CrawlWithContext with a background context.
+// Once the crawl is done, the errors channel is closed after the last error and then the done channel
+// is closed, so both can be consumed with range. The errors must be consumed, otherwise the crawl blocks,
+// unless they are passed to the OnError callback, in which case nothing is sent on the errors channel.
+// A crawler can crawl only once, unless it is Reset afterwards. Otherwise ErrAlreadyCrawled is sent
+// on the returned errors channel and both channels are closed right away.
+func (c *Crawler) Crawl() (chan struct{}, chan CrawlError) {
+	return c.CrawlWithContext(context.Background())
+}
+
+// CrawlWithContext starts crawling like Crawl, bound to the context. Once the context is cancelled or its
+// deadline passes, no new URLs are scheduled and the requests in flight are aborted (if the downloader
+// implements ContextDownloader). The crawl then completes as usual and GetSiteMap holds the partial results.
+func (c *Crawler) CrawlWithContext(ctx context.Context) (chan struct{}, chan CrawlError) {
+	c.mustate.Lock()
+	defer c.mustate.Unlock()
+
+	if c.state != stateIdle {
+		done, errors := make(chan struct{}), make(chan CrawlError, 1)
+		errors <- CrawlError{Err: ErrAlreadyCrawled}
+
+		close(errors)
+		close(done)
+
+		return done, errors
+	}
+
+	c.state = stateRunning
+	c.ctx = ctx
+
+	if c.maxDuration > 0 {
+		c.deadline = time.Now().Add(c.maxDuration)
+	}
+
 	c.wgStop.Add(c.maxWorkers)
 
 	for i := 0; i < c.maxWorkers; i++ {
 		q := make(chan struct{})
 		c.quit = append(c.quit, q)
 
-		go c.collect(q)
+		var inbox chan *result
+		if c.hostAffinity {
+			inbox = make(chan *result, 1)
+			c.inboxes = append(c.inboxes, inbox)
+		}
+
+		go c.collect(q, inbox)
 	}
 
-	go func() {
-		c.wg.Add(1)
+	c.wgStop.Add(c.fetchers())
+
+	for i := 0; i < c.fetchers(); i++ {
+		q := make(chan struct{})
+		c.quit = append(c.quit, q)
+
+		go c.fetch(q)
+	}
+
+	// Held until the seeds or the frontier of the checkpoint are scheduled
+	c.wg.Add(1)
+
+	done, errors := c.done, c.errors
+	resumeFrom := c.resumeFrom
+	c.resumeFrom = nil
+
+	var checkpointStop, checkpointDone chan struct{}
+	if c.checkpointPath != "" && c.checkpointInterval > 0 {
+		checkpointStop, checkpointDone = make(chan struct{}), make(chan struct{})
+
+		go func() {
+			c.writeCheckpoints(c.checkpointInterval, checkpointStop)
+			close(checkpointDone)
+		}()
+	}
 
-		c.sites["<root>"] = &Page{
+	c.mustat.Lock()
+	c.startedAt = time.Now()
+	c.mustat.Unlock()
+
+	var statsStop, statsDone chan struct{}
+	if c.onStats != nil && c.statsInterval > 0 {
+		statsStop, statsDone = make(chan struct{}), make(chan struct{})
+
+		go func() {
+			c.reportStats(c.statsInterval, statsStop)
+			close(statsDone)
+		}()
+	}
+
+	var watchdogStop, watchdogDone chan struct{}
+	if c.stallTimeout > 0 {
+		watchdogStop, watchdogDone = make(chan struct{}), make(chan struct{})
+
+		go func() {
+			c.watchTasks(watchdogStop)
+			close(watchdogDone)
+		}()
+	}
+
+	go func() {
+		c.markVisited("<root>", &Page{
 			LinkedFrom: make([]*Page, 0),
 			LinksTo:    make([]*Page, 0),
 			Assets:     make([]*Asset, 0),
+		})
+
+		c.warmUp()
+
+		if resumeFrom != nil {
+			c.resume(resumeFrom)
+		} else {
+			c.crawlSeeds()
+
+			if c.seedSitemap {
+				c.crawlSitemaps()
+			}
+		}
+
+		if c.shared != nil {
+			c.drain()
 		}
 
-		c.crawl(c.url, "<root>")
+		c.wg.Done()
+
 		c.wg.Wait()
 
+		// Stopped before the goroutines, so that no stalled one is replaced meanwhile
+		if watchdogStop != nil {
+			close(watchdogStop)
+			<-watchdogDone
+		}
+
+		c.applyCanonical()
+		c.applyNoindex()
+
+		if c.compareSitemap {
+			c.compareSitemaps()
+		}
+
+		if c.invariants {
+			for _, err := range c.checkInvariants() {
+				c.reportError(CrawlError{Url: c.url, Phase: PhaseCrawl, Err: err})
+			}
+		}
+
+		c.mustat.Lock()
+		c.finishedAt = time.Now()
+		c.mustat.Unlock()
+
+		if statsStop != nil {
+			close(statsStop)
+			<-statsDone
+
+			c.onStats(c.Stats())
+		}
+
+		if checkpointStop != nil {
+			close(checkpointStop)
+			<-checkpointDone
+		}
+
+		if c.checkpointPath != "" {
+			if err := c.writeCheckpoint(); err != nil {
+				c.reportError(CrawlError{Url: c.checkpointPath, Phase: PhaseOutput, Err: err})
+			}
+		}
+
 		c.stopGoroutines()
 		c.wgStop.Wait()
 
 		close(c.results)
+		for _, inbox := range c.inboxes {
+			close(inbox)
+		}
+
+		c.mus.Lock()
 		delete(c.sites, "<root>")
+		c.mus.Unlock()
+
+		if c.sink != nil {
+			if err := c.sink.Close(); err != nil {
+				c.reportError(CrawlError{Url: c.url, Phase: PhaseOutput, Err: err})
+			}
+		}
+
+		// The bookkeeping of the finished crawl is released, but left usable
+		c.mur.Lock()
+		c.retries = make(map[string]int)
+		c.mur.Unlock()
 
-		c.retries = nil
-		c.processed = nil
+		c.mup.Lock()
+		c.processed = make(map[string]bool)
+		c.mup.Unlock()
 
-		c.done <- struct{}{}
+		c.mustate.Lock()
+		c.state = stateFinished
+		c.mustate.Unlock()
+
+		close(errors)
+		close(done)
 	}()
 
 	return c.done, c.errors
 }
 
-func (c *Crawler) GetSiteMap() map[string]*Page {
-	return c.sites
+// Stop stops a running crawl gracefully and returns once it is done. No new URLs are scheduled,
+// but the requests in flight complete and their pages are added, so that GetSiteMap holds whatever
+// was crawled so far. The channels are closed as if the crawl completed, so the errors must be consumed
+// by another goroutine while Stop is waiting, unless they are passed to the OnError callback.
+// Stopping a crawler which is not crawling does nothing.
+func (c *Crawler) Stop() {
+	c.mustate.Lock()
+
+	if c.state != stateRunning {
+		c.mustate.Unlock()
+		return
+	}
+
+	if !c.stopped {
+		c.stopped = true
+		close(c.stop)
+	}
+
+	done := c.done
+	c.mustate.Unlock()
+
+	<-done
+}
+
+// abort stops the crawl from within, as Stop does without waiting for it to finish
+func (c *Crawler) abort() {
+	c.mustate.Lock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.stop)
+	}
+	c.mustate.Unlock()
+}
+
+// Pause suspends a running crawl until Resume is called. The requests and pages in flight complete,
+// but no new requests are sent and no downloaded pages are processed, so that no URLs are lost.
+// Note that MaxDuration keeps elapsing while the crawl is paused. Pausing a paused crawl does nothing
+// and pausing a crawler before it crawls makes the crawl start paused.
+func (c *Crawler) Pause() {
+	c.mupause.Lock()
+	defer c.mupause.Unlock()
+
+	if !c.paused {
+		c.paused = true
+		c.gate = make(chan struct{})
+	}
+}
+
+// Resume resumes a paused crawl. Resuming a crawl which is not paused does nothing.
+func (c *Crawler) Resume() {
+	c.mupause.Lock()
+	defer c.mupause.Unlock()
+
+	if c.paused {
+		c.paused = false
+		close(c.gate)
+	}
+}
+
+// Reset prepares the crawler for another crawl, discarding the state of the previous one.
+// The sitemaps returned by GetSiteMap before are left intact. ErrCrawlRunning is returned
+// if the crawl is still in progress.
+func (c *Crawler) Reset() error {
+	c.mustate.Lock()
+	defer c.mustate.Unlock()
+
+	if c.state == stateRunning {
+		return ErrCrawlRunning
+	}
+
+	c.reset()
+	c.state = stateIdle
+
+	return nil
 }
 
+func (c *Crawler) reset() {
+	c.results = make(chan *result, c.maxWorkers)
+	c.inboxes = nil
+	c.quit = make([]chan struct{}, 0, c.maxWorkers+c.fetchers())
+	c.wake = make(chan struct{}, c.fetchers())
+
+	c.done = make(chan struct{})
+	c.errors = make(chan CrawlError, 100)
+
+	c.errorStats = newErrorStats()
+	c.findings = nil
+
+	c.sites = make(map[string]*Page)
+	c.retries = make(map[string]int)
+	c.processed = make(map[string]bool)
+	c.uncrawled = make(map[string]UncrawledURL)
+
+	c.visited = c.visitedSet
+	if c.visited == nil {
+		c.visited = newMapVisitedSet()
+	}
+
+	c.pending = make(map[string]UncrawledURL)
+	c.tasks = make(map[*task]struct{})
+	c.live = make(map[*task]struct{})
+	c.taskCounts = make(map[string]int)
+	c.thirdParty = make(map[string]*ThirdPartyDomain)
+	c.linkRefs = make(map[string][]string)
+	c.brokenLinks = make(map[string]*BrokenLink)
+	c.checkedLinks = make(map[string]struct{})
+	c.feeds = make(map[string]struct{})
+	c.protocols = make(map[string]int)
+	c.encodings = make(map[string]int)
+	c.caching = make(map[string]*CachingPrefix)
+	c.pageLifetimes = make(map[string]int)
+	c.assetLifetimes = make(map[string]int)
+	c.redirects = make(map[string]string)
+	c.redirected = make(map[string]string)
+	c.variants = make(map[string]map[string]*LinkVariant)
+	c.noindex = make(map[string]struct{})
+	c.blocked = make(map[string]*BlockedURL)
+
+	c.deadline = time.Time{}
+	c.ctx = context.Background()
+
+	c.stop = make(chan struct{})
+	c.drained = false
+	c.stopped = false
+
+	c.gate = make(chan struct{})
+	close(c.gate)
+	c.paused = false
+
+	c.pages, c.bytesRead = 0, 0
+	c.hostPages = make(map[string]int)
+
+	c.slots = nil
+	if c.maxHostConns > 0 {
+		c.slots = newHostSlots(c.maxHostConns)
+	}
+	c.fetched, c.retried = 0, 0
+	c.startedAt, c.finishedAt = time.Time{}, time.Time{}
+
+	if !c.ignoreRobots {
+		c.robots = newRobotsCache(c.fetchFile)
+	}
+}
+
+// GetSiteMap returns the sitemap of the pages crawled so far, the pseudo-page of the root left out.
+// It is safe to call while the crawl is running, the pages crawled afterwards not being added to it.
+func (c *Crawler) GetSiteMap() *SiteMap {
+	c.mus.RLock()
+	pages := make(map[string]*Page, len(c.sites))
+	for url, page := range c.sites {
+		if url != "<root>" {
+			pages[url] = page
+		}
+	}
+	c.mus.RUnlock()
+
+	return newSiteMapFrom(pages)
+}
+
+// GetFindings returns the issues discovered so far.
+func (c *Crawler) GetFindings() Findings {
+	c.muf.Lock()
+	findings := make(Findings, len(c.findings))
+	copy(findings, c.findings)
+	c.muf.Unlock()
+
+	return findings
+}
+
+// GetErrorSummary returns the errors encountered so far aggregated by host and error class.
+func (c *Crawler) GetErrorSummary() []ErrorStat {
+	return c.errorStats.summary()
+}
+
+// reportError passes the error to the OnError callback or sends it on the errors channel,
+// the attempt is the current one of the URL unless it is set
+func (c *Crawler) reportError(e CrawlError) {
+	c.errorStats.add(e.Url, e.Err)
+
+	if e.Attempt == 0 {
+		e.Attempt = c.retryCount(e.Url) + 1
+	}
+
+	c.hookError(&e)
+
+	if c.onError != nil {
+		c.onError(e)
+	} else {
+		c.errors <- e
+	}
+}
+
+func (c *Crawler) addFinding(f Finding) {
+	c.muf.Lock()
+	c.findings = append(c.findings, f)
+	c.muf.Unlock()
+}
+
+func (c *Crawler) assetFailed(asset *Asset, from string, err error) {
+	c.reportError(CrawlError{Url: asset.Url, Phase: PhaseAsset, Err: err})
+
+	message := err.Error()
+	if asset.Status != 0 {
+		message = fmt.Sprintf("%s: status %d", message, asset.Status)
+	}
+
+	c.addFinding(Finding{
+		Category: CategoryBrokenAsset,
+		Severity: SeverityWarning,
+		Url:      asset.Url,
+		Page:     from,
+		Message:  message,
+	})
+}
+
+// recordAsset records the status and content type of the response with the asset, and its size if it was
+// only verified, as given by Content-Length. Only the default downloader records the responses.
+func recordAsset(asset *Asset, response *responseInfo) {
+	if response.status == 0 {
+		return
+	}
+
+	asset.Status, asset.ContentType = response.status, response.contentType
+
+	if asset.Size == 0 && response.size > 0 {
+		asset.Size = int(response.size)
+	}
+}
+
+// pageOf returns the URL of the linking page, empty for the root
+func pageOf(from string) string {
+	if from == "<root>" {
+		return ""
+	}
+
+	return from
+}
+
+// fetchers returns the number of goroutines downloading the websites
+func (c *Crawler) fetchers() int {
+	if c.maxFetchers > 0 {
+		return c.maxFetchers
+	}
+
+	return 4 * c.maxWorkers
+}
+
+// stopGoroutines closes the quit channels, which are shared by the goroutines replacing stalled ones
 func (c *Crawler) stopGoroutines() {
 	for i, _ := range c.quit {
-		c.quit[i] <- struct{}{}
+		close(c.quit[i])
 	}
 }
 
-func (c *Crawler) crawl(url, from string) {
+func (c *Crawler) crawl(t *task, url, from string, depth int) {
 	var (
-		body []byte
-		err  error
+		body       []byte
+		validators Validators
+		err        error
 	)
 
-	if body, err = c.downloader.Download(url); err == nil {
-		c.markBeingProcessed(url, false)
+	c.waitIfPaused()
+
+	// The fetcher moves on to the URLs of other hosts while the host has as many requests in flight as it may
+	if !c.acquireSlot(FrontierItem{Url: url, From: from, Depth: depth}) {
+		return
+	}
 
-		c.results <- &result{
-			url:  url,
-			from: from,
-			body: body,
+	c.throttle(nil, url)
+
+	ctx, ttfb := c.traceFirstByte()
+	ctx, cancel := context.WithCancel(ctx)
+
+	redirects := c.redirectsOf(from)
+	ctx = withRedirectPolicy(ctx, redirects)
+
+	// The GET request following the HEAD one waits for the host without counting towards the stall timeout
+	if c.headCheck != nil {
+		check := *c.headCheck
+		check.throttle = func(url string) {
+			c.throttle(t, url)
 		}
+
+		ctx = withHeadCheck(ctx, &check)
+	}
+
+	response := new(responseInfo)
+	ctx = withResponseInfo(ctx, response)
+
+	c.hookRequest(url)
+
+	c.watch(t, url, cancel)
+	body, validators, err = c.downloadContext(ctx, url, from)
+	c.unwatch(t)
+	cancel()
+	c.releaseSlot(url)
+
+	c.hookResponse(url, response, body, err)
+
+	location, redirectedFrom := c.redirectChain(url, redirects.hops)
+
+	fetched := url
+	if location != "" {
+		fetched = location
+	}
+
+	if err == nil {
+		c.markBeingProcessed(url, false)
+		c.checkSlow(url, from, *ttfb)
+		c.addBytes(len(body))
+		c.countFetched()
+		c.countProtocol(response)
+		c.auditCaching(fetched, false, response)
+		c.checkCacheLifetime(fetched, "", nil, response)
+
+		c.dispatch(&result{
+			url:            url,
+			from:           from,
+			depth:          depth,
+			body:           body,
+			validators:     validators,
+			response:       *response,
+			location:       location,
+			redirectedFrom: redirectedFrom,
+		})
+	} else if err == ErrNotModified {
+		c.markBeingProcessed(url, false)
+		c.checkSlow(url, from, *ttfb)
+		c.countFetched()
+		c.countProtocol(response)
+		c.auditCaching(fetched, false, response)
+		c.checkCacheLifetime(fetched, "", nil, response)
+
+		cached, _ := c.baseline.Get(url)
+
+		c.dispatch(&result{
+			url:            url,
+			from:           from,
+			depth:          depth,
+			validators:     validators,
+			response:       *response,
+			cached:         cached,
+			location:       location,
+			redirectedFrom: redirectedFrom,
+		})
+	} else if errors.Is(err, ErrPinMismatch) {
+		c.reportError(CrawlError{Url: url, Phase: PhaseDownload, Worker: t.id(), Err: err})
+		c.markBeingProcessed(url, false)
+
+		c.addFinding(Finding{
+			Category: CategoryPinMismatch,
+			Severity: SeverityError,
+			Url:      url,
+			Page:     pageOf(from),
+			Message:  err.Error(),
+		})
+
+		// The host may be impersonated, so nothing more is crawled
+		c.abort()
+
+		c.removePending(url)
+		c.settle(FrontierItem{Url: url, From: from, Depth: depth})
+	} else if redirect := (*RedirectError)(nil); errors.As(err, &redirect) {
+		c.markExternalRedirect(url, from, redirect.Location)
+		c.markBeingProcessed(url, false)
+		c.removePending(url)
+		c.settle(FrontierItem{Url: url, From: from, Depth: depth})
+	} else if skipped := (*SkippedContentError)(nil); errors.As(err, &skipped) {
+		c.markUncrawled(url, from, depth, skipped.Reason)
+		c.markBeingProcessed(url, false)
+		c.removePending(url)
+		c.settle(FrontierItem{Url: url, From: from, Depth: depth})
 	} else {
-		c.errors <- err
+		retry := c.shouldRetry(url) && !c.stopping() && !errors.Is(err, ErrRedirects)
+		c.reportError(CrawlError{Url: url, Phase: PhaseDownload, Worker: t.id(), Err: err, Retry: retry})
 
-		if c.shouldRetry(url) {
+		if retry {
 			c.markRetry(url)
-
-			c.crawl(url, from)
+			c.countRetry()
+			c.retryLater(url, from, depth)
 		} else {
 			c.markBeingProcessed(url, false)
+			c.markBrokenLink(url, false, response.status, err)
+
+			c.addFinding(Finding{
+				Category: CategoryBrokenPage,
+				Severity: SeverityError,
+				Url:      url,
+				Page:     pageOf(from),
+				Message:  err.Error(),
+			})
+
+			c.removePending(url)
+			c.settle(FrontierItem{Url: url, From: from, Depth: depth})
+		}
+	}
+}
+
+// inScope reports whether the URL belongs to the domain of one of the seeds
+func (c *Crawler) inScope(url string) bool {
+	_, third := c.thirdPartyDomain(url)
+	return !third
+}
+
+// markExternalRedirect records that the URL linked from the page redirects out of scope, it is not retried
+func (c *Crawler) markExternalRedirect(url, from, location string) {
+	c.muxr.Lock()
+	c.redirects[url] = location
+	c.muxr.Unlock()
+
+	c.addExternalRedirect(url, pageOf(from), location)
+}
+
+// externalRedirect returns the location the URL redirects to, if it redirects out of scope
+func (c *Crawler) externalRedirect(url string) (string, bool) {
+	c.muxr.Lock()
+	defer c.muxr.Unlock()
+
+	location, ok := c.redirects[url]
+	return location, ok
+}
+
+func (c *Crawler) addExternalRedirect(url, page, location string) {
+	c.addFinding(Finding{
+		Category: CategoryExternalRedirect,
+		Severity: SeverityWarning,
+		Url:      url,
+		Page:     page,
+		Message:  "Redirects out of scope to " + location,
+	})
+}
+
+// crawlSeeds schedules the seeds, each of them once, as long as the page budgets allow
+func (c *Crawler) crawlSeeds() {
+	for _, seed := range c.seeds {
+		if c.isBeingProcessed(seed) {
+			continue
+		}
+
+		if reason, skip := c.reservePage(seed); skip {
+			c.markUncrawled(seed, "<root>", 0, reason)
+			continue
+		}
 
-			c.wg.Done()
+		// Another crawler sharing the frontier may have scheduled the seed already
+		if !c.claim(seed) && c.shared != nil {
+			c.releasePage(seed)
+			continue
 		}
+
+		c.markBeingProcessed(seed, true)
+		c.addPending(seed, "<root>", 0)
+		c.enqueue(seed, "<root>", 0)
 	}
 }
 
-func (c *Crawler) collect(quit <-chan struct{}) {
+// dispatch passes the result to the workers, with host affinity to the worker chosen for its host
+func (c *Crawler) dispatch(r *result) {
+	if len(c.inboxes) == 0 {
+		c.results <- r
+		return
+	}
+
+	var host string
+	if u, err := neturl.Parse(r.url); err == nil {
+		host = u.Hostname()
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(host))
+
+	c.inboxes[h.Sum32()%uint32(len(c.inboxes))] <- r
+}
+
+func (c *Crawler) collect(quit <-chan struct{}, inbox <-chan *result) {
 	defer c.wgStop.Done()
 
+	t := c.newTask(taskWorker, func() {
+		c.wgStop.Add(1)
+		go c.collect(quit, inbox)
+	})
+	defer c.endTask(t)
+
 	for {
+		var r *result
+
 		select {
-		case result := <-c.results:
-			var (
-				title  string
-				links  []string
-				assets []*Asset
-				err    error
-			)
-
-			if title, links, assets, err = c.extractor.Extract(result.body); err == nil {
-				page := &Page{
-					Title:      title,
-					Url:        result.url,
-					LinkedFrom: make([]*Page, 0),
-					LinksTo:    make([]*Page, 0),
-					Assets:     assets,
+		case r = <-c.results:
+		case r = <-inbox:
+		case <-quit:
+			return
+		}
+
+		// The time the crawl is paused for must not count towards the stall timeout
+		c.waitIfPaused()
+
+		c.watch(t, r.url, nil)
+		c.process(t, r)
+		c.unwatch(t)
+
+		if c.retired(t) {
+			return
+		}
+	}
+}
+
+// process extracts the page from the result and schedules the links discovered on it, t being the worker doing so
+func (c *Crawler) process(t *task, result *result) {
+	c.waitIfPaused()
+
+	c.mucp.RLock()
+	defer c.mucp.RUnlock()
+
+	var (
+		title  string
+		links  []string
+		assets []*Asset
+		inline InlineSizes
+		err    error
+	)
+
+	if title, links, assets, inline, err = c.extract(result); err == nil {
+		url := result.pageURL()
+
+		c.recordVariants(url, links)
+		links = c.redirectedLinks(c.canonicalLinks(links))
+
+		robots := c.robotsOf(result)
+		nofollow := c.nofollowLinks(links, robots)
+
+		if c.respectNoindex && robots.NoIndex {
+			c.markNoindex(url)
+		}
+
+		if result.cached == nil {
+			c.checkPreloads(url, assets)
+			c.checkInline(url, inline)
+			c.checkTargets(url, result.body)
+
+			if c.integrity {
+				c.checkIntegrity(t, url, assets)
+			}
+
+			if c.csp {
+				c.checkCSP(url, assets, &result.response)
+			}
+		}
+
+		external := c.externalLinksOf(result)
+
+		c.recordThirdParty(url, assets, links)
+		c.recordThirdParty(url, nil, external)
+
+		hash := c.hashOf(result)
+
+		page := &Page{
+			Title:            title,
+			Url:              url,
+			Canonical:        c.canonicalOf(result),
+			RedirectedFrom:   result.redirectedFrom,
+			ContentHash:      hash,
+			SimHash:          c.simHashOf(result),
+			Change:           c.changeOf(result, hash),
+			ETag:             result.validators.ETag,
+			LastModified:     result.validators.LastModified,
+			Protocol:         result.response.protocol,
+			Encoding:         result.response.encoding,
+			CrawledAt:        time.Now(),
+			Depth:            result.depth,
+			Size:             c.sizeOf(result),
+			InlineScriptSize: inline.Scripts,
+			InlineStyleSize:  inline.Styles,
+			LinkedFrom:       make([]*Page, 0),
+			LinksTo:          make([]*Page, 0),
+			ExternalLinks:    external,
+			Assets:           c.applyAssetPolicies(t, assets, url),
+		}
+
+		// A page reached through several URLs, e.g. redirecting to it, is recorded and its links followed once
+		claimed := c.claimPage(url, result.from, page)
+		c.markRedirected(result.redirectedFrom, url)
+
+		if claimed {
+			if c.sink != nil {
+				if err := c.sink.Write(page); err != nil {
+					c.reportError(CrawlError{Url: url, Phase: PhaseOutput, Worker: t.id(), Err: err})
 				}
+			}
+
+			c.hookPage(page)
+			c.checkLinks(t, url, links, external)
 
-				c.markVisited(result.url, page)
-				c.addLinksTo(result.from, page)
-
-				for _, link := range links {
-					if c.hasVisited(link) {
-						c.addLinkedFrom(link, page)
-					} else {
-						if !c.isBeingProcessed(link) && c.shouldRetry(link) {
-							c.markBeingProcessed(link, true)
-
-							c.wg.Add(1)
-
-							go func(url, from string) {
-								c.crawl(url, from)
-							}(link, result.url)
-
-							if c.callback != nil {
-								go func(s string) {
-									c.callback(s)
-								}(link)
-							}
-						}
-					}
+			c.shuffle(links)
+
+			if c.followFeeds && result.cached == nil && !(c.respectNofollow && robots.NoFollow) {
+				c.crawlFeeds(result, url)
+			}
+
+			for _, link := range links {
+				if c.hasVisited(link) {
+					c.addLinkedFrom(link, page)
+				} else if location, ok := c.externalRedirect(link); ok {
+					c.addExternalRedirect(link, url, location)
+				} else if _, ok := nofollow[link]; ok {
+					c.markUncrawled(link, url, result.depth+1, SkipNofollow)
+					c.discover(DiscoveryEvent{Url: link, From: url, Depth: result.depth + 1, Via: ViaLink, Reason: SkipNofollow})
+				} else if !c.visited.Contains(link) {
+					c.schedule(link, url, result.depth+1, ViaLink)
 				}
-			} else {
-				c.errors <- err
 			}
+		}
+	} else {
+		c.reportError(CrawlError{Url: result.url, Phase: PhaseExtract, Worker: t.id(), Err: err})
+
+		c.addFinding(Finding{
+			Category: CategoryInvalidHTML,
+			Severity: SeverityWarning,
+			Url:      result.url,
+			Message:  err.Error(),
+		})
+	}
 
-			c.wg.Done()
-		case <-quit:
-			return
+	c.removePending(result.url)
+	c.settle(FrontierItem{Url: result.url, From: result.from, Depth: result.depth})
+}
+
+// schedule crawls the link discovered on the page from via the source, unless it must be skipped
+func (c *Crawler) schedule(link, from string, depth int, via DiscoverySource) {
+	reason, skip := c.skipReason(link, depth)
+	if !skip {
+		reason, skip = c.reservePage(link)
+	}
+
+	if skip {
+		c.markUncrawled(link, from, depth, reason)
+		c.discover(DiscoveryEvent{Url: link, From: from, Depth: depth, Via: via, Reason: reason})
+
+		if reason == SkipRobots {
+			c.recordBlocked(link, from, depth-1)
 		}
+
+		return
+	}
+
+	// Another worker may have scheduled the link meanwhile
+	if !c.claim(link) {
+		c.releasePage(link)
+		return
 	}
+
+	c.markBeingProcessed(link, true)
+	c.addPending(link, from, depth)
+	c.enqueue(link, from, depth)
+
+	c.discover(DiscoveryEvent{Url: link, From: from, Depth: depth, Via: via, Enqueued: true})
+}
+
+// discover passes the event to the callback, if there is one, without waiting for it
+func (c *Crawler) discover(e DiscoveryEvent) {
+	if c.callback != nil {
+		go c.callback(e)
+	}
+}
+
+func (c *Crawler) shuffle(links []string) {
+	if c.rand == nil {
+		return
+	}
+
+	c.murand.Lock()
+	c.rand.Shuffle(len(links), func(i, j int) {
+		links[i], links[j] = links[j], links[i]
+	})
+	c.murand.Unlock()
+}
+
+func (c *Crawler) withinDepth(depth int) bool {
+	return c.maxDepth == 0 || depth <= c.maxDepth
+}
+
+// stopping reports whether no new URLs should be scheduled, because the deadline passed, the context is done,
+// the byte budget is used up or the crawl was stopped
+func (c *Crawler) stopping() bool {
+	return c.isStopped() || (!c.deadline.IsZero() && time.Now().After(c.deadline)) || c.ctx.Err() != nil || c.bytesExhausted()
+}
+
+// throttle waits until the politeness of the crawler and the Crawl-delay of the URL's host allow requesting it.
+// The time waited does not count towards the stall timeout of the task t, if any.
+func (c *Crawler) throttle(t *task, url string) {
+	var delay time.Duration
+	if c.robots != nil {
+		delay = c.robots.delay(url)
+	}
+
+	resume := c.pauseTask(t)
+	c.limiter.wait(url, delay, c.ctx.Done())
+	resume()
+}
+
+func (c *Crawler) allowedByRobots(url string) bool {
+	return c.robots == nil || c.robots.allowed(url)
+}
+
+// fetchFile downloads a file of the site (e.g. robots.txt) on behalf of no page, copying it out of the downloader's buffer
+func (c *Crawler) fetchFile(url string) ([]byte, error) {
+	body, _, err := c.download(url, "<root>")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), body...), nil
+}
+
+// waitIfPaused blocks while the crawl is paused, unless it is stopped or its context is done
+func (c *Crawler) waitIfPaused() {
+	c.mupause.Lock()
+	gate := c.gate
+	c.mupause.Unlock()
+
+	select {
+	case <-gate:
+	case <-c.stop:
+	case <-c.ctx.Done():
+	}
+}
+
+func (c *Crawler) isStopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// reservePage counts the page of the URL about to be scheduled against the page budgets of the crawl and
+// of its host, returning the reason to skip the URL if either of them is used up
+func (c *Crawler) reservePage(url string) (SkipReason, bool) {
+	host := hostOfURL(url)
+
+	c.mub.Lock()
+	defer c.mub.Unlock()
+
+	if c.maxPages > 0 && c.pages >= c.maxPages {
+		return SkipMaxPages, true
+	}
+
+	if c.maxHostPages > 0 {
+		if c.hostPages[host] >= c.maxHostPages {
+			return SkipHostPages, true
+		}
+
+		c.hostPages[host]++
+	}
+
+	c.pages++
+	return "", false
+}
+
+// releasePage gives back the page reserved for a URL which is not crawled after all
+func (c *Crawler) releasePage(url string) {
+	host := hostOfURL(url)
+
+	c.mub.Lock()
+	c.pages--
+	if c.maxHostPages > 0 {
+		c.hostPages[host]--
+	}
+	c.mub.Unlock()
+}
+
+// hostOfURL returns the host of the canonical URL, empty if it is malformed
+func hostOfURL(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+func (c *Crawler) addBytes(n int) {
+	c.mub.Lock()
+	c.bytesRead += int64(n)
+	c.mub.Unlock()
+}
+
+func (c *Crawler) bytesExhausted() bool {
+	c.mub.Lock()
+	defer c.mub.Unlock()
+
+	return c.maxBytes > 0 && c.bytesRead >= c.maxBytes
 }
 
 func (c *Crawler) hasVisited(url string) bool {
@@ -280,12 +1710,6 @@ func (c *Crawler) addLinkedFrom(url string, page *Page) {
 	c.mus.Unlock()
 }
 
-func (c *Crawler) addLinksTo(url string, page *Page) {
-	c.mus.Lock()
-	c.sites[url].LinksTo = append(c.sites[url].LinksTo, page)
-	c.mus.Unlock()
-}
-
 func (c *Crawler) isBeingProcessed(url string) bool {
 	c.mup.RLock()
 	value := c.processed[url]
@@ -296,7 +1720,11 @@ func (c *Crawler) isBeingProcessed(url string) bool {
 
 func (c *Crawler) markBeingProcessed(url string, processed bool) {
 	c.mup.Lock()
-	c.processed[url] = processed
+	if processed {
+		c.processed[url] = true
+	} else {
+		delete(c.processed, url)
+	}
 	c.mup.Unlock()
 }
 
@@ -313,3 +1741,343 @@ func (c *Crawler) markRetry(url string) {
 	c.retries[url] = c.retries[url] + 1
 	c.mur.Unlock()
 }
+
+// traceFirstByte returns the context of the crawl traced to measure the time to first byte of the request
+// it is used for, which is stored in the returned duration. It is left zero if the slow pages are not reported.
+func (c *Crawler) traceFirstByte() (context.Context, *time.Duration) {
+	ttfb := new(time.Duration)
+	if c.slowThreshold == 0 {
+		return c.ctx, ttfb
+	}
+
+	start := time.Now()
+
+	return httptrace.WithClientTrace(c.ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			// Only the first response counts, not the ones after redirects or retries
+			if *ttfb == 0 {
+				*ttfb = time.Since(start)
+			}
+		},
+	}), ttfb
+}
+
+func (c *Crawler) checkSlow(url, from string, ttfb time.Duration) {
+	if c.slowThreshold == 0 || ttfb <= c.slowThreshold {
+		return
+	}
+
+	c.addFinding(Finding{
+		Category: CategorySlowPage,
+		Severity: SeverityInfo,
+		Url:      url,
+		Page:     pageOf(from),
+		Message:  fmt.Sprintf("Time to first byte %s exceeds %s", ttfb.Round(time.Millisecond), c.slowThreshold),
+	})
+}
+
+// download fetches the content conditionally if the URL is present in the baseline,
+// so that unchanged pages do not need to be downloaded again. The linking page is passed on as the referer.
+func (c *Crawler) download(url, from string) ([]byte, Validators, error) {
+	return c.downloadContext(c.ctx, url, from)
+}
+
+func (c *Crawler) downloadContext(ctx context.Context, url, from string) ([]byte, Validators, error) {
+	var v Validators
+	if c.baseline != nil {
+		if page, ok := c.baseline.Get(url); ok {
+			v = Validators{ETag: page.ETag, LastModified: page.LastModified}
+		}
+	}
+
+	switch d := c.downloader.(type) {
+	case ContextDownloader:
+		return d.DownloadContext(ctx, url, pageOf(from), v)
+	case RefererDownloader:
+		return d.DownloadFrom(url, pageOf(from), v)
+	case ConditionalDownloader:
+		return d.DownloadConditional(url, v)
+	default:
+		body, err := d.Download(url)
+		return body, Validators{}, err
+	}
+}
+
+func (c *Crawler) extract(r *result) (string, []string, []*Asset, InlineSizes, error) {
+	if r.cached != nil {
+		inline := InlineSizes{Scripts: r.cached.InlineScriptSize, Styles: r.cached.InlineStyleSize}
+		return r.cached.Title, c.baselineLinks[r.url], r.cached.Assets, inline, nil
+	}
+
+	extractor := c.extractorFor(r.url)
+
+	if e, ok := extractor.(InlineExtractor); ok {
+		return e.ExtractInline(r.body)
+	}
+
+	title, links, assets, err := extractor.Extract(r.body)
+	return title, links, assets, InlineSizes{}, err
+}
+
+// extractorFor returns the extractor of the host of the URL, the extractor of the root URL by default.
+// If subdomains are included, the extractor of a subdomain is created once its first page is crawled,
+// so that its relative links are resolved against it while the scope of the seed is kept.
+func (c *Crawler) extractorFor(url string) Extractor {
+	if c.extractorOptions == nil {
+		return c.extractor
+	}
+
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return c.extractor
+	}
+
+	c.muext.Lock()
+	defer c.muext.Unlock()
+
+	if e, ok := c.extractors[hostOf(u)]; ok {
+		return e
+	}
+
+	if !c.extractorOptions.IncludeSubdomains {
+		return c.extractor
+	}
+
+	for _, seed := range c.seeds {
+		s, err := neturl.Parse(seed)
+		if err != nil {
+			continue
+		}
+
+		if scope := scopeOf(s.Hostname()); strings.HasSuffix(hostnameOf(u.Hostname()), "."+scope) {
+			options := *c.extractorOptions
+			options.ScopeDomain = scope
+
+			if e, err := NewDefaultExtractorWithOptions(u.Scheme+"://"+u.Host+"/", &options); err == nil {
+				c.extractors[hostOf(u)] = e
+				return e
+			}
+		}
+	}
+
+	return c.extractor
+}
+
+// sizeOf returns the size of the page's HTML, taken from the baseline if it was not modified
+func (c *Crawler) sizeOf(r *result) int {
+	if r.cached != nil {
+		return r.cached.Size
+	}
+
+	return len(r.body)
+}
+
+// checkInline reports the page if its inline scripts or stylesheets exceed the threshold
+func (c *Crawler) checkInline(url string, inline InlineSizes) {
+	if c.inlineThreshold == 0 {
+		return
+	}
+
+	sizes := []struct {
+		kind string
+		size int
+	}{
+		{"scripts", inline.Scripts},
+		{"stylesheets", inline.Styles},
+	}
+
+	for _, s := range sizes {
+		if s.size > c.inlineThreshold {
+			c.addFinding(Finding{
+				Category: CategoryHeavyInline,
+				Severity: SeverityWarning,
+				Url:      url,
+				Message:  fmt.Sprintf("Inline %s of %d bytes exceed %d bytes", s.kind, s.size, c.inlineThreshold),
+			})
+		}
+	}
+}
+
+// checkTargets reports the links of the page opening in a new window without rel="noopener" or rel="noreferrer",
+// provided the extractor of the page implements TargetExtractor
+func (c *Crawler) checkTargets(url string, body []byte) {
+	e, ok := c.extractorFor(url).(TargetExtractor)
+	if !ok {
+		return
+	}
+
+	targets, err := e.ExtractUnsafeTargets(body)
+	if err != nil {
+		return
+	}
+
+	for _, target := range targets {
+		c.addFinding(Finding{
+			Category: CategoryUnsafeTarget,
+			Severity: SeverityWarning,
+			Url:      target,
+			Page:     url,
+			Message:  `Link opens in a new window (target="_blank") without rel="noopener" or rel="noreferrer"`,
+		})
+	}
+}
+
+// preloadDestinations are the destinations of preloaded resources which must be referenced by the page itself,
+// others (e.g. fonts) are usually referenced by its stylesheets or scripts
+var preloadDestinations = map[string]bool{
+	"script": true,
+	"style":  true,
+	"image":  true,
+}
+
+// checkPreloads reports the scripts, stylesheets and images preloaded by the page but not referenced by it,
+// which are downloaded in vain
+func (c *Crawler) checkPreloads(url string, assets []*Asset) {
+	referenced := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		if asset.Type != Hint {
+			referenced[asset.Url] = true
+		}
+	}
+
+	for _, asset := range assets {
+		if asset.Type != Hint || referenced[asset.Url] {
+			continue
+		}
+
+		if (asset.Rel == "preload" && preloadDestinations[asset.As]) || asset.Rel == "modulepreload" {
+			c.addFinding(Finding{
+				Category: CategoryUnusedPreload,
+				Severity: SeverityWarning,
+				Url:      asset.Url,
+				Page:     url,
+				Message:  fmt.Sprintf("Resource hinted with %s is not referenced by the page", asset.Rel),
+			})
+		}
+	}
+}
+
+// admitAsset waits until the asset may be fetched on behalf of the page, the same way its pages are:
+// robots.txt of the asset's host must allow it, the requests to the host are spaced by the politeness delay
+// and a slot of the host is taken if their concurrency is limited, which must be released once the asset is fetched.
+// Assets disallowed by robots.txt are reported as blocked-asset findings, and none are fetched once the crawl is done.
+// The stall timeout of the task t fetching the asset, if any, restarts for the asset once it is admitted.
+func (c *Crawler) admitAsset(t *task, asset *Asset, from string) bool {
+	if !c.allowedByRobots(asset.Url) {
+		c.addFinding(Finding{
+			Category: CategoryBlockedAsset,
+			Severity: SeverityInfo,
+			Url:      asset.Url,
+			Page:     from,
+			Message:  "Resource disallowed by robots.txt is not fetched",
+		})
+
+		return false
+	}
+
+	c.throttle(t, asset.Url)
+
+	resume := c.pauseTask(t)
+	admitted := c.ctx.Err() == nil && (c.slots == nil || c.slots.acquire(hostOfURL(asset.Url), c.ctx.Done()))
+	resume()
+
+	c.nextStep(t)
+
+	return admitted
+}
+
+// acquireSlot takes a slot of the host of the URL for its download, or parks the URL until a slot is released
+func (c *Crawler) acquireSlot(item FrontierItem) bool {
+	return c.slots == nil || c.slots.tryAcquire(hostOfURL(item.Url), item)
+}
+
+// releaseSlot gives back the slot of the host of the URL, pushing the URL parked first for the host to the frontier
+func (c *Crawler) releaseSlot(url string) {
+	if c.slots == nil {
+		return
+	}
+
+	if item, ok := c.slots.release(hostOfURL(url)); ok {
+		c.push(item)
+	}
+}
+
+// applyAssetPolicies handles the assets of the page according to their policies, fetching them on behalf of the page
+// with the task t.
+func (c *Crawler) applyAssetPolicies(t *task, assets []*Asset, from string) []*Asset {
+	if len(c.assetPolicies) == 0 {
+		return assets
+	}
+
+	kept := make([]*Asset, 0, len(assets))
+
+	for _, asset := range assets {
+		policy := c.assetPolicies[asset.Type]
+
+		// Once the byte budget is used up, the assets to download are only recorded
+		if policy == PolicyDownload && c.bytesExhausted() {
+			kept = append(kept, asset)
+			continue
+		}
+
+		if (policy == PolicyVerify || policy == PolicyDownload) && !c.admitAsset(t, asset, from) {
+			kept = append(kept, asset)
+			continue
+		}
+
+		response := new(responseInfo)
+		ctx := withResponseInfo(c.ctx, response)
+
+		var (
+			fetched = true
+			err     error
+		)
+
+		switch policy {
+		case PolicyIgnore:
+			continue
+		case PolicyVerify:
+			switch v := c.downloader.(type) {
+			case ContextDownloader:
+				err = v.VerifyContext(ctx, asset.Url, from)
+			case RefererVerifier:
+				err = v.VerifyFrom(asset.Url, from)
+			case Verifier:
+				err = v.Verify(asset.Url)
+			default:
+				fetched = false
+			}
+		case PolicyDownload:
+			var body []byte
+			if body, _, err = c.downloadContext(ctx, asset.Url, from); err == nil {
+				asset.Size = len(body)
+				c.addBytes(len(body))
+			}
+		default:
+			fetched = false
+		}
+
+		if fetched {
+			recordAsset(asset, response)
+
+			if err == nil {
+				asset.Verified = true
+			} else {
+				c.assetFailed(asset, from, err)
+			}
+		}
+
+		if policy == PolicyVerify || policy == PolicyDownload {
+			c.releaseSlot(asset.Url)
+		}
+
+		if asset.Verified {
+			c.auditCaching(asset.Url, true, response)
+			c.checkCacheLifetime(asset.Url, from, asset, response)
+		}
+
+		kept = append(kept, asset)
+	}
+
+	return kept
+}
@@ -0,0 +1,1445 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lineExtractor treats every line of the body as a link, which keeps the crawler tests independent of HTML parsing
+type lineExtractor struct{}
+
+func (lineExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	links := make([]string, 0)
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			links = append(links, line)
+		}
+	}
+
+	return "", links, []*Asset{}, nil
+}
+
+// unpooledDownloader reads every response to its own slice, so that the bodies of pages processed in parallel
+// are never backed by the same pooled buffer
+type unpooledDownloader struct{}
+
+func (unpooledDownloader) Download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrBadResponse
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func TestCrawlerSendsRefererOfLinkingPage(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		referers = make(map[string]string)
+		server   *httptest.Server
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		referers[r.URL.Path] = r.Header.Get("Referer")
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			w.Write([]byte(server.URL + "/a\n"))
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:     2,
+		MaxRetries:     1,
+		Extractor:      lineExtractor{},
+		RequestHeaders: &RequestHeaders{RefererPolicy: RefererFull},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errors := c.Crawl()
+	go func() {
+		for range errors {
+		}
+	}()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if r, ok := referers["/"]; !ok || r != "" {
+		t.Errorf("Unexpected Referer of the root page: %q\n", r)
+	}
+
+	if r := referers["/a"]; r != server.URL+"/" {
+		t.Errorf("Unexpected Referer: %q\n", r)
+	}
+}
+
+func TestCrawlerCanBeResetAndCrawledAgain(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		server  = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+	)
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 2,
+		MaxRetries: 1,
+		Extractor:  lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, _ := c.Crawl()
+	release <- struct{}{}
+	<-done
+
+	first := c.GetSiteMap()
+	if first.Len() != 1 {
+		t.Errorf("Unexpected sitemap length: %d\n", first.Len())
+	}
+
+	done, errors := c.Crawl()
+	<-done
+	if e := <-errors; e.Err != ErrAlreadyCrawled {
+		t.Errorf("Crawling twice does not fail: %v\n", e)
+	}
+
+	if err = c.Reset(); err != nil {
+		t.Fatalf("Reset fails with error: %s\n", err.Error())
+	}
+
+	done, _ = c.Crawl()
+	if err = c.Reset(); err != ErrCrawlRunning {
+		t.Errorf("Reset of running crawl does not fail: %v\n", err)
+	}
+
+	release <- struct{}{}
+	<-done
+
+	if c.GetSiteMap().Len() != 1 || first.Len() != 1 {
+		t.Errorf("Unexpected sitemap length after reset: %d\n", c.GetSiteMap().Len())
+	}
+}
+
+func TestCrawlerClosesChannelsOnCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 2,
+		MaxRetries: 2,
+		Extractor:  lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errors := c.Crawl()
+
+	count := 0
+	for range errors {
+		count++
+	}
+
+	if count != 3 {
+		t.Errorf("Unexpected number of errors: %d\n", count)
+	}
+
+	if _, ok := <-done; ok {
+		t.Errorf("Done channel is not closed\n")
+	}
+}
+
+func TestCrawlerReportsDiscoveryEvents(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n", server.URL)
+		case "/a":
+			fmt.Fprintf(w, "%s/\n%s/a/deep\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	events := make(chan DiscoveryEvent, 10)
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		MaxDepth:     1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		Callback: func(e DiscoveryEvent) {
+			events <- e
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	// The events are passed to the callback asynchronously
+	discovered := make(map[string]DiscoveryEvent)
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-events:
+			discovered[e.Url] = e
+		case <-time.After(time.Second):
+			t.Fatalf("Missing discovery events: %v\n", discovered)
+		}
+	}
+
+	expected := map[string]DiscoveryEvent{
+		server.URL + "/a":      {Url: server.URL + "/a", From: server.URL + "/", Depth: 1, Via: ViaLink, Enqueued: true},
+		server.URL + "/a/deep": {Url: server.URL + "/a/deep", From: server.URL + "/a", Depth: 2, Via: ViaLink, Reason: SkipMaxDepth},
+	}
+
+	if !reflect.DeepEqual(discovered, expected) {
+		t.Errorf("Unexpected discovery events: %v\n", discovered)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("Unexpected discovery event: %v\n", <-events)
+	}
+}
+
+func TestCrawlerPassesErrorsToCallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var (
+		mu       sync.Mutex
+		received []CrawlError
+	)
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 2,
+		MaxRetries: 1,
+		Extractor:  lineExtractor{},
+		OnError: func(e CrawlError) {
+			mu.Lock()
+			received = append(received, e)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errors := c.Crawl()
+	<-done
+
+	if _, ok := <-errors; ok {
+		t.Errorf("Error sent on the channel despite the callback\n")
+	}
+
+	if len(received) != 2 || received[0].Url != server.URL+"/" || received[0].Err != ErrBadResponse {
+		t.Fatalf("Unexpected errors: %v\n", received)
+	}
+
+	for i, e := range received {
+		if e.Phase != PhaseDownload || e.Attempt != i+1 || e.Retry != (i == 0) {
+			t.Errorf("Unexpected error: %s\n", e.Error())
+		}
+	}
+}
+
+func TestCrawlerStopsWhenContextIsCancelled(t *testing.T) {
+	var (
+		requested = make(chan struct{})
+		server    = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(requested)
+			<-r.Context().Done()
+		}))
+	)
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 2,
+		MaxRetries: 3,
+		Extractor:  lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done, errs := c.CrawlWithContext(ctx)
+
+	<-requested
+	cancel()
+
+	count := 0
+	for err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error: %s\n", err.Error())
+		}
+		count++
+	}
+	<-done
+
+	if count != 1 {
+		t.Errorf("Cancelled request retried: %d\n", count)
+	}
+}
+
+func TestCrawlerRespectsMaxDepth(t *testing.T) {
+	var server *httptest.Server
+
+	// Every page links to the page one level deeper, i.e. /d1, /d1/d2, ...
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(server.URL + strings.TrimSuffix(r.URL.Path, "/") + "/d\n"))
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 2,
+		MaxRetries: 1,
+		MaxDepth:   2,
+		Extractor:  lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	s := c.GetSiteMap()
+	if s.Len() != 3 {
+		t.Errorf("Unexpected sitemap length: %d\n", s.Len())
+	}
+
+	if p, ok := s.Get(server.URL + "/d/d"); !ok || p.Depth != 2 {
+		t.Errorf("Deepest page not crawled at expected depth\n")
+	}
+}
+
+func TestCrawlerWithHostAffinityCompletes(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 20; i++ {
+				fmt.Fprintf(w, "%s/%d\n", server.URL, i)
+			}
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   4,
+		MaxRetries:   1,
+		HostAffinity: true,
+		Extractor:    lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if c.GetSiteMap().Len() != 21 {
+		t.Errorf("Unexpected sitemap length: %d\n", c.GetSiteMap().Len())
+	}
+}
+
+func TestCrawlerWithMaxPagesAndMaxTotalBytes(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 20; i++ {
+				fmt.Fprintf(w, "%s/%d\n", server.URL, i)
+			}
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		options *Options
+		max     int
+	}{
+		{&Options{MaxPages: 5}, 5},
+		{&Options{MaxPages: 1}, 1},
+		{&Options{MaxTotalBytes: 1}, 1},
+		{&Options{MaxBytes: 1}, 1},
+	}
+
+	for _, tc := range cases {
+		tc.options.MaxWorkers, tc.options.MaxRetries, tc.options.Extractor = 2, 1, lineExtractor{}
+
+		c, err := NewCrawlerWithOptions(server.URL+"/", tc.options)
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, errs := c.Crawl()
+		for range errs {
+		}
+		<-done
+
+		if l := c.GetSiteMap().Len(); l > tc.max || l == 0 {
+			t.Errorf("Unexpected sitemap length: %d, expected at most %d\n", l, tc.max)
+		}
+	}
+}
+
+func TestCrawlerStopsDownloadingAssetsPastMaxTotalBytes(t *testing.T) {
+	var (
+		server *httptest.Server
+		mu     sync.Mutex
+		assets int
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "image %s/a.png\nimage %s/b.png\n", server.URL, server.URL)
+		} else {
+			mu.Lock()
+			assets++
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:    1,
+		MaxRetries:    1,
+		MaxTotalBytes: 1,
+		Extractor:     assetExtractor{},
+		AssetPolicies: map[AssetType]AssetPolicy{Image: PolicyDownload},
+		IgnoreRobots:  true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	page, ok := c.GetSiteMap().Get(server.URL + "/")
+	if !ok || len(page.Assets) != 2 {
+		t.Fatalf("Unexpected page: %v\n", page)
+	}
+
+	for _, asset := range page.Assets {
+		if asset.Verified {
+			t.Errorf("Unexpected download of %s\n", asset.Url)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assets != 0 {
+		t.Errorf("Unexpected asset requests: %d\n", assets)
+	}
+}
+
+func TestCrawlerRecordsVerifiedAssets(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "image %s/logo.png\nimage %s/missing.png\n", server.URL, server.URL)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", "2048")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:    1,
+		MaxRetries:    1,
+		Extractor:     assetExtractor{},
+		AssetPolicies: map[AssetType]AssetPolicy{Image: PolicyVerify},
+		IgnoreRobots:  true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	page, ok := c.GetSiteMap().Get(server.URL + "/")
+	if !ok || len(page.Assets) != 2 {
+		t.Fatalf("Unexpected page: %v\n", page)
+	}
+
+	expected := []Asset{
+		{Type: Image, Url: server.URL + "/logo.png", Size: 2048, Verified: true, Status: http.StatusOK, ContentType: "image/png"},
+		{Type: Image, Url: server.URL + "/missing.png", Status: http.StatusNotFound},
+	}
+
+	for i, e := range expected {
+		if *page.Assets[i] != e {
+			t.Errorf("Unexpected asset: %v\n", *page.Assets[i])
+		}
+	}
+
+	broken := c.GetFindings().ByCategory(CategoryBrokenAsset)
+	if len(broken) != 1 || broken[0].Url != server.URL+"/missing.png" || !strings.HasSuffix(broken[0].Message, "status 404") {
+		t.Errorf("Unexpected broken assets: %v\n", broken)
+	}
+}
+
+func TestCrawlerWithMaxPagesPerHost(t *testing.T) {
+	var servers []*httptest.Server
+
+	for i := 0; i < 2; i++ {
+		var server *httptest.Server
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				for i := 0; i < 20; i++ {
+					fmt.Fprintf(w, "%s/%d\n", server.URL, i)
+				}
+			}
+		}))
+		defer server.Close()
+
+		servers = append(servers, server)
+	}
+
+	c, err := NewCrawlerWithSeeds([]string{servers[0].URL + "/", servers[1].URL + "/"}, &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Downloader:      unpooledDownloader{},
+		Extractor:       lineExtractor{},
+		IgnoreRobots:    true,
+		MaxPages:        20,
+		MaxPagesPerHost: 5,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	for _, server := range servers {
+		n := 0
+		for _, page := range c.GetSiteMap().Pages() {
+			if strings.HasPrefix(page.Url, server.URL+"/") {
+				n++
+			}
+		}
+
+		if n != 5 {
+			t.Errorf("Unexpected number of pages of %s: %d\n", server.URL, n)
+		}
+	}
+
+	for _, u := range c.GetUncrawled() {
+		if u.Reason != SkipHostPages {
+			t.Errorf("Unexpected reason %s for uncrawled %s\n", u.Reason, u.Url)
+		}
+	}
+}
+
+func TestCrawlerWithMaxPagesSkipsSeeds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithSeeds([]string{server.URL + "/a", server.URL + "/b"}, &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		MaxPages:     1,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 1 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+
+	uncrawled := c.GetUncrawled()
+	if len(uncrawled) != 1 || uncrawled[0].Url != server.URL+"/b" || uncrawled[0].Reason != SkipMaxPages {
+		t.Errorf("Unexpected uncrawled URLs: %v\n", uncrawled)
+	}
+}
+
+func TestCrawlerWithMaxConcurrencyPerHost(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight = make(map[string]int)
+		peak     = make(map[string]int)
+		servers  []*httptest.Server
+	)
+
+	for i := 0; i < 2; i++ {
+		var server *httptest.Server
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight[r.Host]++
+			if inFlight[r.Host] > peak[r.Host] {
+				peak[r.Host] = inFlight[r.Host]
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			if r.URL.Path == "/" {
+				for i := 0; i < 10; i++ {
+					fmt.Fprintf(w, "%s/%d\n", server.URL, i)
+				}
+			}
+
+			mu.Lock()
+			inFlight[r.Host]--
+			mu.Unlock()
+		}))
+		defer server.Close()
+
+		servers = append(servers, server)
+	}
+
+	c, err := NewCrawlerWithSeeds([]string{servers[0].URL + "/", servers[1].URL + "/"}, &Options{
+		MaxWorkers:            2,
+		MaxFetchers:           8,
+		MaxRetries:            1,
+		Downloader:            unpooledDownloader{},
+		Extractor:             lineExtractor{},
+		IgnoreRobots:          true,
+		MaxConcurrencyPerHost: 2,
+		CheckInvariants:       true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 22 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+
+	for host, n := range peak {
+		if n > 2 {
+			t.Errorf("Unexpected number of concurrent requests to %s: %d\n", host, n)
+		}
+	}
+}
+
+func TestCrawlerHonorsRobots(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/public\n%s/private\n", server.URL, server.URL)
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer server.Close()
+
+	for _, ignore := range []bool{false, true} {
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:   2,
+			MaxRetries:   1,
+			Extractor:    lineExtractor{},
+			IgnoreRobots: ignore,
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, errs := c.Crawl()
+		for range errs {
+		}
+		<-done
+
+		if _, ok := c.GetSiteMap().Get(server.URL + "/private"); ok != ignore {
+			t.Errorf("Unexpected presence of disallowed page with IgnoreRobots %t: %t\n", ignore, ok)
+		}
+
+		if _, ok := c.GetSiteMap().Get(server.URL + "/public"); !ok {
+			t.Errorf("Allowed page not crawled\n")
+		}
+	}
+}
+
+// assetExtractor treats the lines prefixed with "image " as images and the others as links
+type assetExtractor struct {
+	lineExtractor
+}
+
+func (e assetExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	title, lines, assets, err := e.lineExtractor.Extract(body)
+
+	links := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(line, "image ") {
+			assets = append(assets, &Asset{Type: Image, Url: strings.TrimPrefix(line, "image ")})
+		} else {
+			links = append(links, line)
+		}
+	}
+
+	return title, links, assets, err
+}
+
+func TestCrawlerFetchesAssetsPolitely(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests = make(map[string]time.Time)
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path] = time.Now()
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "image %s/a.png\nimage %s/private/b.png\nimage %s/c.png\n", server.URL, server.URL, server.URL)
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Extractor:       assetExtractor{},
+		AssetPolicies:   map[AssetType]AssetPolicy{Image: PolicyDownload},
+		PolitenessDelay: delay,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := requests["/private/b.png"]; ok {
+		t.Errorf("Asset disallowed by robots.txt fetched\n")
+	}
+
+	if findings := c.GetFindings(); len(findings) != 1 || findings[0].Category != CategoryBlockedAsset {
+		t.Errorf("Unexpected findings: %v\n", findings)
+	}
+
+	times := []time.Time{requests["/"], requests["/a.png"], requests["/c.png"]}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d < delay-5*time.Millisecond {
+			t.Errorf("Requests to the host only %s apart\n", d)
+		}
+	}
+}
+
+func TestCrawlerReportsSlowPages(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/fast\n%s/slow\n", server.URL, server.URL)
+		} else if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:    2,
+		MaxRetries:    1,
+		Extractor:     lineExtractor{},
+		SlowThreshold: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	slow := c.GetFindings().ByCategory(CategorySlowPage)
+	if len(slow) != 1 || slow[0].Url != server.URL+"/slow" || slow[0].Page != server.URL+"/" {
+		t.Errorf("Unexpected slow page findings: %v\n", slow)
+	}
+
+	if _, ok := c.GetSiteMap().Get(server.URL + "/slow"); !ok {
+		t.Errorf("Slow page not crawled\n")
+	}
+}
+
+func TestCrawlerStopKeepsPartialResults(t *testing.T) {
+	var (
+		server    *httptest.Server
+		requested = make(chan struct{}, 3)
+		release   = make(chan struct{})
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/1\n%s/2\n%s/3\n", server.URL, server.URL, server.URL)
+		case "/1", "/2", "/3":
+			requested <- struct{}{}
+			<-release
+			fmt.Fprintf(w, "%s%s/child\n", server.URL, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	go func() {
+		for range errs {
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		<-requested
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		c.Stop()
+		close(stopped)
+	}()
+
+	// Let Stop take effect before the requests in flight complete
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	<-stopped
+	if _, ok := <-done; ok {
+		t.Errorf("Done channel is not closed\n")
+	}
+
+	if l := c.GetSiteMap().Len(); l != 4 {
+		t.Errorf("Unexpected sitemap length after stop: %d\n", l)
+	}
+
+	c.Stop()
+}
+
+func TestCrawlerSiteMapCanBeReadWhileCrawling(t *testing.T) {
+	var (
+		server    *httptest.Server
+		requested = make(chan struct{}, 1)
+		release   = make(chan struct{})
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/1\n%s/2\n", server.URL, server.URL)
+		case "/2":
+			requested <- struct{}{}
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	<-requested
+
+	s := c.GetSiteMap()
+	if _, ok := s.Get("<root>"); ok {
+		t.Errorf("Pseudo-page of the root in the sitemap\n")
+	}
+
+	l := s.Len()
+
+	close(release)
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	// The sitemap taken during the crawl is not affected by the pages crawled afterwards
+	if s.Len() != l || l > 2 {
+		t.Errorf("Unexpected sitemap length during the crawl: %d, %d now\n", l, s.Len())
+	}
+
+	if l := c.GetSiteMap().Len(); l != 3 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+}
+
+func TestCrawlerRecordsUncrawledURLs(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/private\n", server.URL, server.URL)
+		case "/a":
+			fmt.Fprintf(w, "%s/a/b\n%s/\n", server.URL, server.URL)
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 2,
+		MaxRetries: 1,
+		MaxDepth:   1,
+		Downloader: unpooledDownloader{},
+		Extractor:  lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	expected := []UncrawledURL{
+		{Url: server.URL + "/a/b", From: server.URL + "/a", Depth: 2, Reason: SkipMaxDepth},
+		{Url: server.URL + "/private", From: server.URL + "/", Depth: 1, Reason: SkipRobots},
+	}
+
+	if uncrawled := c.GetUncrawled(); !reflect.DeepEqual(uncrawled, expected) {
+		t.Errorf("Unexpected uncrawled URLs: %v\n", uncrawled)
+	}
+}
+
+func TestCrawlerReportsUnusedPreloads(t *testing.T) {
+	c := &Crawler{}
+
+	c.checkPreloads("http://example.com/", []*Asset{
+		{Url: "http://example.com/used.js", Type: Hint, Rel: "preload", As: "script"},
+		{Url: "http://example.com/unused.js", Type: Hint, Rel: "preload", As: "script"},
+		{Url: "http://example.com/unused.mjs", Type: Hint, Rel: "modulepreload"},
+		{Url: "http://example.com/font.woff2", Type: Hint, Rel: "preload", As: "font"},
+		{Url: "http://example.com/next.html", Type: Hint, Rel: "prefetch"},
+		{Url: "http://example.com/used.js", Type: Script},
+	})
+
+	unused := c.GetFindings().ByCategory(CategoryUnusedPreload)
+	if len(unused) != 2 || unused[0].Url != "http://example.com/unused.js" || unused[1].Url != "http://example.com/unused.mjs" {
+		t.Errorf("Unexpected unused preload findings: %v\n", unused)
+	}
+}
+
+func TestCrawlerCanBePausedAndResumed(t *testing.T) {
+	var (
+		server  *httptest.Server
+		root    = make(chan struct{})
+		release = make(chan struct{})
+
+		mu        sync.Mutex
+		requested []string
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			close(root)
+			<-release
+			fmt.Fprintf(w, "%s/1\n%s/2\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+
+	// The root page is downloaded while paused, but not processed
+	<-root
+	c.Pause()
+	close(release)
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	if len(requested) != 1 {
+		t.Errorf("Requests sent while paused: %v\n", requested)
+	}
+	mu.Unlock()
+
+	c.Resume()
+	c.Resume()
+
+	for range errs {
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 3 {
+		t.Errorf("Unexpected sitemap length after resume: %d\n", l)
+	}
+}
+
+func TestCrawlerReportsHeavyInlineCode(t *testing.T) {
+	c := &Crawler{inlineThreshold: 10}
+
+	c.checkInline("http://example.com/", InlineSizes{Scripts: 11, Styles: 10})
+
+	heavy := c.GetFindings().ByCategory(CategoryHeavyInline)
+	if len(heavy) != 1 || heavy[0].Message != "Inline scripts of 11 bytes exceed 10 bytes" {
+		t.Errorf("Unexpected heavy inline findings: %v\n", heavy)
+	}
+}
+
+func TestCrawlerMergesSeedsWithinTheirDomains(t *testing.T) {
+	var first, second *httptest.Server
+
+	first = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, `<a href="/a">A</a><a href="%s/b">B</a>`, second.URL)
+		}
+	}))
+	defer first.Close()
+
+	second = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/c">C</a>`)
+		}
+	}))
+	defer second.Close()
+
+	c, err := NewCrawlerWithSeeds([]string{first.URL + "/", second.URL + "/", first.URL + "/"}, &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	if c.extractorFor(second.URL+"/c") == c.extractorFor(first.URL+"/a") {
+		t.Errorf("Seeds share the extractor\n")
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if _, ok := c.GetSiteMap().Get(second.URL + "/"); !ok {
+		t.Errorf("Second seed not crawled\n")
+	}
+
+	if _, err := NewCrawlerWithSeeds(nil, &Options{}); err != ErrNoArgument {
+		t.Errorf("Crawler without seeds does not fail: %v\n", err)
+	}
+}
+
+func TestCrawlerCreatesExtractorsOfSubdomains(t *testing.T) {
+	c, err := NewCrawlerWithOptions("http://www.example.com/", &Options{IncludeSubdomains: true})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	e := c.extractorFor("http://blog.example.com/post")
+	if e == c.extractor || e != c.extractorFor("http://blog.example.com/other") {
+		t.Errorf("Extractor of the subdomain not created once\n")
+	}
+
+	if d := e.(*defaultExtractor); d.domain.Host != "blog.example.com" || d.scope != "example.com" {
+		t.Errorf("Unexpected extractor of the subdomain: %s, %s\n", d.domain.Host, d.scope)
+	}
+
+	if c.extractorFor("http://other.com/") != c.extractor {
+		t.Errorf("Extractor created outside of the scope\n")
+	}
+}
+
+func TestCrawlerFiltersURLs(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs/" {
+			fmt.Fprintf(w, "%s/docs/intro\n%s/docs/logout\n%s/blog\n", server.URL, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/docs/", &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Extractor:       lineExtractor{},
+		IncludePatterns: []string{"/docs/*"},
+		ExcludePatterns: []string{"*/logout"},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if _, ok := c.GetSiteMap().Get(server.URL + "/docs/intro"); !ok {
+		t.Errorf("Included page not crawled\n")
+	}
+
+	for _, path := range []string{"/docs/logout", "/blog"} {
+		if _, ok := c.GetSiteMap().Get(server.URL + path); ok {
+			t.Errorf("Filtered page crawled: %s\n", path)
+		}
+	}
+
+	if uncrawled := c.GetUncrawled(); len(uncrawled) != 2 || uncrawled[0].Reason != SkipFiltered {
+		t.Errorf("Unexpected uncrawled URLs: %v\n", uncrawled)
+	}
+}
+
+type targetExtractor struct {
+	lineExtractor
+}
+
+func (targetExtractor) ExtractUnsafeTargets(body []byte) ([]string, error) {
+	return []string{"https://other.com/"}, nil
+}
+
+func TestCrawlerReportsUnsafeTargets(t *testing.T) {
+	c := &Crawler{extractor: targetExtractor{}}
+
+	c.checkTargets("http://example.com/", nil)
+
+	unsafe := c.GetFindings().ByCategory(CategoryUnsafeTarget)
+	if len(unsafe) != 1 || unsafe[0].Url != "https://other.com/" || unsafe[0].Page != "http://example.com/" {
+		t.Errorf("Unexpected unsafe target findings: %v\n", unsafe)
+	}
+
+	c = &Crawler{extractor: lineExtractor{}}
+
+	c.checkTargets("http://example.com/", nil)
+
+	if unsafe := c.GetFindings().ByCategory(CategoryUnsafeTarget); len(unsafe) != 0 {
+		t.Errorf("Unsafe targets reported without TargetExtractor: %v\n", unsafe)
+	}
+}
+
+func TestCrawlerBoundsConcurrentDownloads(t *testing.T) {
+	var (
+		server            *httptest.Server
+		mu                sync.Mutex
+		inFlight, maximum int
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 200; i++ {
+				fmt.Fprintf(w, "%s/%d\n", server.URL, i)
+			}
+
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		if inFlight > maximum {
+			maximum = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxFetchers:  3,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 201 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+
+	if maximum > 3 {
+		t.Errorf("Too many concurrent downloads: %d\n", maximum)
+	}
+}
+
+func TestCrawlerReportsExternalRedirects(t *testing.T) {
+	var (
+		server, external *httptest.Server
+		mu               sync.Mutex
+		requests         int
+	)
+
+	external = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("External redirect followed: %s\n", r.URL.Path)
+	}))
+	defer external.Close()
+
+	// The external server is out of scope under another host name
+	location := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/out\n", server.URL, server.URL)
+		case "/a":
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprintf(w, "%s/out\n", server.URL)
+		case "/out":
+			mu.Lock()
+			requests++
+			mu.Unlock()
+
+			http.Redirect(w, r, location+"/landing", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   3,
+		RetryBackoff: RetryBackoff{},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if requests != 1 {
+		t.Errorf("External redirect requested %d times\n", requests)
+	}
+
+	redirects := c.GetFindings().ByCategory(CategoryExternalRedirect)
+	if len(redirects) != 2 {
+		t.Fatalf("Unexpected external redirect findings: %v\n", redirects)
+	}
+
+	pages := map[string]bool{redirects[0].Page: true, redirects[1].Page: true}
+	for _, page := range []string{server.URL + "/", server.URL + "/a"} {
+		if !pages[page] || redirects[0].Url != server.URL+"/out" {
+			t.Errorf("External redirect not reported on %s: %v\n", page, redirects)
+		}
+	}
+
+	if broken := c.GetFindings().ByCategory(CategoryBrokenPage); len(broken) != 0 {
+		t.Errorf("External redirect reported as broken: %v\n", broken)
+	}
+}
+
+func TestCrawlerRecordsRedirectChains(t *testing.T) {
+	var server, external *httptest.Server
+
+	external = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer external.Close()
+
+	// The external server is out of scope under another host name
+	location := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/old\n%s/new\n%s/loop\n%s/out\n", server.URL, server.URL, server.URL, server.URL)
+		case "/old":
+			http.Redirect(w, r, "/mid", http.StatusMovedPermanently)
+		case "/mid":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/new":
+			fmt.Fprintf(w, "%s/old\n%s/deep\n", server.URL, server.URL)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/out":
+			http.Redirect(w, r, location+"/landing", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, followExternal := range []bool{false, true} {
+		var (
+			mu         sync.Mutex
+			violations []error
+		)
+
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:        2,
+			MaxRetries:        3,
+			RetryBackoff:      RetryBackoff{},
+			Extractor:         lineExtractor{},
+			IgnoreRobots:      true,
+			MaxRedirects:      3,
+			ExternalRedirects: followExternal,
+			CheckInvariants:   true,
+			OnError: func(err CrawlError) {
+				mu.Lock()
+				violations = append(violations, err)
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, _ := c.Crawl()
+		<-done
+
+		// The only error is the redirect loop, which is not retried
+		if len(violations) != 1 || !errors.Is(violations[0], ErrRedirects) || violations[0].(CrawlError).Retry {
+			t.Errorf("Unexpected errors: %v\n", violations)
+		}
+
+		sitemap := c.GetSiteMap()
+
+		urls := make([]string, 0, sitemap.Len())
+		for _, page := range sitemap.Pages() {
+			urls = append(urls, page.Url)
+		}
+
+		expected := []string{server.URL + "/", server.URL + "/deep", server.URL + "/new"}
+		if followExternal {
+			expected = append(expected, location+"/landing")
+		}
+
+		if !reflect.DeepEqual(urls, expected) {
+			t.Errorf("Unexpected pages: %v\n", urls)
+		}
+
+		page, _ := sitemap.Get(server.URL + "/new")
+		if page == nil || !reflect.DeepEqual(page.RedirectedFrom, []string{server.URL + "/old", server.URL + "/mid"}) {
+			t.Errorf("Redirect chain not recorded: %v\n", page)
+		}
+
+		if links := sitemap.outLinks()[server.URL+"/"]; len(links) != len(expected)-2 {
+			t.Errorf("Unexpected links of the root: %v\n", links)
+		}
+
+		if broken := c.GetFindings().ByCategory(CategoryBrokenPage); len(broken) != 1 || broken[0].Url != server.URL+"/loop" {
+			t.Errorf("Redirect loop not reported: %v\n", broken)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	neturl "net/url"
+	"path"
+	"strings"
+)
+
+// contentSecurityPolicy maps the fetch directives of a Content-Security-Policy, e.g. script-src, to their sources.
+// The header may hold several policies separated by commas, an asset must be allowed by all of them.
+type contentSecurityPolicy []map[string][]string
+
+// parseCSP parses the policies of the Content-Security-Policy header. The directive names are case-insensitive
+// and only the first of the repeated ones counts.
+func parseCSP(header string) contentSecurityPolicy {
+	var policies contentSecurityPolicy
+
+	for _, p := range strings.Split(header, ",") {
+		policy := make(map[string][]string)
+
+		for _, d := range strings.Split(p, ";") {
+			fields := strings.Fields(d)
+			if len(fields) == 0 {
+				continue
+			}
+
+			name := strings.ToLower(fields[0])
+			if _, ok := policy[name]; !ok {
+				policy[name] = fields[1:]
+			}
+		}
+
+		if len(policy) > 0 {
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies
+}
+
+// cspDirectives returns the fetch directive governing the asset followed by the ones it falls back to,
+// none if the kind of the asset is unknown, e.g. a preconnect hint or a link to a file of another type
+func cspDirectives(asset *Asset) []string {
+	kind := ""
+
+	switch asset.Type {
+	case Script:
+		kind = "script"
+	case Image:
+		kind = "img"
+	case Video:
+		kind = "media"
+	case Link:
+		switch strings.ToLower(path.Ext(asset.Url)) {
+		case ".css":
+			kind = "style"
+		case ".ico", ".png", ".svg", ".gif", ".jpg", ".jpeg", ".webp", ".avif":
+			kind = "img"
+		case ".webmanifest":
+			kind = "manifest"
+		}
+	case Hint:
+		if asset.Rel == "preload" || asset.Rel == "modulepreload" || asset.Rel == "prefetch" {
+			switch asset.As {
+			case "script", "":
+				if asset.Rel == "modulepreload" || asset.As == "script" {
+					kind = "script"
+				}
+			case "style":
+				kind = "style"
+			case "image":
+				kind = "img"
+			case "font":
+				kind = "font"
+			case "audio", "video", "track":
+				kind = "media"
+			case "fetch":
+				kind = "connect"
+			}
+		}
+	}
+
+	switch kind {
+	case "":
+		return nil
+	case "script", "style":
+		return []string{kind + "-src-elem", kind + "-src", "default-src"}
+	default:
+		return []string{kind + "-src", "default-src"}
+	}
+}
+
+// blocks returns the directive of the policy blocking the asset of the page, if one does. Scripts are not checked
+// against directives with 'strict-dynamic', as the scripts they allow depend on the scripts loading them.
+func (p contentSecurityPolicy) blocks(asset *Asset, page *neturl.URL) (string, bool) {
+	directives := cspDirectives(asset)
+	if len(directives) == 0 {
+		return "", false
+	}
+
+	u, err := neturl.Parse(asset.Url)
+	if err != nil {
+		return "", false
+	}
+
+	for _, policy := range p {
+		for _, name := range directives {
+			sources, ok := policy[name]
+			if !ok {
+				continue
+			}
+
+			if !cspAllows(sources, u, page) {
+				return name, true
+			}
+
+			break
+		}
+	}
+
+	return "", false
+}
+
+// cspAllows reports whether one of the sources of a directive matches the URL loaded by the page
+func cspAllows(sources []string, u, page *neturl.URL) bool {
+	for _, source := range sources {
+		switch s := strings.ToLower(source); {
+		case s == "'strict-dynamic'":
+			return true
+		case s == "'self'":
+			if u.Hostname() == page.Hostname() && cspSchemeMatches(page.Scheme, u.Scheme) && cspPort(u) == cspPort(page) {
+				return true
+			}
+		case s == "*":
+			if u.Scheme == "http" || u.Scheme == "https" || u.Scheme == page.Scheme {
+				return true
+			}
+		case strings.HasPrefix(s, "'"):
+			// Nonces, hashes and 'unsafe-inline' allow inline scripts and styles, 'none' nothing
+		case strings.HasSuffix(s, ":") && !strings.Contains(s, "/"):
+			if cspSchemeMatches(strings.TrimSuffix(s, ":"), u.Scheme) {
+				return true
+			}
+		default:
+			if cspHostMatches(s, u, page) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// cspSchemeMatches reports whether the scheme of a source allows the scheme of the URL, upgrades from http included
+func cspSchemeMatches(source, scheme string) bool {
+	return source == scheme || (source == "http" && scheme == "https") || (source == "ws" && scheme == "wss")
+}
+
+// cspHostMatches reports whether the host source, [scheme://]host[:port][/path], matches the URL. The host may start
+// with a *. wildcard and the port may be *. A source without a scheme takes the scheme of the page.
+func cspHostMatches(source string, u, page *neturl.URL) bool {
+	scheme := page.Scheme
+	if i := strings.Index(source, "://"); i >= 0 {
+		scheme, source = source[:i], source[i+3:]
+	}
+
+	if !cspSchemeMatches(scheme, u.Scheme) {
+		return false
+	}
+
+	host, sourcePath := source, ""
+	if i := strings.IndexByte(source, '/'); i >= 0 {
+		host, sourcePath = source[:i], source[i:]
+	}
+
+	sourcePort := ""
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host, sourcePort = host[:i], host[i+1:]
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+
+	switch {
+	case strings.HasPrefix(host, "*."):
+		if !strings.HasSuffix(hostname, host[1:]) {
+			return false
+		}
+	case host != hostname:
+		return false
+	}
+
+	switch {
+	case sourcePort == "*":
+	case sourcePort != "":
+		if sourcePort != cspPort(u) {
+			return false
+		}
+	case u.Port() != "":
+		// Without a port, the default port of the scheme of the source is allowed, and 443 for upgrades from 80
+		if u.Port() != cspDefaultPort(scheme) && !(scheme == "http" && u.Scheme == "https" && u.Port() == "443") {
+			return false
+		}
+	}
+
+	if sourcePath == "" || sourcePath == "/" {
+		return true
+	}
+
+	if strings.HasSuffix(sourcePath, "/") {
+		return strings.HasPrefix(u.Path, sourcePath)
+	}
+
+	return u.Path == sourcePath
+}
+
+// cspPort returns the port of the URL, the default port of its scheme if it has none
+func cspPort(u *neturl.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+
+	return cspDefaultPort(u.Scheme)
+}
+
+func cspDefaultPort(scheme string) string {
+	switch scheme {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	default:
+		return ""
+	}
+}
+
+// checkCSP reports the assets of the page which its Content-Security-Policy blocks as csp-violation findings,
+// and the ones its Content-Security-Policy-Report-Only would report, which are not blocked, as info findings
+func (c *Crawler) checkCSP(url string, assets []*Asset, response *responseInfo) {
+	if response.csp == "" && response.cspReportOnly == "" {
+		return
+	}
+
+	page, err := neturl.Parse(url)
+	if err != nil {
+		return
+	}
+
+	enforced, reported := parseCSP(response.csp), parseCSP(response.cspReportOnly)
+
+	for _, asset := range assets {
+		if directive, blocked := enforced.blocks(asset, page); blocked {
+			c.addFinding(Finding{
+				Category: CategoryCSPViolation,
+				Severity: SeverityWarning,
+				Url:      asset.Url,
+				Page:     url,
+				Message:  fmt.Sprintf("Blocked by %s of the Content-Security-Policy", directive),
+			})
+		} else if directive, blocked = reported.blocks(asset, page); blocked {
+			c.addFinding(Finding{
+				Category: CategoryCSPViolation,
+				Severity: SeverityInfo,
+				Url:      asset.Url,
+				Page:     url,
+				Message:  fmt.Sprintf("Would be blocked by %s of the Content-Security-Policy-Report-Only", directive),
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"testing"
+)
+
+func TestParseCSP(t *testing.T) {
+	policy := parseCSP("default-src 'self'; SCRIPT-SRC https://cdn.example.com; script-src *, img-src data:")
+
+	if len(policy) != 2 {
+		t.Fatalf("Unexpected policies: %v\n", policy)
+	}
+
+	if sources := policy[0]["script-src"]; len(sources) != 1 || sources[0] != "https://cdn.example.com" {
+		t.Errorf("Unexpected script-src: %v\n", sources)
+	}
+
+	if sources := policy[1]["img-src"]; len(sources) != 1 || sources[0] != "data:" {
+		t.Errorf("Unexpected img-src: %v\n", sources)
+	}
+}
+
+func TestCSPBlocksAssets(t *testing.T) {
+	page, _ := neturl.Parse("https://example.com/blog/")
+
+	cases := []struct {
+		policy  string
+		asset   *Asset
+		blocked string
+	}{
+		{"default-src 'self'", &Asset{Type: Script, Url: "https://example.com/app.js"}, ""},
+		{"default-src 'self'", &Asset{Type: Script, Url: "https://cdn.example.com/app.js"}, "default-src"},
+		{"default-src 'self'; script-src https://cdn.example.com", &Asset{Type: Script, Url: "https://cdn.example.com/app.js"}, ""},
+		{"script-src 'self'; script-src-elem *.example.com", &Asset{Type: Script, Url: "https://cdn.example.com/app.js"}, ""},
+		{"script-src 'none'", &Asset{Type: Script, Url: "https://example.com/app.js"}, "script-src"},
+		{"script-src 'nonce-abc' 'strict-dynamic'", &Asset{Type: Script, Url: "https://evil.com/app.js"}, ""},
+		{"img-src https:", &Asset{Type: Image, Url: "https://images.com/a.png"}, ""},
+		{"img-src https:", &Asset{Type: Image, Url: "http://images.com/a.png"}, "img-src"},
+		{"img-src *", &Asset{Type: Image, Url: "http://images.com/a.png"}, ""},
+		{"img-src example.com:8443", &Asset{Type: Image, Url: "https://example.com/a.png"}, "img-src"},
+		{"img-src example.com:*", &Asset{Type: Image, Url: "https://example.com:8443/a.png"}, ""},
+		{"img-src http://example.com", &Asset{Type: Image, Url: "https://example.com/a.png"}, ""},
+		{"img-src example.com/img/", &Asset{Type: Image, Url: "https://example.com/img/a.png"}, ""},
+		{"img-src example.com/img/", &Asset{Type: Image, Url: "https://example.com/a.png"}, "img-src"},
+		{"img-src example.com/img/a.png", &Asset{Type: Image, Url: "https://example.com/img/b.png"}, "img-src"},
+		{"style-src 'self'", &Asset{Type: Link, Url: "https://fonts.com/a.css"}, "style-src"},
+		{"style-src 'self'", &Asset{Type: Link, Url: "https://fonts.com/feed.xml"}, ""},
+		{"media-src 'self'", &Asset{Type: Video, Url: "https://videos.com/a.mp4"}, "media-src"},
+		{"font-src 'self'", &Asset{Type: Hint, Url: "https://fonts.com/a.woff2", Rel: "preload", As: "font"}, "font-src"},
+		{"default-src 'self'", &Asset{Type: Hint, Url: "https://fonts.com", Rel: "preconnect"}, ""},
+		{"default-src *; img-src 'self', img-src *", &Asset{Type: Image, Url: "https://images.com/a.png"}, "img-src"},
+	}
+
+	for _, c := range cases {
+		directive, blocked := parseCSP(c.policy).blocks(c.asset, page)
+		if blocked != (c.blocked != "") || directive != c.blocked {
+			t.Errorf("Unexpected verdict of %q on %s: %q\n", c.policy, c.asset.Url, directive)
+		}
+	}
+}
+
+func TestCrawlerChecksCSP(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Security-Policy", "default-src 'self'")
+			fmt.Fprintf(w, "%s/report\nimage %s/logo.png\nimage https://images.example.com/photo.png\n", server.URL, server.URL)
+		case "/report":
+			w.Header().Set("Content-Security-Policy-Report-Only", "img-src https://images.example.com")
+			fmt.Fprintf(w, "image %s/logo.png\nimage https://images.example.com/photo.png\n", server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    assetExtractor{},
+		CheckCSP:     true,
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	findings := c.GetFindings().ByCategory(CategoryCSPViolation)
+	if len(findings) != 2 {
+		t.Fatalf("Unexpected findings: %v\n", findings)
+	}
+
+	for _, f := range findings {
+		switch f.Page {
+		case server.URL + "/":
+			if f.Url != "https://images.example.com/photo.png" || f.Severity != SeverityWarning {
+				t.Errorf("Unexpected finding of the enforced policy: %v\n", f)
+			}
+		case server.URL + "/report":
+			if f.Url != server.URL+"/logo.png" || f.Severity != SeverityInfo {
+				t.Errorf("Unexpected finding of the report-only policy: %v\n", f)
+			}
+		default:
+			t.Errorf("Unexpected finding: %v\n", f)
+		}
+	}
+}
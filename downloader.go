@@ -1,7 +1,17 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -10,46 +20,507 @@ type Downloader interface {
 	Download(url string) (body []byte, err error)
 }
 
+// Verifier interface abstracts the operation of checking that the resource under given URL is reachable
+// without fetching its content. Downloaders implementing it are used for assets with PolicyVerify.
+type Verifier interface {
+	Verify(url string) error
+}
+
+// ConditionalDownloader interface abstracts fetching the website's content only if it has changed
+// since the validators (ETag and Last-Modified) were obtained. ErrNotModified is returned otherwise.
+// Fresh validators of the fetched content are returned alongside the body.
+type ConditionalDownloader interface {
+	DownloadConditional(url string, v Validators) (body []byte, fresh Validators, err error)
+}
+
+// RefererDownloader interface abstracts fetching the website's content on behalf of the page linking to it.
+// The referer is the URL of the linking page, empty if there is none.
+type RefererDownloader interface {
+	DownloadFrom(url, referer string, v Validators) (body []byte, fresh Validators, err error)
+}
+
+// RefererVerifier interface abstracts checking the resource under given URL on behalf of the page referencing it.
+type RefererVerifier interface {
+	VerifyFrom(url, referer string) error
+}
+
+// ContextDownloader interface abstracts fetching and verifying resources with requests bound to a context,
+// so that they are aborted as soon as the context is cancelled.
+type ContextDownloader interface {
+	DownloadContext(ctx context.Context, url, referer string, v Validators) (body []byte, fresh Validators, err error)
+	VerifyContext(ctx context.Context, url, referer string) error
+}
+
+// maxRedirects is the number of redirects the default downloader follows, as the default of net/http
+const maxRedirects = 10
+
+// Redirect struct represents a redirect followed by the downloader, from Url to Location with StatusCode.
+type Redirect struct {
+	Url, Location string
+	StatusCode    int
+}
+
+// redirectPolicy struct decides which redirects the default downloader follows and records the ones it does.
+// follow reports whether the redirect to given URL is followed (all of them are if nil), the redirects it rejects
+// are returned as RedirectError. At most max redirects are followed (maxRedirects if zero), ErrRedirects is returned
+// past them. The followed redirects are appended to hops in order.
+type redirectPolicy struct {
+	follow func(url string) bool
+	max    int
+	hops   []Redirect
+}
+
+// redirectPolicyKey is the key of the context value holding the redirect policy of the request.
+// All redirects up to maxRedirects are followed if there is none.
+type redirectPolicyKey struct{}
+
+func withRedirectPolicy(ctx context.Context, p *redirectPolicy) context.Context {
+	return context.WithValue(ctx, redirectPolicyKey{}, p)
+}
+
+// responseInfo struct records the HTTP protocol of the response to a request, e.g. HTTP/1.1 or HTTP/2.0,
+// its status, the encoding of its content, identity if it was not compressed, and its caching headers.
+type responseInfo struct {
+	status                int
+	protocol, encoding    string
+	validators            Validators
+	cacheControl, expires string
+	date                  string
+	csp, cspReportOnly    string
+	contentType           string
+	size                  int64
+}
+
+// responseInfoKey is the key of the context value the default downloader records the response to the request in.
+type responseInfoKey struct{}
+
+func withResponseInfo(ctx context.Context, info *responseInfo) context.Context {
+	return context.WithValue(ctx, responseInfoKey{}, info)
+}
+
+// recordResponse records the status, protocol, content encoding, caching and CSP headers, content type and size
+// of the response in the context value of its request. The size is -1 if it is unknown.
+// The transport asks for gzip and decompresses the content itself unless the request sets Accept-Encoding,
+// in which case the Content-Encoding header is left as the server sent it.
+func recordResponse(resp *http.Response) {
+	info, _ := resp.Request.Context().Value(responseInfoKey{}).(*responseInfo)
+	if info == nil {
+		return
+	}
+
+	info.status = resp.StatusCode
+	info.protocol, info.encoding = resp.Proto, resp.Header.Get("Content-Encoding")
+	info.validators = Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	info.cacheControl, info.expires = resp.Header.Get("Cache-Control"), resp.Header.Get("Expires")
+	info.date = resp.Header.Get("Date")
+	info.csp = strings.Join(resp.Header.Values("Content-Security-Policy"), ",")
+	info.cspReportOnly = strings.Join(resp.Header.Values("Content-Security-Policy-Report-Only"), ",")
+	info.contentType, info.size = resp.Header.Get("Content-Type"), resp.ContentLength
+	if resp.Uncompressed {
+		info.encoding = "gzip"
+	} else if info.encoding == "" {
+		info.encoding = "identity"
+	}
+
+	// The size of a partial response is the complete length in its Content-Range, e.g. bytes 0-0/1024
+	if resp.StatusCode == http.StatusPartialContent {
+		info.size = -1
+
+		if _, length, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			if n, err := strconv.ParseInt(length, 10, 64); err == nil {
+				info.size = n
+			}
+		}
+	}
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	p, _ := req.Context().Value(redirectPolicyKey{}).(*redirectPolicy)
+
+	limit := maxRedirects
+	if p != nil && p.max > 0 {
+		limit = p.max
+	}
+
+	if len(via) >= limit {
+		return fmt.Errorf("%w: stopped after %d", ErrRedirects, limit)
+	}
+
+	if p == nil {
+		return nil
+	}
+
+	if p.follow != nil && !p.follow(req.URL.String()) {
+		return http.ErrUseLastResponse
+	}
+
+	hop := Redirect{Url: via[len(via)-1].URL.String(), Location: req.URL.String()}
+	if req.Response != nil {
+		hop.StatusCode = req.Response.StatusCode
+	}
+
+	p.hops = append(p.hops, hop)
+
+	return nil
+}
+
+// Validators struct holds the HTTP cache validators of the fetched content.
+type Validators struct {
+	ETag, LastModified string
+}
+
+// DefaultUserAgent is the User-Agent header of the default downloader if none is given, as many sites
+// block the default one of Go.
+const DefaultUserAgent = "Mozilla/5.0 (compatible; crawler/1.0; +https://github.com/mpraski/crawler)"
+
+// RequestHeaders struct shapes the requests sent by the default downloader, so that sites varying their content
+// by language or referer can be crawled representatively.
+// UserAgent is sent as the User-Agent header, DefaultUserAgent if empty,
+// AcceptLanguage is sent as the Accept-Language header if not empty,
+// RefererPolicy defines how much of the linking page's URL is sent as the Referer header,
+// Headers are set on every request in the given order, later ones replacing earlier ones with the same key.
+// Note that net/http writes the headers in its own order regardless of the order they are set in.
+type RequestHeaders struct {
+	UserAgent      string
+	AcceptLanguage string
+	RefererPolicy  RefererPolicy
+	Headers        []Header
+}
+
+type Header struct {
+	Key, Value string
+}
+
+// RefererPolicy defines the Referer header sent with requests. RefererNone omits it,
+// RefererOrigin sends only the scheme and host of the linking page and RefererFull sends its whole URL.
+type RefererPolicy uint8
+
+const (
+	RefererNone   RefererPolicy = iota
+	RefererOrigin RefererPolicy = iota
+	RefererFull   RefererPolicy = iota
+)
+
 // defaultDownloader implementation uses a http.Client with user defined timeout to fetch the content.
 // To save memory between subsequent calls the response is read to a buffer taken from the buffer pool
 // whose parameters (initial number of buffers and size of each) are specified by the caller
 type defaultDownloader struct {
-	client *http.Client
-	pool   *BufferPool
+	client  *http.Client
+	pool    *BufferPool
+	headers RequestHeaders
+}
+
+// DownloaderOptions struct represents list of parameters to the default downloader.
+// Timeout is the number of seconds before a request times out, Pool is the buffer pool the responses are read to,
+// Headers shapes the requests and Proxy routes them through a chain of SOCKS5 proxies (both are optional).
+// Resolve maps the host:port (or the host alone, for any port) of the URLs to the address connected to instead,
+// e.g. www.example.com:443 to 10.0.0.5 or 10.0.0.5:8443, while the Host header and the TLS server name
+// remain those of the URLs. The port of the URL is kept if the address has none.
+// Pins maps host names to the pins of the certificates (sha256/ followed by the base64 SHA-256 hash of the public key)
+// one of which the chain presented by the host must have, the leaf or a CA, otherwise ErrPinMismatch is returned.
+// HTTP3 makes it fetch https URLs over HTTP/3 (QUIC) first, falling back to HTTP/2 or HTTP/1.1 for good
+// for the hosts it fails with. It is experimental and cannot be combined with Proxy, as QUIC runs over UDP.
+// Jar stores the cookies set by the responses and adds them to the following requests (no cookies are kept if nil).
+// WarmConnections is the number of idle keep-alive connections kept to each host, 2 by default,
+// so that the connections opened with Warm are not closed before they are used.
+type DownloaderOptions struct {
+	Timeout int
+	Pool    *BufferPool
+	Headers *RequestHeaders
+	Proxy   *ProxyOptions
+	Resolve map[string]string
+	Pins    map[string][]string
+	HTTP3   bool
+	Jar     http.CookieJar
+
+	WarmConnections int
 }
 
 func NewDefaultDownloader(timeout int, pool *BufferPool) Downloader {
+	return NewDefaultDownloaderWithHeaders(timeout, pool, &RequestHeaders{})
+}
+
+func NewDefaultDownloaderWithHeaders(timeout int, pool *BufferPool, headers *RequestHeaders) Downloader {
 	return &defaultDownloader{
 		client: &http.Client{
-			Timeout: time.Second * time.Duration(timeout),
+			Timeout:       time.Second * time.Duration(timeout),
+			CheckRedirect: checkRedirect,
 		},
-		pool: pool,
+		pool:    pool,
+		headers: *headers,
+	}
+}
+
+func NewDefaultDownloaderWithOptions(options *DownloaderOptions) (Downloader, error) {
+	d := &defaultDownloader{
+		client: &http.Client{
+			Timeout:       time.Second * time.Duration(options.Timeout),
+			CheckRedirect: checkRedirect,
+			Jar:           options.Jar,
+		},
+		pool: options.Pool,
+	}
+
+	if options.Headers != nil {
+		d.headers = *options.Headers
+	}
+
+	var (
+		dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+		isolate bool
+		err     error
+	)
+
+	if options.Proxy != nil {
+		if dial, err = newProxyDialer(options.Proxy); err != nil {
+			return nil, err
+		}
+
+		isolate = options.Proxy.IsolateCircuits
+	}
+
+	if len(options.Resolve) > 0 {
+		dial = newOverridingDialer(options.Resolve, dial)
+	}
+
+	if options.HTTP3 && options.Proxy != nil {
+		return nil, ErrHTTP3Proxy
+	}
+
+	if dial != nil || len(options.Pins) > 0 || options.HTTP3 || options.WarmConnections > 0 {
+		// The default transport keeps its proxy from the environment and its timeouts, a transport with
+		// its own dialer or TLS configuration only negotiating HTTP/2 if forced to
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ForceAttemptHTTP2 = true
+		transport.DisableKeepAlives = isolate
+
+		if dial != nil {
+			transport.DialContext = dial
+		}
+
+		// The chain of SOCKS5 proxies replaces the proxy of the environment
+		if options.Proxy != nil {
+			transport.Proxy = nil
+		}
+
+		if options.WarmConnections > 0 {
+			transport.MaxIdleConnsPerHost = options.WarmConnections
+		}
+
+		if len(options.Pins) > 0 {
+			transport.TLSClientConfig = &tls.Config{VerifyConnection: verifyPins(options.Pins)}
+		}
+
+		d.client.Transport = transport
+
+		if options.HTTP3 {
+			d.client.Transport = newHTTP3Transport(transport, options.Resolve)
+		}
 	}
+
+	return d, nil
 }
 
 func (d *defaultDownloader) Download(url string) ([]byte, error) {
+	body, _, err := d.DownloadFrom(url, "", Validators{})
+	return body, err
+}
+
+func (d *defaultDownloader) DownloadConditional(url string, v Validators) ([]byte, Validators, error) {
+	return d.DownloadFrom(url, "", v)
+}
+
+func (d *defaultDownloader) DownloadFrom(url, referer string, v Validators) ([]byte, Validators, error) {
+	return d.DownloadContext(context.Background(), url, referer, v)
+}
+
+func (d *defaultDownloader) DownloadContext(ctx context.Context, url, referer string, v Validators) ([]byte, Validators, error) {
 	var (
+		req  *http.Request
 		resp *http.Response
 		err  error
 	)
 
-	resp, err = d.client.Get(url)
+	// The pages known from the baseline are requested conditionally instead
+	if v.ETag == "" && v.LastModified == "" {
+		if err = d.checkHead(ctx, url, referer); err != nil {
+			return nil, v, err
+		}
+	}
+
+	if req, err = d.newRequest(ctx, http.MethodGet, url, referer); err != nil {
+		return nil, v, err
+	}
+
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err = d.do(req)
 	if err != nil {
-		return nil, err
+		return nil, v, err
+	}
+
+	defer resp.Body.Close()
+
+	recordResponse(resp)
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, v, ErrNotModified
+	}
+
+	// Only the redirects rejected by the policy are left unfollowed
+	if location, err := resp.Location(); err == nil && isRedirect(resp.StatusCode) {
+		return nil, v, &RedirectError{Url: url, Location: location.String(), StatusCode: resp.StatusCode}
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ErrBadResponse
+		return nil, v, ErrBadResponse
 	}
 
-	defer resp.Body.Close()
+	fresh := Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
 
 	b := d.pool.Get()
 	defer d.pool.Put(b)
 
 	if _, err = b.ReadFrom(resp.Body); err == nil {
-		return b.Bytes(), nil
+		return b.Bytes(), fresh, nil
 	} else {
+		return nil, v, err
+	}
+}
+
+func (d *defaultDownloader) Verify(url string) error {
+	return d.VerifyFrom(url, "")
+}
+
+func (d *defaultDownloader) VerifyFrom(url, referer string) error {
+	return d.VerifyContext(context.Background(), url, referer)
+}
+
+// VerifyContext checks the resource with a HEAD request or, if the server does not allow HEAD,
+// with a GET request of its first byte.
+func (d *defaultDownloader) VerifyContext(ctx context.Context, url, referer string) error {
+	req, err := d.newRequest(ctx, http.MethodHead, url, referer)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.do(req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		resp.Body.Close()
+
+		if req, err = d.newRequest(ctx, http.MethodGet, url, referer); err != nil {
+			return err
+		}
+
+		req.Header.Set("Range", "bytes=0-0")
+
+		if resp, err = d.do(req); err != nil {
+			return err
+		}
+	}
+
+	defer resp.Body.Close()
+
+	recordResponse(resp)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return ErrBadResponse
+	}
+
+	return nil
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// resetRetries is the number of times a request is repeated after the connection was reset before the response started
+const resetRetries = 2
+
+// do sends the request, repeating it if the connection was reset before the response started.
+// Only GET and HEAD requests are sent, which are idempotent, so repeating them is safe. These retries
+// are transparent to the crawler and do not count against its MaxRetries.
+func (d *defaultDownloader) do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+
+	for i := 0; i < resetRetries && err != nil && isConnectionReset(err) && req.Context().Err() == nil; i++ {
+		resp, err = d.client.Do(req)
+	}
+
+	return resp, err
+}
+
+func isConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (d *defaultDownloader) newRequest(ctx context.Context, method, address, referer string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, address, nil)
+	if err != nil {
 		return nil, err
 	}
+
+	if d.headers.UserAgent != "" {
+		req.Header.Set("User-Agent", d.headers.UserAgent)
+	} else {
+		req.Header.Set("User-Agent", DefaultUserAgent)
+	}
+
+	for _, h := range d.headers.Headers {
+		req.Header.Set(h.Key, h.Value)
+	}
+
+	if d.headers.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", d.headers.AcceptLanguage)
+	}
+
+	if r := d.referer(referer); r != "" {
+		req.Header.Set("Referer", r)
+	}
+
+	return req, nil
+}
+
+func (d *defaultDownloader) referer(referer string) string {
+	if referer == "" {
+		return ""
+	}
+
+	switch d.headers.RefererPolicy {
+	case RefererFull:
+		u, err := url.Parse(referer)
+		if err != nil {
+			return ""
+		}
+
+		u.User, u.Fragment = nil, ""
+		return u.String()
+	case RefererOrigin:
+		u, err := url.Parse(referer)
+		if err != nil {
+			return ""
+		}
+
+		return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	default:
+		return ""
+	}
 }
@@ -1,7 +1,17 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDownloaderFetchesCorrectly(t *testing.T) {
@@ -26,3 +36,339 @@ func TestDownloaderFetchesCorrectly(t *testing.T) {
 		t.Errorf("Size of downloaded data mismatch: %d\n", len(data))
 	}
 }
+
+func TestDownloaderSkipsNotModifiedContent(t *testing.T) {
+	const ETAG = `"v1"`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == ETAG {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", ETAG)
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	var (
+		downloader = NewDefaultDownloader(5, NewBufferPool(2, 1024)).(ConditionalDownloader)
+		fresh      Validators
+		err        error
+	)
+
+	if _, fresh, err = downloader.DownloadConditional(server.URL, Validators{}); err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if fresh.ETag != ETAG {
+		t.Errorf("Unexpected ETag: %s\n", fresh.ETag)
+	}
+
+	if _, _, err = downloader.DownloadConditional(server.URL, fresh); err != ErrNotModified {
+		t.Errorf("Downloader does not report unmodified content: %v\n", err)
+	}
+}
+
+func TestDownloaderShapesRequests(t *testing.T) {
+	var received http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+
+	var (
+		headers = &RequestHeaders{
+			AcceptLanguage: "de-DE",
+			RefererPolicy:  RefererOrigin,
+			Headers:        []Header{{Key: "X-Test", Value: "1"}, {Key: "X-Test", Value: "2"}},
+		}
+		downloader = NewDefaultDownloaderWithHeaders(5, NewBufferPool(2, 1024), headers).(RefererDownloader)
+	)
+
+	if _, _, err := downloader.DownloadFrom(server.URL, "http://user@example.com/some/page#top", Validators{}); err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if v := received.Get("Accept-Language"); v != "de-DE" {
+		t.Errorf("Unexpected Accept-Language: %s\n", v)
+	}
+
+	if v := received.Get("X-Test"); v != "2" {
+		t.Errorf("Unexpected header value: %s\n", v)
+	}
+
+	if v := received.Get("Referer"); v != "http://example.com/" {
+		t.Errorf("Unexpected Referer: %s\n", v)
+	}
+
+	if v := received.Get("User-Agent"); v != DefaultUserAgent {
+		t.Errorf("Unexpected User-Agent: %s\n", v)
+	}
+
+	headers.UserAgent = "test-agent/2.0"
+	downloader = NewDefaultDownloaderWithHeaders(5, NewBufferPool(2, 1024), headers).(RefererDownloader)
+
+	if _, _, err := downloader.DownloadFrom(server.URL, "", Validators{}); err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if v := received.Get("User-Agent"); v != "test-agent/2.0" {
+		t.Errorf("Unexpected User-Agent: %s\n", v)
+	}
+}
+
+func TestDownloaderKeepsCookies(t *testing.T) {
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Cookie"))
+		http.SetCookie(w, &http.Cookie{Name: "consent", Value: "yes", Path: "/"})
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("Cookie jar fails with error: %s\n", err.Error())
+	}
+
+	downloader, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout: 5,
+		Pool:    NewBufferPool(2, 1024),
+		Jar:     jar,
+	})
+	if err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	for _, path := range []string{"/", "/a"} {
+		if _, err = downloader.Download(server.URL + path); err != nil {
+			t.Fatalf("Downloader fails with error: %s\n", err.Error())
+		}
+	}
+
+	if !reflect.DeepEqual(received, []string{"", "consent=yes"}) {
+		t.Errorf("Unexpected cookies: %v\n", received)
+	}
+}
+
+func TestDownloaderKeepsDefaultTransportSettings(t *testing.T) {
+	downloader, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout:         5,
+		Pool:            NewBufferPool(2, 1024),
+		Pins:            map[string][]string{"example.com": {"sha256/AAAA"}},
+		WarmConnections: 4,
+	})
+	if err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	transport := downloader.(*defaultDownloader).client.Transport.(*http.Transport)
+	if transport.Proxy == nil || transport.TLSHandshakeTimeout == 0 || transport.IdleConnTimeout == 0 || transport.DialContext == nil {
+		t.Errorf("Default transport settings dropped: %+v\n", transport)
+	}
+
+	if transport.MaxIdleConnsPerHost != 4 || transport.TLSClientConfig == nil || transport.TLSClientConfig.VerifyConnection == nil {
+		t.Errorf("Transport not configured: %+v\n", transport)
+	}
+
+	// The proxies of the environment are not used along with the chain of proxies
+	downloader, err = NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout: 5,
+		Pool:    NewBufferPool(2, 1024),
+		Proxy:   &ProxyOptions{Chain: []string{"socks5://127.0.0.1:9050"}},
+	})
+	if err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if transport = downloader.(*defaultDownloader).client.Transport.(*http.Transport); transport.Proxy != nil || transport.TLSHandshakeTimeout == 0 {
+		t.Errorf("Unexpected transport of the proxies: %+v\n", transport)
+	}
+}
+
+func TestCrawlerRequestTimeout(t *testing.T) {
+	for timeout, expected := range map[time.Duration]time.Duration{0: 2 * time.Second, 1500 * time.Millisecond: 2 * time.Second, 30 * time.Second: 30 * time.Second} {
+		c, err := NewCrawlerWithOptions("http://example.com/", &Options{RequestTimeout: timeout})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		if d := c.downloader.(*defaultDownloader).client.Timeout; d != expected {
+			t.Errorf("Unexpected timeout of %s: %s\n", timeout, d)
+		}
+	}
+}
+
+func TestDownloaderRejectsInvalidProxies(t *testing.T) {
+	for _, chain := range [][]string{{}, {"http://127.0.0.1:8080"}, {"socks5://127.0.0.1:9050", "socks5://"}} {
+		_, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+			Timeout: 5,
+			Pool:    NewBufferPool(2, 1024),
+			Proxy:   &ProxyOptions{Chain: chain},
+		})
+
+		if err != ErrInvalidProxy {
+			t.Errorf("Downloader does not fail for invalid proxy chain: %v\n", chain)
+		}
+	}
+}
+
+func TestDownloaderOverridesHostAddresses(t *testing.T) {
+	var host string
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	downloader, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout: 5,
+		Pool:    NewBufferPool(2, 1024),
+		Resolve: map[string]string{"Example.com:443": server.Listener.Addr().String()},
+	})
+	if err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	// The certificate of the test server is valid for example.com, which is verified as the server name
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	downloader.(*defaultDownloader).client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: roots}
+
+	if _, err = downloader.Download("https://example.com/"); err != nil {
+		t.Errorf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if host != "example.com" {
+		t.Errorf("Unexpected Host header: %s\n", host)
+	}
+
+	overrides := hostOverrides{"example.com:443": "10.0.0.5:8443", "example.org": "10.0.0.6"}
+	for addr, expected := range map[string]string{
+		"example.com:443":     "10.0.0.5:8443",
+		"example.com:80":      "example.com:80",
+		"example.org:80":      "10.0.0.6:80",
+		"www.example.org:443": "www.example.org:443",
+	} {
+		if a := overrides.address(addr); a != expected {
+			t.Errorf("Unexpected address of %s: %s\n", addr, a)
+		}
+	}
+}
+
+func TestDownloaderRetriesResetConnections(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+
+		if n <= resetRetries {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	downloader := NewDefaultDownloader(5, NewBufferPool(2, 1024))
+
+	if _, err := downloader.Download(server.URL); err != nil {
+		t.Errorf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if requests != resetRetries+1 {
+		t.Errorf("Unexpected number of requests: %d\n", requests)
+	}
+}
+
+func TestDownloaderFollowsRedirectsByPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/older":
+			http.Redirect(w, r, "/old", http.StatusFound)
+			return
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	downloader := NewDefaultDownloader(5, NewBufferPool(2, 1024)).(ContextDownloader)
+
+	if _, _, err := downloader.DownloadContext(context.Background(), server.URL+"/old", "", Validators{}); err != nil {
+		t.Errorf("Downloader does not follow redirects: %s\n", err.Error())
+	}
+
+	policy := &redirectPolicy{}
+	if _, _, err := downloader.DownloadContext(withRedirectPolicy(context.Background(), policy), server.URL+"/older", "", Validators{}); err != nil {
+		t.Errorf("Downloader does not follow redirects: %s\n", err.Error())
+	}
+
+	expected := []Redirect{
+		{Url: server.URL + "/older", Location: server.URL + "/old", StatusCode: http.StatusFound},
+		{Url: server.URL + "/old", Location: server.URL + "/new", StatusCode: http.StatusMovedPermanently},
+	}
+	if !reflect.DeepEqual(policy.hops, expected) {
+		t.Errorf("Unexpected redirect chain: %v\n", policy.hops)
+	}
+
+	ctx := withRedirectPolicy(context.Background(), &redirectPolicy{max: 1})
+	if _, _, err := downloader.DownloadContext(ctx, server.URL+"/older", "", Validators{}); !errors.Is(err, ErrRedirects) {
+		t.Errorf("Downloader follows too many redirects: %v\n", err)
+	}
+
+	ctx = withRedirectPolicy(context.Background(), &redirectPolicy{follow: func(url string) bool { return false }})
+
+	var redirect *RedirectError
+	if _, _, err := downloader.DownloadContext(ctx, server.URL+"/old", "", Validators{}); !errors.As(err, &redirect) {
+		t.Fatalf("Downloader follows rejected redirect: %v\n", err)
+	}
+
+	if redirect.Location != server.URL+"/new" || redirect.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Unexpected redirect: %s\n", redirect.Error())
+	}
+}
+
+func TestDownloaderVerifiesWithRangeIfHeadIsNotAllowed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("Unexpected Range: %q\n", r.Header.Get("Range"))
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Range", "bytes 0-0/1024")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte{0x89})
+	}))
+	defer server.Close()
+
+	d := NewDefaultDownloader(2, NewBufferPool(1, 1024)).(*defaultDownloader)
+
+	response := new(responseInfo)
+	if err := d.VerifyContext(withResponseInfo(context.Background(), response), server.URL+"/a.png", ""); err != nil {
+		t.Fatalf("Verify fails with error: %s\n", err.Error())
+	}
+
+	if response.status != http.StatusPartialContent || response.size != 1024 || response.contentType != "image/png" {
+		t.Errorf("Unexpected response: %v\n", response)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// simHashShingle is the number of consecutive words hashed together into the SimHash of a page
+	simHashShingle = 3
+	// nearDuplicateDistance is the number of bits the SimHashes of near-duplicate pages may differ in at most
+	nearDuplicateDistance = 3
+)
+
+// DuplicateCluster struct represents the pages of the sitemap with the same content, or near-identical content
+// if Near is set, i.e. some of them have different content hashes but SimHashes differing in at most 3 bits.
+type DuplicateCluster struct {
+	Near  bool     `json:"near"`
+	Pages []string `json:"pages"`
+}
+
+// simHash returns the SimHash of the text of the HTML, the words outside of its tags, scripts and stylesheets,
+// empty if it has none. Pages with similar text have SimHashes differing in few bits.
+func simHash(body []byte) string {
+	words := strings.FieldsFunc(string(htmlText(body)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(words) == 0 {
+		return ""
+	}
+
+	var weights [64]int
+
+	shingles := len(words) - simHashShingle + 1
+	if shingles < 1 {
+		shingles = 1
+	}
+
+	for i := 0; i < shingles; i++ {
+		end := i + simHashShingle
+		if end > len(words) {
+			end = len(words)
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		sum := h.Sum64()
+
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << bit
+		}
+	}
+
+	return fmt.Sprintf("%016x", hash)
+}
+
+// htmlText returns the lowercased HTML without its tags and the content of its script and style elements
+func htmlText(body []byte) []byte {
+	var (
+		lower = bytes.ToLower(body)
+		text  = make([]byte, 0, len(body))
+	)
+
+	for i := 0; i < len(lower); {
+		if lower[i] != '<' {
+			text = append(text, lower[i])
+			i++
+			continue
+		}
+
+		// The content of scripts and stylesheets is skipped along with their tags
+		tag := i
+		for _, element := range []string{"script", "style"} {
+			if bytes.HasPrefix(lower[i+1:], []byte(element)) {
+				if j := bytes.Index(lower[i:], []byte("</"+element)); j >= 0 {
+					tag = i + j
+				}
+				break
+			}
+		}
+
+		j := bytes.IndexByte(lower[tag:], '>')
+		if j < 0 {
+			break
+		}
+
+		text = append(text, ' ')
+		i = tag + j + 1
+	}
+
+	return text
+}
+
+// simHashOf returns the SimHash of the result's page if they are computed, taken from the baseline if it was not modified
+func (c *Crawler) simHashOf(r *result) string {
+	switch {
+	case !c.simhash:
+		return ""
+	case r.cached != nil:
+		return r.cached.SimHash
+	default:
+		return simHash(r.body)
+	}
+}
+
+// Duplicates returns the clusters of pages with duplicate content, the largest first. Pages are duplicates
+// if they have the same content hash or, if their SimHashes were computed (see Options), SimHashes differing
+// in at most 3 bits. Near-duplicates are found by the 16-bit bands of the SimHashes, as two of them differing
+// in at most 3 bits must have one of their 4 bands in common.
+func (s *SiteMap) Duplicates() []DuplicateCluster {
+	var (
+		pages  = s.Pages()
+		parent = make([]int, len(pages))
+		hashes = make(map[string]int)
+		bands  = make(map[[2]uint64][]int)
+		sims   = make([]uint64, len(pages))
+	)
+
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	union := func(i, j int) {
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[rj] = ri
+		}
+	}
+
+	for i, page := range pages {
+		if page.ContentHash != "" {
+			if j, ok := hashes[page.ContentHash]; ok {
+				union(j, i)
+			} else {
+				hashes[page.ContentHash] = i
+			}
+		}
+
+		sim, err := strconv.ParseUint(page.SimHash, 16, 64)
+		if err != nil {
+			continue
+		}
+
+		sims[i] = sim
+		for band := uint64(0); band < 4; band++ {
+			key := [2]uint64{band, (sim >> (16 * band)) & 0xffff}
+			for _, j := range bands[key] {
+				if find(i) != find(j) && bits.OnesCount64(sim^sims[j]) <= nearDuplicateDistance {
+					union(j, i)
+				}
+			}
+			bands[key] = append(bands[key], i)
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range pages {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+
+	clusters := make([]DuplicateCluster, 0)
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+
+		cluster := DuplicateCluster{Pages: make([]string, 0, len(members))}
+		for _, i := range members {
+			cluster.Pages = append(cluster.Pages, pages[i].Url)
+			cluster.Near = cluster.Near || pages[i].ContentHash != pages[members[0]].ContentHash
+		}
+
+		sort.Strings(cluster.Pages)
+		clusters = append(clusters, cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Pages) != len(clusters[j].Pages) {
+			return len(clusters[i].Pages) > len(clusters[j].Pages)
+		}
+
+		return clusters[i].Pages[0] < clusters[j].Pages[0]
+	})
+
+	return clusters
+}
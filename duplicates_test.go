@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// article is a text long enough for its SimHash to change little with a few words
+var article = func() string {
+	sections := make([]string, 0, 40)
+	for i := 0; i < 40; i++ {
+		sections = append(sections, fmt.Sprintf("Section %d of the quick brown fox jumps over the lazy dog.", i))
+	}
+
+	return strings.Join(sections, " ")
+}()
+
+func simHashDistance(a, b string) int {
+	x, _ := strconv.ParseUint(a, 16, 64)
+	y, _ := strconv.ParseUint(b, 16, 64)
+
+	return bits.OnesCount64(x ^ y)
+}
+
+func TestSimHashIgnoresMarkup(t *testing.T) {
+	plain := simHash([]byte(article))
+	markup := simHash([]byte("<html><head><script>var x = 1;</script><style>p { color: red }</style></head><body><p class=\"a\">" +
+		strings.Replace(article, "jumps", "<b>jumps</b>", 1) + "</p></body></html>"))
+
+	if plain == "" || plain != markup {
+		t.Errorf("Unexpected SimHashes: %s, %s\n", plain, markup)
+	}
+
+	if h := simHash([]byte("<p> , . </p>")); h != "" {
+		t.Errorf("Unexpected SimHash of a page without text: %s\n", h)
+	}
+}
+
+func TestSimHashOfSimilarText(t *testing.T) {
+	var (
+		a = simHash([]byte(article + " on 1 March"))
+		b = simHash([]byte(article + " on 2 March"))
+		c = simHash([]byte("Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore"))
+	)
+
+	if d := simHashDistance(a, b); d > nearDuplicateDistance {
+		t.Errorf("Unexpected distance of similar texts: %d\n", d)
+	}
+
+	if d := simHashDistance(a, c); d <= nearDuplicateDistance {
+		t.Errorf("Unexpected distance of different texts: %d\n", d)
+	}
+}
+
+func TestSiteMapDuplicates(t *testing.T) {
+	s := NewSiteMap()
+	for _, p := range []*Page{
+		{Url: "https://example.com/a", ContentHash: "1", SimHash: "00000000000000ff"},
+		{Url: "https://example.com/b", ContentHash: "1", SimHash: "00000000000000ff"},
+		{Url: "https://example.com/c", ContentHash: "2", SimHash: "00000000000000f8"},
+		{Url: "https://example.com/d", ContentHash: "3", SimHash: "ff000000000000ff"},
+		{Url: "https://example.com/e", ContentHash: "4", SimHash: "ff00000000000000"},
+		{Url: "https://example.com/f", ContentHash: "4"},
+		{Url: "https://example.com/g", ContentHash: "5"},
+	} {
+		s.pages[p.Url] = p
+	}
+
+	clusters := s.Duplicates()
+	if len(clusters) != 2 {
+		t.Fatalf("Unexpected clusters: %v\n", clusters)
+	}
+
+	expected := []DuplicateCluster{
+		{Near: true, Pages: []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}},
+		{Near: false, Pages: []string{"https://example.com/e", "https://example.com/f"}},
+	}
+
+	for i, e := range expected {
+		if clusters[i].Near != e.Near || fmt.Sprint(clusters[i].Pages) != fmt.Sprint(e.Pages) {
+			t.Errorf("Unexpected cluster: %v\n", clusters[i])
+		}
+	}
+}
+
+func TestCrawlerComputesSimHashes(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/b\n", server.URL, server.URL)
+		case "/a":
+			fmt.Fprint(w, article+" on 1 March")
+		case "/b":
+			fmt.Fprint(w, article+" on 2 March")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		SimHash:      true,
+		MaxDepth:     1,
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	for _, page := range c.GetSiteMap().Pages() {
+		if page.SimHash == "" {
+			t.Errorf("Missing SimHash of %s\n", page.Url)
+		}
+	}
+
+	clusters := c.GetSiteMap().Duplicates()
+	if len(clusters) != 1 || !clusters[0].Near || len(clusters[0].Pages) != 2 {
+		t.Errorf("Unexpected clusters: %v\n", clusters)
+	}
+}
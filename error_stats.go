@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// ErrorClass groups errors of similar cause so that repeated failures can be reported once per host.
+type ErrorClass string
+
+const (
+	ClassDNS         ErrorClass = "dns"
+	ClassRefused     ErrorClass = "connection refused"
+	ClassReset       ErrorClass = "connection reset"
+	ClassTimeout     ErrorClass = "timeout"
+	ClassTLS         ErrorClass = "tls"
+	ClassBadResponse ErrorClass = "bad response"
+	ClassOther       ErrorClass = "other"
+)
+
+// ErrorStat struct represents the number of errors of given class encountered for a single host.
+// Sample is the first error seen, kept as an example of the failure.
+type ErrorStat struct {
+	Host   string
+	Class  ErrorClass
+	Count  int
+	Sample error
+}
+
+type errorKey struct {
+	host  string
+	class ErrorClass
+}
+
+// errorStats aggregates errors by host and error class. It is safe for concurrent use.
+type errorStats struct {
+	mu    sync.Mutex
+	stats map[errorKey]*ErrorStat
+}
+
+func newErrorStats() *errorStats {
+	return &errorStats{
+		stats: make(map[errorKey]*ErrorStat),
+	}
+}
+
+func (e *errorStats) add(address string, err error) {
+	var (
+		host  string
+		class = classifyError(err)
+	)
+
+	if u, perr := url.Parse(address); perr == nil {
+		host = u.Hostname()
+	}
+
+	key := errorKey{host: host, class: class}
+
+	e.mu.Lock()
+	if stat, ok := e.stats[key]; ok {
+		stat.Count++
+	} else {
+		e.stats[key] = &ErrorStat{Host: host, Class: class, Count: 1, Sample: err}
+	}
+	e.mu.Unlock()
+}
+
+// summary returns the aggregated errors, the most frequent first.
+func (e *errorStats) summary() []ErrorStat {
+	e.mu.Lock()
+	stats := make([]ErrorStat, 0, len(e.stats))
+	for _, stat := range e.stats {
+		stats = append(stats, *stat)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+
+		if stats[i].Host != stats[j].Host {
+			return stats[i].Host < stats[j].Host
+		}
+
+		return stats[i].Class < stats[j].Class
+	})
+
+	return stats
+}
+
+func classifyError(err error) ErrorClass {
+	var (
+		dnsErr  *net.DNSError
+		certErr *tls.CertificateVerificationError
+		authErr x509.UnknownAuthorityError
+		hostErr x509.HostnameError
+		netErr  net.Error
+	)
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return ClassDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ClassRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ClassReset
+	case errors.As(err, &certErr), errors.As(err, &authErr), errors.As(err, &hostErr):
+		return ClassTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	case errors.Is(err, ErrBadResponse):
+		return ClassBadResponse
+	default:
+		return ClassOther
+	}
+}
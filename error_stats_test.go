@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestErrorsAreAggregatedByHostAndClass(t *testing.T) {
+	var (
+		stats  = newErrorStats()
+		dnsErr = &net.DNSError{Err: "no such host", Name: "example.com"}
+	)
+
+	for i := 0; i < 3; i++ {
+		stats.add("http://example.com/page", dnsErr)
+	}
+
+	stats.add("http://example.com/other", ErrBadResponse)
+	stats.add("http://example.org/", errors.New("unexpected"))
+
+	summary := stats.summary()
+
+	if len(summary) != 3 {
+		t.Fatalf("Unexpected number of aggregated errors: %d\n", len(summary))
+	}
+
+	if summary[0].Host != "example.com" || summary[0].Class != ClassDNS || summary[0].Count != 3 {
+		t.Errorf("Unexpected aggregate: %+v\n", summary[0])
+	}
+
+	if summary[1].Class != ClassBadResponse || summary[2].Class != ClassOther {
+		t.Errorf("Unexpected classes: %s, %s\n", summary[1].Class, summary[2].Class)
+	}
+}
@@ -1,10 +1,114 @@
 package main
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrInvalidHtml = errors.New("Error while parsing HTML")
 	ErrBadResponse = errors.New("Wrong HTTP response code")
+	ErrNotModified = errors.New("Content not modified")
+	ErrRedirects   = errors.New("Too many redirects")
 	ErrNoArgument  = errors.New("No argument")
 	ErrInvalidURL  = errors.New("Invalid URL")
+	ErrInvalidRef  = errors.New("Invalid reference")
+
+	ErrAlreadyCrawled = errors.New("Crawler has already crawled, it must be reset first")
+	ErrCrawlRunning   = errors.New("Crawl is still running")
+
+	ErrInvalidPolicy  = errors.New("Invalid asset policy")
+	ErrInvalidPart    = errors.New("Invalid link variant part")
+	ErrInvalidHeader  = errors.New("Invalid header")
+	ErrInvalidProxy   = errors.New("Invalid proxy")
+	ErrInvalidHost    = errors.New("Invalid host override")
+	ErrInvalidPin     = errors.New("Invalid certificate pin")
+	ErrInvalidRewrite = errors.New("Invalid rewrite rule")
+	ErrPinMismatch    = errors.New("Certificate chain does not match the pins")
+	ErrHTTP3Proxy     = errors.New("HTTP/3 cannot be routed through the proxies")
+	ErrInvalidColumn  = errors.New("Invalid sink column")
+	ErrInvalidTable   = errors.New("Invalid sink table")
+	ErrInvalidGraph   = errors.New("Unsupported graph format")
+	ErrGraphTooLarge  = errors.New("Too many pages for the graph")
+	ErrSinkInsert     = errors.New("Inserting rows into the sink failed")
+	ErrRedisReply     = errors.New("Redis replied with an error")
+	ErrRedisProtocol  = errors.New("Invalid Redis reply")
+
+	ErrInvalidSeverity   = errors.New("Invalid finding severity")
+	ErrInvalidIntegrity  = errors.New("Invalid integrity metadata")
+	ErrUnsupportedSchema = errors.New("Unsupported schema version")
+	ErrNoCheckpoint      = errors.New("Debug bundle has no checkpoint")
+	ErrInvalidVisitedSet = errors.New("Invalid visited set")
+	ErrStalled           = errors.New("Stuck on the URL past the stall timeout")
+	ErrInvariant         = errors.New("Crawl invariant violated")
+
+	ErrInvalidConfigVersion = errors.New("Invalid config schema version")
+	ErrUnknownConfigKey     = errors.New("Unknown config key")
+)
+
+// CrawlPhase identifies the step of the crawl an error was encountered in.
+type CrawlPhase string
+
+const (
+	PhaseDownload CrawlPhase = "download"
+	PhaseExtract  CrawlPhase = "extract"
+	PhaseAsset    CrawlPhase = "asset"
+	PhaseOutput   CrawlPhase = "output"
+	PhaseCrawl    CrawlPhase = "crawl"
 )
+
+// CrawlError struct represents an error encountered while crawling the resource under Url.
+// Phase is the step of the crawl which failed, Attempt the number of the attempt to download the resource
+// (starting from 1) and Retry whether it will be downloaded again. The errors of the crawl as a whole,
+// e.g. ErrAlreadyCrawled, have neither Url nor Phase. Worker identifies the fetcher or the worker which
+// encountered the error, e.g. fetcher-3 (see Crawler.Workers), if any.
+type CrawlError struct {
+	Url     string
+	Phase   CrawlPhase
+	Attempt int
+	Worker  string
+	Err     error
+	Retry   bool
+}
+
+func (e CrawlError) Error() string {
+	worker := ""
+	if e.Worker != "" {
+		worker = ", " + e.Worker
+	}
+
+	switch {
+	case e.Url == "":
+		return e.Err.Error()
+	case e.Retry:
+		return fmt.Sprintf("%s %s (attempt %d, retrying%s): %s", e.Phase, e.Url, e.Attempt, worker, e.Err.Error())
+	default:
+		return fmt.Sprintf("%s %s (attempt %d%s): %s", e.Phase, e.Url, e.Attempt, worker, e.Err.Error())
+	}
+}
+
+func (e CrawlError) Unwrap() error {
+	return e.Err
+}
+
+// RedirectError struct represents a redirect from Url to Location which the downloader did not follow.
+type RedirectError struct {
+	Url, Location string
+	StatusCode    int
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("%s redirects to %s (%d)", e.Url, e.Location, e.StatusCode)
+}
+
+// SkippedContentError struct represents a resource under Url which was not downloaded since its HEAD response
+// announced a content type or size (-1 if unknown) the crawl skips, Reason telling which (see Options.HeadFirst).
+type SkippedContentError struct {
+	Url, ContentType string
+	Size             int64
+	Reason           SkipReason
+}
+
+func (e *SkippedContentError) Error() string {
+	return fmt.Sprintf("%s skipped as %s (%s, %d bytes)", e.Url, e.Reason, e.ContentType, e.Size)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// eventBuffer is the number of events kept for a client reading them slower than they come, past which
+// the client misses them rather than holding up the crawl
+const eventBuffer = 256
+
+// eventsShutdownTimeout bounds how long the events server waits for the clients to be sent the done event
+const eventsShutdownTimeout = 5 * time.Second
+
+// streamEvent is a Server-Sent Event, its name and JSON data
+type streamEvent struct {
+	name string
+	data []byte
+}
+
+// pageEvent is the data of the page events, the links of the page being still discovered as the event is sent
+type pageEvent struct {
+	Url       string    `json:"url"`
+	Title     string    `json:"title"`
+	Depth     int       `json:"depth"`
+	Size      int       `json:"size,omitempty"`
+	Change    string    `json:"change,omitempty"`
+	CrawledAt time.Time `json:"crawled_at"`
+}
+
+// EventStream struct streams the progress of a running crawl to its clients as Server-Sent Events, so that they
+// are told about it instead of polling: a stats event with the Stats every time they are reported (see StatsInterval),
+// a page event with the URL, title, depth, size and change of every page once it is crawled, and a done event with
+// the final Stats once the crawl is done, after which the streams end. Page and Stats are meant to be passed
+// as the OnPage hook (see Hook) and the OnStats option. A client reading slower than the events come misses some of them.
+// The events can be logged as well (see Log), whether there are clients or not.
+type EventStream struct {
+	mu      sync.Mutex
+	clients map[chan streamEvent]struct{}
+	last    *streamEvent
+	done    bool
+	log     io.Writer
+}
+
+func NewEventStream() *EventStream {
+	return &EventStream{
+		clients: make(map[chan streamEvent]struct{}),
+	}
+}
+
+// Page sends a page event for the crawled page
+func (s *EventStream) Page(page *Page) {
+	s.send("page", pageEvent{
+		Url:       page.Url,
+		Title:     page.Title,
+		Depth:     page.Depth,
+		Size:      page.Size,
+		Change:    string(page.Change),
+		CrawledAt: page.CrawledAt,
+	})
+}
+
+// Hook returns a copy of the hooks (none if nil) whose OnPage also sends a page event for the crawled page,
+// after calling their own OnPage
+func (s *EventStream) Hook(hooks *Hooks) *Hooks {
+	var chained Hooks
+	if hooks != nil {
+		chained = *hooks
+	}
+
+	onPage := chained.OnPage
+	chained.OnPage = func(page *Page) {
+		if onPage != nil {
+			onPage(page)
+		}
+
+		s.Page(page)
+	}
+
+	return &chained
+}
+
+// Log writes every event sent from now on to w as well, as a JSON line, e.g. {"event":"page","data":{...}},
+// so that the events of the crawl are kept along with its results
+func (s *EventStream) Log(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log = w
+}
+
+// Stats sends a stats event with the progress of the crawl
+func (s *EventStream) Stats(stats Stats) {
+	s.send("stats", stats)
+}
+
+// Done sends a done event with the final stats of the crawl and ends the streams. The clients connecting
+// afterwards are only sent the done event.
+func (s *EventStream) Done(stats Stats) {
+	s.send("done", stats)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done = true
+	for client := range s.clients {
+		close(client)
+		delete(s.clients, client)
+	}
+}
+
+func (s *EventStream) send(name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	event := streamEvent{name: name, data: data}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+
+	if s.log != nil {
+		fmt.Fprintf(s.log, "{\"event\":%q,\"data\":%s}\n", name, data)
+	}
+
+	// The latest stats are sent to the clients as soon as they connect
+	if name != "page" {
+		s.last = &event
+	}
+
+	for client := range s.clients {
+		select {
+		case client <- event:
+		default:
+		}
+	}
+}
+
+// ServeHTTP streams the events to the client until the crawl is done or the client disconnects
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan streamEvent, eventBuffer)
+
+	s.mu.Lock()
+	last, done := s.last, s.done
+	if !done {
+		s.clients[client] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if last != nil {
+		writeEvent(w, *last)
+	}
+	flusher.Flush()
+
+	if done {
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-client:
+			if !ok {
+				return
+			}
+
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-r.Context().Done():
+			s.mu.Lock()
+			delete(s.clients, client)
+			s.mu.Unlock()
+
+			return
+		}
+	}
+}
+
+// serveEvents serves the events under /events at the address until closeEvents is called
+func serveEvents(address string, stream *EventStream) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", stream)
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	return server, nil
+}
+
+// closeEvents waits for the clients to be sent the done event before closing the server
+func closeEvents(server *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventsShutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+func writeEvent(w http.ResponseWriter, event streamEvent) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data)
+}
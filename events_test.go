@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readEvents reads the Server-Sent Events of the response until the stream ends
+func readEvents(t *testing.T, resp *http.Response) map[string][]string {
+	events := make(map[string][]string)
+
+	var name string
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			events[name] = append(events[name], strings.TrimPrefix(line, "data: "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Errorf("Stream fails with error: %s\n", err.Error())
+	}
+
+	return events
+}
+
+func TestEventStreamSendsEvents(t *testing.T) {
+	stream := NewEventStream()
+	stream.Stats(Stats{Queued: 1})
+
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request fails with error: %s\n", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Unexpected content type: %s\n", ct)
+	}
+
+	stream.Page(&Page{Url: "https://example.com/", Title: "Example", Depth: 1})
+	stream.Stats(Stats{Fetched: 1})
+	stream.Done(Stats{Fetched: 2})
+
+	events := readEvents(t, resp)
+
+	if s := events["stats"]; len(s) != 2 || s[0] != `{"queued":1,"busy":0,"fetched":0,"bytes":0,"errors":0,"retries":0,"elapsed":0,"pages_per_second":0}` {
+		t.Errorf("Unexpected stats events: %v\n", s)
+	}
+
+	var page pageEvent
+	if p := events["page"]; len(p) != 1 || json.Unmarshal([]byte(p[0]), &page) != nil || page.Url != "https://example.com/" || page.Title != "Example" || page.Depth != 1 {
+		t.Errorf("Unexpected page events: %v\n", p)
+	}
+
+	var final Stats
+	if d := events["done"]; len(d) != 1 || json.Unmarshal([]byte(d[0]), &final) != nil || final.Fetched != 2 {
+		t.Errorf("Unexpected done events: %v\n", d)
+	}
+
+	// The clients connecting once the crawl is done are only sent the done event
+	late, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request fails with error: %s\n", err.Error())
+	}
+	defer late.Body.Close()
+
+	if events := readEvents(t, late); len(events) != 1 || len(events["done"]) != 1 {
+		t.Errorf("Unexpected events after the crawl: %v\n", events)
+	}
+}
+
+func TestEventStreamLogsEvents(t *testing.T) {
+	var log strings.Builder
+
+	stream := NewEventStream()
+	stream.Page(&Page{Url: "https://example.com/a"})
+
+	stream.Log(&log)
+	stream.Page(&Page{Url: "https://example.com/b"})
+	stream.Done(Stats{Fetched: 2})
+	stream.Stats(Stats{Fetched: 3})
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected log: %s\n", log.String())
+	}
+
+	var page struct {
+		Event string    `json:"event"`
+		Data  pageEvent `json:"data"`
+	}
+
+	if err := json.Unmarshal([]byte(lines[0]), &page); err != nil || page.Event != "page" || page.Data.Url != "https://example.com/b" {
+		t.Errorf("Unexpected page event: %s\n", lines[0])
+	}
+
+	if !strings.HasPrefix(lines[1], `{"event":"done","data":{"queued":0,"busy":0,"fetched":2,`) {
+		t.Errorf("Unexpected done event: %s\n", lines[1])
+	}
+}
+
+func TestCrawlerStreamsEvents(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\n%s/b\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		stream = NewEventStream()
+		mu     sync.Mutex
+		hooked int
+	)
+
+	events := httptest.NewServer(stream)
+	defer events.Close()
+
+	// The page events are added to the hooks already set
+	hooks := stream.Hook(&Hooks{OnPage: func(page *Page) {
+		mu.Lock()
+		hooked++
+		mu.Unlock()
+	}})
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:    2,
+		MaxRetries:    1,
+		Extractor:     lineExtractor{},
+		IgnoreRobots:  true,
+		Hooks:         hooks,
+		OnStats:       stream.Stats,
+		StatsInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	resp, err := http.Get(events.URL)
+	if err != nil {
+		t.Fatalf("Request fails with error: %s\n", err.Error())
+	}
+	defer resp.Body.Close()
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	stream.Done(c.Stats())
+
+	received := readEvents(t, resp)
+
+	urls := make([]string, 0)
+	for _, data := range received["page"] {
+		var page pageEvent
+		if err := json.Unmarshal([]byte(data), &page); err != nil {
+			t.Errorf("Invalid page event: %s\n", data)
+		}
+
+		urls = append(urls, page.Url)
+	}
+	sort.Strings(urls)
+
+	if expected := []string{server.URL + "/", server.URL + "/a", server.URL + "/b"}; strings.Join(urls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected page events: %v\n", urls)
+	}
+
+	if hooked != 3 {
+		t.Errorf("Unexpected pages passed to the hook: %d\n", hooked)
+	}
+
+	if len(received["stats"]) == 0 || len(received["done"]) != 1 {
+		t.Errorf("Unexpected progress events: %v\n", received)
+	}
+}
@@ -2,11 +2,12 @@ package main
 
 import (
 	"bytes"
-	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 
 	"golang.org/x/net/html"
 )
@@ -14,18 +15,115 @@ import (
 // Extractor interface abstract the operation of extracting interesting pieces of data from the content.
 // As of now the website's title, list of internal hyperlings and list of static assets are extracted
 // If extraction fails an error is returned.
+// Resource hints (link elements with rel preload, modulepreload, prefetch, preconnect or dns-prefetch)
+// are extracted as assets of the Hint type, whether they point to a file or an origin.
 type Extractor interface {
 	Extract(body []byte) (name string, links []string, assets []*Asset, err error)
 }
 
+// InlineExtractor interface abstracts extracting the same data as Extractor along with the sizes
+// of the inline scripts and stylesheets of the content, which do not appear as assets.
+type InlineExtractor interface {
+	ExtractInline(body []byte) (name string, links []string, assets []*Asset, inline InlineSizes, err error)
+}
+
+// TargetExtractor interface abstracts extracting the links of the content which open in a new window
+// (target="_blank") without rel="noopener" or rel="noreferrer", so that the opened page gets access to window.opener.
+type TargetExtractor interface {
+	ExtractUnsafeTargets(body []byte) ([]string, error)
+}
+
+// CanonicalExtractor interface abstracts extracting the canonical URL the content declares
+// with <link rel="canonical">, empty if it declares none.
+type CanonicalExtractor interface {
+	ExtractCanonical(body []byte) (string, error)
+}
+
+// FeedExtractor interface abstracts extracting the URLs of the RSS and Atom feeds the content declares
+// with <link rel="alternate" type="application/rss+xml"> (or application/atom+xml).
+type FeedExtractor interface {
+	ExtractFeeds(body []byte) ([]string, error)
+}
+
+// ExternalLinkExtractor interface abstracts extracting the links of the content out of the domain of the extractor,
+// which are recorded but not followed.
+type ExternalLinkExtractor interface {
+	ExtractExternalLinks(body []byte) ([]string, error)
+}
+
+// RobotsExtractor interface abstracts extracting the directives of the content to robots,
+// given by the robots meta tag and the rel="nofollow" attributes of the links.
+type RobotsExtractor interface {
+	ExtractRobots(body []byte) (RobotsDirectives, error)
+}
+
+// RobotsDirectives struct represents the directives of a page to robots. NoIndex and NoFollow are set by
+// <meta name="robots"> with the content noindex, nofollow or none (which means both), NofollowLinks holds
+// the links with rel="nofollow".
+type RobotsDirectives struct {
+	NoIndex, NoFollow bool
+	NofollowLinks     []string
+}
+
+// SkipCounter interface abstracts counting the references skipped by the extractor because they do not point
+// to anything which could be crawled, by the category of the reference.
+type SkipCounter interface {
+	Skipped() map[SkippedRef]int
+}
+
+// SkippedRef defines why a reference of the content was skipped.
+type SkippedRef string
+
+const (
+	SkippedFragment   SkippedRef = "fragment"
+	SkippedJavascript SkippedRef = "javascript"
+	SkippedData       SkippedRef = "data"
+	SkippedScheme     SkippedRef = "other-scheme"
+)
+
+// InlineSizes struct holds the total number of bytes of inline scripts and stylesheets.
+type InlineSizes struct {
+	Scripts, Styles int
+}
+
+// ExtractorOptions struct represents list of optional parameters to the default extractor.
+// RouteFragments makes the extractor keep hash-bang (#!/) and hash-slash (#/) fragments,
+// which single page applications use for routing, so that each route is crawled as a distinct page.
+// Any other fragment is stripped from the extracted URLs.
+// IncludeSubdomains makes the links to the subdomains of ScopeDomain extracted as well, ScopeDomain being
+// the host of the extractor's domain without the www. prefix by default (e.g. blog.example.com for example.com).
+// KeepFragments makes the extractor keep all fragments of the links (but not of the assets), so that the links
+// differing only in the fragment can be reported, the crawler strips them before crawling the links anyway.
+type ExtractorOptions struct {
+	RouteFragments    bool
+	IncludeSubdomains bool
+	ScopeDomain       string
+	KeepFragments     bool
+}
+
 // defaultExtractor implementation uses the golang.org/x/net/html for tokenizing the html tree.
-// A unique list of links and assets is generated.
+// A unique list of links and assets is generated. References to fragments of the same page,
+// javascript: and data: URIs and URIs of schemes other than HTTP (e.g. mailto:) are skipped.
 type defaultExtractor struct {
-	domain    *url.URL
-	fileRegex *regexp.Regexp
+	domain         *url.URL
+	resolver       *Resolver
+	linkResolver   *Resolver
+	fileRegex      *regexp.Regexp
+	routeFragments bool
+
+	// Hosts ending with .scope are in scope too, empty if subdomains are not included
+	scope string
+
+	// Since the extractor can be used by multiple goroutines, the counters are guarded with a mutex
+	muskip  sync.Mutex
+	skipped map[SkippedRef]int
 }
 
 func NewDefaultExtractor(domain string) (Extractor, error) {
+	return NewDefaultExtractorWithOptions(domain, &ExtractorOptions{})
+}
+
+func NewDefaultExtractorWithOptions(domain string, options *ExtractorOptions) (Extractor, error) {
 	u, err := url.ParseRequestURI(domain)
 	if err != nil {
 		return nil, err
@@ -37,19 +135,84 @@ func NewDefaultExtractor(domain string) (Extractor, error) {
 
 	r := regexp.MustCompile("^(/.*){0,}[\\w,\\s-]+\\.[A-Za-z]{1,}$")
 
-	return &defaultExtractor{
-		domain:    u,
-		fileRegex: r,
-	}, nil
+	resolver, err := NewResolver(domain, options.RouteFragments)
+	if err != nil {
+		return nil, err
+	}
+
+	linkResolver := resolver
+	if options.KeepFragments {
+		if linkResolver, err = newResolver(domain, options.RouteFragments, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// The links are expanded with the canonical host
+	u.Host = hostOf(u)
+
+	d := &defaultExtractor{
+		domain:         u,
+		resolver:       resolver,
+		linkResolver:   linkResolver,
+		fileRegex:      r,
+		routeFragments: options.RouteFragments,
+		skipped:        make(map[SkippedRef]int),
+	}
+
+	if options.IncludeSubdomains {
+		d.scope = options.ScopeDomain
+		if d.scope == "" {
+			d.scope = scopeOf(u.Hostname())
+		}
+	}
+
+	return d, nil
+}
+
+// scopeOf returns the domain whose subdomains are in scope of the host, i.e. the host without the www. prefix
+func scopeOf(host string) string {
+	return strings.TrimPrefix(hostnameOf(host), "www.")
+}
+
+// hostnameOf returns the host name in lower case and without the trailing dots of a fully qualified name,
+// so that example.com. and Example.com are the same host as example.com
+func hostnameOf(host string) string {
+	return strings.TrimRight(strings.ToLower(host), ".")
+}
+
+// hostOf returns the canonical host of the URL: its host name as returned by hostnameOf,
+// followed by the port unless it is the default port of the scheme
+func hostOf(u *url.URL) string {
+	host, port := hostnameOf(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+
+	return host
 }
 
 func (d *defaultExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	title, links, assets, _, err := d.ExtractInline(body)
+	return title, links, assets, err
+}
+
+func (d *defaultExtractor) ExtractInline(body []byte) (string, []string, []*Asset, InlineSizes, error) {
 	var (
 		z                   *html.Tokenizer     = html.NewTokenizer(bytes.NewReader(body))
 		setLinks, setAssets map[string]struct{} = make(map[string]struct{}), make(map[string]struct{})
+		setHints            map[string]struct{} = make(map[string]struct{})
 		title               string
 		links               []string = make([]string, 0)
 		assets              []*Asset = make([]*Asset, 0)
+		inline              InlineSizes
 	)
 
 	for {
@@ -60,7 +223,7 @@ func (d *defaultExtractor) Extract(body []byte) (string, []string, []*Asset, err
 				break
 			}
 
-			return "", []string{}, []*Asset{}, z.Err()
+			return "", []string{}, []*Asset{}, InlineSizes{}, z.Err()
 		}
 
 		if tt == html.StartTagToken {
@@ -74,28 +237,49 @@ func (d *defaultExtractor) Extract(body []byte) (string, []string, []*Asset, err
 				}
 			case "a":
 				for _, a := range t.Attr {
-					if a.Key == "href" {
-						if d.isFileUrl(a.Val) {
-							expanded := d.expandIfNeeded(a.Val)
+					if a.Key != "href" || d.skip(a.Val) {
+						continue
+					}
+
+					if expanded, err := d.resolver.Resolve(a.Val); err == nil {
+						if d.isFileUrl(expanded) {
 							if _, ok := setAssets[expanded]; !ok {
 								assets = append(assets, &Asset{Url: expanded, Type: Link})
 								setAssets[expanded] = struct{}{}
 							}
-						} else if d.isSameDomain(a.Val) {
-							expanded := d.expandIfNeeded(a.Val)
-							if _, ok := setLinks[expanded]; !ok {
-								links = append(links, expanded)
-								setLinks[expanded] = struct{}{}
+						} else if d.isSameDomain(expanded) {
+							link, _ := d.linkResolver.Resolve(a.Val)
+							if _, ok := setLinks[link]; !ok {
+								links = append(links, link)
+								setLinks[link] = struct{}{}
 							}
 						}
 					}
 				}
 			case "script":
+				var src, integrity string
 				for _, a := range t.Attr {
-					if a.Key == "src" {
-						d.addAsset(&assets, setAssets, a.Val, Script)
+					switch a.Key {
+					case "src":
+						src = a.Val
+					case "integrity":
+						integrity = a.Val
 					}
 				}
+
+				external := src != ""
+				if external {
+					d.addAsset(&assets, setAssets, src, Script)
+					d.setIntegrity(assets, src, integrity)
+				}
+
+				if !external && z.Next() == html.TextToken {
+					inline.Scripts += len(z.Text())
+				}
+			case "style":
+				if z.Next() == html.TextToken {
+					inline.Styles += len(z.Text())
+				}
 			case "img":
 				for _, a := range t.Attr {
 					if a.Key == "src" {
@@ -103,11 +287,26 @@ func (d *defaultExtractor) Extract(body []byte) (string, []string, []*Asset, err
 					}
 				}
 			case "link":
+				var href, rel, as, integrity string
 				for _, a := range t.Attr {
-					if a.Key == "href" {
-						d.addAsset(&assets, setAssets, a.Val, Link)
+					switch a.Key {
+					case "href":
+						href = a.Val
+					case "rel":
+						rel = a.Val
+					case "as":
+						as = a.Val
+					case "integrity":
+						integrity = a.Val
 					}
 				}
+
+				if hint := resourceHint(rel); hint != "" {
+					d.addHint(&assets, setHints, href, hint, as)
+				} else if href != "" {
+					d.addAsset(&assets, setAssets, href, Link)
+					d.setIntegrity(assets, href, integrity)
+				}
 			case "source":
 				if tt == html.TextToken {
 					d.addAsset(&assets, setAssets, z.Token().Data, Video)
@@ -116,12 +315,296 @@ func (d *defaultExtractor) Extract(body []byte) (string, []string, []*Asset, err
 		}
 	}
 
-	return title, links, assets, nil
+	return title, links, assets, inline, nil
+}
+
+func (d *defaultExtractor) ExtractUnsafeTargets(body []byte) ([]string, error) {
+	var (
+		z       = html.NewTokenizer(bytes.NewReader(body))
+		set     = make(map[string]struct{})
+		targets = make([]string, 0)
+	)
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				break
+			}
+
+			return []string{}, z.Err()
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.Data != "a" && t.Data != "area" {
+			continue
+		}
+
+		var href, target, rel string
+		for _, a := range t.Attr {
+			switch a.Key {
+			case "href":
+				href = a.Val
+			case "target":
+				target = a.Val
+			case "rel":
+				rel = a.Val
+			}
+		}
+
+		if href == "" || !strings.EqualFold(target, "_blank") || isOpenerSafe(rel) || refCategory(href, d.routeFragments) != "" {
+			continue
+		}
+
+		expanded, err := d.resolver.Resolve(href)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := set[expanded]; !ok {
+			targets = append(targets, expanded)
+			set[expanded] = struct{}{}
+		}
+	}
+
+	return targets, nil
+}
+
+func (d *defaultExtractor) ExtractCanonical(body []byte) (string, error) {
+	z := html.NewTokenizer(bytes.NewReader(body))
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return "", nil
+			}
+
+			return "", z.Err()
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.Data != "link" {
+			continue
+		}
+
+		var href, rel string
+		for _, a := range t.Attr {
+			switch a.Key {
+			case "href":
+				href = a.Val
+			case "rel":
+				rel = a.Val
+			}
+		}
+
+		if !hasRel(rel, "canonical") || href == "" {
+			continue
+		}
+
+		// The first canonical link counts, even if it is invalid
+		canonical, err := d.resolver.Resolve(href)
+		if err != nil {
+			return "", nil
+		}
+
+		return canonical, nil
+	}
+}
+
+func (d *defaultExtractor) ExtractFeeds(body []byte) ([]string, error) {
+	var (
+		z     = html.NewTokenizer(bytes.NewReader(body))
+		feeds = make([]string, 0)
+		set   = make(map[string]struct{})
+	)
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return feeds, nil
+			}
+
+			return nil, z.Err()
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.Data != "link" {
+			continue
+		}
+
+		var href, rel, kind string
+		for _, a := range t.Attr {
+			switch a.Key {
+			case "href":
+				href = a.Val
+			case "rel":
+				rel = a.Val
+			case "type":
+				kind = strings.ToLower(strings.TrimSpace(a.Val))
+			}
+		}
+
+		if !hasRel(rel, "alternate") || (kind != "application/rss+xml" && kind != "application/atom+xml") || href == "" {
+			continue
+		}
+
+		feed, err := d.resolver.Resolve(href)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := set[feed]; !ok {
+			set[feed] = struct{}{}
+			feeds = append(feeds, feed)
+		}
+	}
+}
+
+func (d *defaultExtractor) ExtractExternalLinks(body []byte) ([]string, error) {
+	var (
+		z     = html.NewTokenizer(bytes.NewReader(body))
+		links = make([]string, 0)
+		set   = make(map[string]struct{})
+	)
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return links, nil
+			}
+
+			return nil, z.Err()
+		}
+
+		if tt != html.StartTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.Data != "a" {
+			continue
+		}
+
+		for _, a := range t.Attr {
+			if a.Key != "href" || refCategory(a.Val, false) != "" {
+				continue
+			}
+
+			// The files are extracted as assets wherever they are
+			link, err := d.resolver.Resolve(a.Val)
+			if err != nil || d.isFileUrl(link) || d.isSameDomain(link) {
+				continue
+			}
+
+			if _, ok := set[link]; !ok {
+				set[link] = struct{}{}
+				links = append(links, link)
+			}
+		}
+	}
+}
+
+func (d *defaultExtractor) ExtractRobots(body []byte) (RobotsDirectives, error) {
+	var (
+		z          = html.NewTokenizer(bytes.NewReader(body))
+		directives = RobotsDirectives{NofollowLinks: make([]string, 0)}
+	)
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return directives, nil
+			}
+
+			return RobotsDirectives{}, z.Err()
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+
+		attrs := make(map[string]string, len(t.Attr))
+		for _, a := range t.Attr {
+			attrs[a.Key] = a.Val
+		}
+
+		switch t.Data {
+		case "meta":
+			if !strings.EqualFold(strings.TrimSpace(attrs["name"]), "robots") {
+				continue
+			}
+
+			for _, v := range strings.Split(strings.ToLower(attrs["content"]), ",") {
+				switch strings.TrimSpace(v) {
+				case "noindex":
+					directives.NoIndex = true
+				case "nofollow":
+					directives.NoFollow = true
+				case "none":
+					directives.NoIndex, directives.NoFollow = true, true
+				}
+			}
+		case "a", "area":
+			if href := attrs["href"]; hasRel(attrs["rel"], "nofollow") && refCategory(href, d.routeFragments) == "" {
+				if link, err := d.resolver.Resolve(href); err == nil {
+					directives.NofollowLinks = append(directives.NofollowLinks, link)
+				}
+			}
+		}
+	}
+}
+
+// hasRel reports whether the value is among the space separated rel values
+func hasRel(rel, value string) bool {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isOpenerSafe reports whether the space separated rel values keep the opened page from accessing window.opener
+func isOpenerSafe(rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == "noopener" || r == "noreferrer" {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (d *defaultExtractor) addAsset(assets *[]*Asset, set map[string]struct{}, address string, kind AssetType) {
-	if d.isFileUrl(address) {
-		expanded := d.expandIfNeeded(address)
+	if d.skip(address) {
+		return
+	}
+
+	if expanded, err := d.resolver.Resolve(address); err == nil && d.isFileUrl(expanded) {
 		if _, ok := set[expanded]; !ok {
 			*assets = append(*assets, &Asset{Url: expanded, Type: kind})
 			set[expanded] = struct{}{}
@@ -129,30 +612,122 @@ func (d *defaultExtractor) addAsset(assets *[]*Asset, set map[string]struct{}, a
 	}
 }
 
-func (d *defaultExtractor) isSameDomain(address string) bool {
-	u, err := url.Parse(address)
+// setIntegrity sets the integrity metadata of the asset added last, if it was added for the address
+func (d *defaultExtractor) setIntegrity(assets []*Asset, address, integrity string) {
+	if integrity == "" || len(assets) == 0 {
+		return
+	}
+
+	last := assets[len(assets)-1]
+	if expanded, err := d.resolver.Resolve(address); err == nil && last.Url == expanded {
+		last.Integrity = strings.TrimSpace(integrity)
+	}
+}
+
+// addHint adds the resource hint unless the same hint has already been added. Unlike other assets,
+// preconnect and dns-prefetch hints point to origins rather than files, so the address is not checked.
+func (d *defaultExtractor) addHint(assets *[]*Asset, set map[string]struct{}, address, rel, as string) {
+	if address == "" || d.skip(address) {
+		return
+	}
+
+	expanded, err := d.resolver.Resolve(address)
 	if err != nil {
+		return
+	}
+
+	key := rel + " " + expanded
+	if _, ok := set[key]; !ok {
+		*assets = append(*assets, &Asset{Url: expanded, Type: Hint, Rel: rel, As: strings.ToLower(as)})
+		set[key] = struct{}{}
+	}
+}
+
+// resourceHint returns the kind of the resource hint among the space separated rel values, empty if there is none
+func resourceHint(rel string) string {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		switch r {
+		case "preload", "modulepreload", "prefetch", "preconnect", "dns-prefetch":
+			return r
+		}
+	}
+
+	return ""
+}
+
+// Skipped returns the number of references skipped since the extractor was created, by category.
+func (d *defaultExtractor) Skipped() map[SkippedRef]int {
+	d.muskip.Lock()
+	defer d.muskip.Unlock()
+
+	skipped := make(map[SkippedRef]int, len(d.skipped))
+	for category, n := range d.skipped {
+		skipped[category] = n
+	}
+
+	return skipped
+}
+
+// skip reports whether the reference must be skipped, counting it if so
+func (d *defaultExtractor) skip(address string) bool {
+	category := refCategory(address, d.routeFragments)
+	if category == "" {
 		return false
 	}
 
-	return (u.Host == "") || d.domain.Host == u.Host
+	d.muskip.Lock()
+	d.skipped[category]++
+	d.muskip.Unlock()
+
+	return true
 }
 
-func (d *defaultExtractor) expandIfNeeded(address string) string {
+// refCategory returns why the reference does not point to anything which could be crawled, empty if it does.
+// Route fragments are only skipped if they are not kept.
+func refCategory(address string, routeFragments bool) SkippedRef {
+	address = strings.TrimSpace(address)
+	lower := strings.ToLower(address)
+
+	switch {
+	case strings.HasPrefix(lower, "javascript:"):
+		return SkippedJavascript
+	case strings.HasPrefix(lower, "data:"):
+		return SkippedData
+	case strings.HasPrefix(address, "#"):
+		if routeFragments && (strings.HasPrefix(address, "#!/") || strings.HasPrefix(address, "#/")) {
+			return ""
+		}
+
+		return SkippedFragment
+	}
+
+	if u, err := url.Parse(address); err == nil && u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return SkippedScheme
+	}
+
+	return ""
+}
+
+func (d *defaultExtractor) isSameDomain(address string) bool {
 	u, err := url.Parse(address)
 	if err != nil {
-		return ""
+		return false
 	}
 
-	if u.Host == "" {
-		if strings.HasPrefix(u.Path, "/") {
-			address = fmt.Sprintf("%s://%s%s", d.domain.Scheme, d.domain.Host, address)
-		} else {
-			address = fmt.Sprintf("%s://%s/%s", d.domain.Scheme, d.domain.Host, address)
-		}
+	if u.Host == "" || d.domain.Host == hostOf(u) {
+		return true
+	}
+
+	return d.inScope(u.Hostname())
+}
+
+func (d *defaultExtractor) inScope(host string) bool {
+	if d.scope == "" {
+		return false
 	}
 
-	return address
+	host = hostnameOf(host)
+	return host == d.scope || strings.HasSuffix(host, "."+d.scope)
 }
 
 func (d *defaultExtractor) isFileUrl(address string) bool {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -139,3 +140,324 @@ func TestExtractorRecongizesTags(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractorHandlesRouteFragments(t *testing.T) {
+	var (
+		url   = "http://example.com/"
+		cases = []struct {
+			address, stripped, kept string
+		}{
+			{"/page#section", "http://example.com/page", "http://example.com/page"},
+			{"/#!/users/1", "http://example.com/", "http://example.com/#!/users/1"},
+			{"/app#/settings", "http://example.com/app", "http://example.com/app#/settings"},
+		}
+	)
+
+	strip, err := NewDefaultExtractor(url)
+	if err != nil {
+		t.Fatalf("Extractor fails for URL %s with error: %s\n", url, err.Error())
+	}
+
+	keep, err := NewDefaultExtractorWithOptions(url, &ExtractorOptions{RouteFragments: true})
+	if err != nil {
+		t.Fatalf("Extractor fails for URL %s with error: %s\n", url, err.Error())
+	}
+
+	for _, c := range cases {
+		if s, _ := strip.(*defaultExtractor).resolver.Resolve(c.address); s != c.stripped {
+			t.Errorf("Unexpected URL for %s: %s\n", c.address, s)
+		}
+
+		if s, _ := keep.(*defaultExtractor).resolver.Resolve(c.address); s != c.kept {
+			t.Errorf("Unexpected URL for %s with route fragments: %s\n", c.address, s)
+		}
+	}
+}
+
+func TestExtractorRecognizesResourceHints(t *testing.T) {
+	var (
+		html = `
+		<html>
+		    <head>
+		        <link rel="preconnect" href="https://cdn.example.org">
+		        <link rel="dns-prefetch" href="//fonts.example.org">
+		        <link href="/js/app.js" rel="preload" as="Script">
+		        <link rel="modulepreload" href="js/module.js">
+		        <link rel="prefetch" href="/next-page.html">
+		        <link rel="stylesheet" href="/css/main.css">
+		    </head>
+		    <body>
+		        <script src="/js/app.js"></script>
+		    </body>
+		</html>
+		`
+
+		expectedAssets = []*Asset{
+			{Url: "https://cdn.example.org", Type: Hint, Rel: "preconnect"},
+			{Url: "http://fonts.example.org", Type: Hint, Rel: "dns-prefetch"},
+			{Url: "http://example.com/js/app.js", Type: Hint, Rel: "preload", As: "script"},
+			{Url: "http://example.com/js/module.js", Type: Hint, Rel: "modulepreload"},
+			{Url: "http://example.com/next-page.html", Type: Hint, Rel: "prefetch"},
+			{Url: "http://example.com/css/main.css", Type: Link},
+			{Url: "http://example.com/js/app.js", Type: Script},
+		}
+	)
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	_, _, assets, err := e.Extract([]byte(html))
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	if len(assets) != len(expectedAssets) {
+		t.Fatalf("Unexpected number of assets: %d\n", len(assets))
+	}
+
+	for i := range assets {
+		if *assets[i] != *expectedAssets[i] {
+			t.Errorf("Unexpected asset: %v\n", *assets[i])
+		}
+	}
+}
+
+func TestExtractorMeasuresInlineScriptsAndStyles(t *testing.T) {
+	html := `
+	<html>
+	    <head>
+	        <style>body { margin: 0; }</style>
+	        <script src="/js/app.js"></script>
+	        <script>var a = 1;</script>
+	    </head>
+	    <body>
+	        <script>var b = 22;</script>
+	    </body>
+	</html>
+	`
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	_, _, _, inline, err := e.(InlineExtractor).ExtractInline([]byte(html))
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	if inline.Scripts != len("var a = 1;")+len("var b = 22;") || inline.Styles != len("body { margin: 0; }") {
+		t.Errorf("Unexpected inline sizes: %v\n", inline)
+	}
+}
+
+func TestExtractorIncludesSubdomains(t *testing.T) {
+	cases := []struct {
+		domain, address string
+		options         ExtractorOptions
+		expected        bool
+	}{
+		{"http://example.com/", "http://blog.example.com/post", ExtractorOptions{}, false},
+		{"http://example.com/", "http://blog.example.com/post", ExtractorOptions{IncludeSubdomains: true}, true},
+		{"http://www.example.com/", "http://shop.example.com/", ExtractorOptions{IncludeSubdomains: true}, true},
+		{"http://www.example.com/", "http://example.com/", ExtractorOptions{IncludeSubdomains: true}, true},
+		{"http://example.com/", "http://notexample.com/", ExtractorOptions{IncludeSubdomains: true}, false},
+		{"http://blog.example.com/", "http://www.example.com/", ExtractorOptions{IncludeSubdomains: true, ScopeDomain: "example.com"}, true},
+		{"http://blog.example.com/", "http://www.example.com/", ExtractorOptions{IncludeSubdomains: true}, false},
+	}
+
+	for _, tc := range cases {
+		e, err := NewDefaultExtractorWithOptions(tc.domain, &tc.options)
+		if err != nil {
+			t.Fatalf("Extractor fails with error: %s\n", err.Error())
+		}
+
+		if e.(*defaultExtractor).isSameDomain(tc.address) != tc.expected {
+			t.Errorf("Unexpected scope of %s for %s: %t\n", tc.address, tc.domain, !tc.expected)
+		}
+	}
+}
+
+func TestExtractorTreatsEquivalentHostsAsSameDomain(t *testing.T) {
+	cases := []struct {
+		domain, address, expanded string
+	}{
+		{"http://example.com/", "http://example.com./a", "http://example.com/a"},
+		{"http://example.com/", "http://Example.com:80/a#top", "http://example.com/a"},
+		{"https://example.com/", "https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com.:80/", "/a", "http://example.com/a"},
+		{"http://example.com:8080/", "http://example.com:8080/a", "http://example.com:8080/a"},
+	}
+
+	for _, tc := range cases {
+		e, err := NewDefaultExtractor(tc.domain)
+		if err != nil {
+			t.Fatalf("Extractor fails with error: %s\n", err.Error())
+		}
+
+		d := e.(*defaultExtractor)
+
+		if !d.isSameDomain(tc.address) {
+			t.Errorf("%s out of scope of %s\n", tc.address, tc.domain)
+		}
+
+		if expanded, _ := d.resolver.Resolve(tc.address); expanded != tc.expanded {
+			t.Errorf("Unexpected URL for %s: %s\n", tc.address, expanded)
+		}
+	}
+
+	e, _ := NewDefaultExtractor("https://example.com/")
+	if e.(*defaultExtractor).isSameDomain("https://example.com:80/") {
+		t.Errorf("Non-default port in scope\n")
+	}
+}
+
+func TestExtractorFindsUnsafeTargets(t *testing.T) {
+	html := `
+	<html>
+	    <body>
+	        <a href="https://other.com/" target="_blank">Unsafe</a>
+	        <a href="https://other.com/" target="_BLANK">Duplicate</a>
+	        <a href="/docs" target="_blank" rel="nofollow">Unsafe relative</a>
+	        <a href="https://safe.com/" target="_blank" rel="noopener">Safe</a>
+	        <a href="https://safe.com/referrer" target="_blank" rel="external NoReferrer">Safe</a>
+	        <a href="https://same.com/" target="_self">Same window</a>
+	    </body>
+	</html>
+	`
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	targets, err := e.(TargetExtractor).ExtractUnsafeTargets([]byte(html))
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	expected := []string{"https://other.com/", "http://example.com/docs"}
+	if len(targets) != len(expected) || targets[0] != expected[0] || targets[1] != expected[1] {
+		t.Errorf("Unexpected unsafe targets: %v\n", targets)
+	}
+}
+
+func TestExtractorSkipsUncrawlableReferences(t *testing.T) {
+	cases := []struct {
+		address        string
+		routeFragments bool
+		category       SkippedRef
+	}{
+		{"#section", false, SkippedFragment},
+		{"#!/route", false, SkippedFragment},
+		{"#!/route", true, ""},
+		{" JavaScript:void(0)", false, SkippedJavascript},
+		{"data:image/png;base64,iVBORw0KGgo=", false, SkippedData},
+		{"mailto:info@example.com", false, SkippedScheme},
+		{"tel:+123456789", false, SkippedScheme},
+		{"HTTPS://example.com/", false, ""},
+		{"/about#team", false, ""},
+		{"about", false, ""},
+	}
+
+	for _, tc := range cases {
+		if category := refCategory(tc.address, tc.routeFragments); category != tc.category {
+			t.Errorf("Unexpected category of %s: %s\n", tc.address, category)
+		}
+	}
+}
+
+func TestExtractorFindsCanonicalURL(t *testing.T) {
+	cases := []struct {
+		html, expected string
+	}{
+		{`<html><head><link rel="Canonical" href="/a?b=1#top"></head></html>`, "http://example.com/a?b=1"},
+		{`<html><head><link rel="alternate canonical" href="https://other.com/"/><link rel="canonical" href="/b"></head></html>`, "https://other.com/"},
+		{`<html><head><link rel="stylesheet" href="/main.css"></head></html>`, ""},
+	}
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	for _, tc := range cases {
+		if canonical, err := e.(CanonicalExtractor).ExtractCanonical([]byte(tc.html)); err != nil || canonical != tc.expected {
+			t.Errorf("Unexpected canonical URL: %s, %v\n", canonical, err)
+		}
+	}
+}
+
+func TestExtractorFindsFeeds(t *testing.T) {
+	html := `<html><head>
+		<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+		<link rel="Alternate" type="Application/Atom+XML" href="https://example.com/atom.xml"/>
+		<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+		<link rel="alternate" hreflang="de" href="/de/">
+		<link rel="stylesheet" type="text/css" href="/main.css">
+	</head></html>`
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	expected := []string{"http://example.com/feed.xml", "https://example.com/atom.xml"}
+	if feeds, err := e.(FeedExtractor).ExtractFeeds([]byte(html)); err != nil || !reflect.DeepEqual(feeds, expected) {
+		t.Errorf("Unexpected feeds: %v, %v\n", feeds, err)
+	}
+}
+
+func TestExtractorFindsExternalLinks(t *testing.T) {
+	html := `<html><body>
+		<a href="/about">About</a>
+		<a href="https://github.com/mpraski#readme">GitHub</a>
+		<a href="https://github.com/mpraski">GitHub</a>
+		<a href="https://cdn.example.net/report.pdf">Report</a>
+		<a href="mailto:me@example.com">Mail</a>
+		<a href="//twitter.com/mpraski">Twitter</a>
+	</body></html>`
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	expected := []string{"https://github.com/mpraski", "http://twitter.com/mpraski"}
+	if links, err := e.(ExternalLinkExtractor).ExtractExternalLinks([]byte(html)); err != nil || !reflect.DeepEqual(links, expected) {
+		t.Errorf("Unexpected external links: %v, %v\n", links, err)
+	}
+}
+
+func TestExtractorFindsRobotsDirectives(t *testing.T) {
+	cases := []struct {
+		html     string
+		expected RobotsDirectives
+	}{
+		{
+			`<html><head><meta name="Robots" content="NOINDEX, follow"></head><body><a href="/a" rel="nofollow ugc">A</a><a href="/b">B</a><a href="#top" rel="nofollow">Top</a></body></html>`,
+			RobotsDirectives{NoIndex: true, NofollowLinks: []string{"http://example.com/a"}},
+		},
+		{
+			`<html><head><meta name="robots" content="none"></head></html>`,
+			RobotsDirectives{NoIndex: true, NoFollow: true, NofollowLinks: []string{}},
+		},
+		{
+			`<html><head><meta name="description" content="noindex"></head></html>`,
+			RobotsDirectives{NofollowLinks: []string{}},
+		},
+	}
+
+	e, err := NewDefaultExtractor("http://example.com/")
+	if err != nil {
+		t.Fatalf("Extractor fails with error: %s\n", err.Error())
+	}
+
+	for _, tc := range cases {
+		if directives, err := e.(RobotsExtractor).ExtractRobots([]byte(tc.html)); err != nil || !reflect.DeepEqual(directives, tc.expected) {
+			t.Errorf("Unexpected directives: %v, %v\n", directives, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// feedDocument struct is the union of an RSS 2.0, RSS 1.0 (RDF) and Atom feed, only one of which is populated.
+// The items of RSS 1.0 are siblings of its channel rather than its children.
+type feedDocument struct {
+	XMLName  xml.Name
+	Items    []*feedItem  `xml:"channel>item"`
+	RDFItems []*feedItem  `xml:"item"`
+	Entries  []*feedEntry `xml:"entry"`
+}
+
+type feedItem struct {
+	Link string `xml:"link"`
+}
+
+type feedEntry struct {
+	Links []*feedLink `xml:"link"`
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// parseFeed returns the URLs of the items of the RSS feed or the entries of the Atom feed under the URL,
+// resolved against it. An Atom entry is linked to by its link with rel="alternate" or without rel.
+func parseFeed(url string, body []byte) ([]string, error) {
+	resolver, err := NewResolver(url, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var feed feedDocument
+	if err = xml.NewDecoder(bytes.NewReader(body)).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	refs := make([]string, 0, len(feed.Items)+len(feed.RDFItems)+len(feed.Entries))
+	for _, item := range append(feed.Items, feed.RDFItems...) {
+		refs = append(refs, item.Link)
+	}
+
+	for _, entry := range feed.Entries {
+		for _, link := range entry.Links {
+			if rel := strings.TrimSpace(link.Rel); rel == "" || rel == "alternate" {
+				refs = append(refs, link.Href)
+				break
+			}
+		}
+	}
+
+	urls := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+
+		if u, err := resolver.Resolve(ref); err == nil {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls, nil
+}
+
+// crawlFeeds fetches the feeds declared by the page of the result, each once per crawl, and schedules
+// the pages they list as if the page linked to them, provided the extractor of the page implements FeedExtractor
+func (c *Crawler) crawlFeeds(r *result, url string) {
+	e, ok := c.extractorFor(r.url).(FeedExtractor)
+	if !ok {
+		return
+	}
+
+	feeds, err := e.ExtractFeeds(r.body)
+	if err != nil {
+		return
+	}
+
+	for _, feed := range feeds {
+		if !c.claimFeed(feed) || !c.allowedByRobots(feed) {
+			continue
+		}
+
+		body, err := c.fetchFile(feed)
+		if err != nil {
+			c.reportError(CrawlError{Url: feed, Phase: PhaseCrawl, Err: err})
+			continue
+		}
+
+		entries, err := parseFeed(feed, body)
+		if err != nil {
+			c.reportError(CrawlError{Url: feed, Phase: PhaseCrawl, Err: err})
+			continue
+		}
+
+		for _, link := range c.redirectedLinks(c.canonicalLinks(entries)) {
+			if c.inScope(link) && !c.visited.Contains(link) {
+				c.schedule(link, url, r.depth+1, ViaFeed)
+			}
+		}
+	}
+}
+
+// claimFeed reports whether the feed is fetched for the first time in the crawl
+func (c *Crawler) claimFeed(feed string) bool {
+	c.mufeed.Lock()
+	defer c.mufeed.Unlock()
+
+	if _, ok := c.feeds[feed]; ok {
+		return false
+	}
+
+	c.feeds[feed] = struct{}{}
+	return true
+}
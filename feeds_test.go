@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedsAreParsed(t *testing.T) {
+	cases := []struct {
+		feed     string
+		expected []string
+	}{
+		{
+			`<?xml version="1.0"?><rss version="2.0"><channel><link>https://example.com/</link><item><link>https://example.com/a</link></item><item><link> /b </link></item><item><title>No link</title></item></channel></rss>`,
+			[]string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			`<?xml version="1.0"?><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"><channel><link>https://example.com/</link></channel><item><link>https://example.com/c</link></item></rdf:RDF>`,
+			[]string{"https://example.com/c"},
+		},
+		{
+			`<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><link rel="self" href="/feed.xml"/><entry><link rel="edit" href="/edit/d"/><link href="/d"/></entry><entry><link rel="alternate" href="https://example.com/e#comments"/></entry></feed>`,
+			[]string{"https://example.com/d", "https://example.com/e"},
+		},
+	}
+
+	for _, tc := range cases {
+		if urls, err := parseFeed("https://example.com/feed.xml", []byte(tc.feed)); err != nil || !reflect.DeepEqual(urls, tc.expected) {
+			t.Errorf("Unexpected URLs of feed: %v, %v\n", urls, err)
+		}
+	}
+
+	if _, err := parseFeed("https://example.com/feed.xml", []byte("<html><body>")); err == nil {
+		t.Errorf("Invalid feed parsed\n")
+	}
+}
+
+// feedExtractor treats the lines starting with feed as the feeds of the page and every other line as a link
+type feedExtractor struct{}
+
+func (feedExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	title, links, assets, err := lineExtractor{}.Extract(body)
+
+	filtered := make([]string, 0, len(links))
+	for _, link := range links {
+		if !strings.HasPrefix(link, "feed ") {
+			filtered = append(filtered, link)
+		}
+	}
+
+	return title, filtered, assets, err
+}
+
+func (feedExtractor) ExtractFeeds(body []byte) ([]string, error) {
+	feeds := make([]string, 0)
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "feed ") {
+			feeds = append(feeds, strings.TrimPrefix(line, "feed "))
+		}
+	}
+
+	return feeds, nil
+}
+
+func TestCrawlerFollowsFeeds(t *testing.T) {
+	var (
+		server  *httptest.Server
+		fetches = make(chan string, 10)
+		events  = make(chan DiscoveryEvent, 10)
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "feed %s/feed.xml\n%s/about\n", server.URL, server.URL)
+		case "/about":
+			fmt.Fprintf(w, "feed %s/feed.xml\n", server.URL)
+		case "/feed.xml":
+			fetches <- r.URL.Path
+			fmt.Fprintf(w, `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><entry><link href="%s/posts/1"/></entry><entry><link href="%s/posts/2"/></entry><entry><link href="https://example.org/elsewhere"/></entry></feed>`, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    feedExtractor{},
+		IgnoreRobots: true,
+		FollowFeeds:  true,
+		Callback: func(e DiscoveryEvent) {
+			events <- e
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	// The events are passed to the callback asynchronously
+	via := make(map[string]DiscoverySource)
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-events:
+			via[strings.TrimPrefix(e.Url, server.URL)] = e.Via
+		case <-time.After(time.Second):
+			t.Fatalf("Missing discovery events: %v\n", via)
+		}
+	}
+
+	if expected := map[string]DiscoverySource{"/about": ViaLink, "/posts/1": ViaFeed, "/posts/2": ViaFeed}; !reflect.DeepEqual(via, expected) {
+		t.Errorf("Unexpected sources of the discovered urls: %v\n", via)
+	}
+
+	if len(fetches) != 1 {
+		t.Errorf("Feed fetched %d times\n", len(fetches))
+	}
+
+	crawled := make(map[string]bool)
+	for _, page := range c.GetSiteMap().Pages() {
+		crawled[page.Url] = true
+	}
+
+	if len(crawled) != 4 || !crawled[server.URL+"/posts/1"] || !crawled[server.URL+"/posts/2"] {
+		t.Errorf("Unexpected pages crawled: %v\n", crawled)
+	}
+
+	if post, ok := c.GetSiteMap().Get(server.URL + "/posts/1"); !ok || post.Depth != 1 {
+		t.Errorf("Unexpected post: %v\n", post)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks the URL patterns which are regular expressions rather than globs
+const regexPrefix = "re:"
+
+// urlFilter struct decides which of the discovered URLs are crawled according to the include and exclude patterns.
+type urlFilter struct {
+	include, exclude []urlPattern
+}
+
+// urlPattern struct represents a compiled pattern, matched against the whole URL if it is a regular expression
+// and against its path and query if it is a glob.
+type urlPattern struct {
+	re   *regexp.Regexp
+	glob bool
+}
+
+// newURLFilter compiles the patterns, returning nil if there are none. Patterns prefixed with "re:" are regular
+// expressions matching anywhere in the URL, e.g. re:[?&]page=, the others are globs matching the path and query
+// of the URL, where * stands for any characters and ? for a single one, e.g. /docs/*.
+func newURLFilter(include, exclude []string) (*urlFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &urlFilter{}
+
+	var err error
+	if f.include, err = compilePatterns(include); err != nil {
+		return nil, err
+	}
+
+	if f.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]urlPattern, error) {
+	compiled := make([]urlPattern, 0, len(patterns))
+
+	for _, p := range patterns {
+		if strings.HasPrefix(p, regexPrefix) {
+			re, err := regexp.Compile(strings.TrimPrefix(p, regexPrefix))
+			if err != nil {
+				return nil, err
+			}
+
+			compiled = append(compiled, urlPattern{re: re})
+		} else {
+			compiled = append(compiled, urlPattern{re: globToRegexp(p), glob: true})
+		}
+	}
+
+	return compiled, nil
+}
+
+func globToRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteByte('^')
+
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteByte('$')
+
+	return regexp.MustCompile(b.String())
+}
+
+// allowed reports whether the URL is matched by any of the include patterns, if there are any,
+// and by none of the exclude patterns.
+func (f *urlFilter) allowed(url string) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 && !matchesAny(f.include, url) {
+		return false
+	}
+
+	return !matchesAny(f.exclude, url)
+}
+
+func matchesAny(patterns []urlPattern, url string) bool {
+	var path string
+	if u, err := neturl.Parse(url); err == nil {
+		path = u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+	}
+
+	for _, p := range patterns {
+		if p.glob && p.re.MatchString(path) || !p.glob && p.re.MatchString(url) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestURLFilterMatchesPatterns(t *testing.T) {
+	f, err := newURLFilter([]string{"/docs/*", "re:^https://api\\."}, []string{"/docs/*/draft", "re:[?&]sort="})
+	if err != nil {
+		t.Fatalf("Filter fails with error: %s\n", err.Error())
+	}
+
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/docs/intro", true},
+		{"https://example.com/docs/intro/draft", false},
+		{"https://example.com/docs/list?sort=name", false},
+		{"https://example.com/blog/post", false},
+		{"https://example.com/docs", false},
+		{"https://api.example.com/users", true},
+	}
+
+	for _, tc := range cases {
+		if allowed := f.allowed(tc.url); allowed != tc.allowed {
+			t.Errorf("Unexpected filtering of %s: %t\n", tc.url, allowed)
+		}
+	}
+}
+
+func TestURLFilterWithoutPatterns(t *testing.T) {
+	f, err := newURLFilter(nil, nil)
+	if err != nil {
+		t.Fatalf("Filter fails with error: %s\n", err.Error())
+	}
+
+	if !f.allowed("https://example.com/") {
+		t.Errorf("URL filtered out without any patterns\n")
+	}
+}
+
+func TestURLFilterRejectsInvalidRegexp(t *testing.T) {
+	if _, err := newURLFilter(nil, []string{"re:("}); err == nil {
+		t.Errorf("Invalid regular expression accepted\n")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// FindingsSchemaVersion is the version of the JSON representation of findings.
+// It is incremented whenever the representation changes in a way that could break its consumers,
+// new categories do not change it.
+const FindingsSchemaVersion = 1
+
+// FindingCategory identifies the kind of issue a finding reports.
+type FindingCategory string
+
+const (
+	CategoryBrokenPage    FindingCategory = "broken-page"
+	CategoryBrokenAsset   FindingCategory = "broken-asset"
+	CategoryBlockedAsset  FindingCategory = "blocked-asset"
+	CategoryInvalidHTML   FindingCategory = "invalid-html"
+	CategorySlowPage      FindingCategory = "slow-page"
+	CategoryUnusedPreload FindingCategory = "unused-preload"
+	CategoryHeavyInline   FindingCategory = "heavy-inline"
+	CategoryUnsafeTarget  FindingCategory = "unsafe-target"
+
+	CategoryExternalRedirect FindingCategory = "external-redirect"
+
+	CategorySitemapUnreachable FindingCategory = "sitemap-unreachable"
+	CategoryMissingFromSitemap FindingCategory = "missing-from-sitemap"
+
+	CategoryIntegrity        FindingCategory = "integrity-mismatch"
+	CategoryMissingIntegrity FindingCategory = "missing-integrity"
+
+	CategoryPinMismatch FindingCategory = "pin-mismatch"
+
+	CategoryNoStorePage     FindingCategory = "no-store-page"
+	CategoryShortLivedAsset FindingCategory = "short-lived-asset"
+
+	CategoryCSPViolation FindingCategory = "csp-violation"
+
+	CategoryBrokenLink FindingCategory = "broken-link"
+)
+
+// Severity defines how serious the issue reported by a finding is.
+type Severity uint8
+
+const (
+	SeverityInfo    Severity = iota
+	SeverityWarning Severity = iota
+	SeverityError   Severity = iota
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityNames {
+		if name == string(text) {
+			*s = severity
+			return nil
+		}
+	}
+
+	return ErrInvalidSeverity
+}
+
+// Finding struct represents a single issue discovered during the crawl.
+// Url is the address the issue was found at and Page the crawled page it relates to, if any.
+type Finding struct {
+	Category FindingCategory `json:"category"`
+	Severity Severity        `json:"severity"`
+	Url      string          `json:"url"`
+	Page     string          `json:"page,omitempty"`
+	Message  string          `json:"message"`
+}
+
+// Findings is a list of findings which can be narrowed down with its query methods.
+type Findings []Finding
+
+func (f Findings) ByCategory(category FindingCategory) Findings {
+	return f.filter(func(finding *Finding) bool {
+		return finding.Category == category
+	})
+}
+
+// BySeverity returns the findings at least as severe as given severity.
+func (f Findings) BySeverity(severity Severity) Findings {
+	return f.filter(func(finding *Finding) bool {
+		return finding.Severity >= severity
+	})
+}
+
+func (f Findings) ByURLPrefix(prefix string) Findings {
+	return f.filter(func(finding *Finding) bool {
+		return strings.HasPrefix(finding.Url, prefix)
+	})
+}
+
+// Suppress returns the findings not matched by any of the suppressions.
+func (f Findings) Suppress(s Suppressions) Findings {
+	return f.filter(func(finding *Finding) bool {
+		for i := range s {
+			if s[i].matches(finding) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+func (f Findings) filter(keep func(*Finding) bool) Findings {
+	filtered := make(Findings, 0)
+	for i := range f {
+		if keep(&f[i]) {
+			filtered = append(filtered, f[i])
+		}
+	}
+
+	return filtered
+}
+
+// MarshalJSON wraps the findings in an object carrying the schema version.
+func (f Findings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SchemaVersion int       `json:"schema_version"`
+		Findings      []Finding `json:"findings"`
+	}{
+		SchemaVersion: FindingsSchemaVersion,
+		Findings:      []Finding(f),
+	})
+}
+
+func (f *Findings) UnmarshalJSON(data []byte) error {
+	var report struct {
+		SchemaVersion int       `json:"schema_version"`
+		Findings      []Finding `json:"findings"`
+	}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+
+	if report.SchemaVersion > FindingsSchemaVersion {
+		return ErrUnsupportedSchema
+	}
+
+	*f = Findings(report.Findings)
+	return nil
+}
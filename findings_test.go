@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFindingsAreQueried(t *testing.T) {
+	findings := Findings{
+		{Category: CategoryBrokenPage, Severity: SeverityError, Url: "http://example.com/a"},
+		{Category: CategoryBrokenAsset, Severity: SeverityWarning, Url: "http://example.com/js/a.js"},
+		{Category: CategoryBrokenAsset, Severity: SeverityInfo, Url: "http://example.com/js/b.js"},
+	}
+
+	if f := findings.ByCategory(CategoryBrokenAsset); len(f) != 2 {
+		t.Errorf("Unexpected findings by category: %v\n", f)
+	}
+
+	if f := findings.BySeverity(SeverityWarning); len(f) != 2 {
+		t.Errorf("Unexpected findings by severity: %v\n", f)
+	}
+
+	if f := findings.ByURLPrefix("http://example.com/js/").BySeverity(SeverityWarning); len(f) != 1 || f[0].Url != "http://example.com/js/a.js" {
+		t.Errorf("Unexpected findings by URL prefix: %v\n", f)
+	}
+}
+
+func TestFindingsCarrySchemaVersion(t *testing.T) {
+	findings := Findings{
+		{Category: CategoryBrokenPage, Severity: SeverityError, Url: "http://example.com/a"},
+	}
+
+	data, err := json.Marshal(findings)
+	if err != nil {
+		t.Fatalf("Marshalling fails with error: %s\n", err.Error())
+	}
+
+	var report struct {
+		SchemaVersion int `json:"schema_version"`
+		Findings      []struct {
+			Severity string `json:"severity"`
+		} `json:"findings"`
+	}
+
+	if err = json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Unmarshalling fails with error: %s\n", err.Error())
+	}
+
+	if report.SchemaVersion != FindingsSchemaVersion || len(report.Findings) != 1 || report.Findings[0].Severity != "error" {
+		t.Errorf("Unexpected JSON: %s\n", data)
+	}
+
+	var restored Findings
+	if err = json.Unmarshal(data, &restored); err != nil || len(restored) != 1 || restored[0].Severity != SeverityError {
+		t.Errorf("Findings not restored from JSON: %s\n", data)
+	}
+
+	if err = json.Unmarshal([]byte(`{"schema_version": 99, "findings": []}`), &restored); err != ErrUnsupportedSchema {
+		t.Errorf("Unsupported schema version accepted\n")
+	}
+}
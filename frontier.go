@@ -0,0 +1,242 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// sharedPollInterval is how often the crawlers sharing a frontier check it for URLs pushed by the others
+const sharedPollInterval = 200 * time.Millisecond
+
+// Frontier interface abstracts the queue of URLs scheduled to be crawled, so that it can be replaced
+// e.g. with a priority, persistent or distributed queue. Push adds a URL to the queue, Pop removes the URL
+// to be crawled next, returning false if there is none, and Len returns the number of queued URLs.
+// The methods are called from multiple goroutines. The crawl only finishes once every pushed URL is popped.
+type Frontier interface {
+	Push(item FrontierItem)
+	Pop() (FrontierItem, bool)
+	Len() int
+}
+
+// SharedFrontier interface is implemented by the frontiers shared by several crawlers, e.g. RedisFrontier, so that
+// a URL pushed by one crawler may be popped by another one. Every URL is counted as pending work of the crawl
+// with Reserve before it is marked visited, and uncounted with Release if another crawler marked it first.
+// Each crawler counts as its work only the URLs it popped and settles each of them with Done once it is crawled,
+// given up or pushed again to be retried, a URL pushed again being reserved again. Pending returns the number
+// of URLs reserved by any of the crawlers and not settled yet, the crawl being over once it is zero.
+type SharedFrontier interface {
+	Frontier
+	Reserve()
+	Release()
+	Done(item FrontierItem)
+	Pending() (int, error)
+}
+
+// FrontierItem struct represents a URL discovered on the page From, Depth links away from the root URL.
+type FrontierItem struct {
+	Url   string `json:"url"`
+	From  string `json:"from"`
+	Depth int    `json:"depth"`
+}
+
+// memoryFrontier is the default frontier, a first in first out queue kept in memory.
+type memoryFrontier struct {
+	mu    sync.Mutex
+	items []FrontierItem
+}
+
+func NewMemoryFrontier() Frontier {
+	return &memoryFrontier{}
+}
+
+func (f *memoryFrontier) Push(item FrontierItem) {
+	f.mu.Lock()
+	f.items = append(f.items, item)
+	f.mu.Unlock()
+}
+
+func (f *memoryFrontier) Pop() (FrontierItem, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.items) == 0 {
+		return FrontierItem{}, false
+	}
+
+	item := f.items[0]
+	f.items[0] = FrontierItem{}
+	f.items = f.items[1:]
+
+	return item, true
+}
+
+func (f *memoryFrontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.items)
+}
+
+// enqueue counts the URL as pending work and pushes it to the frontier. The URLs pushed to a shared frontier
+// are handed off instead, they are counted by the crawler which pops them.
+func (c *Crawler) enqueue(url, from string, depth int) {
+	item := FrontierItem{Url: url, From: from, Depth: depth}
+
+	if c.shared != nil {
+		c.handOff(item)
+		return
+	}
+
+	c.wg.Add(1)
+	c.push(item)
+}
+
+// push adds the item, which must already be counted as pending work, to the frontier and wakes up a fetcher.
+// An item pushed to a shared frontier again is settled, since another crawler may pop it.
+func (c *Crawler) push(item FrontierItem) {
+	if c.shared != nil {
+		c.shared.Reserve()
+		c.handOff(item)
+		c.settle(item)
+		return
+	}
+
+	c.frontier.Push(item)
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fetch crawls the URLs popped from the frontier one by one, waiting for new ones while it is empty.
+// Once the crawl is stopped, the URLs left in the frontier are given up instead.
+func (c *Crawler) fetch(quit <-chan struct{}) {
+	defer c.wgStop.Done()
+
+	t := c.newTask(taskFetcher, func() {
+		c.wgStop.Add(1)
+		go c.fetch(quit)
+	})
+	defer c.endTask(t)
+
+	for {
+		if item, ok := c.pop(); ok {
+			if c.isStopped() || c.ctx.Err() != nil {
+				c.giveUp(item)
+			} else {
+				c.crawl(t, item.Url, item.From, item.Depth)
+			}
+
+			if c.retired(t) {
+				return
+			}
+
+			continue
+		}
+
+		// The other crawlers sharing the frontier do not wake up the fetchers of this one
+		var poll <-chan time.Time
+		if c.shared != nil {
+			poll = time.After(sharedPollInterval)
+		}
+
+		select {
+		case <-c.wake:
+		case <-poll:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// pop removes the next URL from the frontier. A URL popped from a shared frontier is counted as pending work
+// of this crawler, none is popped once the frontier is drained or the crawl is stopped, leaving the URLs
+// to the other crawlers.
+func (c *Crawler) pop() (FrontierItem, bool) {
+	if c.shared == nil {
+		return c.frontier.Pop()
+	}
+
+	c.mudrain.Lock()
+	defer c.mudrain.Unlock()
+
+	if c.drained || c.isStopped() || c.ctx.Err() != nil {
+		return FrontierItem{}, false
+	}
+
+	item, ok := c.shared.Pop()
+	if ok {
+		c.wg.Add(1)
+		c.markBeingProcessed(item.Url, true)
+		c.addPending(item.Url, item.From, item.Depth)
+	}
+
+	return item, ok
+}
+
+// claim marks the URL as visited, reporting whether it was not visited yet. It is counted as pending work of the
+// shared frontier first, so that no crawler finishes between another one marking it visited and pushing it.
+func (c *Crawler) claim(url string) bool {
+	if c.shared == nil {
+		return c.visited.Add(url)
+	}
+
+	c.shared.Reserve()
+	if c.visited.Add(url) {
+		return true
+	}
+
+	c.shared.Release()
+	return false
+}
+
+// handOff pushes the URL to the shared frontier, leaving it to whichever crawler pops it
+func (c *Crawler) handOff(item FrontierItem) {
+	c.shared.Push(item)
+	c.markBeingProcessed(item.Url, false)
+	c.removePending(item.Url)
+}
+
+// settle marks the popped URL as no longer pending work, since it was crawled, given up or pushed again
+func (c *Crawler) settle(item FrontierItem) {
+	if c.shared != nil {
+		c.shared.Done(item)
+	}
+
+	c.wg.Done()
+}
+
+// drain waits until none of the crawlers sharing the frontier has URLs left to crawl, or until this one
+// is stopped, and keeps the fetchers from popping URLs afterwards. If the frontier fails, the crawl finishes
+// with the error rather than waiting for URLs which may have been lost.
+func (c *Crawler) drain() {
+	defer func() {
+		c.mudrain.Lock()
+		c.drained = true
+		c.mudrain.Unlock()
+	}()
+
+	for !c.isStopped() && c.ctx.Err() == nil {
+		c.mudrain.Lock()
+		pending, err := c.shared.Pending()
+		if err != nil || pending == 0 {
+			// Set under the lock, so that no URL is popped after the frontier was found drained
+			c.drained = true
+			c.mudrain.Unlock()
+
+			if err != nil {
+				c.reportError(CrawlError{Url: c.url, Phase: PhaseCrawl, Err: err})
+			}
+
+			return
+		}
+		c.mudrain.Unlock()
+
+		select {
+		case <-time.After(sharedPollInterval):
+		case <-c.stop:
+		case <-c.ctx.Done():
+		}
+	}
+}
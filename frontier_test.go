@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMemoryFrontierIsFirstInFirstOut(t *testing.T) {
+	f := NewMemoryFrontier()
+
+	if _, ok := f.Pop(); ok {
+		t.Errorf("Item popped from empty frontier\n")
+	}
+
+	for _, url := range []string{"a", "b", "c"} {
+		f.Push(FrontierItem{Url: url})
+	}
+
+	if f.Len() != 3 {
+		t.Errorf("Unexpected frontier length: %d\n", f.Len())
+	}
+
+	for _, url := range []string{"a", "b", "c"} {
+		if item, ok := f.Pop(); !ok || item.Url != url {
+			t.Errorf("Unexpected item popped: %v\n", item)
+		}
+	}
+
+	if f.Len() != 0 {
+		t.Errorf("Frontier not empty: %d\n", f.Len())
+	}
+}
+
+// stackFrontier pops the URL pushed last, counting the pushed URLs
+type stackFrontier struct {
+	mu     sync.Mutex
+	items  []FrontierItem
+	pushed int
+}
+
+func (f *stackFrontier) Push(item FrontierItem) {
+	f.mu.Lock()
+	f.items = append(f.items, item)
+	f.pushed++
+	f.mu.Unlock()
+}
+
+func (f *stackFrontier) Pop() (FrontierItem, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.items) == 0 {
+		return FrontierItem{}, false
+	}
+
+	item := f.items[len(f.items)-1]
+	f.items = f.items[:len(f.items)-1]
+
+	return item, true
+}
+
+func (f *stackFrontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.items)
+}
+
+func TestCrawlerUsesCustomFrontier(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\n%s/b\n%s/c\n", server.URL, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	frontier := &stackFrontier{}
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		Frontier:     frontier,
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if frontier.pushed != 4 || frontier.Len() != 0 {
+		t.Errorf("Unexpected use of frontier: %d pushed, %d left\n", frontier.pushed, frontier.Len())
+	}
+
+	if len(c.GetSiteMap().pages) != 4 {
+		t.Errorf("Unexpected number of pages: %d\n", len(c.GetSiteMap().pages))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// defaultPageContentTypes are the content types of the pages fetched with HeadFirst if none are given
+var defaultPageContentTypes = []string{"text/html", "application/xhtml+xml"}
+
+// headCheck struct decides which resources the default downloader skips after a HEAD request, those whose
+// content type is none of types or whose size exceeds maxSize (not checked if zero). The resources whose
+// HEAD response is not successful or lacks the headers are downloaded anyway. The throttle, if set, waits until
+// the politeness of the crawler and the Crawl-delay of the host allow the next request to it.
+type headCheck struct {
+	types    []string
+	maxSize  int64
+	throttle func(url string)
+}
+
+// headCheckKey is the key of the context value holding the check of the request, there is no HEAD request without it.
+type headCheckKey struct{}
+
+func withHeadCheck(ctx context.Context, check *headCheck) context.Context {
+	return context.WithValue(ctx, headCheckKey{}, check)
+}
+
+// newHeadCheck returns the check of the pages of the crawl, nil if they are not requested with HEAD first
+func newHeadCheck(options *Options) *headCheck {
+	if !options.HeadFirst {
+		return nil
+	}
+
+	types := options.PageContentTypes
+	if len(types) == 0 {
+		types = defaultPageContentTypes
+	}
+
+	return &headCheck{types: types, maxSize: options.MaxPageSize}
+}
+
+// skip returns why the resource with the content type and size must not be downloaded, if it must not
+func (h *headCheck) skip(contentType string, size int64) (SkipReason, bool) {
+	if h.maxSize > 0 && size > h.maxSize {
+		return SkipTooLarge, true
+	}
+
+	if contentType == "" {
+		return "", false
+	}
+
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+
+	for _, t := range h.types {
+		if strings.EqualFold(media, t) {
+			return "", false
+		}
+	}
+
+	return SkipContentType, true
+}
+
+// checkHead requests the resource with HEAD if the context holds a head check, returning SkippedContentError
+// if the check rejects it. The redirects followed are not recorded, as the GET request follows them again.
+// The HEAD request is sent in the slot of the host the crawler waited for, so the GET request waits for the next one.
+func (d *defaultDownloader) checkHead(ctx context.Context, url, referer string) error {
+	check, _ := ctx.Value(headCheckKey{}).(*headCheck)
+	if check == nil {
+		return nil
+	}
+
+	if p, _ := ctx.Value(redirectPolicyKey{}).(*redirectPolicy); p != nil {
+		ctx = withRedirectPolicy(ctx, &redirectPolicy{follow: p.follow, max: p.max})
+	}
+
+	req, err := d.newRequest(ctx, http.MethodHead, url, referer)
+	if err != nil {
+		return err
+	}
+
+	// The failures are left to the GET request to report
+	resp, err := d.do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	if err == nil && resp.StatusCode == http.StatusOK {
+		contentType := resp.Header.Get("Content-Type")
+		if reason, ok := check.skip(contentType, resp.ContentLength); ok {
+			recordResponse(resp)
+
+			return &SkippedContentError{Url: url, ContentType: contentType, Size: resp.ContentLength, Reason: reason}
+		}
+	}
+
+	if check.throttle != nil {
+		check.throttle(url)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeadCheckSkipsContent(t *testing.T) {
+	check := &headCheck{types: defaultPageContentTypes, maxSize: 100}
+
+	cases := []struct {
+		contentType string
+		size        int64
+		reason      SkipReason
+		skipped     bool
+	}{
+		{"text/html; charset=utf-8", 10, "", false},
+		{"Application/XHTML+XML", -1, "", false},
+		{"", -1, "", false},
+		{"invalid;;", 10, "", false},
+		{"application/pdf", 10, SkipContentType, true},
+		{"text/html", 101, SkipTooLarge, true},
+	}
+
+	for _, c := range cases {
+		if reason, ok := check.skip(c.contentType, c.size); reason != c.reason || ok != c.skipped {
+			t.Errorf("Unexpected check of %q (%d bytes): %s, %t\n", c.contentType, c.size, reason, ok)
+		}
+	}
+}
+
+func TestCrawlerRequestsHeadFirst(t *testing.T) {
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "%s/a\n%s/doc.pdf\n%s/big\n", server.URL, server.URL, server.URL)
+		case "/doc.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF-1.4")
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, strings.Repeat("a", 1000))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		HeadFirst:    true,
+		MaxPageSize:  500,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 2 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+
+	mu.Lock()
+	expected := map[string]int{
+		"HEAD /":        1,
+		"GET /":         1,
+		"HEAD /a":       1,
+		"GET /a":        1,
+		"HEAD /doc.pdf": 1,
+		"HEAD /big":     1,
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Unexpected requests: %v\n", requests)
+	}
+	mu.Unlock()
+
+	reasons := make(map[string]SkipReason)
+	for _, u := range c.GetUncrawled() {
+		reasons[u.Url] = u.Reason
+	}
+
+	if expected := map[string]SkipReason{server.URL + "/doc.pdf": SkipContentType, server.URL + "/big": SkipTooLarge}; !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Unexpected uncrawled websites: %v\n", reasons)
+	}
+}
+
+func TestCrawlerRequestsNoHeadByDefault(t *testing.T) {
+	var (
+		server *httptest.Server
+		mu     sync.Mutex
+		heads  int
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			mu.Lock()
+			heads++
+			mu.Unlock()
+		}
+
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/doc.pdf\n", server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 2 || heads != 0 {
+		t.Errorf("Unexpected crawl: %d websites, %d HEAD requests\n", l, heads)
+	}
+}
+
+func TestCrawlerSpacesHeadRequests(t *testing.T) {
+	var (
+		server *httptest.Server
+		mu     sync.Mutex
+		times  []time.Time
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\n", server.URL)
+		}
+	}))
+	defer server.Close()
+
+	delay := 50 * time.Millisecond
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Extractor:       lineExtractor{},
+		IgnoreRobots:    true,
+		HeadFirst:       true,
+		PolitenessDelay: delay,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(times) != 4 {
+		t.Fatalf("Unexpected number of requests: %d\n", len(times))
+	}
+
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < delay-5*time.Millisecond {
+			t.Errorf("Requests %d and %d are %s apart\n", i-1, i, gap)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "net/http"
+
+// Hooks struct holds the callbacks invoked at the steps of the crawl, e.g. to log, filter or measure it
+// without changing the crawler. Any of them may be nil. They are called synchronously by the fetchers
+// and workers, so they must be safe for concurrent use and return quickly.
+// OnRequest is called before every page is requested, retries included,
+// OnResponse with every response to those requests: its status, 304 if the page was not modified since the baseline,
+// and its body, which must not be retained as the buffer is reused. The default downloader reports the status
+// of the errors too (e.g. 404), other downloaders 200 for the content they return and nothing for their errors,
+// OnPage with every page once it is recorded in the sitemap, before its links are followed,
+// OnError with every error, along with the OnError option or the errors channel,
+// OnSkip with every URL discovered but not crawled because of the limits of the crawl, once per URL (see SkipReason).
+type Hooks struct {
+	OnRequest  func(url string)
+	OnResponse func(url string, status int, body []byte)
+	OnPage     func(page *Page)
+	OnError    func(err *CrawlError)
+	OnSkip     func(url string, reason SkipReason)
+}
+
+func (c *Crawler) hookRequest(url string) {
+	if c.hooks.OnRequest != nil {
+		c.hooks.OnRequest(url)
+	}
+}
+
+// hookResponse reports the response to the request of the URL, unless none was received
+func (c *Crawler) hookResponse(url string, response *responseInfo, body []byte, err error) {
+	if c.hooks.OnResponse == nil {
+		return
+	}
+
+	status := response.status
+	if status == 0 {
+		switch err {
+		case nil:
+			status = http.StatusOK
+		case ErrNotModified:
+			status = http.StatusNotModified
+		default:
+			return
+		}
+	}
+
+	c.hooks.OnResponse(url, status, body)
+}
+
+func (c *Crawler) hookPage(page *Page) {
+	if c.hooks.OnPage != nil {
+		c.hooks.OnPage(page)
+	}
+}
+
+func (c *Crawler) hookError(e *CrawlError) {
+	if c.hooks.OnError != nil {
+		c.hooks.OnError(e)
+	}
+}
+
+func (c *Crawler) hookSkip(url string, reason SkipReason) {
+	if c.hooks.OnSkip != nil {
+		c.hooks.OnSkip(url, reason)
+	}
+}
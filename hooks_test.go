@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCrawlerCallsHooks(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/missing\n", server.URL, server.URL)
+		case "/a":
+			fmt.Fprintf(w, "%s/a/deep\n", server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mu        sync.Mutex
+		requests  []string
+		responses = make(map[string]int)
+		pages     []string
+		errs      []string
+		skipped   = make(map[string]SkipReason)
+	)
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		MaxDepth:     1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		OnError:      func(CrawlError) {},
+		Hooks: &Hooks{
+			OnRequest: func(url string) {
+				mu.Lock()
+				requests = append(requests, url)
+				mu.Unlock()
+			},
+			OnResponse: func(url string, status int, body []byte) {
+				mu.Lock()
+				responses[url] = status
+				mu.Unlock()
+			},
+			OnPage: func(page *Page) {
+				mu.Lock()
+				pages = append(pages, page.Url)
+				mu.Unlock()
+			},
+			OnError: func(err *CrawlError) {
+				mu.Lock()
+				errs = append(errs, err.Url)
+				mu.Unlock()
+			},
+			OnSkip: func(url string, reason SkipReason) {
+				mu.Lock()
+				skipped[url] = reason
+				mu.Unlock()
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, _ := c.Crawl()
+	<-done
+
+	sort.Strings(requests)
+	sort.Strings(pages)
+
+	// The missing page is requested again once
+	if len(requests) != 4 || requests[0] != server.URL+"/" || requests[1] != server.URL+"/a" || requests[3] != server.URL+"/missing" {
+		t.Errorf("Unexpected requests: %v\n", requests)
+	}
+
+	if len(responses) != 3 || responses[server.URL+"/"] != http.StatusOK || responses[server.URL+"/missing"] != http.StatusNotFound {
+		t.Errorf("Unexpected responses: %v\n", responses)
+	}
+
+	if len(pages) != 2 || pages[0] != server.URL+"/" || pages[1] != server.URL+"/a" {
+		t.Errorf("Unexpected pages: %v\n", pages)
+	}
+
+	if len(errs) != 2 || errs[0] != server.URL+"/missing" || errs[1] != server.URL+"/missing" {
+		t.Errorf("Unexpected errors: %v\n", errs)
+	}
+
+	if len(skipped) != 1 || skipped[server.URL+"/a/deep"] != SkipMaxDepth {
+		t.Errorf("Unexpected skipped URLs: %v\n", skipped)
+	}
+}
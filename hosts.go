@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// hostOverrides maps the host:port (or the host alone, for any port) of the requested URLs to the address the
+// default downloader connects to instead, like curl --resolve. The address may omit the port, the port of the URL
+// is kept then. Only the connection is redirected: the Host header and the TLS server name are those of the URL.
+type hostOverrides map[string]string
+
+// address returns the address to connect to for the requested address
+func (h hostOverrides) address(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	host = strings.ToLower(host)
+
+	target, ok := h[net.JoinHostPort(host, port)]
+	if !ok {
+		if target, ok = h[host]; !ok {
+			return addr
+		}
+	}
+
+	if _, _, err = net.SplitHostPort(target); err != nil {
+		return net.JoinHostPort(strings.Trim(target, "[]"), port)
+	}
+
+	return target
+}
+
+// newHostOverrides returns the overrides with the host names in lower case
+func newHostOverrides(overrides map[string]string) hostOverrides {
+	h := make(hostOverrides, len(overrides))
+	for host, target := range overrides {
+		h[strings.ToLower(host)] = target
+	}
+
+	return h
+}
+
+// newOverridingDialer returns a function dialing the overridden addresses with dial, or directly if dial is nil
+func newOverridingDialer(overrides map[string]string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	h := newHostOverrides(overrides)
+
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dial(ctx, network, h.address(addr))
+	}
+}
+
+// parseHosts parses the overrides of the addresses of hosts in the format of /etc/hosts, an IP address followed
+// by the host names it overrides on each line, e.g. 10.0.0.5 www.example.com example.com. Comments start with #.
+func parseHosts(r io.Reader) (map[string]string, error) {
+	hosts := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidHost, n)
+		}
+
+		for _, host := range fields[1:] {
+			hosts[strings.ToLower(host)] = fields[0]
+		}
+	}
+
+	return hosts, scanner.Err()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHostsFileIsParsed(t *testing.T) {
+	hosts, err := parseHosts(strings.NewReader("# green deployment\n10.0.0.5 WWW.example.com example.com\n\n::1\tapi.example.com # internal\n"))
+	if err != nil {
+		t.Fatalf("Parsing fails with error: %s\n", err.Error())
+	}
+
+	expected := map[string]string{"www.example.com": "10.0.0.5", "example.com": "10.0.0.5", "api.example.com": "::1"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Unexpected hosts: %v\n", hosts)
+	}
+
+	if a := hostOverrides(hosts).address("api.example.com:443"); a != "[::1]:443" {
+		t.Errorf("Unexpected address: %s\n", a)
+	}
+
+	for _, v := range []string{"10.0.0.5", "www.example.com 10.0.0.5"} {
+		if _, err := parseHosts(strings.NewReader(v)); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("Parsing does not fail for invalid line: %s\n", v)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3HandshakeTimeout bounds the QUIC handshake, so that the hosts which do not answer over UDP fall back quickly
+const http3HandshakeTimeout = 3 * time.Second
+
+// http3Transport sends the requests to https URLs over HTTP/3 (QUIC), falling back to the transport over TCP
+// (HTTP/2 or HTTP/1.1) for the hosts it fails with, which are not tried over HTTP/3 again. The requests are sent
+// again only if no response was received, which is safe for the GET and HEAD requests of the default downloader.
+type http3Transport struct {
+	h3       http.RoundTripper
+	fallback http.RoundTripper
+
+	mu     sync.Mutex
+	failed map[string]struct{}
+}
+
+// newHTTP3Transport returns the transport trying HTTP/3 first, with the TLS configuration and the host overrides
+// of the fallback transport
+func newHTTP3Transport(fallback *http.Transport, overrides map[string]string) *http3Transport {
+	h3 := &http3.Transport{
+		TLSClientConfig: fallback.TLSClientConfig.Clone(),
+		QUICConfig:      &quic.Config{HandshakeIdleTimeout: http3HandshakeTimeout},
+	}
+
+	if len(overrides) > 0 {
+		h := newHostOverrides(overrides)
+
+		h3.Dial = func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			return quic.DialAddrEarly(ctx, h.address(addr), tlsCfg, cfg)
+		}
+	}
+
+	return &http3Transport{
+		h3:       h3,
+		fallback: fallback,
+		failed:   make(map[string]struct{}),
+	}
+}
+
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || t.hasFailed(req.URL.Host) {
+		return t.fallback.RoundTrip(req)
+	}
+
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+
+	t.mu.Lock()
+	t.failed[req.URL.Host] = struct{}{}
+	t.mu.Unlock()
+
+	return t.fallback.RoundTrip(req)
+}
+
+func (t *http3Transport) hasFailed(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.failed[host]
+	return ok
+}
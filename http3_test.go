@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// failingTransport fails every request, counting them
+type failingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests++
+	t.mu.Unlock()
+
+	return nil, errors.New("no QUIC")
+}
+
+func TestHTTP3TransportFallsBack(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	h3 := &failingTransport{}
+	transport := &http3Transport{
+		h3:       h3,
+		fallback: server.Client().Transport,
+		failed:   make(map[string]struct{}),
+	}
+
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request fails with error: %s\n", err.Error())
+		}
+
+		resp.Body.Close()
+
+		if resp.ProtoMajor != 1 {
+			t.Errorf("Unexpected protocol: %s\n", resp.Proto)
+		}
+	}
+
+	// The host is not tried over HTTP/3 again once it failed
+	if h3.requests != 1 {
+		t.Errorf("Unexpected number of HTTP/3 requests: %d\n", h3.requests)
+	}
+}
+
+func TestHTTP3CannotUseProxies(t *testing.T) {
+	_, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout: 5,
+		Pool:    NewBufferPool(2, 1024),
+		Proxy:   &ProxyOptions{Chain: []string{"socks5://127.0.0.1:9050"}},
+		HTTP3:   true,
+	})
+	if !errors.Is(err, ErrHTTP3Proxy) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+// integrityAlgorithms are the hash functions of Subresource Integrity, the strongest last
+var integrityAlgorithms = []struct {
+	name string
+	sum  func([]byte) []byte
+}{
+	{"sha256", func(b []byte) []byte { s := sha256.Sum256(b); return s[:] }},
+	{"sha384", func(b []byte) []byte { s := sha512.Sum384(b); return s[:] }},
+	{"sha512", func(b []byte) []byte { s := sha512.Sum512(b); return s[:] }},
+}
+
+// matchesIntegrity reports whether the content matches the integrity metadata. As browsers do, only the hashes
+// of the strongest algorithm listed are considered and any of them matching is enough. The metadata without
+// any known algorithm is invalid.
+func matchesIntegrity(body []byte, integrity string) (bool, error) {
+	hashes := make(map[string][]string)
+	for _, token := range strings.Fields(integrity) {
+		// Options following the hash are not used by browsers yet
+		if i := strings.IndexByte(token, '?'); i >= 0 {
+			token = token[:i]
+		}
+
+		if i := strings.IndexByte(token, '-'); i > 0 {
+			hashes[token[:i]] = append(hashes[token[:i]], token[i+1:])
+		}
+	}
+
+	for i := len(integrityAlgorithms) - 1; i >= 0; i-- {
+		algorithm := integrityAlgorithms[i]
+
+		expected, ok := hashes[algorithm.name]
+		if !ok {
+			continue
+		}
+
+		actual := base64.StdEncoding.EncodeToString(algorithm.sum(body))
+		for _, e := range expected {
+			if e == actual {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return false, ErrInvalidIntegrity
+}
+
+// checkIntegrity fetches the scripts and stylesheets of the page carrying integrity metadata and reports those
+// whose content does not match it, as well as the scripts and stylesheets of other origins which carry none.
+func (c *Crawler) checkIntegrity(t *task, url string, assets []*Asset) {
+	for _, asset := range assets {
+		if asset.Type != Script && asset.Type != Link {
+			continue
+		}
+
+		if asset.Integrity == "" {
+			if isCrossOrigin(url, asset.Url) && (asset.Type == Script || hasFormat(asset.Url, ".css")) {
+				c.addFinding(Finding{
+					Category: CategoryMissingIntegrity,
+					Severity: SeverityWarning,
+					Url:      asset.Url,
+					Page:     url,
+					Message:  "Resource of another origin is included without integrity metadata",
+				})
+			}
+
+			continue
+		}
+
+		if !c.admitAsset(t, asset, url) {
+			continue
+		}
+
+		body, _, err := c.download(asset.Url, url)
+		c.releaseSlot(asset.Url)
+
+		if err != nil {
+			c.assetFailed(asset, url, err)
+			continue
+		}
+
+		c.addBytes(len(body))
+
+		if ok, err := matchesIntegrity(body, asset.Integrity); err != nil || !ok {
+			message := fmt.Sprintf("Content does not match the integrity metadata %s", asset.Integrity)
+			if err != nil {
+				message = err.Error()
+			}
+
+			c.addFinding(Finding{
+				Category: CategoryIntegrity,
+				Severity: SeverityError,
+				Url:      asset.Url,
+				Page:     url,
+				Message:  message,
+			})
+		}
+	}
+}
+
+// isCrossOrigin reports whether the resource is served from an origin other than the page's
+func isCrossOrigin(page, resource string) bool {
+	p, err := neturl.Parse(page)
+	if err != nil {
+		return false
+	}
+
+	r, err := neturl.Parse(resource)
+	if err != nil || r.Host == "" {
+		return false
+	}
+
+	return p.Scheme != r.Scheme || p.Host != r.Host
+}
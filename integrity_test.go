@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIntegrityMatchesStrongestAlgorithm(t *testing.T) {
+	// Hashes of "alert('Hello, world.');"
+	const (
+		SHA256 = "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng="
+		SHA384 = "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO"
+	)
+
+	body := []byte("alert('Hello, world.');")
+
+	cases := []struct {
+		integrity string
+		matches   bool
+		err       error
+	}{
+		{SHA256, true, nil},
+		{SHA384, true, nil},
+		{"sha384-invalid " + SHA384, true, nil},
+		{SHA256 + " sha384-invalid", false, nil},
+		{"sha512-invalid", false, nil},
+		{"md5-invalid", false, ErrInvalidIntegrity},
+		{SHA256 + "?ct=application/javascript", true, nil},
+	}
+
+	for _, tc := range cases {
+		if matches, err := matchesIntegrity(body, tc.integrity); matches != tc.matches || err != tc.err {
+			t.Errorf("Unexpected match of %s: %t, %v\n", tc.integrity, matches, err)
+		}
+	}
+}
+
+func TestCrawlerChecksIntegrity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "alert('Hello, world.');")
+	}))
+	defer server.Close()
+
+	c := &Crawler{downloader: unpooledDownloader{}, limiter: newHostLimiter(0, 0)}
+	c.reset()
+
+	c.checkIntegrity(nil, server.URL+"/", []*Asset{
+		{Url: server.URL + "/valid.js", Type: Script, Integrity: "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng="},
+		{Url: server.URL + "/invalid.js", Type: Script, Integrity: "sha256-invalid"},
+		{Url: server.URL + "/same-origin.js", Type: Script},
+		{Url: "http://cdn.example.org/lib.css", Type: Link},
+		{Url: "http://cdn.example.org/icon.png", Type: Link},
+	})
+
+	if mismatches := c.GetFindings().ByCategory(CategoryIntegrity); len(mismatches) != 1 || mismatches[0].Url != server.URL+"/invalid.js" {
+		t.Errorf("Unexpected integrity mismatches: %v\n", mismatches)
+	}
+
+	if missing := c.GetFindings().ByCategory(CategoryMissingIntegrity); len(missing) != 1 || missing[0].Url != "http://cdn.example.org/lib.css" {
+		t.Errorf("Unexpected missing integrity: %v\n", missing)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+// checkInvariants verifies the bookkeeping of a finished crawl: every URL pushed to the frontier was popped
+// and settled, no download or processing is under way and the pages only link to pages of the sitemap.
+// A violation means that the crawl finished too early, so the scheduling is broken.
+func (c *Crawler) checkInvariants() []error {
+	var violations []error
+
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvariant}, args...)...))
+	}
+
+	if l := c.frontier.Len(); l > 0 {
+		violate("%d URLs left in the frontier", l)
+	}
+
+	c.mupend.Lock()
+	for url := range c.pending {
+		violate("%s scheduled but never settled", url)
+	}
+	c.mupend.Unlock()
+
+	c.mup.RLock()
+	for url, processing := range c.processed {
+		if processing {
+			violate("%s still being downloaded", url)
+		}
+	}
+	c.mup.RUnlock()
+
+	c.mutask.Lock()
+	for t := range c.tasks {
+		violate("%s still being worked on", t.url)
+	}
+	c.mutask.Unlock()
+
+	c.mus.RLock()
+	for url, page := range c.sites {
+		for _, linked := range page.LinksTo {
+			if c.sites[linked.Url] != linked {
+				violate("%s links to %s, which is not in the sitemap", url, linked.Url)
+			}
+		}
+
+		for _, linking := range page.LinkedFrom {
+			if c.sites[linking.Url] != linking {
+				violate("%s is linked from %s, which is not in the sitemap", url, linking.Url)
+			}
+		}
+	}
+	c.mus.RUnlock()
+
+	return violations
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCrawlerCompletesWithInvariants crawls a site where every page links to its parent, its siblings
+// and its children, some of them failing, under various options, checking that every crawl completes
+// with consistent bookkeeping.
+func TestCrawlerCompletesWithInvariants(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		if strings.HasSuffix(path, "3") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if strings.Count(path, "/") >= 3 {
+			return
+		}
+
+		for i := 0; i < 4; i++ {
+			fmt.Fprintf(w, "%s%s/%s\n", server.URL, path, strconv.Itoa(i))
+		}
+
+		fmt.Fprintf(w, "%s/\n", server.URL)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name    string
+		options Options
+		stop    bool
+	}{
+		{"default", Options{}, false},
+		{"single fetcher", Options{MaxFetchers: 1}, false},
+		{"host affinity", Options{HostAffinity: true}, false},
+		{"shuffled", Options{Shuffle: true, Seed: 1}, false},
+		{"max pages", Options{MaxPages: 5}, false},
+		{"max depth", Options{MaxDepth: 1}, false},
+		{"retries", Options{MaxRetries: 2, RetryBackoff: RetryBackoff{Initial: time.Millisecond}}, false},
+		{"stall timeout", Options{StallTimeout: time.Second}, false},
+		{"stopped", Options{RetryBackoff: RetryBackoff{Initial: time.Hour}, MaxRetries: 2}, true},
+	}
+
+	for _, tc := range cases {
+		var (
+			mu         sync.Mutex
+			violations []error
+		)
+
+		options := tc.options
+		options.MaxWorkers = 2
+		options.Downloader = unpooledDownloader{}
+		options.Extractor = lineExtractor{}
+		options.IgnoreRobots = true
+		options.CheckInvariants = true
+		options.OnError = func(err CrawlError) {
+			if errors.Is(err.Err, ErrInvariant) {
+				mu.Lock()
+				violations = append(violations, err.Err)
+				mu.Unlock()
+			}
+		}
+
+		c, err := NewCrawlerWithOptions(server.URL+"/", &options)
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, _ := c.Crawl()
+
+		if tc.stop {
+			time.Sleep(20 * time.Millisecond)
+			c.Stop()
+		}
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Crawl %s does not complete\n", tc.name)
+		}
+
+		if len(violations) > 0 {
+			t.Errorf("Invariants violated by crawl %s: %v\n", tc.name, violations)
+		}
+	}
+}
+
+func TestCrawlerReportsInvariantViolations(t *testing.T) {
+	c, err := NewCrawlerWithOptions("http://example.com/", &Options{MaxWorkers: 1, CheckInvariants: true})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	orphan := &Page{Url: "http://example.com/orphan"}
+
+	c.frontier.Push(FrontierItem{Url: "http://example.com/queued"})
+	c.addPending("http://example.com/pending", "<root>", 1)
+	c.markBeingProcessed("http://example.com/downloading", true)
+	c.markVisited("http://example.com/", &Page{Url: "http://example.com/", LinksTo: []*Page{orphan}})
+
+	violations := c.checkInvariants()
+	if len(violations) != 4 {
+		t.Errorf("Unexpected violations: %v\n", violations)
+	}
+
+	for _, v := range violations {
+		if !errors.Is(v, ErrInvariant) {
+			t.Errorf("Violation is not ErrInvariant: %s\n", v.Error())
+		}
+	}
+}
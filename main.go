@@ -1,49 +1,976 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+var (
+	assetTypes = map[string]AssetType{
+		"link":   Link,
+		"script": Script,
+		"image":  Image,
+		"video":  Video,
+		"hint":   Hint,
+	}
+
+	assetPolicies = map[string]AssetPolicy{
+		"record":   PolicyRecord,
+		"verify":   PolicyVerify,
+		"download": PolicyDownload,
+		"ignore":   PolicyIgnore,
+	}
+
+	refererPolicies = map[string]RefererPolicy{
+		"none":   RefererNone,
+		"origin": RefererOrigin,
+		"full":   RefererFull,
+	}
+
+	canonicalPolicies = map[string]CanonicalPolicy{
+		"record": CanonicalRecord,
+		"alias":  CanonicalAlias,
+		"merge":  CanonicalMerge,
+	}
+)
+
+// The Bloom filter of the scheduled URLs is sized for 10 million URLs unless the capacity is given
+const (
+	bloomCapacity          = 10000000
+	bloomFalsePositiveRate = 0.001
+)
+
+// parseAssetPolicies parses a comma separated list of type=policy pairs, e.g. script=verify,image=ignore
+func parseAssetPolicies(arg string) (map[AssetType]AssetPolicy, error) {
+	policies := make(map[AssetType]AssetPolicy)
+
+	if arg == "" {
+		return policies, nil
+	}
+
+	for _, pair := range strings.Split(arg, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidPolicy
+		}
+
+		t, ok := assetTypes[strings.TrimSpace(kv[0])]
+		if !ok {
+			return nil, ErrInvalidPolicy
+		}
+
+		p, ok := assetPolicies[strings.TrimSpace(kv[1])]
+		if !ok {
+			return nil, ErrInvalidPolicy
+		}
+
+		policies[t] = p
+	}
+
+	return policies, nil
+}
+
+// verifyAssets sets the policy of the asset types other than hints to verify, unless they have one
+func verifyAssets(policies map[AssetType]AssetPolicy) {
+	for _, t := range []AssetType{Link, Script, Image, Video} {
+		if _, ok := policies[t]; !ok {
+			policies[t] = PolicyVerify
+		}
+	}
+}
+
+// parseVariantReport parses a comma separated list of the parts of the links whose variations are reported,
+// query or fragment
+func parseVariantReport(arg string) (VariantReport, error) {
+	var report VariantReport
+
+	for _, part := range strings.Split(arg, ",") {
+		switch strings.TrimSpace(part) {
+		case "query":
+			report.Query = true
+		case "fragment":
+			report.Fragment = true
+		default:
+			return VariantReport{}, fmt.Errorf("%w: %s", ErrInvalidPart, part)
+		}
+	}
+
+	return report, nil
+}
+
+// headerList collects repeated -header flags in the order they were given
+type headerList []Header
+
+func (h *headerList) String() string {
+	pairs := make([]string, 0, len(*h))
+	for _, header := range *h {
+		pairs = append(pairs, header.Key+": "+header.Value)
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+func (h *headerList) Set(arg string) error {
+	kv := strings.SplitN(arg, ":", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return ErrInvalidHeader
+	}
+
+	*h = append(*h, Header{Key: strings.TrimSpace(kv[0]), Value: strings.TrimSpace(kv[1])})
+	return nil
+}
+
+// rewriteList collects repeated -rewrite flags in the order they were given
+type rewriteList []RewriteRule
+
+func (r *rewriteList) String() string {
+	rules := make([]string, 0, len(*r))
+	for _, rule := range *r {
+		rules = append(rules, rule.Pattern.String()+" => "+rule.Replacement)
+	}
+
+	return strings.Join(rules, ", ")
+}
+
+func (r *rewriteList) Set(arg string) error {
+	rule, err := ParseRewriteRule(arg)
+	if err != nil {
+		return err
+	}
+
+	*r = append(*r, rule)
+	return nil
+}
+
+// parseSinkColumns parses a comma separated list of column=field pairs, e.g. page_url=url,page_title=title
+func parseSinkColumns(arg string) (map[string]string, error) {
+	columns := make(map[string]string)
+
+	if arg == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(arg, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidColumn
+		}
+
+		columns[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return columns, nil
+}
+
+// parseRegions parses a comma separated list of name=proxy pairs, e.g. eu=socks5://10.0.0.1:1080
+func parseRegions(arg string) ([]Region, error) {
+	regions := make([]Region, 0)
+
+	for _, pair := range strings.Split(arg, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidProxy
+		}
+
+		regions = append(regions, Region{Name: strings.TrimSpace(kv[0]), Proxy: strings.TrimSpace(kv[1])})
+	}
+
+	return regions, nil
+}
+
+// parseResolve parses a comma separated list of host[:port]=address[:port] pairs, e.g. www.example.com:443=10.0.0.5
+func parseResolve(arg string) (map[string]string, error) {
+	resolve := make(map[string]string)
+
+	if arg == "" {
+		return resolve, nil
+	}
+
+	for _, pair := range strings.Split(arg, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidHost
+		}
+
+		host, address := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if host == "" || address == "" || strings.Contains(host, "/") || strings.Contains(address, "/") {
+			return nil, ErrInvalidHost
+		}
+
+		resolve[host] = address
+	}
+
+	return resolve, nil
+}
+
+// parsePins parses a comma separated list of certificate pins, each either sha256/ followed by the base64 hash
+// of a public key or a file of PEM encoded certificates, e.g. the CA certificate of the site
+func parsePins(arg string) ([]string, error) {
+	if arg == "" {
+		return nil, nil
+	}
+
+	var pins []string
+
+	for _, pin := range strings.Split(arg, ",") {
+		pin = strings.TrimSpace(pin)
+
+		if strings.HasPrefix(pin, pinPrefix) {
+			if !validPin(pin) {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidPin, pin)
+			}
+
+			pins = append(pins, pin)
+			continue
+		}
+
+		data, err := os.ReadFile(pin)
+		if err != nil {
+			return nil, err
+		}
+
+		filePins, err := pinsOfPEM(data)
+		if err != nil {
+			return nil, err
+		}
+
+		pins = append(pins, filePins...)
+	}
+
+	return pins, nil
+}
+
+// compareRegions runs the region comparison mode, printing the differences between the regions
+func compareRegions(regions, urls string) {
+	r, err := parseRegions(regions)
+	if err != nil {
+		panic(err)
+	}
+
+	comparisons, err := CompareRegions(strings.Split(urls, ","), r, 30*time.Second)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, c := range comparisons {
+		marker := "same in all regions"
+		if c.Differs {
+			marker = "\033[1mdiffers between regions\033[0m"
+		}
+
+		fmt.Printf("─────────────────────────────────────────────────\n")
+		fmt.Printf("Compared \033[1m%s\033[0m | %s\n", c.Url, marker)
+
+		for _, f := range c.Fetches {
+			if f.Err != nil {
+				fmt.Printf(" ╠══ %s | error: %s\n", f.Region, f.Err.Error())
+			} else {
+				fmt.Printf(" ╠══ %s | %d | %s | redirects: %s\n", f.Region, f.Status, f.Title, strings.Join(f.Redirects, " → "))
+			}
+		}
+	}
+}
+
+func splitPatterns(arg string) []string {
+	if arg == "" {
+		return nil
+	}
+
+	return strings.Split(arg, ",")
+}
+
 func main() {
 	var (
 		argAddress = flag.String("address", "", "The address to be crawled")
+		argSeeds   = flag.String("seeds", "", "Comma separated addresses crawled along with the address into one sitemap, e.g. https://blog.example.com/")
 		argWorkers = flag.Int("workers", 10, "Number of workers processing the crawled websites")
+		argFetch   = flag.Int("fetchers", 0, "Number of concurrent downloads (four times the workers if zero)")
 		argRetries = flag.Int("retries", 2, "Number of retries for each website")
+		argBackoff = flag.Duration("retry-backoff", defaultRetryBackoff.Initial, "Delay before the first retry of a website, doubled for each next one (retried immediately if zero)")
+		argBackMax = flag.Duration("retry-backoff-max", defaultRetryBackoff.Max, "Maximum delay before a retry of a website (no limit if zero)")
+		argPages   = flag.Int("max-pages", 0, "Maximum number of websites to fetch (no limit if zero)")
+		argHostPg  = flag.Int("max-host-pages", 0, "Maximum number of websites to fetch from each host, e.g. of each seed or subdomain (no limit if zero)")
+		argHostCon = flag.Int("max-host-connections", 0, "Maximum number of requests to the same host in flight at once (no limit if zero)")
+		argTotal   = flag.Int64("max-total-bytes", 0, "Total number of response bytes of the crawl, pages and assets, after which no new websites are scheduled (no limit if zero)")
+		argBytes   = flag.Int64("max-bytes", 0, "Former name of -max-total-bytes, used if it is not set")
+		argSlow    = flag.Duration("slow-threshold", 0, "Time to first byte past which a website is reported as slow, e.g. 2s (not reported if zero)")
+		argDelay   = flag.Duration("delay", 0, "Minimum delay between requests to the same host, e.g. 500ms (no limit if zero)")
+		argRPS     = flag.Float64("rps", 0, "Maximum number of requests per second to the same host (no limit if zero)")
+		argInline  = flag.Int("inline-threshold", 100*1024, "Bytes of inline scripts or stylesheets past which a website is reported (not reported if zero)")
+		argRobots  = flag.Bool("ignore-robots", false, "Crawl the websites disallowed by robots.txt and disregard its Crawl-delay")
+		argRobRep  = flag.String("robots-report", "", "File the websites blocked by robots.txt and the sections blocking them are written to as JSON")
+		argLifetim = flag.String("cache-lifetimes", "", "File the number of websites and assets cacheable for each lifetime, e.g. under an hour or no-store, is written to as JSON")
+		argAstLife = flag.Duration("asset-lifetime", time.Hour, "Cache lifetime of assets under which they are reported, e.g. 24h (not reported if zero)")
+		argCaching = flag.String("caching", "", "File the pages and assets served without caching validators or Cache-Control headers are written to as JSON, by path prefix")
+		argDupes   = flag.String("duplicates", "", "File the clusters of websites with duplicate content are written to as JSON")
+		argSimHsh  = flag.Bool("simhash", false, "Compute the SimHash of the text of the websites, so that -duplicates groups the near-identical ones too")
+		argProto   = flag.String("protocols", "", "File the HTTP protocols and content encodings the websites were fetched with are written to as JSON")
+		argNofollw = flag.Bool("respect-nofollow", false, "Do not follow the links with rel=\"nofollow\" and the links of the websites with the robots meta tag nofollow")
+		argNoindex = flag.Bool("respect-noindex", false, "Leave the websites with the robots meta tag noindex out of the sitemap")
+		argRedirs  = flag.Int("max-redirects", 10, "Maximum number of redirects followed for one website")
+		argExtRdr  = flag.Bool("follow-external-redirects", false, "Follow the redirects out of the crawled domains instead of reporting them")
+		argHead    = flag.Bool("head-first", false, "Request the websites with HEAD before GET, skipping those of other content types or too large without downloading them")
+		argPgTypes = flag.String("page-types", "", "Comma separated content types of the websites downloaded with -head-first (text/html and application/xhtml+xml if empty)")
+		argPgSize  = flag.Int64("max-page-size", 0, "Maximum Content-Length of the websites downloaded with -head-first (no limit if zero)")
+		argSubdoms = flag.Bool("include-subdomains", false, "Crawl the subdomains of the address as well, e.g. blog.example.com for example.com")
+		argSRI     = flag.Bool("check-integrity", false, "Fetch the scripts and stylesheets with integrity attributes to verify their Subresource Integrity")
+		argCSP     = flag.Bool("check-csp", false, "Report the assets the Content-Security-Policy of the websites would block")
+		argBroken  = flag.String("broken-links", "", "File the broken links of the websites, internal or external, and the websites referring to them are written to as JSON")
+		argInclude = flag.String("include", "", "Comma separated patterns of the websites to crawl, globs of the path or regular expressions prefixed with re:, e.g. /docs/*")
+		argExclude = flag.String("exclude", "", "Comma separated patterns of the websites not to crawl, globs of the path or regular expressions prefixed with re:, e.g. /logout,re:[?&]sort=")
+		argAffine  = flag.Bool("host-affinity", false, "Process the websites of the same host always on the same worker")
+		argDepth   = flag.Int("max-depth", 0, "Maximum number of links between the address and a crawled website (no limit if zero)")
+		argPolicy  = flag.String("asset-policy", "", "Comma separated asset policies, e.g. script=verify,image=ignore")
+		argVerify  = flag.Bool("verify-assets", false, "Verify the links, scripts, images and videos whose policy is not given by -asset-policy, recording their status, size and content type")
+		argMaxTime = flag.Duration("max-duration", 0, "Maximum duration of the crawl, e.g. 10m (no limit if zero)")
+		argBase    = flag.String("baseline", "", "JSON sitemap of a previous crawl used to skip unchanged pages")
+		argOutput  = flag.String("output", "", "File the JSON sitemap is written to")
+		argChanges = flag.String("changes", "", "File the changes of the websites since the baseline (unchanged, modified, new or removed) are written to as JSON")
+		argXML     = flag.String("sitemap-xml", "", "File the sitemap.xml is written to, split with an index if too large")
+		argMermaid = flag.String("mermaid", "", "File the Mermaid flowchart of the links between the websites is written to, as a Markdown code block if it ends with .md")
+		argMmdMax  = flag.Int("mermaid-max-nodes", 50, "Maximum number of websites for which the -mermaid flowchart is written (no limit if zero)")
+		argGraph   = flag.String("graph", "", "File the link graph of the websites is written to, as DOT (.dot), GEXF (.gexf) or GraphML (.graphml) by its extension")
+		argFinds   = flag.String("findings", "", "File the findings are written to as JSON")
+		argCmpSite = flag.Bool("check-sitemap", false, "Compare the crawled websites with the sitemap.xml declared by the site, reporting the differences as findings")
+		argFeeds   = flag.Bool("follow-feeds", false, "Crawl the websites listed in the RSS and Atom feeds the websites declare, e.g. blog posts unreachable by pagination")
+		argSeedMap = flag.Bool("seed-sitemap", false, "Crawl the websites listed in the sitemap.xml declared by the site as seeds, besides the ones discovered by links")
+		argSuppr   = flag.String("suppressions", "", "JSON file of the known or accepted findings left out of the findings and -fail-on")
+		argUpdSupp = flag.Bool("update-suppressions", false, "Regenerate the suppressions file from the current findings, accepting all of them")
+		argFailOn  = flag.String("fail-on", "", "Exit with status 1 if there are unsuppressed findings at least as severe, one of info, warning or error")
+		argUncrawl = flag.String("uncrawled", "", "File the URLs discovered but not crawled because of the limits are written to as JSON")
+		argThird   = flag.String("third-party", "", "File the inventory of third-party domains referenced by the websites is written to as JSON")
+		argVariant = flag.String("variants", "", "File the variants of the links to the same website, e.g. with different utm parameters, are written to as JSON")
+		argVarPart = flag.String("variant-parts", "query,fragment", "Comma separated parts of the links whose variations are reported with -variants, query or fragment")
+		argRunsDir = flag.String("runs-dir", "", "Directory the results of every run are kept apart in, under <host>/<start time>, the relative paths of the exports being resolved against it")
+		argKeepRun = flag.Int("keep-runs", 0, "Number of the most recent runs of the site kept in -runs-dir, the older ones being removed (no limit if zero)")
+		argKeepDay = flag.Int("keep-days", 0, "Number of days the runs of the site are kept in -runs-dir for, the older ones being removed (no limit if zero)")
+		argParquet = flag.String("parquet", "", "Directory the pages and edges are written to as Parquet files")
+		argXMLBase = flag.String("sitemap-base", "", "URL the sitemap.xml files are served from (defaults to the crawled address)")
+		argShuffle = flag.Bool("shuffle", false, "Randomize the order in which discovered links are crawled")
+		argSeed    = flag.Int64("seed", 0, "Seed used to randomize the crawl order (defaults to current time)")
+		argReferer = flag.String("referer", "none", "Referer sent on behalf of the linking page, one of none, origin or full")
+		argAgent   = flag.String("user-agent", DefaultUserAgent, "User-Agent header sent with every request")
+		argLang    = flag.String("accept-language", "", "Accept-Language header sent with every request, e.g. en-GB,en;q=0.8")
+		argRoutes  = flag.Bool("route-fragments", false, "Crawl #!/ and #/ fragments of single page applications as distinct pages")
+		argSlash   = flag.Bool("strip-trailing-slash", false, "Treat the addresses differing only in the trailing slash of the path as one website")
+		argSortQ   = flag.Bool("sort-query", false, "Treat the addresses differing only in the order of the query parameters as one website")
+		argStripPm = flag.String("strip-params", "", "Comma separated patterns of the query parameters dropped from the addresses, e.g. utm_*,ref")
+		argTrackPm = flag.Bool("strip-tracking", false, "Drop the known tracking and session query parameters from the addresses, e.g. utm_*, gclid, fbclid or jsessionid")
+		argKeepPm  = flag.String("keep-params", "", "Comma separated patterns of the only query parameters kept in the addresses, e.g. page,id")
+		argCanon   = flag.String("canonical", "record", "What becomes of the websites declaring another one canonical, one of record, alias or merge")
+		argHouse   = flag.String("clickhouse", "", "Address of the ClickHouse HTTP interface the pages are streamed to")
+		argQuery   = flag.String("bigquery", "", "BigQuery table (project.dataset.table) the pages are streamed to, authorized with $BIGQUERY_TOKEN")
+		argTable   = flag.String("clickhouse-table", "pages", "ClickHouse table the pages are inserted into")
+		argColumns = flag.String("sink-columns", "", "Comma separated column=field mapping of the sink table, e.g. page_url=url")
+		argTimeout = flag.Duration("timeout", defaultRequestTimeout*time.Second, "Time after which a request times out, rounded up to whole seconds, best raised when crawling through proxies")
+		argProxy   = flag.String("proxy", "", "Comma separated chain of SOCKS5 proxies, e.g. socks5://127.0.0.1:9050 for Tor")
+		argResolv  = flag.String("resolve", "", "Comma separated host[:port]=address[:port] overrides of the addresses connected to, keeping the Host header, e.g. www.example.com:443=10.0.0.5")
+		argHosts   = flag.String("hosts", "", "File in the format of /etc/hosts overriding the addresses of hosts, e.g. 10.0.0.5 www.example.com")
+		argPins    = flag.String("pin", "", "Comma separated pins (sha256/<base64 hash of the public key>) or PEM files of the certificates the address must present one of, the leaf or a CA")
+		argWarm    = flag.Int("warm-connections", 0, "Number of keep-alive connections opened to the host of the address before crawling (none if zero)")
+		argCookies = flag.Bool("cookies", false, "Keep the cookies set by the websites and send them back with the following requests")
+		argHTTP3   = flag.Bool("http3", false, "Fetch the websites over HTTP/3 (QUIC) first, falling back to HTTP/2 for the hosts which do not support it (experimental)")
+		argIsolate = flag.Bool("isolate-circuits", false, "Use a separate proxy circuit (Tor stream isolation) for every request")
+		argRegions = flag.String("regions", "", "Compare the pages fetched through region proxies instead of crawling, e.g. eu=socks5://10.0.0.1:1080,us=http://10.0.0.2:3128")
+		argCompare = flag.String("compare", "", "Comma separated URLs compared between the regions (defaults to the address)")
+		argCheck   = flag.String("checkpoint", "", "File the state of the crawl is saved to periodically, so that it can be resumed")
+		argCheckIv = flag.Duration("checkpoint-interval", time.Minute, "Interval between the checkpoints, e.g. 30s (only saved once done if zero)")
+		argVisCap  = flag.Int("visited-capacity", 0, "Number of URLs a Bloom filter holding the scheduled URLs is sized for (an exact set if zero)")
+		argVisFile = flag.String("visited-file", "", "File the Bloom filter of the scheduled URLs is read from, if it exists, and written to once the crawl is done")
+		argRedis   = flag.String("redis", "", "Address of the Redis server (host:port) whose frontier and visited set are shared with other crawlers, authorized with $REDIS_PASSWORD")
+		argRedKey  = flag.String("redis-key", "crawler", "Prefix of the Redis keys of the crawl, so that several crawls can share a server")
+		argResume  = flag.String("resume", "", "Checkpoint file of an interrupted crawl to continue instead of crawling the address")
+		argBundle  = flag.String("debug-bundle", "", "File a .tar.gz archive of the config, errors, stats, partial sitemap and frontier is written to once the crawl ends, for bug reports")
+		argProfile = flag.String("profile", "", "File the CPU profile of the crawl is written to, for use with go tool pprof")
+		argMemProf = flag.String("memprofile", "", "File the heap profile is written to once the crawl is done, for use with go tool pprof")
+		argMemEvry = flag.Duration("memprofile-every", 0, "Interval between the heap profiles written during the crawl next to -memprofile, named after it and the time, e.g. 5m (none if zero)")
+		argStall   = flag.Duration("stall-timeout", 0, "Time past which a download or the processing of a website is considered stuck, reported and replaced, e.g. 2m (not watched if zero)")
+		argInvar   = flag.Bool("check-invariants", false, "Verify the bookkeeping of the crawler once the crawl is done, for debugging the crawler itself")
+		argEvents  = flag.String("events", "", "Address the progress and the crawled websites are streamed from as Server-Sent Events under /events, e.g. localhost:8080")
+		argStats   = flag.Duration("stats-interval", 0, "Interval between the progress reports of the crawl, e.g. 10s (not reported if zero)")
+		argConfig  = flag.String("config", "", "YAML config file whose keys are the names of the flags, e.g. workers: 10")
+		argHeaders headerList
+		argRewrite rewriteList
 	)
 
+	flag.Var(&argHeaders, "header", "Header sent with every request, e.g. \"X-Token: abc\" (can be repeated)")
+	flag.Var(&argRewrite, "rewrite", "Rule rewriting the discovered addresses before they are crawled, e.g. \"^http:// => https://\" (can be repeated)")
+
 	flag.Parse()
 
+	if *argConfig != "" {
+		config, warnings, err := loadConfig(*argConfig)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, w := range warnings {
+			fmt.Printf("Config warning: %s\n", w)
+		}
+
+		if err = applyConfig(flag.CommandLine, config); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argRegions != "" {
+		if *argCompare == "" {
+			*argCompare = *argAddress
+		}
+
+		compareRegions(*argRegions, *argCompare)
+		return
+	}
+
+	policies, err := parseAssetPolicies(*argPolicy)
+	if err != nil {
+		panic(err)
+	}
+
+	if *argVerify {
+		verifyAssets(policies)
+	}
+
+	columns, err := parseSinkColumns(*argColumns)
+	if err != nil {
+		panic(err)
+	}
+
+	// An unsupported graph format must not be found out only once the crawl is done
+	if *argGraph != "" {
+		if _, err = graphFormatOf(*argGraph); err != nil {
+			panic(err)
+		}
+	}
+
+	var sink Sink
+	switch {
+	case *argHouse != "":
+		sink, err = NewClickHouseSink(*argHouse, *argTable, &SinkOptions{Columns: columns, Timeout: 30 * time.Second})
+	case *argQuery != "":
+		sink, err = NewBigQuerySink(*argQuery, os.Getenv("BIGQUERY_TOKEN"), &SinkOptions{Columns: columns, Timeout: 30 * time.Second})
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	var suppressions Suppressions
+	if *argSuppr != "" {
+		// When updating, the suppressions are generated from scratch if the file does not exist yet
+		if suppressions, err = ReadSuppressions(*argSuppr); err != nil && !(*argUpdSupp && os.IsNotExist(err)) {
+			panic(err)
+		}
+	}
+
+	var failOn *Severity
+	if *argFailOn != "" {
+		failOn = new(Severity)
+		if err = failOn.UnmarshalText([]byte(*argFailOn)); err != nil {
+			panic(err)
+		}
+	}
+
+	var baseline *SiteMap
+	if *argBase != "" {
+		if baseline, err = readSiteMap(*argBase); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argShuffle && *argSeed == 0 {
+		*argSeed = time.Now().UnixNano()
+	}
+
+	if *argShuffle {
+		fmt.Printf("Shuffling with seed: %d\n", *argSeed)
+	}
+
+	referer, ok := refererPolicies[*argReferer]
+	if !ok {
+		panic(ErrInvalidPolicy)
+	}
+
+	canonical, ok := canonicalPolicies[*argCanon]
+	if !ok {
+		panic(ErrInvalidPolicy)
+	}
+
+	var variants VariantReport
+	if *argVariant != "" {
+		if variants, err = parseVariantReport(*argVarPart); err != nil {
+			panic(err)
+		}
+	}
+
+	var visited *BloomVisitedSet
+	if *argRedis == "" && (*argVisFile != "" || *argVisCap > 0) {
+		capacity := *argVisCap
+		if capacity == 0 {
+			capacity = bloomCapacity
+		}
+
+		visited = NewBloomVisitedSet(capacity, bloomFalsePositiveRate)
+	}
+
+	if visited != nil && *argVisFile != "" {
+		if v, err := readVisitedSet(*argVisFile); err == nil {
+			visited = v
+		} else if !os.IsNotExist(err) {
+			panic(err)
+		}
+	}
+
 	fmt.Printf("Params: (Address: %s), (Workers: %d), (Retries: %d)\n\n", *argAddress, *argWorkers, *argRetries)
 
-	crawler, err := NewCrawlerWithOptions(*argAddress, &Options{
-		MaxWorkers: *argWorkers,
-		MaxRetries: *argRetries,
-		Callback: func(s string) {
-			fmt.Printf("Crawling: %s\n", s)
+	resolve, err := parseResolve(*argResolv)
+	if err != nil {
+		panic(err)
+	}
+
+	if *argHosts != "" {
+		hosts, err := readHosts(*argHosts)
+		if err != nil {
+			panic(err)
+		}
+
+		// The overrides of -resolve take precedence
+		for host, address := range hosts {
+			if _, ok := resolve[host]; !ok {
+				resolve[host] = address
+			}
+		}
+	}
+
+	pins, err := parsePins(*argPins)
+	if err != nil {
+		panic(err)
+	}
+
+	var proxy *ProxyOptions
+	if *argProxy != "" {
+		proxy = &ProxyOptions{
+			Chain:           strings.Split(*argProxy, ","),
+			IsolateCircuits: *argIsolate,
+		}
+	}
+
+	options := &Options{
+		MaxWorkers:  *argWorkers,
+		MaxRetries:  *argRetries,
+		MaxFetchers: *argFetch,
+		RetryBackoff: RetryBackoff{
+			Initial:    *argBackoff,
+			Max:        *argBackMax,
+			Multiplier: defaultRetryBackoff.Multiplier,
+			Jitter:     defaultRetryBackoff.Jitter,
+		},
+		Callback: func(e DiscoveryEvent) {
+			if e.Enqueued {
+				fmt.Printf("Crawling: %s\n", e.Url)
+			}
 		},
-	})
+		AssetPolicies:     policies,
+		MaxDuration:       *argMaxTime,
+		Baseline:          baseline,
+		Shuffle:           *argShuffle,
+		Seed:              *argSeed,
+		RouteFragments:    *argRoutes,
+		Normalization:     URLNormalization{TrailingSlash: *argSlash, SortQuery: *argSortQ, KeepParams: splitPatterns(*argKeepPm)},
+		Canonical:         canonical,
+		ReportVariants:    variants,
+		Sink:              sink,
+		Proxy:             proxy,
+		Resolve:           resolve,
+		PinnedKeys:        pins,
+		HTTP3:             *argHTTP3,
+		WarmConnections:   *argWarm,
+		MaxDepth:          *argDepth,
+		HostAffinity:      *argAffine,
+		IncludeSubdomains: *argSubdoms,
+		CheckIntegrity:    *argSRI,
+		CheckCSP:          *argCSP,
+		CheckLinks:        *argBroken != "",
+		SimHash:           *argSimHsh,
+		IncludePatterns:   splitPatterns(*argInclude),
+		ExcludePatterns:   splitPatterns(*argExclude),
+		MaxPages:          *argPages,
+		MaxPagesPerHost:   *argHostPg,
+		MaxTotalBytes:     *argTotal,
+		MaxBytes:          *argBytes,
+		IgnoreRobots:      *argRobots,
+		RespectNofollow:   *argNofollw,
+		RespectNoindex:    *argNoindex,
+		MaxRedirects:      *argRedirs,
+		ExternalRedirects: *argExtRdr,
+		HeadFirst:         *argHead,
+		PageContentTypes:  splitPatterns(*argPgTypes),
+		MaxPageSize:       *argPgSize,
+		CompareSitemap:    *argCmpSite,
+		SeedFromSitemap:   *argSeedMap,
+		FollowFeeds:       *argFeeds,
+		SlowThreshold:     *argSlow,
+		PolitenessDelay:   *argDelay,
+		RequestsPerSecond: *argRPS,
+		RequestHeaders: &RequestHeaders{
+			UserAgent:      *argAgent,
+			AcceptLanguage: *argLang,
+			RefererPolicy:  referer,
+			Headers:        argHeaders,
+		},
+		CheckpointPath:     *argCheck,
+		CheckpointInterval: *argCheckIv,
+		InlineThreshold:    *argInline,
+		StallTimeout:       *argStall,
+		CheckInvariants:    *argInvar,
+		StatsInterval:      *argStats,
+		OnStats: func(s Stats) {
+			fmt.Printf("Progress: %d queued, %d busy, %d fetched, %d bytes, %d errors, %d retries, %.1f pages/s\n",
+				s.Queued, s.Busy, s.Fetched, s.Bytes, s.Errors, s.Retries, s.PagesPerSecond)
+		},
+	}
+
+	options.Normalization.StripParams = splitPatterns(*argStripPm)
+	if *argTrackPm {
+		options.Normalization.StripParams = append(options.Normalization.StripParams, TrackingParams...)
+	}
+
+	if *argCookies {
+		// A jar without a public suffix list keeps the cookies set for whole public suffixes too, which is harmless
+		// for a crawl of a few sites
+		options.CookieJar, _ = cookiejar.New(nil)
+	}
+
+	if len(argRewrite) > 0 {
+		options.RewriteURL = RewriteRules(argRewrite)
+	}
+
+	options.RequestTimeout = *argTimeout
+	options.MaxConcurrencyPerHost = *argHostCon
+	options.AssetLifetimeThreshold = *argAstLife
+
+	// The progress is streamed every second unless its interval is given, in which case it is printed too.
+	// The events of the runs kept apart are logged with their results
+	var events *EventStream
+	if *argEvents != "" || *argRunsDir != "" {
+		events = NewEventStream()
+		options.Hooks = events.Hook(options.Hooks)
+
+		printStats := options.OnStats
+		options.OnStats = func(s Stats) {
+			if *argStats > 0 {
+				printStats(s)
+			}
+
+			events.Stats(s)
+		}
+
+		if *argStats == 0 {
+			options.StatsInterval = time.Second
+		}
+	}
+
+	if visited != nil {
+		options.VisitedSet = visited
+	}
+
+	if *argRedis != "" {
+		redis := &RedisOptions{
+			Address:  *argRedis,
+			Password: os.Getenv("REDIS_PASSWORD"),
+			Key:      *argRedKey,
+		}
+
+		if options.Frontier, err = NewRedisFrontier(redis); err != nil {
+			panic(err)
+		}
+
+		if options.VisitedSet, err = NewRedisVisitedSet(redis); err != nil {
+			panic(err)
+		}
+	}
+
+	var crawler *Crawler
+	if *argResume != "" {
+		crawler, err = ResumeFromCheckpointWithOptions(*argResume, options)
+	} else {
+		seeds := []string{*argAddress}
+		if *argSeeds != "" {
+			seeds = append(seeds, strings.Split(*argSeeds, ",")...)
+		}
+
+		crawler, err = NewCrawlerWithSeeds(seeds, options)
+	}
 
 	if err != nil {
 		panic(err)
 	}
 
-	done, errors := crawler.Crawl()
+	// The address of a resumed crawl is the one it was started with
+	*argAddress = crawler.url
+
+	startedAt := time.Now()
 
-	go func() {
-		select {
-		case e := <-errors:
-			fmt.Printf("Error: %s\n", e.Error())
+	// The results of the run are kept apart from those of the earlier runs of the site
+	var runDir string
+	if *argRunsDir != "" {
+		runDir = RunDir(*argRunsDir, *argAddress, startedAt)
+		if err = os.MkdirAll(runDir, 0755); err != nil {
+			panic(err)
 		}
-	}()
+
+		logFile, err := os.Create(filepath.Join(runDir, "events.jsonl"))
+		if err != nil {
+			panic(err)
+		}
+
+		defer logFile.Close()
+
+		events.Log(logFile)
+
+		for _, path := range []*string{argOutput, argFinds, argUncrawl, argThird, argBroken, argVariant, argChanges, argRobRep, argProto, argCaching, argLifetim, argDupes, argXML, argGraph, argMermaid, argParquet, argBundle, argProfile, argMemProf} {
+			if *path != "" && !filepath.IsAbs(*path) {
+				*path = filepath.Join(runDir, *path)
+			}
+		}
+	}
+
+	// Interrupting the crawl stops it gracefully, so that the partial results are still written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var stopProfile func() error
+	if *argProfile != "" {
+		if stopProfile, err = startCPUProfile(*argProfile); err != nil {
+			panic(err)
+		}
+	}
+
+	var eventServer *http.Server
+	if *argEvents != "" {
+		if eventServer, err = serveEvents(*argEvents, events); err != nil {
+			panic(err)
+		}
+	}
+
+	done, errors := crawler.CrawlWithContext(ctx)
+
+	var stopSnapshots func()
+	if *argMemEvry > 0 {
+		path := *argMemProf
+		if path == "" {
+			path = "mem.out"
+		}
+
+		stopSnapshots = startHeapSnapshots(path, *argMemEvry, func(err error) {
+			fmt.Printf("Writing heap profile fails with error: %s\n", err.Error())
+		})
+	}
+
+	// Errors are aggregated by the crawler and summarized once the crawl is done,
+	// the latest of them are kept for the debug bundle
+	log := &bundleLog{}
+	for err := range errors {
+		if *argBundle != "" {
+			log.add(err)
+		}
+	}
 
 	<-done
 
+	if events != nil {
+		events.Done(crawler.Stats())
+	}
+
+	if eventServer != nil {
+		if err = closeEvents(eventServer); err != nil {
+			panic(err)
+		}
+	}
+
+	if stopSnapshots != nil {
+		stopSnapshots()
+	}
+
+	if stopProfile != nil {
+		if err = stopProfile(); err != nil {
+			panic(err)
+		}
+	}
+
+	// Taken before the results are exported, so that the profile shows the memory held by the crawler
+	if *argMemProf != "" {
+		if err = writeHeapProfile(*argMemProf); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argOutput != "" {
+		if err = writeSiteMap(*argOutput, crawler.GetSiteMap()); err != nil {
+			panic(err)
+		}
+	}
+
+	findings := crawler.GetFindings()
+
+	if visited != nil && *argVisFile != "" {
+		if err = writeVisitedSet(*argVisFile, visited); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argUpdSupp && *argSuppr != "" {
+		if err = writeSuppressions(*argSuppr, suppressions.Update(findings)); err != nil {
+			panic(err)
+		}
+	}
+
+	findings = findings.Suppress(suppressions)
+
+	if *argFinds != "" {
+		if err = writeFindings(*argFinds, findings); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argUncrawl != "" {
+		if err = writeUncrawled(*argUncrawl, crawler.GetUncrawled()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argThird != "" {
+		if err = writeThirdParty(*argThird, crawler.GetThirdPartyDomains()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argBroken != "" {
+		if err = writeBrokenLinks(*argBroken, crawler.GetBrokenLinks()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argVariant != "" {
+		if err = writeVariants(*argVariant, crawler.GetLinkVariants()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argRobRep != "" {
+		if err = writeRobotsReport(*argRobRep, crawler.GetRobotsReport()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argCaching != "" {
+		if err = writeCachingAudit(*argCaching, crawler.GetCachingAudit()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argLifetim != "" {
+		if err = writeCacheLifetimes(*argLifetim, crawler.GetCacheLifetimes()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argDupes != "" {
+		if err = writeDuplicates(*argDupes, crawler.GetSiteMap().Duplicates()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argProto != "" {
+		if err = writeProtocolReport(*argProto, crawler.GetProtocolReport()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argChanges != "" {
+		if err = writeChanges(*argChanges, crawler.GetChanges()); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argParquet != "" {
+		if err = ExportParquet(crawler.GetSiteMap(), *argParquet); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argGraph != "" {
+		if err = ExportGraph(crawler.GetSiteMap(), *argGraph); err != nil {
+			panic(err)
+		}
+	}
+
+	// The flowchart is a convenience, a crawl too large for it is no reason to fail
+	if *argMermaid != "" {
+		if err = ExportMermaid(crawler.GetSiteMap(), *argMermaid, *argMmdMax); err != nil {
+			fmt.Printf("Mermaid flowchart not written: %s\n", err.Error())
+		}
+	}
+
+	if *argXML != "" {
+		if *argXMLBase == "" {
+			*argXMLBase = *argAddress
+		}
+
+		if _, err = ExportSitemapXML(crawler.GetSiteMap(), *argXML, *argXMLBase); err != nil {
+			panic(err)
+		}
+	}
+
+	// The manifest is written next to the exports, or into the Parquet directory if it is the only one
+	dir := exportDir(*argOutput, *argFinds, *argUncrawl, *argThird, *argBroken, *argVariant, *argChanges, *argRobRep, *argProto, *argCaching, *argLifetim, *argDupes, *argXML, *argGraph, *argMermaid)
+	if dir == "" {
+		dir = *argParquet
+	}
+
+	if dir == "" {
+		dir = runDir
+	}
+
+	if dir != "" {
+		if err = writeManifest(dir, newManifest(crawler.seeds, startedAt, crawler)); err != nil {
+			panic(err)
+		}
+	}
+
+	if *argBundle != "" {
+		if err = writeDebugBundle(*argBundle, crawler, newManifest(crawler.seeds, startedAt, crawler), log); err != nil {
+			panic(err)
+		}
+	}
+
+	// A failed cleanup leaves the earlier runs behind, the results of this one being written already
+	if runDir != "" && (*argKeepRun > 0 || *argKeepDay > 0) {
+		policy := RetentionPolicy{KeepRuns: *argKeepRun, MaxAge: time.Duration(*argKeepDay) * 24 * time.Hour}
+
+		removed, err := PruneRuns(filepath.Dir(runDir), policy, time.Now())
+		for _, dir := range removed {
+			fmt.Printf("Removed the earlier run %s\n", dir)
+		}
+
+		if err != nil {
+			fmt.Printf("Removing the earlier runs fails with error: %s\n", err.Error())
+		}
+	}
+
 	fmt.Printf("\n\033[1mResults:\033[0m\n\n")
 
-	for k, v := range crawler.GetSiteMap() {
+	for _, v := range crawler.GetSiteMap().Pages() {
 		fmt.Printf("─────────────────────────────────────────────────\n")
-		fmt.Printf("Crawled \033[1m%s\033[0m | %s\n", k, v.Title)
+		fmt.Printf("Crawled \033[1m%s\033[0m | %s | depth %d\n", v.Url, v.Title, v.Depth)
 		fmt.Printf(" ╠ \033[1mAssets:\033[0m\n")
 		for _, asset := range v.Assets {
 			fmt.Printf(" ╠══ %s\n", asset.Url)
@@ -62,4 +989,19 @@ func main() {
 		}
 		fmt.Printf("─────────────────────────────────────────────────\n\n")
 	}
+
+	if summary := crawler.GetErrorSummary(); len(summary) > 0 {
+		fmt.Printf("\033[1mErrors:\033[0m\n\n")
+
+		for _, stat := range summary {
+			fmt.Printf(" ╠══ %s | %s | %d times (e.g. %s)\n", stat.Host, stat.Class, stat.Count, stat.Sample.Error())
+		}
+	}
+
+	if failOn != nil && !*argUpdSupp {
+		if failing := findings.BySeverity(*failOn); len(failing) > 0 {
+			fmt.Printf("\n%d findings at least as severe as %s\n", len(failing), failOn)
+			os.Exit(1)
+		}
+	}
 }
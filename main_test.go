@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssetPoliciesAreParsed(t *testing.T) {
+	policies, err := parseAssetPolicies("script=verify, image=ignore,video=download")
+	if err != nil {
+		t.Fatalf("Parsing fails with error: %s\n", err.Error())
+	}
+
+	expected := map[AssetType]AssetPolicy{
+		Script: PolicyVerify,
+		Image:  PolicyIgnore,
+		Video:  PolicyDownload,
+	}
+
+	for k, v := range expected {
+		if policies[k] != v {
+			t.Errorf("Unexpected policy for asset type %d: %d\n", k, policies[k])
+		}
+	}
+
+	for _, v := range []string{"script", "script=check", "font=ignore"} {
+		if _, err := parseAssetPolicies(v); err == nil {
+			t.Errorf("Parsing does not fail for invalid policy: %s\n", v)
+		}
+	}
+}
+
+func TestAssetsAreVerified(t *testing.T) {
+	policies := map[AssetType]AssetPolicy{Image: PolicyDownload}
+	verifyAssets(policies)
+
+	expected := map[AssetType]AssetPolicy{
+		Link:   PolicyVerify,
+		Script: PolicyVerify,
+		Image:  PolicyDownload,
+		Video:  PolicyVerify,
+	}
+
+	if !reflect.DeepEqual(policies, expected) {
+		t.Errorf("Unexpected policies: %v\n", policies)
+	}
+}
+
+func TestVariantReportIsParsed(t *testing.T) {
+	if report, err := parseVariantReport("query, fragment"); err != nil || report != (VariantReport{Query: true, Fragment: true}) {
+		t.Errorf("Unexpected variant report: %v, %v\n", report, err)
+	}
+
+	if _, err := parseVariantReport("query,path"); err == nil {
+		t.Errorf("Parsing does not fail for invalid part\n")
+	}
+}
+
+func TestHostOverridesAreParsed(t *testing.T) {
+	resolve, err := parseResolve("www.example.com:443=10.0.0.5, example.com=[::1]:8443")
+	if err != nil {
+		t.Fatalf("Parsing fails with error: %s\n", err.Error())
+	}
+
+	expected := map[string]string{"www.example.com:443": "10.0.0.5", "example.com": "[::1]:8443"}
+	if !reflect.DeepEqual(resolve, expected) {
+		t.Errorf("Unexpected host overrides: %v\n", resolve)
+	}
+
+	for _, v := range []string{"www.example.com", "=10.0.0.5", "http://www.example.com=10.0.0.5"} {
+		if _, err := parseResolve(v); err == nil {
+			t.Errorf("Parsing does not fail for invalid override: %s\n", v)
+		}
+	}
+}
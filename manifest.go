@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Version of the crawler recorded in the manifest, set at build time with -ldflags "-X main.Version=..."
+var Version = "dev"
+
+// Manifest struct describes a crawl so that its exported results are self-describing and reproducible.
+// Config holds the values of all command line flags the crawl was run with.
+type Manifest struct {
+	Version    string            `json:"version"`
+	GoVersion  string            `json:"go_version"`
+	Seeds      []string          `json:"seeds"`
+	Config     map[string]string `json:"config"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Stats      ManifestStats     `json:"stats"`
+}
+
+type ManifestStats struct {
+	Pages     int `json:"pages"`
+	Assets    int `json:"assets"`
+	Errors    int `json:"errors"`
+	Findings  int `json:"findings"`
+	Uncrawled int `json:"uncrawled"`
+}
+
+func newManifest(seeds []string, startedAt time.Time, c *Crawler) *Manifest {
+	m := &Manifest{
+		Version:    Version,
+		GoVersion:  runtime.Version(),
+		Seeds:      seeds,
+		Config:     manifestConfig(flag.CommandLine),
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+
+	s := c.GetSiteMap()
+	m.Stats.Pages = s.Len()
+
+	for _, page := range s.Pages() {
+		m.Stats.Assets += len(page.Assets)
+	}
+
+	for _, stat := range c.GetErrorSummary() {
+		m.Stats.Errors += stat.Count
+	}
+
+	m.Stats.Findings = len(c.GetFindings())
+	m.Stats.Uncrawled = len(c.GetUncrawled())
+
+	return m
+}
+
+// manifestConfig returns the values of all flags of the set. Values of the headers may carry credentials,
+// so only their names are recorded.
+func manifestConfig(fs *flag.FlagSet) map[string]string {
+	config := make(map[string]string)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if h, ok := f.Value.(*headerList); ok {
+			keys := make([]string, 0, len(*h))
+			for _, header := range *h {
+				keys = append(keys, header.Key)
+			}
+
+			config[f.Name] = strings.Join(keys, ",")
+		} else {
+			config[f.Name] = f.Value.String()
+		}
+	})
+
+	return config
+}
+
+// writeManifest writes the manifest as manifest.json in the directory dir.
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644)
+}
+
+// exportDir returns the directory the first of the exported files is written to, empty if there is none.
+func exportDir(files ...string) string {
+	for _, f := range files {
+		if f != "" {
+			return filepath.Dir(f)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestManifestDescribesCrawl(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/missing\n", server.URL, server.URL)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	startedAt := time.Now()
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	m := newManifest(c.seeds, startedAt, c)
+
+	if m.Version != Version || m.GoVersion != runtime.Version() || len(m.Seeds) != 1 || m.Seeds[0] != server.URL+"/" {
+		t.Errorf("Unexpected manifest: %+v\n", m)
+	}
+
+	if !m.StartedAt.Equal(startedAt) || m.FinishedAt.Before(startedAt) {
+		t.Errorf("Unexpected times: %s, %s\n", m.StartedAt, m.FinishedAt)
+	}
+
+	if m.Stats.Pages != 2 || m.Stats.Errors == 0 {
+		t.Errorf("Unexpected stats: %+v\n", m.Stats)
+	}
+}
+
+func TestManifestRedactsHeaders(t *testing.T) {
+	var (
+		fs      = flag.NewFlagSet("test", flag.ContinueOnError)
+		headers headerList
+	)
+
+	fs.Int("workers", 10, "")
+	fs.Var(&headers, "header", "")
+
+	if err := fs.Parse([]string{"-workers=3", "-header", "Authorization: Bearer secret", "-header", "X-Token: abc"}); err != nil {
+		t.Fatalf("Parsing fails with error: %s\n", err.Error())
+	}
+
+	config := manifestConfig(fs)
+
+	if config["workers"] != "3" || config["header"] != "Authorization,X-Token" {
+		t.Errorf("Unexpected config: %v\n", config)
+	}
+}
+
+func TestManifestIsWrittenNextToExports(t *testing.T) {
+	dir := t.TempDir()
+
+	if d := exportDir("", filepath.Join(dir, "sitemap.json"), filepath.Join(t.TempDir(), "findings.json")); d != dir {
+		t.Errorf("Unexpected export directory: %s\n", d)
+	}
+
+	if d := exportDir("", ""); d != "" {
+		t.Errorf("Unexpected export directory: %s\n", d)
+	}
+
+	if err := writeManifest(dir, &Manifest{Version: "1.2.3", Seeds: []string{"http://example.com/"}}); err != nil {
+		t.Fatalf("Writing manifest fails with error: %s\n", err.Error())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Reading manifest fails with error: %s\n", err.Error())
+	}
+
+	var m Manifest
+	if err = json.Unmarshal(data, &m); err != nil || m.Version != "1.2.3" || m.Seeds[0] != "http://example.com/" {
+		t.Errorf("Unexpected manifest: %s\n", data)
+	}
+}
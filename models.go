@@ -1,17 +1,132 @@
 package main
 
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
 // Page struct represents a single crawled website.
 // It holds references to pages that it links to and that link to it,
-// as well as the list of static assets it depends on
+// as well as the list of static assets it depends on.
+// Versions holds earlier crawls of the same URL retained when merging sitemaps with MergeKeepBoth.
+// ETag and LastModified are the cache validators served with the page, used for warm-starting later crawls.
+// Protocol is the HTTP protocol the page was fetched over, e.g. HTTP/2.0, and Encoding the content encoding
+// it was served with, e.g. gzip or identity, both only recorded by the default downloader.
+// Depth is the number of links between the root URL and the page, along the path it was discovered by.
+// Extra holds arbitrary data attached to the page by its consumers, which is carried over to the exports.
+// While the crawl is running it must only be accessed with SetExtra and GetExtra.
+// Size is the number of bytes of the page's HTML, of which InlineScriptSize bytes are inline scripts
+// and InlineStyleSize bytes inline stylesheets.
+// Canonical is the URL the page declares canonical with <link rel="canonical">, if it is not its own,
+// and Aliases are the URLs of the crawled pages declaring this one canonical (see CanonicalPolicy).
+// RedirectedFrom are the crawled URLs which redirect to the page, each chain of redirects in order.
+// ExternalLinks are the links of the page out of the crawled domains, which are recorded but not followed
+// (only extracted if the extractor implements ExternalLinkExtractor).
+// ContentHash is the hash of the page's HTML and Change how it changed since the baseline (see ChangeStatus).
+// SimHash is the SimHash of the page's text, only computed with the SimHash option, telling near-duplicates apart
+// (see SiteMap.Duplicates).
 type Page struct {
 	Title, Url          string
+	Canonical           string
+	Aliases             []string
+	RedirectedFrom      []string
+	ContentHash         string
+	SimHash             string
+	Change              ChangeStatus
+	ETag, LastModified  string
+	Protocol, Encoding  string
+	CrawledAt           time.Time
+	Depth               int
+	Size                int
+	InlineScriptSize    int
+	InlineStyleSize     int
 	LinksTo, LinkedFrom []*Page
+	ExternalLinks       []string
 	Assets              []*Asset
+	Versions            []*Page
+	Extra               map[string]interface{}
+
+	mue sync.RWMutex
 }
 
+// Weight returns the number of bytes of the page's HTML and its assets. Only the sizes of assets
+// downloaded according to their policy are known, so the others do not count.
+func (p *Page) Weight() int {
+	weight := p.Size
+	for _, asset := range p.Assets {
+		weight += asset.Size
+	}
+
+	return weight
+}
+
+func (p *Page) SetExtra(key string, value interface{}) {
+	p.mue.Lock()
+	if p.Extra == nil {
+		p.Extra = make(map[string]interface{})
+	}
+	p.Extra[key] = value
+	p.mue.Unlock()
+}
+
+func (p *Page) GetExtra(key string) (interface{}, bool) {
+	p.mue.RLock()
+	value, ok := p.Extra[key]
+	p.mue.RUnlock()
+
+	return value, ok
+}
+
+// CopyExtra returns a copy of the data attached to the page, nil if there is none.
+func (p *Page) CopyExtra() map[string]interface{} {
+	p.mue.RLock()
+	defer p.mue.RUnlock()
+
+	if len(p.Extra) == 0 {
+		return nil
+	}
+
+	extra := make(map[string]interface{}, len(p.Extra))
+	for k, v := range p.Extra {
+		extra[k] = v
+	}
+
+	return extra
+}
+
+// extraJSON returns the data attached to the page encoded as JSON, empty if there is none or it cannot be encoded.
+func extraJSON(page *Page) string {
+	extra := page.CopyExtra()
+	if extra == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// Asset struct represents a static resource referenced by a page.
+// Size and Verified are only populated when the asset's policy requires it to be fetched, and so are Status
+// and ContentType, the status code and Content-Type of the response, if the default downloader fetched it.
+// The size of a verified asset is its Content-Length, if the server sent one.
+// Rel and As are only populated for resource hints, with the kind of the hint (e.g. preload or preconnect)
+// and the destination of the hinted resource (e.g. script or font).
+// Integrity is the Subresource Integrity metadata of scripts and links, if there is any.
 type Asset struct {
-	Type AssetType
-	Url  string
+	Type        AssetType `json:"type"`
+	Url         string    `json:"url"`
+	Size        int       `json:"size,omitempty"`
+	Verified    bool      `json:"verified,omitempty"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Rel         string    `json:"rel,omitempty"`
+	As          string    `json:"as,omitempty"`
+	Integrity   string    `json:"integrity,omitempty"`
 }
 
 type AssetType uint8
@@ -21,9 +136,54 @@ const (
 	Script AssetType = iota
 	Image  AssetType = iota
 	Video  AssetType = iota
+	Hint   AssetType = iota
+)
+
+// AssetPolicy defines what the crawler does with assets of a given type.
+// PolicyRecord only stores the asset in the sitemap, PolicyVerify additionally checks
+// that it is reachable with a HEAD request, PolicyDownload fetches the whole asset
+// and PolicyIgnore drops it altogether.
+type AssetPolicy uint8
+
+const (
+	PolicyRecord   AssetPolicy = iota
+	PolicyVerify   AssetPolicy = iota
+	PolicyDownload AssetPolicy = iota
+	PolicyIgnore   AssetPolicy = iota
+)
+
+// CanonicalPolicy defines what the crawler does with the pages declaring another crawled page canonical.
+// CanonicalRecord only stores the canonical URL in the page, CanonicalAlias additionally adds the page
+// to the Aliases of the canonical page and CanonicalMerge collapses it into the canonical page:
+// the page is removed from the sitemap and its links are moved to the canonical page.
+type CanonicalPolicy uint8
+
+const (
+	CanonicalRecord CanonicalPolicy = iota
+	CanonicalAlias  CanonicalPolicy = iota
+	CanonicalMerge  CanonicalPolicy = iota
 )
 
 type result struct {
-	url, from string
-	body      []byte
+	url, from  string
+	depth      int
+	body       []byte
+	validators Validators
+	response   responseInfo
+
+	// cached is the baseline page used instead of the body when the content was not modified
+	cached *Page
+
+	// location is the URL the content was served from if the URL redirected there, through the redirectedFrom URLs
+	location       string
+	redirectedFrom []string
+}
+
+// pageURL returns the URL the page of the result is recorded under, the one it redirected to if it did
+func (r *result) pageURL() string {
+	if r.location != "" {
+		return r.location
+	}
+
+	return r.url
 }
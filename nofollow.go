@@ -0,0 +1,83 @@
+package main
+
+// robotsOf returns the directives of the page of the result to robots, provided the extractor of the page
+// implements RobotsExtractor. The directives of pages not modified since the baseline are not known.
+func (c *Crawler) robotsOf(r *result) RobotsDirectives {
+	if r.cached != nil || (!c.respectNofollow && !c.respectNoindex) {
+		return RobotsDirectives{}
+	}
+
+	e, ok := c.extractorFor(r.url).(RobotsExtractor)
+	if !ok {
+		return RobotsDirectives{}
+	}
+
+	directives, err := e.ExtractRobots(r.body)
+	if err != nil {
+		return RobotsDirectives{}
+	}
+
+	return directives
+}
+
+// nofollowLinks returns the links of the page which must not be followed, nil if all of them may be
+func (c *Crawler) nofollowLinks(links []string, directives RobotsDirectives) map[string]struct{} {
+	if !c.respectNofollow || (!directives.NoFollow && len(directives.NofollowLinks) == 0) {
+		return nil
+	}
+
+	if directives.NoFollow {
+		directives.NofollowLinks = links
+	}
+
+	nofollow := make(map[string]struct{}, len(directives.NofollowLinks))
+	for _, link := range c.redirectedLinks(c.canonicalLinks(directives.NofollowLinks)) {
+		nofollow[link] = struct{}{}
+	}
+
+	return nofollow
+}
+
+func (c *Crawler) markNoindex(url string) {
+	c.muni.Lock()
+	c.noindex[url] = struct{}{}
+	c.muni.Unlock()
+}
+
+// applyNoindex removes the pages which must not be indexed from the sitemap, along with the links to and from them.
+// They stay in the sitemap while the crawl is running, so that they are crawled once.
+func (c *Crawler) applyNoindex() {
+	c.muni.Lock()
+	defer c.muni.Unlock()
+
+	if len(c.noindex) == 0 {
+		return
+	}
+
+	c.mus.Lock()
+	defer c.mus.Unlock()
+
+	removed := make(map[*Page]struct{}, len(c.noindex))
+	for url := range c.noindex {
+		if page, ok := c.sites[url]; ok {
+			removed[page] = struct{}{}
+			delete(c.sites, url)
+		}
+	}
+
+	for _, page := range c.sites {
+		page.LinksTo = withoutPages(page.LinksTo, removed)
+		page.LinkedFrom = withoutPages(page.LinkedFrom, removed)
+	}
+}
+
+func withoutPages(pages []*Page, removed map[*Page]struct{}) []*Page {
+	kept := pages[:0]
+	for _, p := range pages {
+		if _, ok := removed[p]; !ok {
+			kept = append(kept, p)
+		}
+	}
+
+	return kept
+}
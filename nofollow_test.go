@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// robotsExtractor treats the lines "noindex" and "nofollow" as the robots meta tag and the lines
+// prefixed with "nofollow " as links with rel="nofollow"
+type robotsExtractor struct {
+	lineExtractor
+}
+
+func (e robotsExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	title, lines, assets, err := e.lineExtractor.Extract(body)
+
+	links := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line != "noindex" && line != "nofollow" {
+			links = append(links, strings.TrimPrefix(line, "nofollow "))
+		}
+	}
+
+	return title, links, assets, err
+}
+
+func (robotsExtractor) ExtractRobots(body []byte) (RobotsDirectives, error) {
+	directives := RobotsDirectives{NofollowLinks: make([]string, 0)}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		switch {
+		case line == "noindex":
+			directives.NoIndex = true
+		case line == "nofollow":
+			directives.NoFollow = true
+		case strings.HasPrefix(line, "nofollow "):
+			directives.NofollowLinks = append(directives.NofollowLinks, strings.TrimPrefix(line, "nofollow "))
+		}
+	}
+
+	return directives, nil
+}
+
+func TestCrawlerRespectsRobotsDirectives(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\nnofollow %s/login\n%s/hidden\n", server.URL, server.URL, server.URL)
+		case "/hidden":
+			fmt.Fprintf(w, "noindex\n%s/b\n", server.URL)
+		case "/a":
+			fmt.Fprintf(w, "nofollow\n%s/c\n%s/\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	for _, respect := range []bool{false, true} {
+		var (
+			mu         sync.Mutex
+			violations []error
+		)
+
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:      2,
+			MaxRetries:      1,
+			Downloader:      unpooledDownloader{},
+			Extractor:       robotsExtractor{},
+			IgnoreRobots:    true,
+			RespectNofollow: respect,
+			RespectNoindex:  respect,
+			CheckInvariants: true,
+			OnError: func(err CrawlError) {
+				mu.Lock()
+				violations = append(violations, err)
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, _ := c.Crawl()
+		<-done
+
+		if len(violations) != 0 {
+			t.Errorf("Unexpected errors: %v\n", violations)
+		}
+
+		sitemap := c.GetSiteMap()
+
+		urls := make([]string, 0, sitemap.Len())
+		for _, page := range sitemap.Pages() {
+			urls = append(urls, page.Url)
+		}
+
+		expected := []string{server.URL + "/", server.URL + "/a", server.URL + "/b", server.URL + "/c", server.URL + "/hidden", server.URL + "/login"}
+		if respect {
+			expected = []string{server.URL + "/", server.URL + "/a", server.URL + "/b"}
+		}
+
+		if !reflect.DeepEqual(urls, expected) {
+			t.Errorf("Unexpected pages: %v\n", urls)
+		}
+
+		if !respect {
+			continue
+		}
+
+		if links := sitemap.outLinks()[server.URL+"/"]; !reflect.DeepEqual(links, []string{server.URL + "/a"}) {
+			t.Errorf("Unexpected links to the pages left out: %v\n", links)
+		}
+
+		uncrawled := c.GetUncrawled()
+		if len(uncrawled) != 2 || uncrawled[0].Url != server.URL+"/c" || uncrawled[1].Url != server.URL+"/login" ||
+			uncrawled[0].Reason != SkipNofollow || uncrawled[1].Reason != SkipNofollow {
+			t.Errorf("Unexpected uncrawled URLs: %v\n", uncrawled)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipFile wraps a gzip writer so that closing it flushes the compressed stream and closes the underlying file.
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+
+	return g.f.Close()
+}
+
+// gunzipFile wraps a gzip reader so that closing it closes the underlying file.
+type gunzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gunzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// createOutput creates the file under path, compressing its content if the path ends with .gz
+func createOutput(path string) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+
+	return &gzipFile{Writer: gzip.NewWriter(f), f: f}, nil
+}
+
+// openInput opens the file under path, decompressing its content if the path ends with .gz
+func openInput(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &gunzipFile{Reader: r, f: f}, nil
+}
+
+// hasFormat reports whether path has given extension, ignoring the .gz suffix of compressed files
+func hasFormat(path, ext string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ext)
+}
+
+// readSiteMap reads the sitemap from a JSON or, if the path ends with .ndjson, a newline delimited JSON file.
+func readSiteMap(path string) (*SiteMap, error) {
+	r, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	s := NewSiteMap()
+
+	if hasFormat(path, ".ndjson") {
+		err = s.ReadNDJSON(r)
+	} else {
+		err = json.NewDecoder(r).Decode(s)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// writeSiteMap writes the sitemap as JSON or, if the path ends with .ndjson, as newline delimited JSON.
+func writeSiteMap(path string, s *SiteMap) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+
+	if hasFormat(path, ".ndjson") {
+		err = s.WriteNDJSON(w)
+	} else {
+		var data []byte
+		if data, err = json.MarshalIndent(s, "", "  "); err == nil {
+			_, err = w.Write(data)
+		}
+	}
+
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// writeFindings writes the findings as JSON carrying the schema version.
+func writeFindings(path string, f Findings) error {
+	return writeJSON(path, f)
+}
+
+// writeSuppressions writes the suppressions as JSON.
+func writeSuppressions(path string, s Suppressions) error {
+	return writeJSON(path, s)
+}
+
+// writeUncrawled writes the URLs discovered but not crawled as JSON, along with the reasons.
+func writeUncrawled(path string, uncrawled []UncrawledURL) error {
+	return writeJSON(path, uncrawled)
+}
+
+// writeThirdParty writes the inventory of third-party domains as JSON.
+func writeThirdParty(path string, domains []ThirdPartyDomain) error {
+	return writeJSON(path, domains)
+}
+
+// writeVariants writes the variants of the links to the same pages as JSON.
+func writeVariants(path string, variants []LinkVariants) error {
+	return writeJSON(path, variants)
+}
+
+// readVisitedSet reads the Bloom filter of the URLs scheduled by earlier crawls, possibly compressed.
+func readVisitedSet(path string) (*BloomVisitedSet, error) {
+	r, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	return ReadBloomVisitedSet(bufio.NewReader(r))
+}
+
+// readHosts reads the overrides of the addresses of hosts in the format of /etc/hosts.
+func readHosts(path string) (map[string]string, error) {
+	r, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	return parseHosts(r)
+}
+
+// writeVisitedSet writes the Bloom filter of the scheduled URLs, compressed if the path ends with .gz.
+func writeVisitedSet(path string, s *BloomVisitedSet) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+	if _, err = s.WriteTo(b); err == nil {
+		err = b.Flush()
+	}
+
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// writeCachingAudit writes the caching headers of the pages and assets by path prefix as JSON.
+func writeCachingAudit(path string, prefixes []CachingPrefix) error {
+	return writeJSON(path, prefixes)
+}
+
+// writeCacheLifetimes writes the number of pages and assets cacheable for each lifetime as JSON.
+func writeCacheLifetimes(path string, lifetimes CacheLifetimes) error {
+	return writeJSON(path, lifetimes)
+}
+
+// writeBrokenLinks writes the broken links and the pages referring to them as JSON.
+func writeBrokenLinks(path string, links []BrokenLink) error {
+	return writeJSON(path, links)
+}
+
+// writeDuplicates writes the clusters of pages with duplicate content as JSON.
+func writeDuplicates(path string, clusters []DuplicateCluster) error {
+	return writeJSON(path, clusters)
+}
+
+// writeProtocolReport writes the adoption of HTTP protocols and content encodings as JSON.
+func writeProtocolReport(path string, report ProtocolReport) error {
+	return writeJSON(path, report)
+}
+
+// writeRobotsReport writes the URLs blocked by robots.txt and the sections blocking them as JSON.
+func writeRobotsReport(path string, report RobotsReport) error {
+	return writeJSON(path, report)
+}
+
+// writeChanges writes how the pages changed since the baseline as JSON.
+func writeChanges(path string, changes []PageChange) error {
+	return writeJSON(path, changes)
+}
+
+func writeJSON(path string, v interface{}) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		_, err = w.Write(data)
+	}
+
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSiteMapRoundTripsThroughCompressedFiles(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		a   = &Page{Url: "http://example.com/", Title: "A"}
+		b   = &Page{Url: "http://example.com/b", Title: "B"}
+	)
+
+	a.LinksTo = []*Page{b}
+
+	for _, name := range []string{"out.json", "out.json.gz", "out.ndjson", "out.ndjson.gz"} {
+		path := filepath.Join(dir, name)
+
+		if err := writeSiteMap(path, newSiteMapFrom(map[string]*Page{a.Url: a, b.Url: b})); err != nil {
+			t.Fatalf("Writing %s fails with error: %s\n", name, err.Error())
+		}
+
+		s, err := readSiteMap(path)
+		if err != nil {
+			t.Fatalf("Reading %s fails with error: %s\n", name, err.Error())
+		}
+
+		if p, ok := s.Get(a.Url); !ok || p.Title != "A" || len(p.LinksTo) != 1 {
+			t.Errorf("Page not restored from %s\n", name)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out.json.gz"))
+	if err != nil || len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Errorf("Output is not gzip compressed\n")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+// pinPrefix prefixes the base64 SHA-256 hash of the public key of a certificate in a pin
+const pinPrefix = "sha256/"
+
+// certPin returns the pin of the certificate, sha256/ followed by the base64 SHA-256 hash of its public key
+// (SubjectPublicKeyInfo), which survives the renewal of the certificate with the same key
+func certPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// validPin reports whether the pin is sha256/ followed by a base64 SHA-256 hash
+func validPin(pin string) bool {
+	if !strings.HasPrefix(pin, pinPrefix) {
+		return false
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix))
+	return err == nil && len(sum) == sha256.Size
+}
+
+// pinsOfPEM returns the pins of the PEM encoded certificates, e.g. the leaf or CA certificates of a site
+func pinsOfPEM(data []byte) ([]string, error) {
+	var pins []string
+
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		pins = append(pins, certPin(cert))
+	}
+
+	if len(pins) == 0 {
+		return nil, fmt.Errorf("%w: no certificates", ErrInvalidPin)
+	}
+
+	return pins, nil
+}
+
+// seedPins returns the pins expected of each host of the seeds
+func seedPins(seeds, pins []string) map[string][]string {
+	hosts := make(map[string][]string, len(seeds))
+
+	for _, seed := range seeds {
+		if u, err := neturl.Parse(seed); err == nil && u.Hostname() != "" {
+			hosts[u.Hostname()] = pins
+		}
+	}
+
+	return hosts
+}
+
+// verifyPins returns a function for tls.Config.VerifyConnection failing with ErrPinMismatch, unless the chain
+// presented by a host with pins has a certificate with one of them. The chain is verified as usual before.
+func verifyPins(pins map[string][]string) func(cs tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		expected, ok := pins[strings.ToLower(cs.ServerName)]
+		if !ok {
+			return nil
+		}
+
+		certs := cs.PeerCertificates
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+
+		for _, cert := range certs {
+			pin := certPin(cert)
+			for _, p := range expected {
+				if p == pin {
+					return nil
+				}
+			}
+		}
+
+		presented := make([]string, len(cs.PeerCertificates))
+		for i, cert := range cs.PeerCertificates {
+			presented[i] = fmt.Sprintf("%s (%s)", certPin(cert), cert.Subject.CommonName)
+		}
+
+		return fmt.Errorf("%w: %s presented %s", ErrPinMismatch, cs.ServerName, strings.Join(presented, ", "))
+	}
+}
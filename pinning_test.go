@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// trustServer makes the default downloader trust the certificate of the test server, valid for example.com
+func trustServer(d Downloader, server *httptest.Server) {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	transport := d.(*defaultDownloader).client.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+
+	transport.TLSClientConfig.RootCAs = roots
+}
+
+func TestDownloaderVerifiesPins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	pin := certPin(server.Certificate())
+	other := "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	for _, tc := range []struct {
+		pins map[string][]string
+		err  error
+	}{
+		{map[string][]string{"example.com": {other, pin}}, nil},
+		{map[string][]string{"example.com": {other}}, ErrPinMismatch},
+		{map[string][]string{"example.org": {other}}, nil},
+	} {
+		downloader, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+			Timeout: 5,
+			Pool:    NewBufferPool(2, 1024),
+			Resolve: map[string]string{"example.com": server.Listener.Addr().String()},
+			Pins:    tc.pins,
+		})
+		if err != nil {
+			t.Fatalf("Downloader fails with error: %s\n", err.Error())
+		}
+
+		trustServer(downloader, server)
+
+		if _, err = downloader.Download("https://example.com/"); !errors.Is(err, tc.err) {
+			t.Errorf("Unexpected error for pins %v: %v\n", tc.pins, err)
+		}
+	}
+}
+
+func TestCertificatePinsAreParsed(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	if pins, err := pinsOfPEM(data); err != nil || len(pins) != 1 || pins[0] != certPin(server.Certificate()) {
+		t.Errorf("Unexpected pins of PEM: %v, %v\n", pins, err)
+	}
+
+	if _, err := pinsOfPEM([]byte("no certificates")); !errors.Is(err, ErrInvalidPin) {
+		t.Errorf("Parsing does not fail without certificates\n")
+	}
+
+	if !validPin(certPin(server.Certificate())) || validPin("sha256/abc") || validPin("md5/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=") {
+		t.Errorf("Unexpected validation of pins\n")
+	}
+}
+
+func TestCrawlerStopsOnPinMismatch(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "https://example.com/a\n")
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions("https://example.com/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   3,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		Resolve:      map[string]string{"example.com": server.Listener.Addr().String()},
+		PinnedKeys:   []string{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	trustServer(c.downloader, server)
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	findings := c.GetFindings()
+	if len(findings) != 1 || findings[0].Category != CategoryPinMismatch || findings[0].Url != "https://example.com/" {
+		t.Errorf("Unexpected findings: %v\n", findings)
+	}
+
+	if l := c.GetSiteMap().Len(); l != 0 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+}
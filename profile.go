@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+)
+
+// startCPUProfile starts writing the CPU profile to the file under path, returning the function stopping it.
+func startCPUProfile(path string) (func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+		return f.Close()
+	}, nil
+}
+
+// writeHeapProfile writes the profile of the memory in use to the file under path, collecting garbage first
+// so that it is up to date.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	runtime.GC()
+
+	if err = pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// heapSnapshotPath returns the path of the heap profile taken at t during the crawl, the path of the final one
+// with the UTC time inserted before its extension, e.g. mem-20260101T120000Z.out
+func heapSnapshotPath(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + t.UTC().Format(runLayout) + ext
+}
+
+// startHeapSnapshots writes a heap profile every interval (see heapSnapshotPath) until the returned function
+// is called, so that the growth of the memory of a long crawl can be followed. The failures are passed to onError,
+// the snapshots going on.
+func startHeapSnapshots(path string, interval time.Duration, onError func(error)) func() {
+	var (
+		stop = make(chan struct{})
+		done = make(chan struct{})
+	)
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case t := <-ticker.C:
+				if err := writeHeapProfile(heapSnapshotPath(path, t)); err != nil {
+					onError(err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProfilesAreWritten(t *testing.T) {
+	dir := t.TempDir()
+	cpu, heap := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out")
+
+	stop, err := startCPUProfile(cpu)
+	if err != nil {
+		t.Fatalf("Starting CPU profile fails with error: %s\n", err.Error())
+	}
+
+	if err = stop(); err != nil {
+		t.Errorf("Stopping CPU profile fails with error: %s\n", err.Error())
+	}
+
+	if err = writeHeapProfile(heap); err != nil {
+		t.Errorf("Writing heap profile fails with error: %s\n", err.Error())
+	}
+
+	for _, path := range []string{cpu, heap} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("Profile not written: %s\n", path)
+		}
+	}
+}
+
+func TestHeapSnapshotsAreWrittenDuringCrawl(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	if path := heapSnapshotPath(filepath.Join("out", "mem.out"), at); path != filepath.Join("out", "mem-20260101T120000Z.out") {
+		t.Errorf("Unexpected snapshot path: %s\n", path)
+	}
+
+	path := filepath.Join(t.TempDir(), "mem.out")
+
+	stop := startHeapSnapshots(path, 10*time.Millisecond, func(err error) {
+		t.Errorf("Writing snapshot fails with error: %s\n", err.Error())
+	})
+
+	var snapshots []string
+	for deadline := time.Now().Add(5 * time.Second); len(snapshots) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		snapshots, _ = filepath.Glob(filepath.Join(filepath.Dir(path), "mem-*.out"))
+	}
+
+	stop()
+
+	if len(snapshots) == 0 {
+		t.Fatalf("No snapshot written\n")
+	}
+
+	if info, err := os.Stat(snapshots[0]); err != nil || info.Size() == 0 {
+		t.Errorf("Snapshot not written: %s\n", snapshots[0])
+	}
+}
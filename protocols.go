@@ -0,0 +1,66 @@
+package main
+
+import "sort"
+
+// ProtocolReport struct represents the adoption of HTTP protocols and content encodings across the crawl.
+// Fetches is the number of pages fetched by the default downloader, Protocols and Encodings break it down
+// by the protocol of the responses, e.g. HTTP/1.1 or HTTP/2.0, and their content encoding, e.g. gzip or identity.
+type ProtocolReport struct {
+	Fetches   int              `json:"fetches"`
+	Protocols []*ProtocolShare `json:"protocols"`
+	Encodings []*ProtocolShare `json:"encodings"`
+}
+
+// ProtocolShare struct represents the number of fetches using Name and their share of all fetches, between 0 and 1.
+type ProtocolShare struct {
+	Name    string  `json:"name"`
+	Fetches int     `json:"fetches"`
+	Share   float64 `json:"share"`
+}
+
+// GetProtocolReport returns the protocols and content encodings of the pages fetched so far, the most used first.
+// Pages fetched by custom downloaders are not counted, as their protocols are unknown.
+func (c *Crawler) GetProtocolReport() ProtocolReport {
+	c.muproto.Lock()
+	defer c.muproto.Unlock()
+
+	var report ProtocolReport
+	for _, n := range c.protocols {
+		report.Fetches += n
+	}
+
+	report.Protocols = sharesOf(c.protocols, report.Fetches)
+	report.Encodings = sharesOf(c.encodings, report.Fetches)
+
+	return report
+}
+
+// countProtocol counts the fetch over the protocol and with the content encoding of the response
+func (c *Crawler) countProtocol(response *responseInfo) {
+	if response.protocol == "" {
+		return
+	}
+
+	c.muproto.Lock()
+	c.protocols[response.protocol]++
+	c.encodings[response.encoding]++
+	c.muproto.Unlock()
+}
+
+// sharesOf returns the counts as shares of the total, the largest first
+func sharesOf(counts map[string]int, total int) []*ProtocolShare {
+	shares := make([]*ProtocolShare, 0, len(counts))
+	for name, n := range counts {
+		shares = append(shares, &ProtocolShare{Name: name, Fetches: n, Share: float64(n) / float64(total)})
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Fetches != shares[j].Fetches {
+			return shares[i].Fetches > shares[j].Fetches
+		}
+
+		return shares[i].Name < shares[j].Name
+	})
+
+	return shares
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDownloaderRecordsProtocolAndEncoding(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("<html></html>"))
+		gz.Close()
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	downloader, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout: 5,
+		Pool:    NewBufferPool(2, 1024),
+		Resolve: map[string]string{"example.com": server.Listener.Addr().String()},
+	})
+	if err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	trustServer(downloader, server)
+
+	response := new(responseInfo)
+	ctx := withResponseInfo(context.Background(), response)
+
+	body, _, err := downloader.(ContextDownloader).DownloadContext(ctx, "https://example.com/", "", Validators{})
+	if err != nil {
+		t.Fatalf("Download fails with error: %s\n", err.Error())
+	}
+
+	if string(body) != "<html></html>" {
+		t.Errorf("Unexpected body: %s\n", body)
+	}
+
+	if response.protocol != "HTTP/2.0" || response.encoding != "gzip" {
+		t.Errorf("Unexpected response recorded: %v\n", *response)
+	}
+}
+
+func TestCrawlerReportsProtocols(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Encoding", "gzip")
+
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, "%s/br\n%s/plain\n", server.URL, server.URL)
+			gz.Close()
+		case "/br":
+			// Sent regardless of the Accept-Encoding of the request, so it is left undecoded
+			w.Header().Set("Content-Encoding", "br")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	report := c.GetProtocolReport()
+	if report.Fetches != 3 {
+		t.Fatalf("Unexpected number of fetches: %d\n", report.Fetches)
+	}
+
+	if len(report.Protocols) != 1 || report.Protocols[0].Name != "HTTP/1.1" || report.Protocols[0].Share != 1 {
+		t.Errorf("Unexpected protocols: %v\n", report.Protocols)
+	}
+
+	encodings := make(map[string]int)
+	for _, e := range report.Encodings {
+		encodings[e.Name] = e.Fetches
+	}
+
+	if len(encodings) != 3 || encodings["gzip"] != 1 || encodings["br"] != 1 || encodings["identity"] != 1 {
+		t.Errorf("Unexpected encodings: %v\n", encodings)
+	}
+
+	if page, ok := c.GetSiteMap().Get(server.URL + "/"); !ok || page.Protocol != "HTTP/1.1" || page.Encoding != "gzip" {
+		t.Errorf("Unexpected protocol of page: %v\n", page)
+	}
+}
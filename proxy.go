@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyOptions struct configures the default downloader to connect through a chain of SOCKS5 proxies.
+// Chain lists the proxies in the order they are connected through, as socks5://[user:password@]host:port,
+// so the last one is the exit, e.g. socks5://127.0.0.1:9050 for Tor. Host names are resolved by the proxies.
+// IsolateCircuits makes every request use a fresh connection authenticated with unique credentials
+// at the exit proxy, which Tor uses to route it over a separate circuit.
+type ProxyOptions struct {
+	Chain           []string
+	IsolateCircuits bool
+}
+
+// isolatingDialer connects through the exit proxy with unique credentials for every connection.
+type isolatingDialer struct {
+	forward proxy.Dialer
+	address string
+	next    uint64
+}
+
+func (d *isolatingDialer) Dial(network, addr string) (net.Conn, error) {
+	n := atomic.AddUint64(&d.next, 1)
+
+	exit, err := proxy.SOCKS5("tcp", d.address, &proxy.Auth{User: strconv.FormatUint(n, 10), Password: "isolate"}, d.forward)
+	if err != nil {
+		return nil, err
+	}
+
+	return exit.Dial(network, addr)
+}
+
+// newProxyDialer returns a function dialing through the chain of proxies, suitable for http.Transport.
+func newProxyDialer(options *ProxyOptions) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	if len(options.Chain) == 0 {
+		return nil, ErrInvalidProxy
+	}
+
+	var dialer proxy.Dialer = proxy.Direct
+
+	for i, hop := range options.Chain {
+		u, err := url.Parse(hop)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme != "socks5" && u.Scheme != "socks5h" || u.Host == "" {
+			return nil, ErrInvalidProxy
+		}
+
+		if options.IsolateCircuits && i == len(options.Chain)-1 {
+			dialer = &isolatingDialer{forward: dialer, address: u.Host}
+			break
+		}
+
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+
+		if dialer, err = proxy.SOCKS5("tcp", u.Host, auth, dialer); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if cd, ok := dialer.(proxy.ContextDialer); ok {
+			return cd.DialContext(ctx, network, addr)
+		}
+
+		return dialer.Dial(network, addr)
+	}, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+// hostLimiter struct spaces the requests to each host, so that the workers do not hammer it in parallel.
+// Each request reserves the earliest free slot of its host and waits for it.
+type hostLimiter struct {
+	interval time.Duration
+
+	// Since this map can be accessed by multiple goroutines, it is guarded with a mutex
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// newHostLimiter returns a limiter spacing the requests to a host by the delay, or more if the requests
+// per second require it (no limit if both are zero).
+func newHostLimiter(delay time.Duration, perSecond float64) *hostLimiter {
+	interval := delay
+	if perSecond > 0 {
+		if i := time.Duration(float64(time.Second) / perSecond); i > interval {
+			interval = i
+		}
+	}
+
+	return &hostLimiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// wait blocks until a request to the URL's host is allowed or the done channel is closed.
+// The host's own delay is used instead of the limiter's interval if it is longer.
+func (l *hostLimiter) wait(url string, delay time.Duration, done <-chan struct{}) {
+	if delay < l.interval {
+		delay = l.interval
+	}
+
+	if delay == 0 {
+		return
+	}
+
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	at := l.next[u.Host]
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	l.next[u.Host] = at.Add(delay)
+	l.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+	case <-done:
+	}
+}
+
+// hostSlots struct limits how many requests to each host are in flight. A fetcher does not wait for a slot
+// of the host of its URL, the URL is parked until a slot of the host is released instead, so that the fetcher
+// downloads the URLs of other hosts meanwhile. The workers fetching assets wait for a slot.
+type hostSlots struct {
+	max int
+
+	// Since these maps can be accessed by multiple goroutines, they are guarded with a mutex.
+	// The released channel is closed and replaced whenever a slot is released
+	mu       sync.Mutex
+	active   map[string]int
+	parked   map[string][]FrontierItem
+	released chan struct{}
+}
+
+func newHostSlots(max int) *hostSlots {
+	return &hostSlots{
+		max:      max,
+		active:   make(map[string]int),
+		parked:   make(map[string][]FrontierItem),
+		released: make(chan struct{}),
+	}
+}
+
+// tryAcquire takes a slot of the host for the item, or parks the item if there is none left
+func (s *hostSlots) tryAcquire(host string, item FrontierItem) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[host] >= s.max {
+		s.parked[host] = append(s.parked[host], item)
+		return false
+	}
+
+	s.active[host]++
+	return true
+}
+
+// acquire waits for a slot of the host until the done channel is closed, false if it is
+func (s *hostSlots) acquire(host string, done <-chan struct{}) bool {
+	for {
+		s.mu.Lock()
+		if s.active[host] < s.max {
+			s.active[host]++
+			s.mu.Unlock()
+
+			return true
+		}
+
+		released := s.released
+		s.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// release gives back a slot of the host, returning the item parked first for the host, if any
+func (s *hostSlots) release(host string) (FrontierItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[host]--; s.active[host] == 0 {
+		delete(s.active, host)
+	}
+
+	close(s.released)
+	s.released = make(chan struct{})
+
+	parked := s.parked[host]
+	if len(parked) == 0 {
+		return FrontierItem{}, false
+	}
+
+	item := parked[0]
+	if len(parked) == 1 {
+		delete(s.parked, host)
+	} else {
+		s.parked[host] = parked[1:]
+	}
+
+	return item, true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostLimiterSpacesRequestsToSameHost(t *testing.T) {
+	var (
+		limiter = newHostLimiter(0, 20)
+		done    = make(chan struct{})
+		start   = time.Now()
+	)
+
+	if limiter.interval != 50*time.Millisecond {
+		t.Errorf("Unexpected interval: %s\n", limiter.interval)
+	}
+
+	for i := 0; i < 3; i++ {
+		limiter.wait("http://a.com/page", 0, done)
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Requests to the same host not spaced: %s\n", elapsed)
+	}
+
+	start = time.Now()
+	limiter.wait("http://b.com/page", 0, done)
+
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("Request to another host delayed: %s\n", elapsed)
+	}
+}
+
+func TestHostLimiterPrefersLongerDelayOfHost(t *testing.T) {
+	var (
+		limiter = newHostLimiter(10*time.Millisecond, 0)
+		done    = make(chan struct{})
+		start   = time.Now()
+	)
+
+	limiter.wait("http://a.com/", 100*time.Millisecond, done)
+	limiter.wait("http://a.com/", 100*time.Millisecond, done)
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Delay of the host not honored: %s\n", elapsed)
+	}
+
+	close(done)
+	start = time.Now()
+	limiter.wait("http://a.com/", time.Hour, done)
+
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Waiting not aborted: %s\n", elapsed)
+	}
+}
+
+func TestHostSlotsParkItemsOfBusyHosts(t *testing.T) {
+	slots := newHostSlots(1)
+
+	if !slots.tryAcquire("a", FrontierItem{Url: "a/1"}) || !slots.tryAcquire("b", FrontierItem{Url: "b/1"}) {
+		t.Errorf("Slot of idle host not acquired\n")
+	}
+
+	if slots.tryAcquire("a", FrontierItem{Url: "a/2"}) {
+		t.Errorf("Slot of busy host acquired\n")
+	}
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- slots.acquire("b", nil)
+	}()
+
+	if item, ok := slots.release("a"); !ok || item.Url != "a/2" {
+		t.Errorf("Unexpected parked item: %v\n", item)
+	}
+
+	if _, ok := slots.release("b"); ok {
+		t.Errorf("Item parked for idle host\n")
+	}
+
+	if !<-acquired {
+		t.Errorf("Released slot not acquired\n")
+	}
+
+	done := make(chan struct{})
+	close(done)
+
+	if slots.acquire("b", done) {
+		t.Errorf("Slot of busy host acquired\n")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+// redirectsOf returns the policy of the redirects of the URL linked from the page. The seeds may redirect
+// anywhere, e.g. to their canonical host, the other URLs only within scope unless external redirects are followed.
+func (c *Crawler) redirectsOf(from string) *redirectPolicy {
+	p := &redirectPolicy{max: c.maxRedirects}
+	if from != "<root>" && !c.followExternal {
+		p.follow = c.inScope
+	}
+
+	return p
+}
+
+// redirectChain returns the canonical URL the followed redirects of the URL lead to and the canonical URLs
+// redirecting there in order, each of them once. The location is empty if the redirects lead back to the URL.
+func (c *Crawler) redirectChain(url string, hops []Redirect) (string, []string) {
+	if len(hops) == 0 {
+		return "", nil
+	}
+
+	location := c.canonical(hops[len(hops)-1].Location)
+	if location == url {
+		return "", nil
+	}
+
+	seen := map[string]struct{}{location: {}}
+	from := make([]string, 0, len(hops))
+
+	for _, hop := range hops {
+		u := c.canonical(hop.Url)
+		if _, ok := seen[u]; !ok {
+			seen[u] = struct{}{}
+			from = append(from, u)
+		}
+	}
+
+	return location, from
+}
+
+// claimPage records the page under its URL, linked from the page of from, unless a page is recorded under
+// the URL already, e.g. since another URL redirecting to it was crawled first. The URLs the page was redirected
+// from are then added to the recorded page, which is linked from the page of from instead, and false is returned.
+// The page of from is missing if another crawler sharing the frontier crawled it, the link is not recorded then.
+func (c *Crawler) claimPage(url, from string, page *Page) bool {
+	c.mus.Lock()
+	defer c.mus.Unlock()
+
+	linking, linked := c.sites[from]
+
+	existing, ok := c.sites[url]
+	if !ok {
+		c.sites[url] = page
+
+		if linked {
+			linking.LinksTo = append(linking.LinksTo, page)
+		}
+
+		return true
+	}
+
+	existing.RedirectedFrom = append(existing.RedirectedFrom, page.RedirectedFrom...)
+
+	if linked && linking != existing && !linksTo(linking, existing) && !linkedFrom(existing, linking) {
+		existing.LinkedFrom = append(existing.LinkedFrom, linking)
+	}
+
+	return false
+}
+
+// markRedirected records that the URLs redirect to the page, so that the links to them are taken for links to the page
+func (c *Crawler) markRedirected(from []string, url string) {
+	c.muxr.Lock()
+	for _, u := range from {
+		c.redirected[u] = url
+	}
+	c.muxr.Unlock()
+}
+
+// redirectedLinks returns the links with the URLs known to redirect to a page replaced by the page, each of them once
+func (c *Crawler) redirectedLinks(links []string) []string {
+	c.muxr.Lock()
+	defer c.muxr.Unlock()
+
+	if len(c.redirected) == 0 {
+		return links
+	}
+
+	seen := make(map[string]struct{}, len(links))
+	redirected := make([]string, 0, len(links))
+
+	for _, link := range links {
+		if url, ok := c.redirected[link]; ok {
+			link = url
+		}
+
+		if _, ok := seen[link]; !ok {
+			seen[link] = struct{}{}
+			redirected = append(redirected, link)
+		}
+	}
+
+	return redirected
+}
+
+func linkedFrom(to, from *Page) bool {
+	for _, p := range to.LinkedFrom {
+		if p == from {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisOptions struct configures the connection to the Redis server coordinating a crawl shared by several crawlers.
+// Address is the host:port of the server, Password and DB select the database (none and 0 by default),
+// Key prefixes the keys of the crawl (crawler by default), so that several crawls can share a server,
+// and Timeout bounds every command (5 seconds if zero).
+type RedisOptions struct {
+	Address  string
+	Password string
+	DB       int
+	Key      string
+	Timeout  time.Duration
+}
+
+// RedisFrontier struct implements SharedFrontier with two Redis lists, the queue of URLs and the URLs being crawled,
+// which a URL is moved to atomically once one of the crawlers pops it, and a counter of the URLs reserved
+// and not settled yet. Done removes the URL from the latter list and decrements the counter, so that the crawl is over
+// once it is zero. The keys outlive the crawlers, so that a crawl stopped by all of them continues once they
+// are started again, but the URLs popped by a crawler which died are never settled and the keys of the crawl
+// must be deleted to start over. Once a command fails, Pending returns the error,
+// so that the crawlers finish rather than wait for URLs which may have been lost.
+type RedisFrontier struct {
+	client                     *redisClient
+	queue, processing, pending string
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewRedisFrontier returns the frontier of the crawl under the key of the options, failing if the server cannot be reached.
+func NewRedisFrontier(options *RedisOptions) (*RedisFrontier, error) {
+	client, key, err := newRedisClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisFrontier{
+		client:     client,
+		queue:      key + ":queue",
+		processing: key + ":processing",
+		pending:    key + ":pending",
+	}, nil
+}
+
+func (f *RedisFrontier) Push(item FrontierItem) {
+	value, err := json.Marshal(item)
+	if err == nil {
+		_, err = f.client.do("LPUSH", f.queue, string(value))
+	}
+
+	f.fail(err)
+}
+
+func (f *RedisFrontier) Pop() (FrontierItem, bool) {
+	var item FrontierItem
+
+	reply, err := f.client.do("RPOPLPUSH", f.queue, f.processing)
+	if err != nil || reply == nil {
+		f.fail(err)
+		return item, false
+	}
+
+	value, ok := reply.(string)
+	if !ok {
+		f.fail(ErrRedisProtocol)
+		return item, false
+	}
+
+	if err = json.Unmarshal([]byte(value), &item); err != nil {
+		f.fail(err)
+		return item, false
+	}
+
+	return item, true
+}
+
+func (f *RedisFrontier) Len() int {
+	reply, err := f.client.do("LLEN", f.queue)
+	if err != nil {
+		f.fail(err)
+		return 0
+	}
+
+	n, _ := reply.(int64)
+	return int(n)
+}
+
+func (f *RedisFrontier) Reserve() {
+	_, err := f.client.do("INCR", f.pending)
+	f.fail(err)
+}
+
+func (f *RedisFrontier) Release() {
+	_, err := f.client.do("DECR", f.pending)
+	f.fail(err)
+}
+
+func (f *RedisFrontier) Done(item FrontierItem) {
+	value, err := json.Marshal(item)
+	if err == nil {
+		_, err = f.client.do("LREM", f.processing, "1", string(value))
+	}
+
+	if err == nil {
+		_, err = f.client.do("DECR", f.pending)
+	}
+
+	f.fail(err)
+}
+
+func (f *RedisFrontier) Pending() (int, error) {
+	f.mu.Lock()
+	err := f.err
+	f.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	reply, err := f.client.do("GET", f.pending)
+	if err != nil || reply == nil {
+		return 0, err
+	}
+
+	value, ok := reply.(string)
+	if !ok {
+		return 0, ErrRedisProtocol
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, ErrRedisProtocol
+	}
+
+	return n, nil
+}
+
+// fail records the first error of a command
+func (f *RedisFrontier) fail(err error) {
+	if err == nil {
+		return
+	}
+
+	f.mu.Lock()
+	if f.err == nil {
+		f.err = err
+	}
+	f.mu.Unlock()
+}
+
+// RedisVisitedSet struct implements VisitedSet with a Redis set shared by the crawlers of a distributed crawl.
+// If the server cannot be reached, a URL is taken for a visited one, so that it is not crawled twice.
+type RedisVisitedSet struct {
+	client *redisClient
+	key    string
+}
+
+// NewRedisVisitedSet returns the set of URLs scheduled by the crawl under the key of the options,
+// failing if the server cannot be reached.
+func NewRedisVisitedSet(options *RedisOptions) (*RedisVisitedSet, error) {
+	client, key, err := newRedisClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisVisitedSet{client: client, key: key + ":visited"}, nil
+}
+
+func (s *RedisVisitedSet) Add(url string) bool {
+	reply, err := s.client.do("SADD", s.key, url)
+	return err == nil && reply == int64(1)
+}
+
+func (s *RedisVisitedSet) Contains(url string) bool {
+	reply, err := s.client.do("SISMEMBER", s.key, url)
+	return err != nil || reply == int64(1)
+}
+
+// redisClient sends commands to a Redis server over a single connection, which is established again
+// after a network or protocol error.
+type redisClient struct {
+	mu      sync.Mutex
+	options RedisOptions
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+// newRedisClient connects to the server of the options, returning the client and the prefix of the keys
+func newRedisClient(options *RedisOptions) (*redisClient, string, error) {
+	r := &redisClient{options: *options}
+	if r.options.Timeout == 0 {
+		r.options.Timeout = 5 * time.Second
+	}
+
+	key := options.Key
+	if key == "" {
+		key = "crawler"
+	}
+
+	if _, err := r.do("PING"); err != nil {
+		return nil, "", err
+	}
+
+	return r, key, nil
+}
+
+// do sends the command and returns its reply, a string, an int64, a slice of replies or nil.
+// Error replies are returned as errors wrapping ErrRedisReply.
+func (r *redisClient) do(args ...string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := r.send(args)
+
+	// The replies to come may be out of step with the commands, unless the server replied with an error
+	if err != nil && !errors.Is(err, ErrRedisReply) {
+		r.conn.Close()
+		r.conn = nil
+	}
+
+	return reply, err
+}
+
+func (r *redisClient) dial() error {
+	conn, err := net.DialTimeout("tcp", r.options.Address, r.options.Timeout)
+	if err != nil {
+		return err
+	}
+
+	r.conn, r.reader = conn, bufio.NewReader(conn)
+
+	if r.options.Password != "" {
+		_, err = r.send([]string{"AUTH", r.options.Password})
+	}
+
+	if err == nil && r.options.DB != 0 {
+		_, err = r.send([]string{"SELECT", strconv.Itoa(r.options.DB)})
+	}
+
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+
+	return err
+}
+
+func (r *redisClient) send(args []string) (interface{}, error) {
+	if err := r.conn.SetDeadline(time.Now().Add(r.options.Timeout)); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := r.conn.Write(b.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return readRedisReply(r.reader)
+}
+
+// readRedisReply reads one reply in the Redis serialization protocol (RESP)
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, ErrRedisProtocol
+	}
+
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, fmt.Errorf("%w: %s", ErrRedisReply, line)
+	case ':':
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, ErrRedisProtocol
+		}
+
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < -1 {
+			return nil, ErrRedisProtocol
+		}
+
+		if n == -1 {
+			return nil, nil
+		}
+
+		value := make([]byte, n+2)
+		if _, err = io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+
+		return string(value[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < -1 {
+			return nil, ErrRedisProtocol
+		}
+
+		if n == -1 {
+			return nil, nil
+		}
+
+		replies := make([]interface{}, n)
+		for i := range replies {
+			if replies[i], err = readRedisReply(r); err != nil && !errors.Is(err, ErrRedisReply) {
+				return nil, err
+			}
+		}
+
+		return replies, nil
+	default:
+		return nil, ErrRedisProtocol
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeRedis serves the commands used by the Redis frontier and visited set from memory
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	lists    map[string][]string
+	sets     map[string]map[string]struct{}
+	counters map[string]int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening fails with error: %s\n", err.Error())
+	}
+
+	r := &fakeRedis{
+		listener: listener,
+		password: password,
+		lists:    make(map[string][]string),
+		sets:     make(map[string]map[string]struct{}),
+		counters: make(map[string]int),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go r.serve(conn)
+		}
+	}()
+
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	authorized := r.password == ""
+
+	for {
+		request, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+
+		var args []string
+		for _, arg := range request.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		if args[0] == "AUTH" {
+			authorized = args[1] == r.password
+		}
+
+		if !authorized {
+			fmt.Fprintf(conn, "-WRONGPASS invalid password\r\n")
+			continue
+		}
+
+		fmt.Fprint(conn, r.execute(args))
+	}
+}
+
+func (r *fakeRedis) execute(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	integer := func(n int) string {
+		return ":" + strconv.Itoa(n) + "\r\n"
+	}
+
+	switch args[0] {
+	case "PING", "AUTH":
+		return "+OK\r\n"
+	case "LPUSH":
+		r.lists[args[1]] = append([]string{args[2]}, r.lists[args[1]]...)
+		return integer(len(r.lists[args[1]]))
+	case "RPOPLPUSH":
+		from := r.lists[args[1]]
+		if len(from) == 0 {
+			return "$-1\r\n"
+		}
+
+		value := from[len(from)-1]
+		r.lists[args[1]] = from[:len(from)-1]
+		r.lists[args[2]] = append([]string{value}, r.lists[args[2]]...)
+
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "LREM":
+		list := r.lists[args[1]]
+		for i, value := range list {
+			if value == args[3] {
+				r.lists[args[1]] = append(list[:i:i], list[i+1:]...)
+				return integer(1)
+			}
+		}
+
+		return integer(0)
+	case "LLEN":
+		return integer(len(r.lists[args[1]]))
+	case "INCR":
+		r.counters[args[1]]++
+		return integer(r.counters[args[1]])
+	case "DECR":
+		r.counters[args[1]]--
+		return integer(r.counters[args[1]])
+	case "GET":
+		n, ok := r.counters[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+
+		value := strconv.Itoa(n)
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SADD":
+		set, ok := r.sets[args[1]]
+		if !ok {
+			set = make(map[string]struct{})
+			r.sets[args[1]] = set
+		}
+
+		if _, ok := set[args[2]]; ok {
+			return integer(0)
+		}
+
+		set[args[2]] = struct{}{}
+		return integer(1)
+	case "SISMEMBER":
+		if _, ok := r.sets[args[1]][args[2]]; ok {
+			return integer(1)
+		}
+
+		return integer(0)
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisFrontierIsShared(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	defer server.listener.Close()
+
+	options := &RedisOptions{Address: server.listener.Addr().String(), Password: "secret"}
+
+	first, err := NewRedisFrontier(options)
+	if err != nil {
+		t.Fatalf("Frontier fails with error: %s\n", err.Error())
+	}
+
+	second, err := NewRedisFrontier(options)
+	if err != nil {
+		t.Fatalf("Frontier fails with error: %s\n", err.Error())
+	}
+
+	if pending, err := first.Pending(); err != nil || pending != 0 {
+		t.Errorf("Unexpected pending URLs: %d, %v\n", pending, err)
+	}
+
+	// A reserved URL is pending before it is pushed, unless it is released
+	first.Reserve()
+	second.Reserve()
+	second.Release()
+
+	if pending, err := second.Pending(); err != nil || pending != 1 {
+		t.Errorf("Unexpected pending URLs: %d, %v\n", pending, err)
+	}
+
+	first.Reserve()
+
+	first.Push(FrontierItem{Url: "a", From: "<root>"})
+	first.Push(FrontierItem{Url: "b", From: "a", Depth: 1})
+
+	item, ok := second.Pop()
+	if !ok || item.Url != "a" || item.From != "<root>" {
+		t.Errorf("Unexpected item popped: %v\n", item)
+	}
+
+	if first.Len() != 1 {
+		t.Errorf("Unexpected frontier length: %d\n", first.Len())
+	}
+
+	// The popped URL is pending until it is settled
+	if pending, err := first.Pending(); err != nil || pending != 2 {
+		t.Errorf("Unexpected pending URLs: %d, %v\n", pending, err)
+	}
+
+	second.Done(item)
+
+	if item, ok = first.Pop(); !ok || item.Url != "b" || item.Depth != 1 {
+		t.Errorf("Unexpected item popped: %v\n", item)
+	}
+
+	first.Done(item)
+
+	if _, ok = second.Pop(); ok {
+		t.Errorf("Item popped from empty frontier\n")
+	}
+
+	if pending, err := second.Pending(); err != nil || pending != 0 {
+		t.Errorf("Unexpected pending URLs: %d, %v\n", pending, err)
+	}
+
+	if _, err = NewRedisFrontier(&RedisOptions{Address: options.Address, Password: "wrong"}); !errors.Is(err, ErrRedisReply) {
+		t.Errorf("Unexpected error for wrong password: %v\n", err)
+	}
+}
+
+func TestRedisVisitedSetIsShared(t *testing.T) {
+	server := newFakeRedis(t, "")
+	defer server.listener.Close()
+
+	options := &RedisOptions{Address: server.listener.Addr().String(), Key: "site"}
+
+	first, err := NewRedisVisitedSet(options)
+	if err != nil {
+		t.Fatalf("Visited set fails with error: %s\n", err.Error())
+	}
+
+	second, err := NewRedisVisitedSet(options)
+	if err != nil {
+		t.Fatalf("Visited set fails with error: %s\n", err.Error())
+	}
+
+	if !first.Add("a") || second.Add("a") {
+		t.Errorf("URL added twice\n")
+	}
+
+	if !second.Contains("a") || second.Contains("b") {
+		t.Errorf("Unexpected contents of visited set\n")
+	}
+
+	// A set which cannot reach the server takes every URL for a visited one
+	server.listener.Close()
+	first.client.conn.Close()
+
+	if first.Add("b") || !first.Contains("b") {
+		t.Errorf("URL added without server\n")
+	}
+}
+
+func TestCrawlersShareRedisCrawl(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, "%s/%d\n", server.URL, i)
+		}
+	}))
+	defer server.Close()
+
+	redis := newFakeRedis(t, "")
+	defer redis.listener.Close()
+
+	options := &RedisOptions{Address: redis.listener.Addr().String()}
+
+	var crawlers []*Crawler
+	for i := 0; i < 2; i++ {
+		frontier, err := NewRedisFrontier(options)
+		if err != nil {
+			t.Fatalf("Frontier fails with error: %s\n", err.Error())
+		}
+
+		visited, err := NewRedisVisitedSet(options)
+		if err != nil {
+			t.Fatalf("Visited set fails with error: %s\n", err.Error())
+		}
+
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:      2,
+			MaxRetries:      1,
+			Downloader:      unpooledDownloader{},
+			Extractor:       lineExtractor{},
+			Frontier:        frontier,
+			VisitedSet:      visited,
+			IgnoreRobots:    true,
+			CheckInvariants: true,
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		crawlers = append(crawlers, c)
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range crawlers {
+		wg.Add(1)
+
+		go func(c *Crawler) {
+			defer wg.Done()
+
+			done, errs := c.Crawl()
+			for err := range errs {
+				t.Errorf("Crawl fails with error: %s\n", err.Error())
+			}
+			<-done
+		}(c)
+	}
+
+	wg.Wait()
+
+	crawled := make(map[string]int)
+	for _, c := range crawlers {
+		for _, page := range c.GetSiteMap().Pages() {
+			crawled[page.Url]++
+		}
+	}
+
+	if len(crawled) != 11 {
+		t.Errorf("Unexpected number of pages: %d\n", len(crawled))
+	}
+
+	for url, n := range crawled {
+		if n != 1 {
+			t.Errorf("Page %s crawled %d times\n", url, n)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Region struct represents a vantage point the pages are fetched from, i.e. a proxy located in the region.
+// Proxy is either a HTTP(S) proxy or a SOCKS5 proxy (socks5://host:port).
+type Region struct {
+	Name, Proxy string
+}
+
+// RegionFetch struct represents the outcome of fetching a page from a single region.
+// Redirects lists the URLs the request was redirected to, in order.
+type RegionFetch struct {
+	Region    string
+	Status    int
+	Redirects []string
+	Title     string
+	Err       error
+}
+
+// RegionComparison struct represents a page fetched from all regions. Differs is set
+// if the status, the redirects or the title are not the same in all of them.
+type RegionComparison struct {
+	Url     string
+	Fetches []*RegionFetch
+	Differs bool
+}
+
+// CompareRegions fetches every URL through each region concurrently and reports the differences between them,
+// which is useful for verifying geo routing and localized content.
+func CompareRegions(urls []string, regions []Region, timeout time.Duration) ([]*RegionComparison, error) {
+	clients := make([]*http.Client, 0, len(regions))
+
+	for _, region := range regions {
+		client, err := newRegionClient(region, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+
+	comparisons := make([]*RegionComparison, 0, len(urls))
+
+	for _, address := range urls {
+		extractor, err := NewDefaultExtractor(address)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			wg         sync.WaitGroup
+			comparison = &RegionComparison{
+				Url:     address,
+				Fetches: make([]*RegionFetch, len(regions)),
+			}
+		)
+
+		for i := range regions {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+				comparison.Fetches[i] = fetchFromRegion(clients[i], regions[i].Name, address, extractor)
+			}(i)
+		}
+
+		wg.Wait()
+
+		comparison.Differs = fetchesDiffer(comparison.Fetches)
+		comparisons = append(comparisons, comparison)
+	}
+
+	return comparisons, nil
+}
+
+func newRegionClient(region Region, timeout time.Duration) (*http.Client, error) {
+	u, err := url.Parse(region.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{}
+
+	switch u.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(u)
+	case "socks5", "socks5h":
+		if transport.DialContext, err = newProxyDialer(&ProxyOptions{Chain: []string{region.Proxy}}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidProxy
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func fetchFromRegion(client *http.Client, region, address string, extractor Extractor) *RegionFetch {
+	var (
+		fetch = &RegionFetch{Region: region, Redirects: make([]string, 0)}
+		c     = *client
+	)
+
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		fetch.Redirects = append(fetch.Redirects, req.URL.String())
+		if len(via) >= 10 {
+			return http.ErrUseLastResponse
+		}
+
+		return nil
+	}
+
+	resp, err := c.Get(address)
+	if err != nil {
+		fetch.Err = err
+		return fetch
+	}
+
+	defer resp.Body.Close()
+
+	fetch.Status = resp.StatusCode
+
+	// Titles are in the head of the document, so the rest of large pages is not read
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		fetch.Err = err
+		return fetch
+	}
+
+	if title, _, _, err := extractor.Extract(body); err == nil {
+		fetch.Title = title
+	}
+
+	return fetch
+}
+
+func fetchesDiffer(fetches []*RegionFetch) bool {
+	if len(fetches) < 2 {
+		return false
+	}
+
+	first := fetches[0]
+
+	for _, f := range fetches[1:] {
+		if f.Status != first.Status || f.Title != first.Title || (f.Err == nil) != (first.Err == nil) {
+			return true
+		}
+
+		if len(f.Redirects) != len(first.Redirects) {
+			return true
+		}
+
+		for i := range f.Redirects {
+			if f.Redirects[i] != first.Redirects[i] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
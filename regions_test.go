@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRegionFetchesAreCompared(t *testing.T) {
+	var (
+		same = []*RegionFetch{
+			{Region: "eu", Status: 200, Title: "Home", Redirects: []string{"http://example.com/en"}},
+			{Region: "us", Status: 200, Title: "Home", Redirects: []string{"http://example.com/en"}},
+		}
+		redirected = []*RegionFetch{
+			{Region: "eu", Status: 200, Title: "Home", Redirects: []string{"http://example.com/de"}},
+			{Region: "us", Status: 200, Title: "Home", Redirects: []string{"http://example.com/en"}},
+		}
+		titled = []*RegionFetch{
+			{Region: "eu", Status: 200, Title: "Startseite"},
+			{Region: "us", Status: 200, Title: "Home"},
+		}
+	)
+
+	if fetchesDiffer(same) {
+		t.Errorf("Identical fetches reported as different\n")
+	}
+
+	if !fetchesDiffer(redirected) || !fetchesDiffer(titled) {
+		t.Errorf("Different fetches reported as identical\n")
+	}
+
+	if fetchesDiffer(same[:1]) {
+		t.Errorf("Single fetch reported as different\n")
+	}
+}
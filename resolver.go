@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Resolver struct resolves the references found on the pages of a website, e.g. the values of href and src
+// attributes, to absolute URLs. The references are cleaned up the way browsers do it first: the surrounding
+// whitespace is trimmed, tabs and newlines are removed and backslashes before the query are read as slashes.
+// Relative references, including protocol-relative ones (//cdn.example.com/lib.js), are resolved against
+// the root of the base URL and dot segments are removed. The host of the resolved URL is canonical (see hostOf)
+// and its fragment is stripped, unless it is a #!/ or #/ route and routes are kept.
+// Resolve returns either an absolute HTTP or HTTPS URL or an error wrapping ErrInvalidRef, never an empty string.
+// A Resolver can be used by multiple goroutines.
+type Resolver struct {
+	base           *url.URL
+	routeFragments bool
+
+	// All of the fragments are kept, e.g. so that the links differing only in the fragment can be told apart
+	keepFragments bool
+}
+
+// NewResolver returns a resolver of the references against the base URL, which must be absolute.
+func NewResolver(base string, routeFragments bool) (*Resolver, error) {
+	return newResolver(base, routeFragments, false)
+}
+
+func newResolver(base string, routeFragments, keepFragments bool) (*Resolver, error) {
+	u, err := url.ParseRequestURI(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	return &Resolver{
+		base:           &url.URL{Scheme: u.Scheme, Host: hostOf(u), Path: "/"},
+		routeFragments: routeFragments,
+		keepFragments:  keepFragments,
+	}, nil
+}
+
+func (r *Resolver) Resolve(ref string) (string, error) {
+	u, err := url.Parse(cleanRef(ref))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidRef, err.Error())
+	}
+
+	u = r.base.ResolveReference(u)
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%w: unsupported scheme of %q", ErrInvalidRef, ref)
+	}
+
+	// A host of dots only is empty once it is canonical
+	if strings.Trim(u.Hostname(), ".") == "" || u.Opaque != "" {
+		return "", fmt.Errorf("%w: no host in %q", ErrInvalidRef, ref)
+	}
+
+	u.Host = hostOf(u)
+	u.RawQuery = strings.ReplaceAll(u.RawQuery, " ", "%20")
+
+	route := strings.HasPrefix(u.Fragment, "!/") || strings.HasPrefix(u.Fragment, "/")
+	if !r.keepFragments && !(r.routeFragments && route) {
+		u.Fragment, u.RawFragment = "", ""
+	}
+
+	// Malformed hosts, e.g. unbracketed IPv6 addresses, may not survive being written out
+	resolved := u.String()
+	if parsed, err := url.Parse(resolved); err != nil || parsed.Host != u.Host {
+		return "", fmt.Errorf("%w: malformed host in %q", ErrInvalidRef, ref)
+	}
+
+	return resolved, nil
+}
+
+// refWhitespace is the whitespace removed from anywhere in a reference
+var refWhitespace = strings.NewReplacer("\t", "", "\n", "", "\r", "")
+
+// cleanRef trims the ASCII whitespace around the reference, removes tabs and newlines
+// and replaces the backslashes before the query or fragment with slashes
+func cleanRef(ref string) string {
+	ref = refWhitespace.Replace(strings.Trim(ref, " \t\n\f\r"))
+
+	end := strings.IndexAny(ref, "?#")
+	if end < 0 {
+		end = len(ref)
+	}
+
+	return strings.ReplaceAll(ref[:end], "\\", "/") + ref[end:]
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResolverResolvesReferences(t *testing.T) {
+	r, err := NewResolver("https://example.com/docs/", false)
+	if err != nil {
+		t.Fatalf("Resolver fails with error: %s\n", err.Error())
+	}
+
+	cases := []struct {
+		ref, expected string
+	}{
+		{"", "https://example.com/"},
+		{"/a", "https://example.com/a"},
+		{"a/b", "https://example.com/a/b"},
+		{"?q=1", "https://example.com/?q=1"},
+		{"//cdn.example.org/lib.js", "https://cdn.example.org/lib.js"},
+		{"http://Example.com.:80/a", "http://example.com/a"},
+		{"/a/./b/../c#top", "https://example.com/a/c"},
+		{"  /a\n/b\t ", "https://example.com/a/b"},
+		{"\\a\\b?c=\\d", "https://example.com/a/b?c=\\d"},
+		{"/a b", "https://example.com/a%20b"},
+		{"/zażółć", "https://example.com/za%C5%BC%C3%B3%C5%82%C4%87"},
+		{"../../a", "https://example.com/a"},
+	}
+
+	for _, tc := range cases {
+		if resolved, err := r.Resolve(tc.ref); err != nil || resolved != tc.expected {
+			t.Errorf("Unexpected resolution of %q: %s, %v\n", tc.ref, resolved, err)
+		}
+	}
+
+	for _, ref := range []string{"mailto:a@example.com", "http:opaque", "http://[::1", "/a\x00b", "http://"} {
+		if resolved, err := r.Resolve(ref); !errors.Is(err, ErrInvalidRef) || resolved != "" {
+			t.Errorf("Invalid reference %q resolved: %s, %v\n", ref, resolved, err)
+		}
+	}
+
+	for _, base := range []string{"/a", "mailto:a@example.com", "ftp://example.com/"} {
+		if _, err := NewResolver(base, false); err == nil {
+			t.Errorf("Resolver does not fail for invalid base: %s\n", base)
+		}
+	}
+}
+
+func FuzzResolver(f *testing.F) {
+	for _, seed := range []string{"", "/a", "../b?c#d", "//cdn.example.org/x", "\\a\\b", " /a b ", "/ü", "#!/route", "http://[::1]:80/", "HTTP://EXAMPLE.COM.", "//::0", "//0..", "? #"} {
+		f.Add(seed)
+	}
+
+	r, err := NewResolver("http://example.com/", true)
+	if err != nil {
+		f.Fatalf("Resolver fails with error: %s\n", err.Error())
+	}
+
+	f.Fuzz(func(t *testing.T, ref string) {
+		resolved, err := r.Resolve(ref)
+		if err != nil {
+			if resolved != "" || !errors.Is(err, ErrInvalidRef) {
+				t.Errorf("Unexpected failure of %q: %q, %v\n", ref, resolved, err)
+			}
+
+			return
+		}
+
+		u, err := url.Parse(resolved)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			t.Fatalf("Resolution of %q is not an absolute URL: %q\n", ref, resolved)
+		}
+
+		if u.Fragment != "" && !strings.HasPrefix(u.Fragment, "!/") && !strings.HasPrefix(u.Fragment, "/") {
+			t.Errorf("Resolution of %q has a fragment: %q\n", ref, resolved)
+		}
+
+		if again, err := r.Resolve(resolved); err != nil || again != resolved {
+			t.Errorf("Resolution of %q is not stable: %q, %q, %v\n", ref, resolved, again, err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runLayout is the layout of the names of the run directories, the UTC time the run started at
+const runLayout = "20060102T150405Z"
+
+// RetentionPolicy struct defines which of the earlier runs of a site are kept, the KeepRuns most recent ones
+// and the ones started within MaxAge (no limit if zero). The most recent run is always kept.
+type RetentionPolicy struct {
+	KeepRuns int
+	MaxAge   time.Duration
+}
+
+// RunDir returns the directory the results of the crawl of the address started at startedAt are written to,
+// <root>/<host>/<start time>, so that the runs of each site are kept apart. The port of the host is separated
+// with an underscore, as colons are not allowed in the names of files on every system.
+func RunDir(root, address string, startedAt time.Time) string {
+	host := hostOfURL(address)
+	if host == "" {
+		host = "unknown"
+	}
+
+	return filepath.Join(root, strings.ReplaceAll(host, ":", "_"), startedAt.UTC().Format(runLayout))
+}
+
+// PruneRuns removes the runs of a site under dir the policy does not keep, returning their directories.
+// Only the directories named after the start time of a run are considered, the other files being left alone.
+func PruneRuns(dir string, policy RetentionPolicy, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type run struct {
+		path      string
+		startedAt time.Time
+	}
+
+	runs := make([]run, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if startedAt, err := time.Parse(runLayout, entry.Name()); err == nil {
+			runs = append(runs, run{path: filepath.Join(dir, entry.Name()), startedAt: startedAt})
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].startedAt.After(runs[j].startedAt)
+	})
+
+	removed := make([]string, 0)
+	for i, r := range runs {
+		if i == 0 {
+			continue
+		}
+
+		if (policy.KeepRuns > 0 && i >= policy.KeepRuns) || (policy.MaxAge > 0 && now.Sub(r.startedAt) > policy.MaxAge) {
+			if err = os.RemoveAll(r.path); err != nil {
+				return removed, err
+			}
+
+			removed = append(removed, r.path)
+		}
+	}
+
+	return removed, nil
+}
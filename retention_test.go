@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRunDirIsPerSite(t *testing.T) {
+	startedAt := time.Date(2026, 1, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	if dir := RunDir("runs", "http://localhost:8080/a", startedAt); dir != filepath.Join("runs", "localhost_8080", "20260101T120000Z") {
+		t.Errorf("Unexpected run directory: %s\n", dir)
+	}
+}
+
+func TestPruneRunsKeepsPolicy(t *testing.T) {
+	var (
+		now  = time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+		runs = func() string {
+			dir := t.TempDir()
+
+			for _, days := range []int{0, 1, 3, 5, 8} {
+				run := filepath.Join(dir, now.AddDate(0, 0, -days).Format(runLayout))
+				if err := os.MkdirAll(run, 0755); err != nil {
+					t.Fatalf("Creating run fails with error: %s\n", err.Error())
+				}
+
+				if err := os.WriteFile(filepath.Join(run, "manifest.json"), []byte("{}"), 0644); err != nil {
+					t.Fatalf("Creating run fails with error: %s\n", err.Error())
+				}
+			}
+
+			// Other files of the directory are not runs
+			if err := os.Mkdir(filepath.Join(dir, "notes"), 0755); err != nil {
+				t.Fatalf("Creating directory fails with error: %s\n", err.Error())
+			}
+
+			return dir
+		}
+	)
+
+	cases := []struct {
+		policy RetentionPolicy
+		kept   []string
+	}{
+		{RetentionPolicy{KeepRuns: 2}, []string{"20260109T000000Z", "20260110T000000Z", "notes"}},
+		{RetentionPolicy{MaxAge: 4 * 24 * time.Hour}, []string{"20260107T000000Z", "20260109T000000Z", "20260110T000000Z", "notes"}},
+		{RetentionPolicy{KeepRuns: 4, MaxAge: 4 * 24 * time.Hour}, []string{"20260107T000000Z", "20260109T000000Z", "20260110T000000Z", "notes"}},
+		{RetentionPolicy{KeepRuns: 1, MaxAge: time.Hour}, []string{"20260110T000000Z", "notes"}},
+		{RetentionPolicy{}, []string{"20260102T000000Z", "20260105T000000Z", "20260107T000000Z", "20260109T000000Z", "20260110T000000Z", "notes"}},
+	}
+
+	for _, c := range cases {
+		dir := runs()
+
+		removed, err := PruneRuns(dir, c.policy, now)
+		if err != nil {
+			t.Fatalf("Pruning fails with error: %s\n", err.Error())
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Reading runs fails with error: %s\n", err.Error())
+		}
+
+		kept := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			kept = append(kept, entry.Name())
+		}
+		sort.Strings(kept)
+
+		if !reflect.DeepEqual(kept, c.kept) || len(removed)+len(kept) != 6 {
+			t.Errorf("Unexpected runs kept with %+v: %v (removed %v)\n", c.policy, kept, removed)
+		}
+	}
+
+	if _, err := PruneRuns(filepath.Join(t.TempDir(), "missing"), RetentionPolicy{KeepRuns: 1}, now); !os.IsNotExist(err) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryBackoff struct defines how long the crawler waits before retrying a URL whose download failed.
+// The first retry waits Initial, each next one Multiplier (2 if zero) times longer up to Max (no limit if zero).
+// Jitter is the fraction by which every delay is randomly lengthened or shortened, e.g. 0.2 for ±20%,
+// so that the retries of URLs which failed together are spread out. The zero value retries immediately.
+type RetryBackoff struct {
+	Initial, Max       time.Duration
+	Multiplier, Jitter float64
+}
+
+var defaultRetryBackoff = RetryBackoff{
+	Initial:    500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// delay returns how long to wait before the given retry, counted from one
+func (b *RetryBackoff) delay(retry int) time.Duration {
+	if b.Initial <= 0 {
+		return 0
+	}
+
+	multiplier := b.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	d := float64(b.Initial) * math.Pow(multiplier, float64(retry-1))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		d *= 1 + b.Jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(d)
+}
+
+// retryLater pushes the URL back to the frontier once the backoff of its retry passes. If the crawl is stopped
+// or its context is done meanwhile, the URL is given up and left uncrawled instead.
+func (c *Crawler) retryLater(url, from string, depth int) {
+	item := FrontierItem{Url: url, From: from, Depth: depth}
+
+	delay := c.retryBackoff.delay(c.retryCount(url))
+	if delay == 0 {
+		c.push(item)
+		return
+	}
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			c.push(item)
+		case <-c.stop:
+			c.giveUp(item)
+		case <-c.ctx.Done():
+			c.giveUp(item)
+		}
+	}()
+}
+
+// giveUp leaves the URL uncrawled, or to the other crawlers if the frontier is shared
+func (c *Crawler) giveUp(item FrontierItem) {
+	if c.shared != nil {
+		c.push(item)
+		return
+	}
+
+	c.markBeingProcessed(item.Url, false)
+	c.removePending(item.Url)
+	c.markUncrawled(item.Url, item.From, item.Depth, SkipStopped)
+
+	c.settle(item)
+}
+
+func (c *Crawler) retryCount(url string) int {
+	c.mur.RLock()
+	defer c.mur.RUnlock()
+
+	return c.retries[url]
+}
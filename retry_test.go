@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffGrowsExponentially(t *testing.T) {
+	b := &RetryBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, e := range expected {
+		if d := b.delay(i + 1); d != e {
+			t.Errorf("Unexpected delay of retry %d: %s\n", i+1, d)
+		}
+	}
+
+	if d := (&RetryBackoff{}).delay(3); d != 0 {
+		t.Errorf("Zero backoff delays retry: %s\n", d)
+	}
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	b := &RetryBackoff{Initial: 100 * time.Millisecond, Multiplier: 3, Jitter: 0.2}
+
+	for i := 0; i < 100; i++ {
+		if d := b.delay(2); d < 240*time.Millisecond || d > 360*time.Millisecond {
+			t.Fatalf("Delay out of jitter range: %s\n", d)
+		}
+	}
+}
+
+func TestCrawlerRetriesAfterBackoff(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []time.Time
+		server   *httptest.Server
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/flaky\n", server.URL)
+		case "/flaky":
+			mu.Lock()
+			requests = append(requests, time.Now())
+			failing := len(requests) < 3
+			mu.Unlock()
+
+			if failing {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   2,
+		RetryBackoff: RetryBackoff{Initial: 50 * time.Millisecond},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if _, ok := c.GetSiteMap().Get(server.URL + "/flaky"); !ok {
+		t.Fatalf("Flaky page not crawled after retries\n")
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Unexpected number of requests: %d\n", len(requests))
+	}
+
+	if first, second := requests[1].Sub(requests[0]), requests[2].Sub(requests[1]); first < 50*time.Millisecond || second < 100*time.Millisecond {
+		t.Errorf("Retries not backed off: %s, %s\n", first, second)
+	}
+}
+
+func TestCrawlerGivesUpRetryWhenStopped(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/failing\n", server.URL)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   2,
+		RetryBackoff: RetryBackoff{Initial: time.Hour},
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+
+	// The first error is the failed download, the retry of which waits for an hour
+	<-errs
+	c.Stop()
+
+	for range errs {
+	}
+	<-done
+
+	if uncrawled := c.GetUncrawled(); len(uncrawled) != 1 || uncrawled[0].Reason != SkipStopped {
+		t.Errorf("Unexpected uncrawled URLs: %v\n", uncrawled)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule struct represents a rewrite of the discovered URLs, every match of Pattern being replaced with
+// Replacement, which may refer to the groups of the match as $1 or ${name}, e.g. ^http:// with https://
+// to force https or ^https://staging\.example\.com/ with https://www.example.com/ to map a staging host.
+type RewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// ParseRewriteRule parses the rule written as <pattern> => <replacement>, e.g. ^http://(.*)$ => https://$1
+func ParseRewriteRule(rule string) (RewriteRule, error) {
+	pattern, replacement, ok := strings.Cut(rule, "=>")
+	if !ok || strings.TrimSpace(pattern) == "" {
+		return RewriteRule{}, fmt.Errorf("%w: %s", ErrInvalidRewrite, rule)
+	}
+
+	re, err := regexp.Compile(strings.TrimSpace(pattern))
+	if err != nil {
+		return RewriteRule{}, fmt.Errorf("%w: %s", ErrInvalidRewrite, err.Error())
+	}
+
+	return RewriteRule{Pattern: re, Replacement: strings.TrimSpace(replacement)}, nil
+}
+
+// RewriteRules returns the function applying the rules to the URL in order, each to the result of the previous one,
+// to be passed as RewriteURL.
+func RewriteRules(rules []RewriteRule) func(string) string {
+	return func(url string) string {
+		for _, rule := range rules {
+			url = rule.Pattern.ReplaceAllString(url, rule.Replacement)
+		}
+
+		return url
+	}
+}
+
+// rewritten returns the canonical form of the URL discovered by the crawl once rewritten,
+// empty if the rewrite drops it
+func (c *Crawler) rewritten(url string) string {
+	if c.rewriteURL != nil {
+		if url = c.rewriteURL(url); url == "" {
+			return ""
+		}
+	}
+
+	return c.canonical(url)
+}
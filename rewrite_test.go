@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRewriteRule(t *testing.T) {
+	rule, err := ParseRewriteRule(`^http://staging\.(.*)$ => https://www.$1`)
+	if err != nil {
+		t.Fatalf("Parsing fails with error: %s\n", err.Error())
+	}
+
+	if url := RewriteRules([]RewriteRule{rule})("http://staging.example.com/a"); url != "https://www.example.com/a" {
+		t.Errorf("Unexpected rewrite: %s\n", url)
+	}
+
+	for _, arg := range []string{"^http://", " => https://", "^(http => https"} {
+		if _, err := ParseRewriteRule(arg); !errors.Is(err, ErrInvalidRewrite) {
+			t.Errorf("Unexpected error of %q: %v\n", arg, err)
+		}
+	}
+}
+
+func TestRewriteRulesApplyInOrder(t *testing.T) {
+	var rules []RewriteRule
+	for _, arg := range []string{"^http:// => https://", `:8080/ => /`, `^.*/private/.* =>`} {
+		rule, err := ParseRewriteRule(arg)
+		if err != nil {
+			t.Fatalf("Parsing fails with error: %s\n", err.Error())
+		}
+
+		rules = append(rules, rule)
+	}
+
+	rewrite := RewriteRules(rules)
+
+	cases := map[string]string{
+		"http://example.com:8080/a":    "https://example.com/a",
+		"https://example.com/b":        "https://example.com/b",
+		"http://example.com/private/c": "",
+	}
+
+	for url, expected := range cases {
+		if rewritten := rewrite(url); rewritten != expected {
+			t.Errorf("Unexpected rewrite of %s: %q\n", url, rewritten)
+		}
+	}
+}
+
+func TestCrawlerRewritesDiscoveredURLs(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "http://staging.test/a\n%s/b?session=1\n%s/drop\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		RewriteURL: func(url string) string {
+			if strings.HasSuffix(url, "/drop") {
+				return ""
+			}
+
+			url = strings.Replace(url, "http://staging.test", server.URL, 1)
+			return strings.TrimSuffix(url, "?session=1")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	urls := make([]string, 0)
+	for _, page := range c.GetSiteMap().Pages() {
+		urls = append(urls, page.Url)
+	}
+
+	if expected := []string{server.URL + "/", server.URL + "/a", server.URL + "/b"}; !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Unexpected websites: %v\n", urls)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsAgent is the user agent whose rules are honored, the crawler obeys the rules meant for all crawlers
+const robotsAgent = "*"
+
+// robots struct represents the rules of a robots.txt file which apply to the crawler.
+type robots struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsGroup struct represents the rules listed under one or more User-agent lines
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+	delay  time.Duration
+}
+
+// parseRobots reads the rules of the group matching the agent, falling back to the group for all agents.
+// Lines it does not understand are skipped, so that a malformed file allows rather than blocks the crawl.
+func parseRobots(body []byte, agent string) *robots {
+	var (
+		groups  []*robotsGroup
+		current *robotsGroup
+		inRules bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+
+		key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share the rules following them
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inRules = false
+			}
+
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+
+			inRules = true
+
+			// An empty Disallow allows everything, so it is not a rule at all
+			if value != "" {
+				current.rules = append(current.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+
+			inRules = true
+
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	r := &robots{}
+	if g := matchGroup(groups, strings.ToLower(agent)); g != nil {
+		r.rules, r.delay = g.rules, g.delay
+	}
+
+	return r
+}
+
+func matchGroup(groups []*robotsGroup, agent string) *robotsGroup {
+	var fallback *robotsGroup
+
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if fallback == nil {
+					fallback = g
+				}
+			} else if agent != "*" && strings.Contains(agent, a) {
+				return g
+			}
+		}
+	}
+
+	return fallback
+}
+
+// allowed reports whether the URL may be crawled. The longest matching rule decides,
+// Allow winning over Disallow if they are equally long.
+func (r *robots) allowed(url string) bool {
+	_, disallowed := r.disallowedBy(url)
+	return !disallowed
+}
+
+// disallowedBy returns the pattern of the Disallow rule deciding that the URL may not be crawled, if it may not
+func (r *robots) disallowedBy(url string) (string, bool) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", false
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	var (
+		longest  = -1
+		deciding robotsRule
+	)
+
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+
+		if l := len(rule.pattern); l > longest || (l == longest && rule.allow) {
+			longest, deciding = l, rule
+		}
+	}
+
+	if longest < 0 || deciding.allow {
+		return "", false
+	}
+
+	return deciding.pattern, true
+}
+
+// robotsMatch reports whether the path starts with the pattern, where * matches any sequence
+// of characters and a trailing $ anchors the pattern at the end of the path.
+func robotsMatch(pattern, path string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(path); i++ {
+			if robotsMatch(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case '$':
+		if len(pattern) == 1 {
+			return path == ""
+		}
+	}
+
+	return path != "" && pattern[0] == path[0] && robotsMatch(pattern[1:], path[1:])
+}
+
+// robotsEntry struct holds the rules of a host, fetched once no matter how many goroutines ask for them
+type robotsEntry struct {
+	once   sync.Once
+	robots *robots
+}
+
+// robotsCache struct fetches and caches the robots.txt rules of each host.
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+	fetch func(url string) ([]byte, error)
+}
+
+func newRobotsCache(fetch func(url string) ([]byte, error)) *robotsCache {
+	return &robotsCache{
+		hosts: make(map[string]*robotsEntry),
+		fetch: fetch,
+	}
+}
+
+// allowed reports whether the rules of the URL's host allow crawling it, fetching them first if needed.
+// A host whose robots.txt cannot be fetched is crawled without restrictions.
+func (c *robotsCache) allowed(url string) bool {
+	e := c.entry(url, true)
+	if e == nil {
+		return true
+	}
+
+	return e.robots.allowed(url)
+}
+
+// disallowedBy returns the pattern of the rule of the URL's host disallowing it, if it is disallowed
+func (c *robotsCache) disallowedBy(url string) (string, bool) {
+	e := c.entry(url, true)
+	if e == nil {
+		return "", false
+	}
+
+	return e.robots.disallowedBy(url)
+}
+
+// delay returns the Crawl-delay of the URL's host, zero if its rules have not been fetched.
+func (c *robotsCache) delay(url string) time.Duration {
+	e := c.entry(url, false)
+	if e == nil {
+		return 0
+	}
+
+	return e.robots.delay
+}
+
+func (c *robotsCache) entry(url string, fetch bool) *robotsEntry {
+	u, err := neturl.Parse(url)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	e, ok := c.hosts[u.Host]
+	if !ok {
+		if !fetch {
+			c.mu.Unlock()
+			return nil
+		}
+
+		e = &robotsEntry{}
+		c.hosts[u.Host] = e
+	}
+	c.mu.Unlock()
+
+	// If the rules are being fetched by another goroutine, this waits for them
+	e.once.Do(func() {
+		robotsURL := (&neturl.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String()
+
+		if body, err := c.fetch(robotsURL); err == nil {
+			e.robots = parseRobots(body, robotsAgent)
+		} else {
+			e.robots = &robots{}
+		}
+	})
+
+	return e
+}
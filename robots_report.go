@@ -0,0 +1,130 @@
+package main
+
+import (
+	neturl "net/url"
+	"sort"
+)
+
+// maxBlockedExamples is the number of example pages kept for each URL blocked by robots.txt
+const maxBlockedExamples = 3
+
+// RobotsReport struct represents the discovered URLs which robots.txt of their hosts kept from being crawled,
+// Blocked lists the URLs and Sections the Disallow rules blocking them, both the most linked first.
+type RobotsReport struct {
+	Blocked  []*BlockedURL     `json:"blocked"`
+	Sections []*BlockedSection `json:"sections"`
+}
+
+// BlockedURL struct represents a URL disallowed by the Rule of robots.txt of its host. Links counts the links
+// to it, Pages holds a few of the pages linking to it and Depth is the depth of the shallowest of them.
+type BlockedURL struct {
+	Url   string   `json:"url"`
+	Rule  string   `json:"rule"`
+	Links int      `json:"links"`
+	Depth int      `json:"depth"`
+	Pages []string `json:"pages"`
+}
+
+// BlockedSection struct represents a Disallow rule of robots.txt of the Host with the number of URLs it blocked
+// and of the links to them. A section linked many times or from shallow pages (Depth 0 being the root page)
+// is linked prominently despite being disallowed, which is often a mistake in either robots.txt or the links.
+type BlockedSection struct {
+	Host  string `json:"host"`
+	Rule  string `json:"rule"`
+	URLs  int    `json:"urls"`
+	Links int    `json:"links"`
+	Depth int    `json:"depth"`
+}
+
+// GetRobotsReport returns the URLs blocked by robots.txt so far and the sections of the hosts blocking them.
+func (c *Crawler) GetRobotsReport() RobotsReport {
+	var (
+		report   = RobotsReport{Blocked: make([]*BlockedURL, 0), Sections: make([]*BlockedSection, 0)}
+		sections = make(map[[2]string]*BlockedSection)
+	)
+
+	c.mubl.Lock()
+	for _, b := range c.blocked {
+		pages := make([]string, len(b.Pages))
+		copy(pages, b.Pages)
+
+		report.Blocked = append(report.Blocked, &BlockedURL{Url: b.Url, Rule: b.Rule, Links: b.Links, Depth: b.Depth, Pages: pages})
+
+		var host string
+		if u, err := neturl.Parse(b.Url); err == nil {
+			host = u.Host
+		}
+
+		s, ok := sections[[2]string{host, b.Rule}]
+		if !ok {
+			s = &BlockedSection{Host: host, Rule: b.Rule, Depth: b.Depth}
+			sections[[2]string{host, b.Rule}] = s
+			report.Sections = append(report.Sections, s)
+		}
+
+		s.URLs++
+		s.Links += b.Links
+
+		if b.Depth < s.Depth {
+			s.Depth = b.Depth
+		}
+	}
+	c.mubl.Unlock()
+
+	sort.Slice(report.Blocked, func(i, j int) bool {
+		if report.Blocked[i].Links != report.Blocked[j].Links {
+			return report.Blocked[i].Links > report.Blocked[j].Links
+		}
+
+		return report.Blocked[i].Url < report.Blocked[j].Url
+	})
+
+	sort.Slice(report.Sections, func(i, j int) bool {
+		if report.Sections[i].Links != report.Sections[j].Links {
+			return report.Sections[i].Links > report.Sections[j].Links
+		}
+
+		if report.Sections[i].Host != report.Sections[j].Host {
+			return report.Sections[i].Host < report.Sections[j].Host
+		}
+
+		return report.Sections[i].Rule < report.Sections[j].Rule
+	})
+
+	return report
+}
+
+// recordBlocked counts the link of the page at given depth to the URL disallowed by robots.txt
+func (c *Crawler) recordBlocked(url, page string, depth int) {
+	rule, ok := c.robots.disallowedBy(url)
+	if !ok {
+		return
+	}
+
+	c.mubl.Lock()
+	defer c.mubl.Unlock()
+
+	b, ok := c.blocked[url]
+	if !ok {
+		b = &BlockedURL{Url: url, Rule: rule, Depth: depth}
+		c.blocked[url] = b
+	}
+
+	b.Links++
+
+	if depth < b.Depth {
+		b.Depth = depth
+	}
+
+	if len(b.Pages) == maxBlockedExamples {
+		return
+	}
+
+	for _, p := range b.Pages {
+		if p == page {
+			return
+		}
+	}
+
+	b.Pages = append(b.Pages, page)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlerReportsBlockedURLs(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/private/1\n%s/private/2\n", server.URL, server.URL, server.URL)
+		case "/a":
+			fmt.Fprintf(w, "%s/private/1\n%s/tmp/x\n%s/private/public\n", server.URL, server.URL, server.URL)
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\nDisallow: /tmp/\nAllow: /private/public\n")
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers: 1,
+		MaxRetries: 1,
+		Downloader: unpooledDownloader{},
+		Extractor:  lineExtractor{},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Unexpected error: %s\n", err.Error())
+	}
+	<-done
+
+	u, _ := url.Parse(server.URL)
+	report := c.GetRobotsReport()
+
+	expected := []*BlockedSection{
+		{Host: u.Host, Rule: "/private", URLs: 2, Links: 3, Depth: 0},
+		{Host: u.Host, Rule: "/tmp/", URLs: 1, Links: 1, Depth: 1},
+	}
+
+	if !reflect.DeepEqual(report.Sections, expected) {
+		t.Errorf("Unexpected sections: %v\n", report.Sections)
+	}
+
+	if len(report.Blocked) != 3 || report.Blocked[0].Url != server.URL+"/private/1" || report.Blocked[0].Links != 2 ||
+		!reflect.DeepEqual(report.Blocked[0].Pages, []string{server.URL + "/", server.URL + "/a"}) {
+		t.Errorf("Unexpected blocked URLs: %v\n", report.Blocked)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRobotsHonorsLongestMatchingRule(t *testing.T) {
+	const ROBOTS = `
+# comments are skipped
+User-agent: other
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /search?
+Crawl-delay: 1.5
+`
+
+	r := parseRobots([]byte(ROBOTS), robotsAgent)
+
+	cases := map[string]bool{
+		"http://example.com/":                    true,
+		"http://example.com/about":               true,
+		"http://example.com/private":             false,
+		"http://example.com/private/secret":      false,
+		"http://example.com/private/public/page": true,
+		"http://example.com/files/report.pdf":    false,
+		"http://example.com/files/report.pdf?x":  true,
+		"http://example.com/search":              true,
+		"http://example.com/search?q=crawler":    false,
+	}
+
+	for url, expected := range cases {
+		if r.allowed(url) != expected {
+			t.Errorf("Unexpected verdict for %s: %t\n", url, !expected)
+		}
+	}
+
+	if rule, ok := r.disallowedBy("http://example.com/files/report.pdf"); !ok || rule != "/*.pdf$" {
+		t.Errorf("Unexpected deciding rule: %s\n", rule)
+	}
+
+	if r.delay != 1500*time.Millisecond {
+		t.Errorf("Unexpected crawl delay: %s\n", r.delay)
+	}
+}
+
+func TestRobotsPrefersGroupOfAgent(t *testing.T) {
+	const ROBOTS = `
+User-agent: *
+Disallow: /
+
+User-agent: googlebot
+User-agent: crawler
+Disallow: /private
+`
+
+	r := parseRobots([]byte(ROBOTS), "Crawler/1.0")
+
+	if !r.allowed("http://example.com/page") || r.allowed("http://example.com/private") {
+		t.Errorf("Rules of the agent's group not applied: %v\n", r.rules)
+	}
+
+	if r = parseRobots([]byte("Disallow: /\n"), robotsAgent); !r.allowed("http://example.com/") {
+		t.Errorf("Rules outside of any group applied: %v\n", r.rules)
+	}
+}
+
+func TestRobotsCacheFetchesOncePerHost(t *testing.T) {
+	fetched := make(map[string]int)
+
+	cache := newRobotsCache(func(url string) ([]byte, error) {
+		fetched[url]++
+		if url == "http://b.com/robots.txt" {
+			return nil, ErrBadResponse
+		}
+
+		return []byte("User-agent: *\nDisallow: /private\n"), nil
+	})
+
+	if cache.allowed("http://a.com/private") || !cache.allowed("http://a.com/page") || !cache.allowed("http://b.com/private") {
+		t.Errorf("Unexpected verdicts of the cache\n")
+	}
+
+	if fetched["http://a.com/robots.txt"] != 1 || fetched["http://b.com/robots.txt"] != 1 {
+		t.Errorf("Unexpected fetches: %v\n", fetched)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "context"
+
+// RunOption changes the options of the crawl started by Run, e.g. func(o *Options) { o.MaxPages = 100 }
+type RunOption func(*Options)
+
+// Run crawls the website under url and waits for the crawl to be done, sparing the consumers of the done
+// and errors channels of Crawl. The crawl uses the default options changed by the given ones, in order.
+// It returns the sitemap and the final stats of the crawl. The errors of the websites are passed to the OnError
+// callback of the options if set and discarded otherwise, as they are aggregated by the stats; the returned error
+// is that of creating the crawler, or that of ctx if it is cancelled, in which case the sitemap holds the websites
+// crawled so far.
+func Run(ctx context.Context, url string, opts ...RunOption) (*SiteMap, *Stats, error) {
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c, err := NewCrawlerWithOptions(url, &options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done, errors := c.CrawlWithContext(ctx)
+	for range errors {
+	}
+	<-done
+
+	stats := c.Stats()
+
+	return c.GetSiteMap(), &stats, ctx.Err()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRunCrawls(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/missing\n", server.URL, server.URL)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mu     sync.Mutex
+		failed []string
+	)
+
+	s, stats, err := Run(context.Background(), server.URL+"/", func(o *Options) {
+		o.MaxWorkers = 2
+		o.MaxRetries = 1
+		o.Extractor = lineExtractor{}
+		o.IgnoreRobots = true
+		o.OnError = func(e CrawlError) {
+			mu.Lock()
+			failed = append(failed, e.Url)
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatalf("Run fails with error: %s\n", err.Error())
+	}
+
+	if l := s.Len(); l != 2 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+
+	if stats.Fetched != 2 || stats.Errors != len(failed) || stats.Elapsed == 0 {
+		t.Errorf("Unexpected stats: %+v\n", stats)
+	}
+
+	if len(failed) == 0 || failed[0] != server.URL+"/missing" {
+		t.Errorf("Unexpected errors: %v\n", failed)
+	}
+}
+
+func TestRunReturnsCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, _, err := Run(ctx, server.URL+"/", func(o *Options) {
+		o.Extractor = lineExtractor{}
+		o.IgnoreRobots = true
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if s == nil {
+		t.Errorf("No sitemap of the cancelled crawl\n")
+	}
+}
+
+func TestRunKeepsDefaultOptions(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s/a\n%s/b\n%s/c\n", server.URL, server.URL, server.URL)
+	}))
+	defer server.Close()
+
+	// Only the options given are changed, the workers and retries are the default ones
+	s, stats, err := Run(context.Background(), server.URL+"/", func(o *Options) {
+		o.MaxPages = 2
+		o.Extractor = lineExtractor{}
+	}, func(o *Options) {
+		o.IgnoreRobots = true
+	})
+	if err != nil {
+		t.Fatalf("Run fails with error: %s\n", err.Error())
+	}
+
+	if s.Len() != 2 || stats.Fetched != 2 {
+		t.Errorf("Unexpected crawl: %d websites, %+v\n", s.Len(), stats)
+	}
+}
+
+func TestRunRejectsInvalidURLs(t *testing.T) {
+	if _, _, err := Run(context.Background(), "://invalid"); err == nil {
+		t.Errorf("Run of an invalid url succeeds\n")
+	}
+}
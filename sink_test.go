@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClickHouseSinkInsertsBatches(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+		rows    []map[string]interface{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		queries = append(queries, r.URL.Query().Get("query"))
+
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var row map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &row); err == nil {
+				rows = append(rows, row)
+			}
+		}
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouseSink(server.URL, "crawl.pages", &SinkOptions{
+		Columns:   map[string]string{"page_url": "url", "page_title": "title"},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Sink fails with error: %s\n", err.Error())
+	}
+
+	for _, url := range []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"} {
+		if err = sink.Write(&Page{Url: url, Title: "T"}); err != nil {
+			t.Fatalf("Writing fails with error: %s\n", err.Error())
+		}
+	}
+
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Closing fails with error: %s\n", err.Error())
+	}
+
+	if len(queries) != 2 || queries[0] != "INSERT INTO `crawl`.`pages` FORMAT JSONEachRow" {
+		t.Errorf("Unexpected queries: %v\n", queries)
+	}
+
+	if len(rows) != 3 || rows[2]["page_url"] != "http://example.com/c" || rows[2]["page_title"] != "T" || len(rows[2]) != 2 {
+		t.Errorf("Unexpected rows: %v\n", rows)
+	}
+}
+
+func TestSinkRejectsUnknownFields(t *testing.T) {
+	if _, err := NewClickHouseSink("http://localhost:8123/", "pages", &SinkOptions{Columns: map[string]string{"c": "body"}}); err != ErrInvalidColumn {
+		t.Errorf("Sink does not fail for unknown field\n")
+	}
+
+	for _, table := range []string{"pages; DROP TABLE pages", "a.b.c", "`pages`", ""} {
+		if _, err := NewClickHouseSink("http://localhost:8123/", table, &SinkOptions{}); err != ErrInvalidTable {
+			t.Errorf("Sink does not fail for invalid table %q\n", table)
+		}
+	}
+
+	if _, err := NewBigQuerySink("dataset.table", "", &SinkOptions{}); err != ErrInvalidTable {
+		t.Errorf("Sink does not fail for invalid table\n")
+	}
+}
+
+func TestSinkRetriesFailedBatches(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		failures = map[string]int{"http://example.com/a": 2, "http://example.com/b": 3}
+		inserted []string
+	)
+
+	// a is inserted on the last retry, b is kept once the retries are exhausted and inserted on Close
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var row map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&row); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		url := row["url"].(string)
+		if failures[url] > 0 {
+			failures[url]--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		inserted = append(inserted, url)
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouseSink(server.URL, "pages", &SinkOptions{
+		Columns:   map[string]string{"url": "url"},
+		BatchSize: 1,
+		Retries:   2,
+		Backoff:   RetryBackoff{Initial: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Sink fails with error: %s\n", err.Error())
+	}
+
+	for _, url := range []string{"http://example.com/a", "http://example.com/b"} {
+		if err = sink.Write(&Page{Url: url}); err != nil {
+			t.Errorf("Writing fails with error: %s\n", err.Error())
+		}
+	}
+
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Closing fails with error: %s\n", err.Error())
+	}
+
+	sort.Strings(inserted)
+	if !reflect.DeepEqual(inserted, []string{"http://example.com/a", "http://example.com/b"}) {
+		t.Errorf("Unexpected rows: %v\n", inserted)
+	}
+}
+
+func TestSinkDoesNotWaitForInserts(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouseSink(server.URL, "pages", &SinkOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("Sink fails with error: %s\n", err.Error())
+	}
+
+	written := make(chan error)
+	go func() {
+		written <- sink.Write(&Page{Url: "http://example.com/a"})
+	}()
+
+	select {
+	case err = <-written:
+		if err != nil {
+			t.Errorf("Writing fails with error: %s\n", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Writing waits for the insert\n")
+	}
+
+	close(release)
+
+	if err = sink.Close(); err != nil {
+		t.Errorf("Closing fails with error: %s\n", err.Error())
+	}
+}
+
+func TestBigQuerySinkRetriesRejectedRows(t *testing.T) {
+	type request struct {
+		Rows []struct {
+			InsertId string                 `json:"insertId"`
+			Json     map[string]interface{} `json:"json"`
+		} `json:"rows"`
+	}
+
+	var (
+		mu       sync.Mutex
+		requests []request
+	)
+
+	// The second row of the first insert is rejected
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		requests = append(requests, req)
+
+		if len(requests) == 1 {
+			fmt.Fprint(w, `{"insertErrors":[{"index":1,"errors":[{"reason":"backendError"}]}]}`)
+		} else {
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { bigQueryEndpoint = endpoint }(bigQueryEndpoint)
+	bigQueryEndpoint = server.URL
+
+	sink, err := NewBigQuerySink("project.dataset.pages", "token", &SinkOptions{
+		Columns:   map[string]string{"url": "url"},
+		BatchSize: 3,
+		Backoff:   RetryBackoff{Initial: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Sink fails with error: %s\n", err.Error())
+	}
+
+	for _, url := range []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"} {
+		if err = sink.Write(&Page{Url: url}); err != nil {
+			t.Errorf("Writing fails with error: %s\n", err.Error())
+		}
+	}
+
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Closing fails with error: %s\n", err.Error())
+	}
+
+	if len(requests) != 2 || len(requests[0].Rows) != 3 || len(requests[1].Rows) != 1 {
+		t.Fatalf("Unexpected requests: %+v\n", requests)
+	}
+
+	if retried := requests[1].Rows[0]; retried.Json["url"] != "http://example.com/b" || retried.InsertId == "" || retried.InsertId != requests[0].Rows[1].InsertId {
+		t.Errorf("Unexpected retried row: %+v\n", retried)
+	}
+
+	if requests[0].Rows[0].InsertId == requests[0].Rows[1].InsertId {
+		t.Errorf("Rows of different pages share the insertId %s\n", requests[0].Rows[0].InsertId)
+	}
+}
+
+func TestSinkReportsLostRows(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouseSink(server.URL, "pages", &SinkOptions{BatchSize: 10, Retries: 1, Backoff: RetryBackoff{Initial: time.Millisecond}})
+	if err != nil {
+		t.Fatalf("Sink fails with error: %s\n", err.Error())
+	}
+
+	for _, url := range []string{"http://example.com/a", "http://example.com/b"} {
+		if err = sink.Write(&Page{Url: url}); err != nil {
+			t.Fatalf("Writing fails with error: %s\n", err.Error())
+		}
+	}
+
+	if err = sink.Close(); !errors.Is(err, ErrSinkInsert) || !strings.HasPrefix(err.Error(), "2 rows lost") {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// MergeStrategy defines how SiteMap.Merge resolves pages crawled in both sitemaps.
+// MergeKeepExisting leaves the page already present, MergeLatestWins keeps the page crawled most recently
+// and MergeKeepBoth keeps the most recent page while retaining the other one in its Versions.
+type MergeStrategy uint8
+
+const (
+	MergeKeepExisting MergeStrategy = iota
+	MergeLatestWins   MergeStrategy = iota
+	MergeKeepBoth     MergeStrategy = iota
+)
+
+// SiteMap struct represents the result of a crawl, i.e. the set of crawled pages indexed by their URL.
+// It hides the underlying storage so that callers only rely on its methods.
+type SiteMap struct {
+	pages map[string]*Page
+}
+
+// pageJSON is the serialized form of a Page, in which references to other pages are replaced with their URLs.
+type pageJSON struct {
+	Url        string       `json:"url"`
+	Title      string       `json:"title"`
+	Canonical  string       `json:"canonical,omitempty"`
+	Aliases    []string     `json:"aliases,omitempty"`
+	Redirects  []string     `json:"redirected_from,omitempty"`
+	Hash       string       `json:"content_hash,omitempty"`
+	SimHash    string       `json:"simhash,omitempty"`
+	Change     string       `json:"change,omitempty"`
+	ETag       string       `json:"etag,omitempty"`
+	LastMod    string       `json:"last_modified,omitempty"`
+	Protocol   string       `json:"protocol,omitempty"`
+	Encoding   string       `json:"content_encoding,omitempty"`
+	CrawledAt  time.Time    `json:"crawled_at"`
+	Depth      int          `json:"depth"`
+	Size       int          `json:"size,omitempty"`
+	InlineJS   int          `json:"inline_script_size,omitempty"`
+	InlineCSS  int          `json:"inline_style_size,omitempty"`
+	LinksTo    []string     `json:"links_to"`
+	LinkedFrom []string     `json:"linked_from"`
+	External   []string     `json:"external_links,omitempty"`
+	Assets     []*Asset     `json:"assets"`
+	Versions   pageVersions `json:"versions,omitempty"`
+
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+// pageVersions is the serialized form of the earlier versions of a page. The sitemaps written before the versions
+// were serialized in full only hold their timestamps, which are skipped when reading them.
+type pageVersions []*pageJSON
+
+func (v *pageVersions) UnmarshalJSON(data []byte) error {
+	var versions []json.RawMessage
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if !bytes.HasPrefix(bytes.TrimSpace(version), []byte("{")) {
+			continue
+		}
+
+		var page pageJSON
+		if err := json.Unmarshal(version, &page); err != nil {
+			return err
+		}
+
+		*v = append(*v, &page)
+	}
+
+	return nil
+}
+
+func NewSiteMap() *SiteMap {
+	return &SiteMap{
+		pages: make(map[string]*Page),
+	}
+}
+
+func newSiteMapFrom(pages map[string]*Page) *SiteMap {
+	return &SiteMap{
+		pages: pages,
+	}
+}
+
+func (s *SiteMap) Get(url string) (*Page, bool) {
+	page, ok := s.pages[url]
+	return page, ok
+}
+
+func (s *SiteMap) Len() int {
+	return len(s.pages)
+}
+
+// Pages returns all crawled pages sorted by their URL.
+func (s *SiteMap) Pages() []*Page {
+	pages := make([]*Page, 0, len(s.pages))
+	for _, page := range s.pages {
+		pages = append(pages, page)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Url < pages[j].Url
+	})
+
+	return pages
+}
+
+// Roots returns the pages which are not linked from any other crawled page, sorted by their URL.
+func (s *SiteMap) Roots() []*Page {
+	roots := make([]*Page, 0)
+	for _, page := range s.Pages() {
+		if len(page.LinkedFrom) == 0 {
+			roots = append(roots, page)
+		}
+	}
+
+	return roots
+}
+
+// Merge adds the pages of other to the sitemap, resolving pages present in both according to the strategy.
+// The links of the pages are then resolved by URL within the sitemap, so that they point to the pages it holds
+// and no longer to the pages it replaced. The pages are moved rather than copied, so other must not be used afterwards.
+func (s *SiteMap) Merge(other *SiteMap, strategy MergeStrategy) {
+	// The pages linking to a page present in both are those linking to either of its versions,
+	// as they are not replaced along with it
+	linkedFrom := make(map[string][]string)
+
+	for url, page := range other.pages {
+		existing, ok := s.pages[url]
+		if !ok {
+			s.pages[url] = page
+			continue
+		}
+
+		linkedFrom[url] = unionOf(urlsOf(existing.LinkedFrom), urlsOf(page.LinkedFrom))
+
+		switch strategy {
+		case MergeLatestWins:
+			if page.CrawledAt.After(existing.CrawledAt) {
+				s.pages[url] = page
+			}
+		case MergeKeepBoth:
+			if page.CrawledAt.After(existing.CrawledAt) {
+				page.Versions = append(page.Versions, existing)
+				s.pages[url] = page
+			} else {
+				existing.Versions = append(existing.Versions, page)
+			}
+		}
+	}
+
+	for url, page := range s.pages {
+		from, ok := linkedFrom[url]
+		if !ok {
+			from = urlsOf(page.LinkedFrom)
+		}
+
+		page.LinksTo = s.resolve(urlsOf(page.LinksTo))
+		page.LinkedFrom = s.resolve(from)
+	}
+}
+
+// resolve returns the pages of the sitemap with the URLs, leaving out the URLs of no page
+func (s *SiteMap) resolve(urls []string) []*Page {
+	pages := make([]*Page, 0, len(urls))
+	for _, url := range urls {
+		if page, ok := s.pages[url]; ok {
+			pages = append(pages, page)
+		}
+	}
+
+	return pages
+}
+
+func (s *SiteMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.serialize())
+}
+
+// WriteNDJSON writes the sitemap as newline delimited JSON, one page per line.
+func (s *SiteMap) WriteNDJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+
+	for _, page := range s.serialize() {
+		if err := enc.Encode(page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SiteMap) serialize() []*pageJSON {
+	pages := make([]*pageJSON, 0, len(s.pages))
+
+	for _, page := range s.Pages() {
+		pages = append(pages, jsonOf(page))
+	}
+
+	return pages
+}
+
+// jsonOf returns the serialized form of the page along with its earlier versions
+func jsonOf(page *Page) *pageJSON {
+	p := &pageJSON{
+		Url:        page.Url,
+		Title:      page.Title,
+		Canonical:  page.Canonical,
+		Aliases:    page.Aliases,
+		Redirects:  page.RedirectedFrom,
+		Hash:       page.ContentHash,
+		SimHash:    page.SimHash,
+		Change:     string(page.Change),
+		ETag:       page.ETag,
+		LastMod:    page.LastModified,
+		Protocol:   page.Protocol,
+		Encoding:   page.Encoding,
+		CrawledAt:  page.CrawledAt,
+		Depth:      page.Depth,
+		Size:       page.Size,
+		InlineJS:   page.InlineScriptSize,
+		InlineCSS:  page.InlineStyleSize,
+		LinksTo:    urlsOf(page.LinksTo),
+		LinkedFrom: urlsOf(page.LinkedFrom),
+		External:   page.ExternalLinks,
+		Assets:     page.Assets,
+		Extra:      page.CopyExtra(),
+	}
+
+	for _, version := range page.Versions {
+		p.Versions = append(p.Versions, jsonOf(version))
+	}
+
+	return p
+}
+
+// UnmarshalJSON restores the sitemap from its serialized form, relinking the pages by their URLs.
+func (s *SiteMap) UnmarshalJSON(data []byte) error {
+	var pages []*pageJSON
+	if err := json.Unmarshal(data, &pages); err != nil {
+		return err
+	}
+
+	s.restore(pages)
+	return nil
+}
+
+// ReadNDJSON restores the sitemap from newline delimited JSON written by WriteNDJSON.
+func (s *SiteMap) ReadNDJSON(r io.Reader) error {
+	var (
+		dec   = json.NewDecoder(r)
+		pages = make([]*pageJSON, 0)
+	)
+
+	for {
+		var page pageJSON
+		if err := dec.Decode(&page); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		pages = append(pages, &page)
+	}
+
+	s.restore(pages)
+	return nil
+}
+
+func (s *SiteMap) restore(pages []*pageJSON) {
+	s.pages = make(map[string]*Page, len(pages))
+
+	for _, p := range pages {
+		s.pages[p.Url] = restorePage(p)
+	}
+
+	for _, p := range pages {
+		s.link(s.pages[p.Url], p)
+	}
+}
+
+// restorePage returns the page of the serialized form along with its earlier versions, the links left to link
+func restorePage(p *pageJSON) *Page {
+	page := &Page{
+		Title:            p.Title,
+		Url:              p.Url,
+		Canonical:        p.Canonical,
+		Aliases:          p.Aliases,
+		RedirectedFrom:   p.Redirects,
+		ContentHash:      p.Hash,
+		SimHash:          p.SimHash,
+		Change:           ChangeStatus(p.Change),
+		ETag:             p.ETag,
+		LastModified:     p.LastMod,
+		Protocol:         p.Protocol,
+		Encoding:         p.Encoding,
+		CrawledAt:        p.CrawledAt,
+		Depth:            p.Depth,
+		Size:             p.Size,
+		InlineScriptSize: p.InlineJS,
+		InlineStyleSize:  p.InlineCSS,
+		ExternalLinks:    p.External,
+		Assets:           p.Assets,
+		Extra:            p.Extra,
+	}
+
+	for _, version := range p.Versions {
+		page.Versions = append(page.Versions, restorePage(version))
+	}
+
+	return page
+}
+
+// link links the restored page and its earlier versions to the pages of the sitemap by their URLs
+func (s *SiteMap) link(page *Page, p *pageJSON) {
+	page.LinksTo = s.resolve(p.LinksTo)
+	page.LinkedFrom = s.resolve(p.LinkedFrom)
+
+	for i, version := range p.Versions {
+		s.link(page.Versions[i], version)
+	}
+}
+
+// outLinks returns the URLs each page links to. Since LinksTo only holds the pages first discovered
+// from a given page, the links to pages discovered elsewhere are recovered from their LinkedFrom.
+func (s *SiteMap) outLinks() map[string][]string {
+	links := make(map[string][]string, len(s.pages))
+
+	for url, page := range s.pages {
+		for _, linked := range page.LinksTo {
+			links[url] = append(links[url], linked.Url)
+		}
+
+		for _, linking := range page.LinkedFrom {
+			links[linking.Url] = append(links[linking.Url], url)
+		}
+	}
+
+	return links
+}
+
+// unionOf returns the URLs of a followed by those of b not in a
+func unionOf(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, url := range a {
+		seen[url] = true
+	}
+
+	for _, url := range b {
+		if !seen[url] {
+			seen[url] = true
+			a = append(a, url)
+		}
+	}
+
+	return a
+}
+
+func urlsOf(pages []*Page) []string {
+	urls := make([]string, 0, len(pages))
+	for _, page := range pages {
+		urls = append(urls, page.Url)
+	}
+
+	return urls
+}
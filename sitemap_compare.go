@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	neturl "net/url"
+	"sort"
+)
+
+// maxDeclaredSitemaps is the number of sitemap files read when comparing the crawl with the declared sitemaps,
+// including the indexes, so that a site with a huge tree of sitemaps does not delay the end of the crawl for long
+const maxDeclaredSitemaps = 50
+
+// declaredSitemap struct is the union of a sitemap and a sitemap index, only one of which is populated
+type declaredSitemap struct {
+	XMLName  xml.Name
+	URLs     []*xmlURL     `xml:"url"`
+	Sitemaps []*xmlSitemap `xml:"sitemap"`
+}
+
+// robotsSitemaps returns the URLs of the sitemaps declared by robots.txt with Sitemap lines
+func robotsSitemaps(body []byte) []string {
+	sitemaps := make([]string, 0)
+
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if i := bytes.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		i := bytes.IndexByte(line, ':')
+		if i < 0 || !bytes.EqualFold(bytes.TrimSpace(line[:i]), []byte("sitemap")) {
+			continue
+		}
+
+		if url := string(bytes.TrimSpace(line[i+1:])); url != "" {
+			sitemaps = append(sitemaps, url)
+		}
+	}
+
+	return sitemaps
+}
+
+// parseDeclaredSitemap reads a sitemap or a sitemap index, possibly gzip compressed
+func parseDeclaredSitemap(body []byte) (*declaredSitemap, error) {
+	var r io.Reader = bytes.NewReader(body)
+
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		defer gz.Close()
+		r = gz
+	}
+
+	var s declaredSitemap
+	if err := xml.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// sitemapsOf returns the sitemaps declared for the seeds: the ones listed in robots.txt of their hosts,
+// or /sitemap.xml of each host if there are none
+func (c *Crawler) sitemapsOf() []string {
+	var (
+		seen     = make(map[string]struct{})
+		sitemaps = make([]string, 0)
+	)
+
+	for _, seed := range c.seeds {
+		u, err := neturl.Parse(seed)
+		if err != nil {
+			continue
+		}
+
+		origin := (&neturl.URL{Scheme: u.Scheme, Host: u.Host}).String()
+		if _, ok := seen[origin]; ok {
+			continue
+		}
+
+		seen[origin] = struct{}{}
+
+		declared := []string{origin + "/sitemap.xml"}
+		if body, err := c.fetchFile(origin + "/robots.txt"); err == nil {
+			if s := robotsSitemaps(body); len(s) > 0 {
+				declared = s
+			}
+		}
+
+		sitemaps = append(sitemaps, declared...)
+	}
+
+	return sitemaps
+}
+
+// declaredURLs reads the declared sitemaps, following the sitemap indexes, and returns the canonical URLs they list.
+// It reports whether any sitemap could be read, the sitemaps which cannot are reported as errors.
+func (c *Crawler) declaredURLs() (map[string]struct{}, bool) {
+	var (
+		urls    = make(map[string]struct{})
+		queue   = c.sitemapsOf()
+		fetched = make(map[string]struct{})
+		found   bool
+	)
+
+	for len(queue) > 0 && len(fetched) < maxDeclaredSitemaps {
+		location := queue[0]
+		queue = queue[1:]
+
+		if _, ok := fetched[location]; ok {
+			continue
+		}
+
+		fetched[location] = struct{}{}
+
+		body, err := c.fetchFile(location)
+		if err != nil {
+			c.reportError(CrawlError{Url: location, Phase: PhaseCrawl, Err: err})
+			continue
+		}
+
+		s, err := parseDeclaredSitemap(body)
+		if err != nil {
+			c.reportError(CrawlError{Url: location, Phase: PhaseCrawl, Err: err})
+			continue
+		}
+
+		found = true
+
+		for _, u := range s.URLs {
+			if url := c.rewritten(u.Loc); url != "" {
+				urls[url] = struct{}{}
+			}
+		}
+
+		for _, sitemap := range s.Sitemaps {
+			queue = append(queue, sitemap.Loc)
+		}
+	}
+
+	return urls, found
+}
+
+// crawlSitemaps schedules the pages listed in the declared sitemaps as seeds, the ones within the crawled domains
+// which are not scheduled yet. They are subject to the same limits, filters and robots.txt as the discovered links.
+func (c *Crawler) crawlSitemaps() {
+	declared, _ := c.declaredURLs()
+
+	urls := make([]string, 0, len(declared))
+	for url := range declared {
+		if c.inScope(url) {
+			urls = append(urls, url)
+		}
+	}
+
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		if !c.visited.Contains(url) {
+			c.schedule(url, "<root>", 0, ViaSitemap)
+		}
+	}
+}
+
+// compareSitemaps reports the pages listed in the declared sitemaps which the crawl did not discover
+// as sitemap-unreachable findings, and the crawled pages missing from them as missing-from-sitemap findings.
+// The pages declaring another page canonical are not expected in the sitemaps.
+func (c *Crawler) compareSitemaps() {
+	declared, ok := c.declaredURLs()
+	if !ok {
+		return
+	}
+
+	broken := make(map[string]struct{})
+	for _, f := range c.GetFindings().ByCategory(CategoryBrokenPage) {
+		broken[f.Url] = struct{}{}
+	}
+
+	for url := range declared {
+		if _, ok := broken[url]; !ok && c.inScope(url) && !c.discovered(url) {
+			c.addFinding(Finding{
+				Category: CategorySitemapUnreachable,
+				Severity: SeverityWarning,
+				Url:      url,
+				Message:  "Listed in the sitemap.xml but not reachable by links",
+			})
+		}
+	}
+
+	c.mus.RLock()
+	defer c.mus.RUnlock()
+
+	for url, page := range c.sites {
+		if _, ok := declared[url]; !ok && url != "<root>" && page.Canonical == "" && c.inScope(url) {
+			c.addFinding(Finding{
+				Category: CategoryMissingFromSitemap,
+				Severity: SeverityInfo,
+				Url:      url,
+				Message:  "Crawled but not listed in the sitemap.xml",
+			})
+		}
+	}
+}
+
+// discovered reports whether the URL was linked from a crawled page and crawled, left uncrawled or redirected.
+// The broken pages are not known to it.
+func (c *Crawler) discovered(url string) bool {
+	if c.hasVisited(url) {
+		return true
+	}
+
+	c.muu.Lock()
+	_, uncrawled := c.uncrawled[url]
+	c.muu.Unlock()
+
+	c.muxr.Lock()
+	_, redirected := c.redirected[url]
+	_, external := c.redirects[url]
+	c.muxr.Unlock()
+
+	return uncrawled || redirected || external
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRobotsSitemapsAreParsed(t *testing.T) {
+	body := []byte("User-agent: *\nDisallow: /private\nSITEMAP: https://example.com/a.xml # main\n\nSitemap:https://example.com/b.xml.gz\nSitemap:\n")
+
+	if sitemaps := robotsSitemaps(body); !reflect.DeepEqual(sitemaps, []string{"https://example.com/a.xml", "https://example.com/b.xml.gz"}) {
+		t.Errorf("Unexpected sitemaps: %v\n", sitemaps)
+	}
+}
+
+func TestCrawlerComparesDeclaredSitemaps(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/listed\n%s/unlisted\n", server.URL, server.URL)
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nSitemap: %s/index.xml\n", server.URL)
+		case "/index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="%s"><sitemap><loc>%s/pages.xml.gz</loc></sitemap></sitemapindex>`, sitemapNamespace, server.URL)
+		case "/pages.xml.gz":
+			var b bytes.Buffer
+
+			gz := gzip.NewWriter(&b)
+			fmt.Fprintf(gz, `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="%s">`, sitemapNamespace)
+			for _, path := range []string{"/", "/listed", "/orphan", "/#top"} {
+				fmt.Fprintf(gz, "<url><loc>%s%s</loc></url>", server.URL, path)
+			}
+			fmt.Fprint(gz, "</urlset>")
+			gz.Close()
+
+			w.Write(b.Bytes())
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:     2,
+		MaxRetries:     1,
+		Downloader:     unpooledDownloader{},
+		Extractor:      lineExtractor{},
+		CompareSitemap: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Unexpected error: %s\n", err.Error())
+	}
+	<-done
+
+	findings := c.GetFindings()
+
+	if unreachable := findings.ByCategory(CategorySitemapUnreachable); len(unreachable) != 1 || unreachable[0].Url != server.URL+"/orphan" {
+		t.Errorf("Unexpected unreachable pages: %v\n", unreachable)
+	}
+
+	if missing := findings.ByCategory(CategoryMissingFromSitemap); len(missing) != 1 || missing[0].Url != server.URL+"/unlisted" {
+		t.Errorf("Unexpected pages missing from the sitemap: %v\n", missing)
+	}
+}
+
+func TestCrawlerSeedsFromSitemap(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/linked\n", server.URL)
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="%s"><sitemap><loc>%s/pages.xml.gz</loc></sitemap></sitemapindex>`, sitemapNamespace, server.URL)
+		case "/pages.xml.gz":
+			var b bytes.Buffer
+
+			gz := gzip.NewWriter(&b)
+			fmt.Fprintf(gz, `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="%s">`, sitemapNamespace)
+			for _, loc := range []string{server.URL + "/linked", server.URL + "/orphan", server.URL + "/private", "http://example.org/elsewhere"} {
+				fmt.Fprintf(gz, "<url><loc>%s</loc></url>", loc)
+			}
+			fmt.Fprint(gz, "</urlset>")
+			gz.Close()
+
+			w.Write(b.Bytes())
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Downloader:      unpooledDownloader{},
+		Extractor:       lineExtractor{},
+		SeedFromSitemap: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Unexpected error: %s\n", err.Error())
+	}
+	<-done
+
+	crawled := make(map[string]bool)
+	for _, page := range c.GetSiteMap().Pages() {
+		crawled[page.Url] = true
+	}
+
+	if len(crawled) != 3 || !crawled[server.URL+"/"] || !crawled[server.URL+"/linked"] || !crawled[server.URL+"/orphan"] {
+		t.Errorf("Unexpected pages crawled: %v\n", crawled)
+	}
+
+	if page, _ := c.GetSiteMap().Get(server.URL + "/orphan"); page == nil || page.Depth != 0 {
+		t.Errorf("Unexpected page of the sitemap: %v\n", page)
+	}
+
+	uncrawled := c.GetUncrawled()
+	if len(uncrawled) != 1 || uncrawled[0].Url != server.URL+"/private" || uncrawled[0].Reason != SkipRobots {
+		t.Errorf("Unexpected uncrawled pages: %v\n", uncrawled)
+	}
+}
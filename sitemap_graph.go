@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	neturl "net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GraphFormat defines the file format the link graph of the sitemap is exported in.
+type GraphFormat string
+
+const (
+	GraphDOT     GraphFormat = "dot"
+	GraphGEXF    GraphFormat = "gexf"
+	GraphGraphML GraphFormat = "graphml"
+)
+
+const (
+	gexfNamespace    = "http://gexf.net/1.3"
+	graphmlNamespace = "http://graphml.graphdrawing.org/xmlns"
+)
+
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string         `xml:"defaultedgetype,attr"`
+	Attributes      gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode     `xml:"nodes>node"`
+	Edges           []gexfEdge     `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	Id    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	Id     string         `xml:"id,attr"`
+	Label  string         `xml:"label,attr"`
+	Values []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	Id     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphmlDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlGraph struct {
+	Id          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// graphFormatOf returns the format of the graph file by the extension of its path, .gz aside
+func graphFormatOf(path string) (GraphFormat, error) {
+	switch ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz"))); ext {
+	case ".dot", ".gv":
+		return GraphDOT, nil
+	case ".gexf":
+		return GraphGEXF, nil
+	case ".graphml":
+		return GraphGraphML, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidGraph, ext)
+	}
+}
+
+// ExportGraph writes the crawled pages and the links between them to path as a directed graph, in the format
+// given by the extension of the path: .dot (or .gv) for Graphviz, .gexf for Gephi and .graphml for yEd.
+// The pages are the nodes, labelled by their URL and holding their title, depth and size, and every link between
+// crawled pages is an edge, not only the ones the pages were discovered through. Files are gzip compressed
+// if path ends with .gz
+func ExportGraph(s *SiteMap, path string) error {
+	format, err := graphFormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pages = s.Pages()
+		links = s.graphLinks()
+		ids   = make(map[string]int, len(pages))
+	)
+
+	for i, page := range pages {
+		ids[page.Url] = i
+	}
+
+	switch format {
+	case GraphGEXF:
+		return writeXML(path, gexfOf(pages, links, ids))
+	case GraphGraphML:
+		return writeXML(path, graphmlOf(pages, links, ids))
+	default:
+		return writeDOT(path, pages, links)
+	}
+}
+
+// graphLinks returns the URLs of the pages of the sitemap each page links to, sorted and each of them once
+func (s *SiteMap) graphLinks() map[string][]string {
+	links := s.outLinks()
+
+	for url, to := range links {
+		sort.Strings(to)
+
+		unique := to[:0]
+		for _, link := range to {
+			if _, ok := s.pages[link]; ok && (len(unique) == 0 || link != unique[len(unique)-1]) {
+				unique = append(unique, link)
+			}
+		}
+
+		links[url] = unique
+	}
+
+	return links
+}
+
+func gexfOf(pages []*Page, links map[string][]string, ids map[string]int) *gexfDocument {
+	graph := gexfGraph{
+		DefaultEdgeType: "directed",
+		Attributes: gexfAttributes{
+			Class: "node",
+			Attributes: []gexfAttribute{
+				{Id: "title", Title: "title", Type: "string"},
+				{Id: "depth", Title: "depth", Type: "integer"},
+				{Id: "size", Title: "size", Type: "integer"},
+			},
+		},
+		Nodes: make([]gexfNode, 0, len(pages)),
+		Edges: make([]gexfEdge, 0),
+	}
+
+	for _, page := range pages {
+		id := strconv.Itoa(ids[page.Url])
+
+		graph.Nodes = append(graph.Nodes, gexfNode{
+			Id:    id,
+			Label: page.Url,
+			Values: []gexfAttValue{
+				{For: "title", Value: page.Title},
+				{For: "depth", Value: strconv.Itoa(page.Depth)},
+				{For: "size", Value: strconv.Itoa(page.Size)},
+			},
+		})
+
+		for _, to := range links[page.Url] {
+			graph.Edges = append(graph.Edges, gexfEdge{
+				Id:     strconv.Itoa(len(graph.Edges)),
+				Source: id,
+				Target: strconv.Itoa(ids[to]),
+			})
+		}
+	}
+
+	return &gexfDocument{Xmlns: gexfNamespace, Version: "1.3", Graph: graph}
+}
+
+func graphmlOf(pages []*Page, links map[string][]string, ids map[string]int) *graphmlDocument {
+	graph := graphmlGraph{
+		Id:          "sitemap",
+		EdgeDefault: "directed",
+		Nodes:       make([]graphmlNode, 0, len(pages)),
+		Edges:       make([]graphmlEdge, 0),
+	}
+
+	for _, page := range pages {
+		id := fmt.Sprintf("n%d", ids[page.Url])
+
+		graph.Nodes = append(graph.Nodes, graphmlNode{
+			Id: id,
+			Data: []graphmlData{
+				{Key: "url", Value: page.Url},
+				{Key: "title", Value: page.Title},
+				{Key: "depth", Value: strconv.Itoa(page.Depth)},
+				{Key: "size", Value: strconv.Itoa(page.Size)},
+			},
+		})
+
+		for _, to := range links[page.Url] {
+			graph.Edges = append(graph.Edges, graphmlEdge{Source: id, Target: fmt.Sprintf("n%d", ids[to])})
+		}
+	}
+
+	return &graphmlDocument{
+		Xmlns: graphmlNamespace,
+		Keys: []graphmlKey{
+			{Id: "url", For: "node", Name: "url", Type: "string"},
+			{Id: "title", For: "node", Name: "title", Type: "string"},
+			{Id: "depth", For: "node", Name: "depth", Type: "int"},
+			{Id: "size", For: "node", Name: "size", Type: "int"},
+		},
+		Graph: graph,
+	}
+}
+
+// ExportMermaid writes the crawled pages and the links between them to path as a Mermaid flowchart, which renders
+// in Markdown documents and GitHub issues, wrapped in a mermaid code block if path ends with .md.
+// The pages are labelled by their URL, without the host if they all share one, and every link between crawled
+// pages is an arrow. Since large flowcharts are unreadable, ErrGraphTooLarge is returned without writing anything
+// if there are more than maxNodes pages (no limit if zero).
+func ExportMermaid(s *SiteMap, path string, maxNodes int) error {
+	if maxNodes > 0 && s.Len() > maxNodes {
+		return fmt.Errorf("%w: %d pages, at most %d", ErrGraphTooLarge, s.Len(), maxNodes)
+	}
+
+	var (
+		pages = s.Pages()
+		links = s.graphLinks()
+		ids   = make(map[string]int, len(pages))
+		hosts = make(map[string]struct{})
+		b     strings.Builder
+	)
+
+	for i, page := range pages {
+		ids[page.Url] = i
+
+		if u, err := neturl.Parse(page.Url); err == nil {
+			hosts[u.Host] = struct{}{}
+		}
+	}
+
+	markdown := strings.HasSuffix(path, ".md")
+	if markdown {
+		b.WriteString("```mermaid\n")
+	}
+
+	b.WriteString("flowchart LR\n")
+
+	for i, page := range pages {
+		fmt.Fprintf(&b, "  n%d[\"%s\"]\n", i, mermaidLabel(page.Url, len(hosts) == 1))
+	}
+
+	for _, page := range pages {
+		for _, to := range links[page.Url] {
+			fmt.Fprintf(&b, "  n%d --> n%d\n", ids[page.Url], ids[to])
+		}
+	}
+
+	if markdown {
+		b.WriteString("```\n")
+	}
+
+	return writeText(path, b.String())
+}
+
+// mermaidLabel returns the URL as the label of its node, without the scheme and, if short, without the host.
+// The quotes are escaped as entity codes.
+func mermaidLabel(url string, short bool) string {
+	label := url
+	if u, err := neturl.Parse(url); err == nil {
+		u.Scheme = ""
+		if short {
+			u.Host = ""
+		}
+
+		label = strings.TrimPrefix(u.String(), "//")
+	}
+
+	return strings.ReplaceAll(label, `"`, "#quot;")
+}
+
+func writeDOT(path string, pages []*Page, links map[string][]string) error {
+	var b strings.Builder
+
+	b.WriteString("digraph sitemap {\n")
+
+	for _, page := range pages {
+		fmt.Fprintf(&b, "  %s [title=%s, depth=%d, size=%d];\n", dotQuote(page.Url), dotQuote(page.Title), page.Depth, page.Size)
+	}
+
+	for _, page := range pages {
+		for _, to := range links[page.Url] {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(page.Url), dotQuote(to))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	return writeText(path, b.String())
+}
+
+func writeText(path, text string) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write([]byte(text)); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// dotQuote returns the string as a quoted DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graphSiteMap returns the sitemap of a linking to b and c and c linking to b, a linking to b recorded twice
+func graphSiteMap() *SiteMap {
+	a := &Page{Url: "http://example.com/", Title: "Home \"A\"", Size: 10}
+	b := &Page{Url: "http://example.com/b", Title: "B", Depth: 1}
+	c := &Page{Url: "http://example.com/c", Title: "C", Depth: 1}
+
+	a.LinksTo = []*Page{b, c}
+	b.LinkedFrom = []*Page{a, c}
+
+	return newSiteMapFrom(map[string]*Page{a.Url: a, b.Url: b, c.Url: c})
+}
+
+func TestExportGraphAsGEXF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.gexf")
+
+	if err := ExportGraph(graphSiteMap(), path); err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading graph fails with error: %s\n", err.Error())
+	}
+
+	var doc gexfDocument
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Parsing graph fails with error: %s\n", err.Error())
+	}
+
+	if len(doc.Graph.Nodes) != 3 || doc.Graph.Nodes[0].Label != "http://example.com/" || doc.Graph.Nodes[0].Values[0].Value != "Home \"A\"" {
+		t.Errorf("Unexpected nodes: %+v\n", doc.Graph.Nodes)
+	}
+
+	expected := []gexfEdge{
+		{Id: "0", Source: "0", Target: "1"},
+		{Id: "1", Source: "0", Target: "2"},
+		{Id: "2", Source: "2", Target: "1"},
+	}
+	if !reflect.DeepEqual(doc.Graph.Edges, expected) {
+		t.Errorf("Unexpected edges: %+v\n", doc.Graph.Edges)
+	}
+}
+
+func TestExportGraphAsGraphML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.graphml")
+
+	if err := ExportGraph(graphSiteMap(), path); err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading graph fails with error: %s\n", err.Error())
+	}
+
+	var doc graphmlDocument
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Parsing graph fails with error: %s\n", err.Error())
+	}
+
+	if len(doc.Keys) != 4 || len(doc.Graph.Nodes) != 3 || doc.Graph.Nodes[1].Data[0].Value != "http://example.com/b" {
+		t.Errorf("Unexpected nodes: %+v\n", doc.Graph.Nodes)
+	}
+
+	expected := []graphmlEdge{{Source: "n0", Target: "n1"}, {Source: "n0", Target: "n2"}, {Source: "n2", Target: "n1"}}
+	if !reflect.DeepEqual(doc.Graph.Edges, expected) {
+		t.Errorf("Unexpected edges: %+v\n", doc.Graph.Edges)
+	}
+}
+
+func TestExportGraphAsDOT(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.dot")
+
+	if err := ExportGraph(graphSiteMap(), path); err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading graph fails with error: %s\n", err.Error())
+	}
+
+	expected := `digraph sitemap {
+  "http://example.com/" [title="Home \"A\"", depth=0, size=10];
+  "http://example.com/b" [title="B", depth=1, size=0];
+  "http://example.com/c" [title="C", depth=1, size=0];
+  "http://example.com/" -> "http://example.com/b";
+  "http://example.com/" -> "http://example.com/c";
+  "http://example.com/c" -> "http://example.com/b";
+}
+`
+	if string(data) != expected {
+		t.Errorf("Unexpected graph: %s\n", data)
+	}
+}
+
+func TestExportGraphRejectsUnknownFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.svg")
+
+	if err := ExportGraph(graphSiteMap(), path); !errors.Is(err, ErrInvalidGraph) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Graph of unknown format written\n")
+	}
+}
+
+func TestExportMermaid(t *testing.T) {
+	dir := t.TempDir()
+
+	s := graphSiteMap()
+	if err := ExportMermaid(s, filepath.Join(dir, "graph.md"), 3); err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "graph.md"))
+	if err != nil {
+		t.Fatalf("Reading flowchart fails with error: %s\n", err.Error())
+	}
+
+	expected := "```mermaid\nflowchart LR\n" +
+		"  n0[\"/\"]\n  n1[\"/b\"]\n  n2[\"/c\"]\n" +
+		"  n0 --> n1\n  n0 --> n2\n  n2 --> n1\n```\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected flowchart: %s\n", data)
+	}
+
+	// The pages of several hosts keep the hosts in their labels
+	other := &Page{Url: "https://other.com/x?q=\"y\""}
+	s.pages[other.Url] = other
+
+	path := filepath.Join(dir, "graph.mmd")
+	if err = ExportMermaid(s, path, 0); err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatalf("Reading flowchart fails with error: %s\n", err.Error())
+	}
+
+	if !strings.HasPrefix(string(data), "flowchart LR\n  n0[\"example.com/\"]\n") || !strings.Contains(string(data), "n3[\"other.com/x?q=#quot;y#quot;\"]") {
+		t.Errorf("Unexpected flowchart: %s\n", data)
+	}
+}
+
+func TestExportMermaidRejectsLargeCrawls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.mmd")
+
+	if err := ExportMermaid(graphSiteMap(), path, 2); !errors.Is(err, ErrGraphTooLarge) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Flowchart of a large crawl written\n")
+	}
+}
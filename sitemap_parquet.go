@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetPage is a row of the pages table of the Parquet export. The optional columns are pointers, nil (null)
+// for the pages lacking them. Extra holds the extra fields as a plain JSON string, as the versions of parquet-go
+// do not write the optional columns annotated as JSON alike.
+type parquetPage struct {
+	Url          string  `parquet:"url"`
+	Title        string  `parquet:"title"`
+	CrawledAt    int64   `parquet:"crawled_at,timestamp(millisecond)"`
+	Depth        int32   `parquet:"depth"`
+	ETag         *string `parquet:"etag,optional"`
+	LastModified *string `parquet:"last_modified,optional"`
+	Protocol     *string `parquet:"protocol,optional"`
+	Encoding     *string `parquet:"content_encoding,optional"`
+	ContentHash  *string `parquet:"content_hash,optional"`
+	SimHash      *string `parquet:"simhash,optional"`
+	Assets       int32   `parquet:"assets"`
+	Size         int64   `parquet:"size"`
+	Weight       int64   `parquet:"weight"`
+	InlineScript int64   `parquet:"inline_script_size"`
+	InlineStyle  int64   `parquet:"inline_style_size"`
+	LinksTo      int32   `parquet:"links_to"`
+	LinkedFrom   int32   `parquet:"linked_from"`
+	External     int32   `parquet:"external_links"`
+	Extra        *string `parquet:"extra,optional"`
+}
+
+// parquetEdge is a row of the edges table of the Parquet export, i.e. a single link between two pages.
+type parquetEdge struct {
+	From string `parquet:"from"`
+	To   string `parquet:"to"`
+}
+
+// ExportParquet writes the crawled pages and the links between them as pages.parquet and edges.parquet
+// in the directory dir, so that they can be loaded directly into analytics tools.
+// The edges hold every link between crawled pages, not only the ones the pages were discovered through.
+func ExportParquet(s *SiteMap, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var (
+		links   = s.graphLinks()
+		inbound = make(map[string]int32)
+		pages   = make([]parquetPage, 0, s.Len())
+		edges   = make([]parquetEdge, 0)
+	)
+
+	for _, to := range links {
+		for _, url := range to {
+			inbound[url]++
+		}
+	}
+
+	for _, page := range s.Pages() {
+		pages = append(pages, parquetPage{
+			Url:          page.Url,
+			Title:        page.Title,
+			CrawledAt:    page.CrawledAt.UnixMilli(),
+			Depth:        int32(page.Depth),
+			ETag:         optionalColumn(page.ETag),
+			LastModified: optionalColumn(page.LastModified),
+			Protocol:     optionalColumn(page.Protocol),
+			Encoding:     optionalColumn(page.Encoding),
+			ContentHash:  optionalColumn(page.ContentHash),
+			SimHash:      optionalColumn(page.SimHash),
+			Assets:       int32(len(page.Assets)),
+			Size:         int64(page.Size),
+			Weight:       int64(page.Weight()),
+			InlineScript: int64(page.InlineScriptSize),
+			InlineStyle:  int64(page.InlineStyleSize),
+			LinksTo:      int32(len(links[page.Url])),
+			LinkedFrom:   inbound[page.Url],
+			External:     int32(len(page.ExternalLinks)),
+			Extra:        optionalColumn(extraJSON(page)),
+		})
+
+		for _, to := range links[page.Url] {
+			edges = append(edges, parquetEdge{From: page.Url, To: to})
+		}
+	}
+
+	if err := writeParquet(filepath.Join(dir, "pages.parquet"), pages); err != nil {
+		return err
+	}
+
+	return writeParquet(filepath.Join(dir, "edges.parquet"), edges)
+}
+
+// optionalColumn returns the value of an optional column, nil if it is empty
+func optionalColumn(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func writeParquet[T any](path string, rows []T) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := parquet.NewGenericWriter[T](f)
+
+	if _, err = w.Write(rows); err != nil {
+		w.Close()
+		f.Close()
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// readParquet reads the rows of the Parquet file under path
+func readParquet[T any](t *testing.T, path string) []T {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Opening %s fails with error: %s\n", path, err.Error())
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Opening %s fails with error: %s\n", path, err.Error())
+	}
+
+	rows, err := parquet.Read[T](f, info.Size())
+	if err != nil {
+		t.Fatalf("Reading %s fails with error: %s\n", path, err.Error())
+	}
+
+	return rows
+}
+
+func TestSiteMapIsExportedAsParquet(t *testing.T) {
+	var (
+		now = time.Now().Truncate(time.Millisecond)
+		a   = &Page{Url: "http://example.com/", Title: "A", CrawledAt: now, Size: 10, ETag: `"v1"`}
+		b   = &Page{Url: "http://example.com/b", Title: "B", CrawledAt: now, Depth: 1, Assets: []*Asset{{Url: "http://example.com/a.js", Size: 5}}}
+		c   = &Page{Url: "http://example.com/c", Title: "C", CrawledAt: now, Depth: 1}
+	)
+
+	a.LinksTo = []*Page{b, c}
+	b.LinkedFrom = []*Page{a, c}
+	b.SetExtra("score", 1)
+
+	dir := filepath.Join(t.TempDir(), "export")
+	if err := ExportParquet(newSiteMapFrom(map[string]*Page{a.Url: a, b.Url: b, c.Url: c}), dir); err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	pages := readParquet[parquetPage](t, filepath.Join(dir, "pages.parquet"))
+	if len(pages) != 3 {
+		t.Fatalf("Unexpected pages: %+v\n", pages)
+	}
+
+	expected := parquetPage{
+		Url:        b.Url,
+		Title:      "B",
+		CrawledAt:  now.UnixMilli(),
+		Depth:      1,
+		Assets:     1,
+		Weight:     5,
+		LinkedFrom: 2,
+		Extra:      optionalColumn(`{"score":1}`),
+	}
+	if !reflect.DeepEqual(pages[1], expected) {
+		t.Errorf("Unexpected page: %+v\n", pages[1])
+	}
+
+	if pages[0].Url != a.Url || pages[0].ETag == nil || *pages[0].ETag != `"v1"` || pages[0].Extra != nil || pages[0].LinksTo != 2 || pages[0].Size != 10 {
+		t.Errorf("Unexpected page: %+v\n", pages[0])
+	}
+
+	edges := readParquet[parquetEdge](t, filepath.Join(dir, "edges.parquet"))
+
+	links := make(map[parquetEdge]int)
+	for _, edge := range edges {
+		links[edge]++
+	}
+
+	if expected := map[parquetEdge]int{{a.Url, b.Url}: 1, {a.Url, c.Url}: 1, {c.Url, b.Url}: 1}; len(edges) != 3 || !reflect.DeepEqual(links, expected) {
+		t.Errorf("Unexpected edges: %+v\n", edges)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSiteMapExposesPages(t *testing.T) {
+	var (
+		a = &Page{Url: "http://example.com/", Title: "A"}
+		b = &Page{Url: "http://example.com/b", Title: "B"}
+	)
+
+	a.LinksTo = []*Page{b}
+	b.LinkedFrom = []*Page{a}
+
+	s := newSiteMapFrom(map[string]*Page{a.Url: a, b.Url: b})
+
+	if s.Len() != 2 {
+		t.Errorf("Unexpected sitemap length: %d\n", s.Len())
+	}
+
+	if p, ok := s.Get(b.Url); !ok || p != b {
+		t.Errorf("Page not found: %s\n", b.Url)
+	}
+
+	if roots := s.Roots(); len(roots) != 1 || roots[0] != a {
+		t.Errorf("Unexpected roots: %v\n", roots)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshalling fails with error: %s\n", err.Error())
+	}
+
+	var pages []*pageJSON
+	if err = json.Unmarshal(data, &pages); err != nil {
+		t.Fatalf("Unmarshalling fails with error: %s\n", err.Error())
+	}
+
+	if len(pages) != 2 || pages[0].LinksTo[0] != b.Url || pages[1].LinkedFrom[0] != a.Url {
+		t.Errorf("Unexpected JSON: %s\n", data)
+	}
+}
+
+func TestSiteMapMergeResolvesConflicts(t *testing.T) {
+	const ADDRESS = "http://example.com/"
+
+	var (
+		now    = time.Now()
+		merged = func(strategy MergeStrategy) *SiteMap {
+			s, other := NewSiteMap(), NewSiteMap()
+			s.pages[ADDRESS] = &Page{Url: ADDRESS, Title: "Old", CrawledAt: now.Add(-time.Hour)}
+			other.pages[ADDRESS] = &Page{Url: ADDRESS, Title: "New", CrawledAt: now}
+			other.pages[ADDRESS+"b"] = &Page{Url: ADDRESS + "b", CrawledAt: now}
+
+			s.Merge(other, strategy)
+			return s
+		}
+	)
+
+	s := merged(MergeKeepExisting)
+	if p, _ := s.Get(ADDRESS); s.Len() != 2 || p.Title != "Old" {
+		t.Errorf("Existing page overwritten: %s\n", p.Title)
+	}
+
+	s = merged(MergeLatestWins)
+	if p, _ := s.Get(ADDRESS); p.Title != "New" || len(p.Versions) != 0 {
+		t.Errorf("Latest page not kept: %s\n", p.Title)
+	}
+
+	s = merged(MergeKeepBoth)
+	if p, _ := s.Get(ADDRESS); p.Title != "New" || len(p.Versions) != 1 || p.Versions[0].Title != "Old" {
+		t.Errorf("Both pages not kept: %s\n", p.Title)
+	}
+}
+
+func TestSiteMapMergeRelinksPages(t *testing.T) {
+	var (
+		now   = time.Now()
+		s     = NewSiteMap()
+		other = NewSiteMap()
+	)
+
+	// a links to b in both sitemaps, the newer b linking to c which only the other sitemap holds
+	a := &Page{Url: "http://example.com/", CrawledAt: now.Add(-time.Hour)}
+	b := &Page{Url: "http://example.com/b", CrawledAt: now.Add(-time.Hour)}
+	a.LinksTo, b.LinkedFrom = []*Page{b}, []*Page{a}
+	s.pages[a.Url], s.pages[b.Url] = a, b
+
+	newA := &Page{Url: a.Url, CrawledAt: now}
+	newB := &Page{Url: b.Url, Title: "New", CrawledAt: now}
+	c := &Page{Url: "http://example.com/c", CrawledAt: now}
+	newA.LinksTo, newB.LinkedFrom = []*Page{newB}, []*Page{newA}
+	newB.LinksTo, c.LinkedFrom = []*Page{c}, []*Page{newB}
+	other.pages[newA.Url], other.pages[newB.Url], other.pages[c.Url] = newA, newB, c
+
+	s.Merge(other, MergeKeepExisting)
+
+	// The kept pages link to the merged ones and the other way round, only through the pages of the sitemap
+	for _, page := range s.Pages() {
+		for _, linked := range append(append([]*Page{}, page.LinksTo...), page.LinkedFrom...) {
+			if p, _ := s.Get(linked.Url); p != linked {
+				t.Errorf("Link of %s to %s outside of the sitemap\n", page.Url, linked.Url)
+			}
+		}
+	}
+
+	if p, _ := s.Get(c.Url); len(p.LinkedFrom) != 1 || p.LinkedFrom[0] != b {
+		t.Errorf("Merged page not relinked: %v\n", p.LinkedFrom)
+	}
+}
+
+func TestSiteMapMergeKeepsLinksToReplacedPages(t *testing.T) {
+	now := time.Now()
+
+	for _, strategy := range []MergeStrategy{MergeLatestWins, MergeKeepBoth} {
+		var (
+			s     = NewSiteMap()
+			other = NewSiteMap()
+		)
+
+		// Only the sitemap holds a, linking to the b which the other sitemap holds a newer version of
+		a := &Page{Url: "http://example.com/", CrawledAt: now.Add(-time.Hour)}
+		b := &Page{Url: "http://example.com/b", CrawledAt: now.Add(-time.Hour)}
+		a.LinksTo, b.LinkedFrom = []*Page{b}, []*Page{a}
+		s.pages[a.Url], s.pages[b.Url] = a, b
+
+		newB := &Page{Url: b.Url, Title: "New", CrawledAt: now}
+		other.pages[newB.Url] = newB
+
+		s.Merge(other, strategy)
+
+		if p, _ := s.Get(b.Url); p != newB || len(p.LinkedFrom) != 1 || p.LinkedFrom[0] != a || a.LinksTo[0] != newB {
+			t.Errorf("Replaced page not relinked with %d: %v\n", strategy, p.LinkedFrom)
+		}
+
+		if roots := s.Roots(); len(roots) != 1 || roots[0] != a {
+			t.Errorf("Unexpected roots with %d: %v\n", strategy, urlsOf(roots))
+		}
+	}
+}
+
+func TestSiteMapKeepsVersionsWhenRestored(t *testing.T) {
+	const ADDRESS = "http://example.com/"
+
+	var (
+		now   = time.Now().UTC().Truncate(time.Second)
+		s     = NewSiteMap()
+		other = NewSiteMap()
+	)
+
+	s.pages[ADDRESS] = &Page{Url: ADDRESS, Title: "Old", ContentHash: "1", CrawledAt: now.Add(-time.Hour)}
+	other.pages[ADDRESS] = &Page{Url: ADDRESS, Title: "New", ContentHash: "2", CrawledAt: now}
+
+	s.Merge(other, MergeKeepBoth)
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshalling fails with error: %s\n", err.Error())
+	}
+
+	restored := NewSiteMap()
+	if err = json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshalling fails with error: %s\n", err.Error())
+	}
+
+	p, _ := restored.Get(ADDRESS)
+	if p == nil || p.Title != "New" || len(p.Versions) != 1 {
+		t.Fatalf("Unexpected restored page: %+v\n", p)
+	}
+
+	if v := p.Versions[0]; v.Title != "Old" || v.ContentHash != "1" || !v.CrawledAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Unexpected restored version: %+v\n", v)
+	}
+
+	// The sitemaps written with the timestamps of the versions only are still read
+	legacy := `[{"url":"http://example.com/","title":"New","crawled_at":"2024-01-01T00:00:00Z","depth":0,"links_to":[],"linked_from":[],"assets":[],"versions":["2023-01-01T00:00:00Z"]}]`
+	if err = json.Unmarshal([]byte(legacy), restored); err != nil {
+		t.Fatalf("Unmarshalling fails with error: %s\n", err.Error())
+	}
+
+	if p, _ := restored.Get(ADDRESS); p == nil || len(p.Versions) != 0 {
+		t.Errorf("Unexpected restored page: %+v\n", p)
+	}
+}
+
+func TestSiteMapIsRestoredFromJSON(t *testing.T) {
+	var (
+		a = &Page{Url: "http://example.com/", ETag: `"v1"`}
+		b = &Page{Url: "http://example.com/b"}
+		c = &Page{Url: "http://example.com/c"}
+	)
+
+	a.LinksTo = []*Page{b, c}
+	b.LinkedFrom = []*Page{c}
+
+	data, err := json.Marshal(newSiteMapFrom(map[string]*Page{a.Url: a, b.Url: b, c.Url: c}))
+	if err != nil {
+		t.Fatalf("Marshalling fails with error: %s\n", err.Error())
+	}
+
+	s := NewSiteMap()
+	if err = json.Unmarshal(data, s); err != nil {
+		t.Fatalf("Unmarshalling fails with error: %s\n", err.Error())
+	}
+
+	if p, ok := s.Get(a.Url); !ok || p.ETag != a.ETag || len(p.LinksTo) != 2 {
+		t.Errorf("Page not restored: %s\n", a.Url)
+	}
+
+	if links := s.outLinks(); len(links[a.Url]) != 2 || len(links[c.Url]) != 1 || links[c.Url][0] != b.Url {
+		t.Errorf("Unexpected links: %v\n", links)
+	}
+}
+
+func TestPageExtraIsExported(t *testing.T) {
+	page := &Page{Url: "http://example.com/"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			page.SetExtra(fmt.Sprintf("key%d", i), i)
+		}(i)
+	}
+	wg.Wait()
+
+	if v, ok := page.GetExtra("key3"); !ok || v != 3 {
+		t.Errorf("Unexpected extra value: %v\n", v)
+	}
+
+	data, err := json.Marshal(newSiteMapFrom(map[string]*Page{page.Url: page}))
+	if err != nil {
+		t.Fatalf("Marshalling fails with error: %s\n", err.Error())
+	}
+
+	s := NewSiteMap()
+	if err = json.Unmarshal(data, s); err != nil {
+		t.Fatalf("Unmarshalling fails with error: %s\n", err.Error())
+	}
+
+	if p, _ := s.Get(page.Url); len(p.Extra) != 10 || p.Extra["key3"] != float64(3) {
+		t.Errorf("Extra not restored: %v\n", p.Extra)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Limits of a single sitemap file as defined by the sitemaps.org protocol.
+// Exports exceeding either of them are split into multiple files referenced by a sitemap index.
+var (
+	sitemapMaxURLs  = 50000
+	sitemapMaxBytes = 50 * 1024 * 1024
+)
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type xmlURLSet struct {
+	XMLName xml.Name  `xml:"urlset"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	URLs    []*xmlURL `xml:"url"`
+}
+
+type xmlURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type xmlSitemapIndex struct {
+	XMLName  xml.Name      `xml:"sitemapindex"`
+	Xmlns    string        `xml:"xmlns,attr"`
+	Sitemaps []*xmlSitemap `xml:"sitemap"`
+}
+
+type xmlSitemap struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// ExportSitemapXML writes the crawled pages to path in the sitemap.xml format. If the pages do not fit
+// in a single file, they are written to numbered files next to path (e.g. sitemap-1.xml) and path holds
+// the sitemap index referencing them by baseURL, i.e. the URL of the directory the files are served from.
+// The paths of all written files are returned, the index (or the only sitemap) first.
+// Files are gzip compressed if path ends with .gz
+func ExportSitemapXML(s *SiteMap, path, baseURL string) ([]string, error) {
+	chunks := chunkSitemapURLs(s.Pages())
+
+	if len(chunks) <= 1 {
+		set := &xmlURLSet{Xmlns: sitemapNamespace}
+		if len(chunks) == 1 {
+			set.URLs = chunks[0]
+		}
+
+		return []string{path}, writeXML(path, set)
+	}
+
+	var (
+		gz    = filepath.Ext(path) == ".gz"
+		ext   = filepath.Ext(strings.TrimSuffix(path, ".gz"))
+		base  = strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ext)
+		now   = time.Now().UTC().Format(time.RFC3339)
+		index = &xmlSitemapIndex{Xmlns: sitemapNamespace}
+		paths = []string{path}
+	)
+
+	for i, chunk := range chunks {
+		name := fmt.Sprintf("%s-%d%s", base, i+1, ext)
+		if gz {
+			name += ".gz"
+		}
+
+		if err := writeXML(name, &xmlURLSet{Xmlns: sitemapNamespace, URLs: chunk}); err != nil {
+			return nil, err
+		}
+
+		index.Sitemaps = append(index.Sitemaps, &xmlSitemap{
+			Loc:     strings.TrimSuffix(baseURL, "/") + "/" + filepath.Base(name),
+			LastMod: now,
+		})
+
+		paths = append(paths, name)
+	}
+
+	return paths, writeXML(path, index)
+}
+
+// chunkSitemapURLs splits the pages into chunks respecting the limits of a single sitemap file.
+func chunkSitemapURLs(pages []*Page) [][]*xmlURL {
+	var (
+		chunks = make([][]*xmlURL, 0)
+		chunk  []*xmlURL
+		size   int
+	)
+
+	// Size of the XML declaration and the enclosing urlset element
+	const overhead = 128
+
+	for _, page := range pages {
+		u := &xmlURL{Loc: page.Url}
+		if !page.CrawledAt.IsZero() {
+			u.LastMod = page.CrawledAt.UTC().Format(time.RFC3339)
+		}
+
+		entry, err := xml.Marshal(u)
+		if err != nil {
+			continue
+		}
+
+		if len(chunk) > 0 && (len(chunk) == sitemapMaxURLs || overhead+size+len(entry) > sitemapMaxBytes) {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+
+		chunk = append(chunk, u)
+		size += len(entry)
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+func writeXML(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(append([]byte(xml.Header), data...)); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSitemapXMLIsPaginated(t *testing.T) {
+	defer func(max int) { sitemapMaxURLs = max }(sitemapMaxURLs)
+	sitemapMaxURLs = 2
+
+	var (
+		dir   = t.TempDir()
+		path  = filepath.Join(dir, "sitemap.xml")
+		pages = make(map[string]*Page)
+	)
+
+	for i := 0; i < 5; i++ {
+		url := fmt.Sprintf("http://example.com/%d", i)
+		pages[url] = &Page{Url: url}
+	}
+
+	paths, err := ExportSitemapXML(newSiteMapFrom(pages), path, "http://example.com/")
+	if err != nil {
+		t.Fatalf("Export fails with error: %s\n", err.Error())
+	}
+
+	if len(paths) != 4 {
+		t.Fatalf("Unexpected number of files: %d\n", len(paths))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading index fails with error: %s\n", err.Error())
+	}
+
+	var index xmlSitemapIndex
+	if err = xml.Unmarshal(data, &index); err != nil {
+		t.Fatalf("Parsing index fails with error: %s\n", err.Error())
+	}
+
+	if len(index.Sitemaps) != 3 || index.Sitemaps[2].Loc != "http://example.com/sitemap-3.xml" {
+		t.Errorf("Unexpected index: %+v\n", index.Sitemaps)
+	}
+
+	if data, err = os.ReadFile(paths[3]); err != nil {
+		t.Fatalf("Reading sitemap fails with error: %s\n", err.Error())
+	}
+
+	var set xmlURLSet
+	if err = xml.Unmarshal(data, &set); err != nil {
+		t.Fatalf("Parsing sitemap fails with error: %s\n", err.Error())
+	}
+
+	if len(set.URLs) != 1 || set.URLs[0].Loc != "http://example.com/4" {
+		t.Errorf("Unexpected sitemap: %+v\n", set.URLs)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import "time"
+
+// Stats struct represents the progress of a crawl. Queued is the number of URLs waiting in the frontier,
+// Fetched the number of pages downloaded, Bytes the number of bytes of responses read, Errors the number
+// of errors reported and Retries the number of downloads retried. Elapsed is the time since the crawl started,
+// up to when it finished, and PagesPerSecond the average number of pages fetched per second meanwhile.
+// Skipped is the number of references skipped by the extractors by category, if they implement SkipCounter.
+// Busy is the number of fetchers and workers busy with a URL at the moment, Crawler.Workers telling which.
+type Stats struct {
+	Queued         int                `json:"queued"`
+	Busy           int                `json:"busy"`
+	Fetched        int                `json:"fetched"`
+	Bytes          int64              `json:"bytes"`
+	Errors         int                `json:"errors"`
+	Retries        int                `json:"retries"`
+	Elapsed        time.Duration      `json:"elapsed"`
+	PagesPerSecond float64            `json:"pages_per_second"`
+	Skipped        map[SkippedRef]int `json:"skipped,omitempty"`
+}
+
+// Stats returns the progress of the crawl so far. It is safe to call while the crawl is running.
+func (c *Crawler) Stats() Stats {
+	var s Stats
+
+	if c.frontier != nil {
+		s.Queued = c.frontier.Len()
+	}
+
+	c.mustat.Lock()
+	s.Fetched, s.Retries = c.fetched, c.retried
+	startedAt, finishedAt := c.startedAt, c.finishedAt
+	c.mustat.Unlock()
+
+	c.mub.Lock()
+	s.Bytes = c.bytesRead
+	c.mub.Unlock()
+
+	c.mutask.Lock()
+	s.Busy = len(c.tasks)
+	c.mutask.Unlock()
+
+	for _, stat := range c.GetErrorSummary() {
+		s.Errors += stat.Count
+	}
+
+	if !startedAt.IsZero() {
+		if finishedAt.IsZero() {
+			finishedAt = time.Now()
+		}
+
+		s.Elapsed = finishedAt.Sub(startedAt)
+	}
+
+	if s.Elapsed > 0 {
+		s.PagesPerSecond = float64(s.Fetched) / s.Elapsed.Seconds()
+	}
+
+	s.Skipped = c.skipped()
+
+	return s
+}
+
+// skipped sums the references skipped by the extractors, nil if none of them counts them
+func (c *Crawler) skipped() map[SkippedRef]int {
+	extractors := []Extractor{c.extractor}
+
+	c.muext.Lock()
+	for _, e := range c.extractors {
+		if e != c.extractor {
+			extractors = append(extractors, e)
+		}
+	}
+	c.muext.Unlock()
+
+	var skipped map[SkippedRef]int
+	for _, e := range extractors {
+		counter, ok := e.(SkipCounter)
+		if !ok {
+			continue
+		}
+
+		if skipped == nil {
+			skipped = make(map[SkippedRef]int)
+		}
+
+		for category, n := range counter.Skipped() {
+			skipped[category] += n
+		}
+	}
+
+	return skipped
+}
+
+func (c *Crawler) countFetched() {
+	c.mustat.Lock()
+	c.fetched++
+	c.mustat.Unlock()
+}
+
+func (c *Crawler) countRetry() {
+	c.mustat.Lock()
+	c.retried++
+	c.mustat.Unlock()
+}
+
+// reportStats passes the stats to the callback every interval until the stop channel is closed
+func (c *Crawler) reportStats(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.onStats(c.Stats())
+		case <-stop:
+			return
+		}
+	}
+}
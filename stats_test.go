@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawlerReportsStats(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/b\n%s/broken\n", server.URL, server.URL, server.URL)
+		case "/a", "/b":
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprint(w, "\n")
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mu    sync.Mutex
+		stats []Stats
+	)
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:    2,
+		MaxRetries:    1,
+		Downloader:    unpooledDownloader{},
+		Extractor:     lineExtractor{},
+		IgnoreRobots:  true,
+		StatsInterval: 5 * time.Millisecond,
+		OnStats: func(s Stats) {
+			mu.Lock()
+			stats = append(stats, s)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	if s := c.Stats(); !reflect.DeepEqual(s, Stats{}) {
+		t.Errorf("Unexpected stats before the crawl: %v\n", s)
+	}
+
+	done, errs := c.Crawl()
+	for range errs {
+	}
+	<-done
+
+	if len(stats) < 2 {
+		t.Fatalf("Stats not reported periodically: %v\n", stats)
+	}
+
+	final := stats[len(stats)-1]
+	if !reflect.DeepEqual(final, c.Stats()) {
+		t.Errorf("Last stats reported differ from the final ones: %v\n", final)
+	}
+
+	expected := int64(len(fmt.Sprintf("%s/a\n%s/b\n%s/broken\n", server.URL, server.URL, server.URL)) + 2)
+	if final.Queued != 0 || final.Fetched != 3 || final.Bytes != expected || final.Errors != 2 || final.Retries != 1 {
+		t.Errorf("Unexpected final stats: %+v\n", final)
+	}
+
+	if final.Elapsed < 20*time.Millisecond || final.PagesPerSecond <= 0 {
+		t.Errorf("Unexpected rate: %+v\n", final)
+	}
+}
+
+func TestCrawlerStatsCountSkippedReferences(t *testing.T) {
+	c, err := NewCrawlerWithSeeds([]string{"http://example.com/", "http://example.org/"}, &Options{MaxWorkers: 1})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	c.extractorFor("http://example.com/").(*defaultExtractor).skip("#top")
+	c.extractorFor("http://example.org/").(*defaultExtractor).skip("javascript:void(0)")
+	c.extractorFor("http://example.org/").(*defaultExtractor).skip("#top")
+
+	expected := map[SkippedRef]int{SkippedFragment: 2, SkippedJavascript: 1}
+	if skipped := c.Stats().Skipped; !reflect.DeepEqual(skipped, expected) {
+		t.Errorf("Unexpected skipped references: %v\n", skipped)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+)
+
+// SuppressionsSchemaVersion is the version of the JSON representation of suppressions.
+const SuppressionsSchemaVersion = 1
+
+// Suppression struct represents a known or accepted issue, the findings of Category whose URL matches
+// the Url pattern are left out of the reports. The pattern is a glob of the path and query of the URL
+// or a regular expression prefixed with re:, as the include and exclude patterns of the crawl.
+// Reason documents why the issue is accepted.
+type Suppression struct {
+	Category FindingCategory `json:"category"`
+	Url      string          `json:"url"`
+	Reason   string          `json:"reason,omitempty"`
+}
+
+// Suppressions is a list of suppressions, usually read from a file kept along with the crawl configuration.
+type Suppressions []Suppression
+
+// ReadSuppressions reads the suppressions from the JSON file under path, checking that their patterns are valid.
+func ReadSuppressions(path string) (Suppressions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Suppressions
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	for _, suppression := range s {
+		if _, err = compilePatterns([]string{suppression.Url}); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// SuppressionsFrom returns the suppressions of exactly the given findings, e.g. to accept all current issues.
+func SuppressionsFrom(f Findings) Suppressions {
+	return Suppressions(nil).Update(f)
+}
+
+// Update returns the suppressions still matching any of the findings, followed by the suppressions of exactly
+// the findings none of them matches, so that stale suppressions are dropped and new issues are accepted.
+func (s Suppressions) Update(f Findings) Suppressions {
+	var (
+		updated = make(Suppressions, 0, len(s))
+		matched = make([]bool, len(f))
+		added   = make(map[Suppression]bool)
+	)
+
+	for _, suppression := range s {
+		kept := false
+		for i := range f {
+			if suppression.matches(&f[i]) {
+				matched[i], kept = true, true
+			}
+		}
+
+		if kept {
+			updated = append(updated, suppression)
+		}
+	}
+
+	for i, finding := range f {
+		if matched[i] {
+			continue
+		}
+
+		suppression := Suppression{
+			Category: finding.Category,
+			Url:      regexPrefix + "^" + regexp.QuoteMeta(finding.Url) + "$",
+		}
+
+		if !added[suppression] {
+			updated = append(updated, suppression)
+			added[suppression] = true
+		}
+	}
+
+	return updated
+}
+
+// matches reports whether the suppression covers the finding, a suppression with an invalid pattern covers none
+func (s *Suppression) matches(finding *Finding) bool {
+	if s.Category != finding.Category {
+		return false
+	}
+
+	patterns, err := compilePatterns([]string{s.Url})
+	if err != nil {
+		return false
+	}
+
+	return matchesAny(patterns, finding.Url)
+}
+
+// MarshalJSON wraps the suppressions in an object carrying the schema version.
+func (s Suppressions) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SchemaVersion int           `json:"schema_version"`
+		Suppressions  []Suppression `json:"suppressions"`
+	}{
+		SchemaVersion: SuppressionsSchemaVersion,
+		Suppressions:  []Suppression(s),
+	})
+}
+
+func (s *Suppressions) UnmarshalJSON(data []byte) error {
+	var file struct {
+		SchemaVersion int           `json:"schema_version"`
+		Suppressions  []Suppression `json:"suppressions"`
+	}
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	if file.SchemaVersion > SuppressionsSchemaVersion {
+		return ErrUnsupportedSchema
+	}
+
+	*s = Suppressions(file.Suppressions)
+	return nil
+}
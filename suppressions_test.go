@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindingsSuppress(t *testing.T) {
+	f := Findings{
+		{Category: CategoryBrokenAsset, Url: "http://example.com/legacy/a.js"},
+		{Category: CategoryBrokenAsset, Url: "http://example.com/app.js"},
+		{Category: CategoryBrokenPage, Url: "http://example.com/legacy/page"},
+		{Category: CategoryUnsafeTarget, Url: "https://other.com/"},
+	}
+
+	s := Suppressions{
+		{Category: CategoryBrokenAsset, Url: "/legacy/*"},
+		{Category: CategoryUnsafeTarget, Url: "re:^https://other\\.com/"},
+	}
+
+	kept := f.Suppress(s)
+	if len(kept) != 2 || kept[0].Url != "http://example.com/app.js" || kept[1].Url != "http://example.com/legacy/page" {
+		t.Errorf("Unexpected findings kept: %v\n", kept)
+	}
+}
+
+func TestSuppressionsUpdate(t *testing.T) {
+	f := Findings{
+		{Category: CategoryBrokenAsset, Url: "http://example.com/legacy/a.js"},
+		{Category: CategoryBrokenAsset, Url: "http://example.com/app.js?v=1"},
+		{Category: CategoryBrokenAsset, Url: "http://example.com/app.js?v=1"},
+	}
+
+	s := Suppressions{
+		{Category: CategoryBrokenAsset, Url: "/legacy/*", Reason: "Removed soon"},
+		{Category: CategoryBrokenPage, Url: "/stale"},
+	}
+
+	updated := s.Update(f)
+	if len(updated) != 2 || updated[0] != s[0] {
+		t.Fatalf("Unexpected updated suppressions: %v\n", updated)
+	}
+
+	if updated[1].Url != `re:^http://example\.com/app\.js\?v=1$` {
+		t.Errorf("Unexpected suppression of new finding: %v\n", updated[1])
+	}
+
+	if kept := f.Suppress(updated); len(kept) != 0 {
+		t.Errorf("Findings not suppressed after update: %v\n", kept)
+	}
+
+	if kept := (Findings{{Category: CategoryBrokenAsset, Url: "http://example.com/app.js?v=12"}}).Suppress(updated); len(kept) != 1 {
+		t.Errorf("Generated suppression is not exact\n")
+	}
+}
+
+func TestReadSuppressions(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	data, _ := json.Marshal(Suppressions{{Category: CategorySlowPage, Url: "/search*"}})
+	os.WriteFile(valid, data, 0644)
+
+	if s, err := ReadSuppressions(valid); err != nil || len(s) != 1 || s[0].Url != "/search*" {
+		t.Errorf("Unexpected suppressions read: %v, %v\n", s, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"schema_version": 1, "suppressions": [{"category": "slow-page", "url": "re:("}]}`), 0644)
+
+	if _, err := ReadSuppressions(invalid); err == nil {
+		t.Errorf("Invalid pattern accepted\n")
+	}
+
+	future := filepath.Join(dir, "future.json")
+	os.WriteFile(future, []byte(`{"schema_version": 2, "suppressions": []}`), 0644)
+
+	if _, err := ReadSuppressions(future); err != ErrUnsupportedSchema {
+		t.Errorf("Unexpected error for future schema: %v\n", err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	neturl "net/url"
+	"sort"
+	"strings"
+)
+
+// maxThirdPartyExamples is the number of example pages kept for each third-party domain
+const maxThirdPartyExamples = 3
+
+// ThirdPartyDomain struct represents an external domain the crawled pages depend on, e.g. a CDN or a tracker.
+// Assets and Links count the references to it across the crawl and Pages holds a few of the pages referencing it.
+type ThirdPartyDomain struct {
+	Domain string   `json:"domain"`
+	Assets int      `json:"assets"`
+	Links  int      `json:"links"`
+	Pages  []string `json:"pages"`
+}
+
+// GetThirdPartyDomains returns the domains other than those of the seeds which are referenced by the assets
+// and links of the crawled pages, the most referenced first. The links out of the crawled domains are only counted
+// if the extractor returns them, as links or external links (see ExternalLinkExtractor).
+func (c *Crawler) GetThirdPartyDomains() []ThirdPartyDomain {
+	c.mutp.Lock()
+	domains := make([]ThirdPartyDomain, 0, len(c.thirdParty))
+	for _, d := range c.thirdParty {
+		pages := make([]string, len(d.Pages))
+		copy(pages, d.Pages)
+
+		domains = append(domains, ThirdPartyDomain{Domain: d.Domain, Assets: d.Assets, Links: d.Links, Pages: pages})
+	}
+	c.mutp.Unlock()
+
+	sort.Slice(domains, func(i, j int) bool {
+		if ci, cj := domains[i].Assets+domains[i].Links, domains[j].Assets+domains[j].Links; ci != cj {
+			return ci > cj
+		}
+
+		return domains[i].Domain < domains[j].Domain
+	})
+
+	return domains
+}
+
+// recordThirdParty counts the references of the page to third-party domains
+func (c *Crawler) recordThirdParty(page string, assets []*Asset, links []string) {
+	for _, asset := range assets {
+		if domain, ok := c.thirdPartyDomain(asset.Url); ok {
+			c.addThirdParty(domain, page, true)
+		}
+	}
+
+	for _, link := range links {
+		if domain, ok := c.thirdPartyDomain(link); ok {
+			c.addThirdParty(domain, page, false)
+		}
+	}
+}
+
+// externalLinksOf returns the links of the result's page out of the crawled domains, taken from the baseline
+// if it was not modified
+func (c *Crawler) externalLinksOf(r *result) []string {
+	if r.cached != nil {
+		return r.cached.ExternalLinks
+	}
+
+	e, ok := c.extractorFor(r.url).(ExternalLinkExtractor)
+	if !ok {
+		return nil
+	}
+
+	links, err := e.ExtractExternalLinks(r.body)
+	if err != nil || len(links) == 0 {
+		return nil
+	}
+
+	return links
+}
+
+// thirdPartyDomain returns the domain of the URL, unless it is the domain of one of the seeds
+// or, if subdomains are included, their subdomain. Relative and malformed URLs are not third-party.
+func (c *Crawler) thirdPartyDomain(url string) (string, bool) {
+	u, err := neturl.Parse(url)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+
+	domain := hostnameOf(u.Hostname())
+	for _, seed := range c.seeds {
+		s, err := neturl.Parse(seed)
+		if err != nil {
+			continue
+		}
+
+		if hostnameOf(s.Hostname()) == domain || (c.includeSubdomains && strings.HasSuffix(domain, "."+scopeOf(s.Hostname()))) {
+			return "", false
+		}
+	}
+
+	return domain, true
+}
+
+func (c *Crawler) addThirdParty(domain, page string, asset bool) {
+	c.mutp.Lock()
+	defer c.mutp.Unlock()
+
+	d, ok := c.thirdParty[domain]
+	if !ok {
+		d = &ThirdPartyDomain{Domain: domain}
+		c.thirdParty[domain] = d
+	}
+
+	if asset {
+		d.Assets++
+	} else {
+		d.Links++
+	}
+
+	if len(d.Pages) == maxThirdPartyExamples {
+		return
+	}
+
+	for _, p := range d.Pages {
+		if p == page {
+			return
+		}
+	}
+
+	d.Pages = append(d.Pages, page)
+}
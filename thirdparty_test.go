@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCrawlerInventoriesThirdPartyDomains(t *testing.T) {
+	c := &Crawler{
+		seeds:      []string{"http://example.com/", "http://blog.example.com/"},
+		thirdParty: make(map[string]*ThirdPartyDomain),
+	}
+
+	c.recordThirdParty("http://example.com/", []*Asset{
+		{Url: "http://example.com/main.js", Type: Script},
+		{Url: "https://cdn.example.org/lib.js", Type: Script},
+		{Url: "https://cdn.example.org/lib.css", Type: Link},
+		{Url: "https://tracker.example.net/pixel.gif", Type: Image},
+	}, []string{"http://blog.example.com/post", "http://example.com.:80/about", "https://social.example.net/share"})
+
+	for _, page := range []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"} {
+		c.recordThirdParty(page, []*Asset{{Url: "https://tracker.example.net:443/pixel.gif", Type: Image}}, nil)
+	}
+
+	expected := []ThirdPartyDomain{
+		{Domain: "tracker.example.net", Assets: 4, Pages: []string{"http://example.com/", "http://example.com/a", "http://example.com/b"}},
+		{Domain: "cdn.example.org", Assets: 2, Pages: []string{"http://example.com/"}},
+		{Domain: "social.example.net", Links: 1, Pages: []string{"http://example.com/"}},
+	}
+
+	if domains := c.GetThirdPartyDomains(); !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Unexpected third-party domains: %v\n", domains)
+	}
+}
+
+// externalExtractor treats the lines of the body starting with "external " as external links
+type externalExtractor struct {
+	lineExtractor
+}
+
+func (e externalExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	title, lines, assets, err := e.lineExtractor.Extract(body)
+
+	links := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "external ") {
+			links = append(links, line)
+		}
+	}
+
+	return title, links, assets, err
+}
+
+func (e externalExtractor) ExtractExternalLinks(body []byte) ([]string, error) {
+	_, lines, _, err := e.lineExtractor.Extract(body)
+
+	links := make([]string, 0)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "external ") {
+			links = append(links, strings.TrimPrefix(line, "external "))
+		}
+	}
+
+	return links, err
+}
+
+func TestCrawlerRecordsExternalLinks(t *testing.T) {
+	var (
+		server    *httptest.Server
+		requested = make(map[string]bool)
+		mu        sync.Mutex
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path] = true
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\nexternal https://github.com/mpraski\nexternal %s/elsewhere\n", server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Extractor:    externalExtractor{},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	page, ok := c.GetSiteMap().Get(server.URL + "/")
+	if expected := []string{"https://github.com/mpraski", server.URL + "/elsewhere"}; !ok || !reflect.DeepEqual(page.ExternalLinks, expected) {
+		t.Fatalf("Unexpected external links: %v\n", page)
+	}
+
+	if page, ok = c.GetSiteMap().Get(server.URL + "/a"); !ok || page.ExternalLinks != nil {
+		t.Errorf("Unexpected external links of %s: %v\n", server.URL+"/a", page)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if requested["/elsewhere"] {
+		t.Errorf("External link followed\n")
+	}
+
+	if domains := c.GetThirdPartyDomains(); len(domains) != 1 || domains[0].Domain != "github.com" || domains[0].Links != 1 {
+		t.Errorf("Unexpected third-party domains: %v\n", domains)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// SkipReason defines why a discovered URL was not crawled.
+type SkipReason string
+
+const (
+	SkipMaxDepth  SkipReason = "max-depth"
+	SkipMaxPages  SkipReason = "max-pages"
+	SkipHostPages SkipReason = "max-host-pages"
+	SkipMaxBytes  SkipReason = "max-bytes"
+	SkipTimeLimit SkipReason = "time-limit"
+	SkipStopped   SkipReason = "stopped"
+	SkipRobots    SkipReason = "robots"
+	SkipFiltered  SkipReason = "filtered"
+	SkipNofollow  SkipReason = "nofollow"
+
+	SkipContentType SkipReason = "content-type"
+	SkipTooLarge    SkipReason = "too-large"
+)
+
+// UncrawledURL struct represents a URL discovered on the page From which was never fetched because of Reason.
+// Depth is the number of links between the root URL and the URL.
+type UncrawledURL struct {
+	Url    string     `json:"url"`
+	From   string     `json:"from"`
+	Depth  int        `json:"depth"`
+	Reason SkipReason `json:"reason"`
+}
+
+// DiscoverySource defines where a discovered URL was found.
+type DiscoverySource string
+
+const (
+	ViaLink       DiscoverySource = "link"
+	ViaFeed       DiscoverySource = "feed"
+	ViaSitemap    DiscoverySource = "sitemap"
+	ViaCheckpoint DiscoverySource = "checkpoint"
+)
+
+// DiscoveryEvent struct represents the URL discovered on the page From (<root> for the pages listed in the sitemaps),
+// Depth links away from the root URL, Via telling whether it was linked by the page, listed in one of its feeds
+// or in a sitemap, or left in the frontier of a resumed checkpoint. Enqueued reports whether it was scheduled
+// to be crawled, Reason why it was not otherwise. A URL is enqueued at most once, but may be skipped on every page
+// it is discovered on.
+type DiscoveryEvent struct {
+	Url, From string
+	Depth     int
+	Via       DiscoverySource
+	Enqueued  bool
+	Reason    SkipReason
+}
+
+// GetUncrawled returns the URLs discovered but not crawled so far, sorted by URL. Only the first page
+// a URL was discovered on is kept, and URLs crawled later after all, e.g. through a shorter path, are left out.
+func (c *Crawler) GetUncrawled() []UncrawledURL {
+	c.muu.Lock()
+	uncrawled := make([]UncrawledURL, 0, len(c.uncrawled))
+	for _, u := range c.uncrawled {
+		uncrawled = append(uncrawled, u)
+	}
+	c.muu.Unlock()
+
+	kept := uncrawled[:0]
+	for _, u := range uncrawled {
+		if !c.hasVisited(u.Url) && !c.isBeingProcessed(u.Url) {
+			kept = append(kept, u)
+		}
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Url < kept[j].Url
+	})
+
+	return kept
+}
+
+func (c *Crawler) markUncrawled(url, from string, depth int, reason SkipReason) {
+	c.muu.Lock()
+	_, ok := c.uncrawled[url]
+	if !ok {
+		c.uncrawled[url] = UncrawledURL{Url: url, From: from, Depth: depth, Reason: reason}
+	}
+	c.muu.Unlock()
+
+	if !ok {
+		c.hookSkip(url, reason)
+	}
+}
+
+// skipReason returns why the link discovered at given depth must not be scheduled, if it must not.
+// The page budgets are left to reservePage, so that only the links reserving a page release it.
+func (c *Crawler) skipReason(link string, depth int) (SkipReason, bool) {
+	switch {
+	case c.isStopped() || c.ctx.Err() != nil:
+		return SkipStopped, true
+	case !c.deadline.IsZero() && time.Now().After(c.deadline):
+		return SkipTimeLimit, true
+	case c.bytesExhausted():
+		return SkipMaxBytes, true
+	case !c.withinDepth(depth):
+		return SkipMaxDepth, true
+	case !c.filter.allowed(link):
+		return SkipFiltered, true
+	case !c.allowedByRobots(link):
+		return SkipRobots, true
+	default:
+		return "", false
+	}
+}
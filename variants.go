@@ -0,0 +1,137 @@
+package main
+
+import (
+	neturl "net/url"
+	"sort"
+)
+
+// maxVariantExamples is the number of example pages kept for each variant of a link
+const maxVariantExamples = 3
+
+// VariantReport struct defines which variations of the links to the same page are reported (see GetLinkVariants).
+// Query reports the links differing in the query, e.g. /pricing?utm_source=mail and /pricing?utm_source=ad,
+// Fragment the links differing in the fragment, e.g. /pricing#plans and /pricing#faq. The variations are reported
+// even if the links are crawled as one page, e.g. since fragments are stripped or the query is normalized.
+type VariantReport struct {
+	Query, Fragment bool
+}
+
+// LinkVariants struct represents the variants of the links to the page under Url, which is the canonical URL
+// the links share without the query.
+type LinkVariants struct {
+	Url      string         `json:"url"`
+	Variants []*LinkVariant `json:"variants"`
+}
+
+// LinkVariant struct represents one form of the links to a page, Links counts the pages linking with it
+// and Pages holds a few of them.
+type LinkVariant struct {
+	Url   string   `json:"url"`
+	Links int      `json:"links"`
+	Pages []string `json:"pages"`
+}
+
+// GetLinkVariants returns the pages linked to in more than one way according to VariantReport,
+// the page with the most variants first and the variants of each page the most used first.
+func (c *Crawler) GetLinkVariants() []LinkVariants {
+	c.muvar.Lock()
+	pages := make([]LinkVariants, 0, len(c.variants))
+	for url, variants := range c.variants {
+		if len(variants) < 2 {
+			continue
+		}
+
+		v := LinkVariants{Url: url, Variants: make([]*LinkVariant, 0, len(variants))}
+		for _, variant := range variants {
+			pages := make([]string, len(variant.Pages))
+			copy(pages, variant.Pages)
+
+			v.Variants = append(v.Variants, &LinkVariant{Url: variant.Url, Links: variant.Links, Pages: pages})
+		}
+
+		sort.Slice(v.Variants, func(i, j int) bool {
+			if v.Variants[i].Links != v.Variants[j].Links {
+				return v.Variants[i].Links > v.Variants[j].Links
+			}
+
+			return v.Variants[i].Url < v.Variants[j].Url
+		})
+
+		pages = append(pages, v)
+	}
+	c.muvar.Unlock()
+
+	sort.Slice(pages, func(i, j int) bool {
+		if len(pages[i].Variants) != len(pages[j].Variants) {
+			return len(pages[i].Variants) > len(pages[j].Variants)
+		}
+
+		return pages[i].Url < pages[j].Url
+	})
+
+	return pages
+}
+
+// recordVariants counts the forms of the links of the page, as they are before canonicalization.
+// The forms differ only in the reported parts, the rest of the link is canonical.
+func (c *Crawler) recordVariants(page string, links []string) {
+	if !c.variantReport.Query && !c.variantReport.Fragment {
+		return
+	}
+
+	for _, link := range links {
+		raw, err := neturl.Parse(link)
+		if err != nil {
+			continue
+		}
+
+		u, err := neturl.Parse(c.rewritten(link))
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		u.RawQuery, u.ForceQuery = "", false
+		url := u.String()
+
+		if c.variantReport.Query {
+			u.RawQuery = raw.RawQuery
+		}
+
+		if c.variantReport.Fragment {
+			u.Fragment, u.RawFragment = raw.Fragment, raw.RawFragment
+		}
+
+		c.addVariant(url, u.String(), page)
+	}
+}
+
+func (c *Crawler) addVariant(url, variant, page string) {
+	c.muvar.Lock()
+	defer c.muvar.Unlock()
+
+	variants, ok := c.variants[url]
+	if !ok {
+		variants = make(map[string]*LinkVariant)
+		c.variants[url] = variants
+	}
+
+	v, ok := variants[variant]
+	if !ok {
+		v = &LinkVariant{Url: variant}
+		variants[variant] = v
+	}
+
+	v.Links++
+
+	if len(v.Pages) == maxVariantExamples {
+		return
+	}
+
+	for _, p := range v.Pages {
+		if p == page {
+			return
+		}
+	}
+
+	v.Pages = append(v.Pages, page)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlerReportsLinkVariants(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/pricing?utm_source=mail\n%s/pricing#plans\n%s/about\n%s/blog\n", server.URL, server.URL, server.URL, server.URL)
+		case "/blog":
+			fmt.Fprintf(w, "%s/pricing?utm_source=mail\n%s/pricing?utm_source=ad\n%s/ABOUT/../about\n", server.URL, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		report   VariantReport
+		expected []LinkVariants
+	}{
+		{VariantReport{}, []LinkVariants{}},
+		{VariantReport{Query: true}, []LinkVariants{{
+			Url: server.URL + "/pricing",
+			Variants: []*LinkVariant{
+				{Url: server.URL + "/pricing?utm_source=mail", Links: 2, Pages: []string{server.URL + "/", server.URL + "/blog"}},
+				{Url: server.URL + "/pricing", Links: 1, Pages: []string{server.URL + "/"}},
+				{Url: server.URL + "/pricing?utm_source=ad", Links: 1, Pages: []string{server.URL + "/blog"}},
+			},
+		}}},
+		{VariantReport{Query: true, Fragment: true}, []LinkVariants{{
+			Url: server.URL + "/pricing",
+			Variants: []*LinkVariant{
+				{Url: server.URL + "/pricing?utm_source=mail", Links: 2, Pages: []string{server.URL + "/", server.URL + "/blog"}},
+				{Url: server.URL + "/pricing#plans", Links: 1, Pages: []string{server.URL + "/"}},
+				{Url: server.URL + "/pricing?utm_source=ad", Links: 1, Pages: []string{server.URL + "/blog"}},
+			},
+		}}},
+	} {
+		c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+			MaxWorkers:     1,
+			MaxRetries:     1,
+			Downloader:     unpooledDownloader{},
+			Extractor:      lineExtractor{},
+			IgnoreRobots:   true,
+			ReportVariants: tc.report,
+		})
+		if err != nil {
+			t.Fatalf("Crawler fails with error: %s\n", err.Error())
+		}
+
+		done, errs := c.Crawl()
+		for range errs {
+		}
+		<-done
+
+		if variants := c.GetLinkVariants(); !reflect.DeepEqual(variants, tc.expected) {
+			t.Errorf("Unexpected variants reported for %v: %v\n", tc.report, variants)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync"
+)
+
+// VisitedSet interface abstracts the set of URLs the crawler has scheduled, which decides whether a discovered
+// URL is new. Add adds the URL and reports whether it was not in the set yet, it must be atomic since the workers
+// add the URLs they discover concurrently. Implementations may err on the side of containing a URL,
+// which is then never crawled, but never on the other.
+type VisitedSet interface {
+	Add(url string) bool
+	Contains(url string) bool
+}
+
+// mapVisitedSet implementation holds every URL in a map, it is exact but grows with the crawl
+type mapVisitedSet struct {
+	mu   sync.RWMutex
+	urls map[string]struct{}
+}
+
+func newMapVisitedSet() *mapVisitedSet {
+	return &mapVisitedSet{urls: make(map[string]struct{})}
+}
+
+func (s *mapVisitedSet) Add(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.urls[url]; ok {
+		return false
+	}
+
+	s.urls[url] = struct{}{}
+	return true
+}
+
+func (s *mapVisitedSet) Contains(url string) bool {
+	s.mu.RLock()
+	_, ok := s.urls[url]
+	s.mu.RUnlock()
+
+	return ok
+}
+
+// bloomMagic identifies the serialized form of BloomVisitedSet
+const bloomMagic uint32 = 0x626c6f6d
+
+// BloomVisitedSet struct implements VisitedSet with a Bloom filter, whose size is fixed no matter how long
+// the URLs are. A URL not yet added is taken for a visited one with a small probability, so that it is not crawled.
+// The set can be written to a file with WriteTo and read with ReadBloomVisitedSet to be shared between crawls.
+type BloomVisitedSet struct {
+	mu     sync.RWMutex
+	bits   []uint64
+	hashes uint32
+}
+
+// NewBloomVisitedSet returns a Bloom filter sized for the capacity of URLs with the false positive rate,
+// e.g. 10 million URLs at 0.001 take about 18MB. The rate grows past the capacity.
+func NewBloomVisitedSet(capacity int, rate float64) *BloomVisitedSet {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	if rate <= 0 || rate >= 1 {
+		rate = 0.001
+	}
+
+	m := math.Ceil(-float64(capacity) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(capacity)*math.Ln2))
+
+	return &BloomVisitedSet{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		hashes: uint32(k),
+	}
+}
+
+// ReadBloomVisitedSet reads the set written by WriteTo.
+func ReadBloomVisitedSet(r io.Reader) (*BloomVisitedSet, error) {
+	var header struct {
+		Magic, Hashes uint32
+		Words         uint64
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Magic != bloomMagic || header.Hashes == 0 || header.Words == 0 {
+		return nil, ErrInvalidVisitedSet
+	}
+
+	s := &BloomVisitedSet{hashes: header.Hashes, bits: make([]uint64, header.Words)}
+	if err := binary.Read(r, binary.LittleEndian, s.bits); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// WriteTo writes the set in a binary form read by ReadBloomVisitedSet.
+func (s *BloomVisitedSet) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	header := struct {
+		Magic, Hashes uint32
+		Words         uint64
+	}{bloomMagic, s.hashes, uint64(len(s.bits))}
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, s.bits); err != nil {
+		return 16, err
+	}
+
+	return 16 + 8*int64(len(s.bits)), nil
+}
+
+func (s *BloomVisitedSet) Add(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := false
+	s.positions(url, func(word int, bit uint64) {
+		if s.bits[word]&bit == 0 {
+			s.bits[word] |= bit
+			added = true
+		}
+	})
+
+	return added
+}
+
+func (s *BloomVisitedSet) Contains(url string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	contains := true
+	s.positions(url, func(word int, bit uint64) {
+		contains = contains && s.bits[word]&bit != 0
+	})
+
+	return contains
+}
+
+// positions calls f with the bits of the URL, derived from two hashes of it by double hashing
+func (s *BloomVisitedSet) positions(url string, f func(word int, bit uint64)) {
+	h1, h2 := fnv.New64a(), fnv.New64()
+	h1.Write([]byte(url))
+	h2.Write([]byte(url))
+
+	var (
+		a, b = h1.Sum64(), h2.Sum64() | 1
+		m    = uint64(len(s.bits)) * 64
+	)
+
+	for i := uint64(0); i < uint64(s.hashes); i++ {
+		p := (a + i*b) % m
+		f(int(p/64), 1<<(p%64))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBloomVisitedSetAddsURLs(t *testing.T) {
+	const n = 10000
+
+	s := NewBloomVisitedSet(n, 0.01)
+
+	// A URL not added yet may be taken for an added one, but an added one never for a new one
+	var positives int
+	for i := 0; i < n; i++ {
+		url := fmt.Sprintf("http://example.com/%d", i)
+		if !s.Add(url) {
+			positives++
+		}
+
+		if s.Add(url) {
+			t.Fatalf("URL added twice: %s\n", url)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if url := fmt.Sprintf("http://example.com/%d", i); !s.Contains(url) {
+			t.Fatalf("Added URL not contained: %s\n", url)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if s.Contains(fmt.Sprintf("http://example.org/%d", i)) {
+			positives++
+		}
+	}
+
+	if rate := float64(positives) / (2 * n); rate > 0.02 {
+		t.Errorf("False positive rate too high: %f\n", rate)
+	}
+}
+
+func TestBloomVisitedSetIsPersisted(t *testing.T) {
+	s := NewBloomVisitedSet(100, 0.001)
+	s.Add("http://example.com/")
+
+	var b bytes.Buffer
+	if _, err := s.WriteTo(&b); err != nil {
+		t.Fatalf("Writing the set fails with error: %s\n", err.Error())
+	}
+
+	restored, err := ReadBloomVisitedSet(&b)
+	if err != nil {
+		t.Fatalf("Reading the set fails with error: %s\n", err.Error())
+	}
+
+	if !restored.Contains("http://example.com/") || restored.Contains("http://example.com/a") {
+		t.Errorf("Restored set differs\n")
+	}
+
+	if _, err := ReadBloomVisitedSet(bytes.NewReader(make([]byte, 32))); err != ErrInvalidVisitedSet {
+		t.Errorf("Invalid set read: %v\n", err)
+	}
+}
+
+func TestCrawlerUsesVisitedSet(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/a\n%s/b\n", server.URL, server.URL)
+		case "/a":
+			fmt.Fprintf(w, "%s/\n%s/b\n%s/c\n", server.URL, server.URL, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	visited := NewBloomVisitedSet(100, 0.001)
+	visited.Add(server.URL + "/c")
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Extractor:       lineExtractor{},
+		IgnoreRobots:    true,
+		VisitedSet:      visited,
+		CheckInvariants: true,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Unexpected error: %s\n", err.Error())
+	}
+	<-done
+
+	sitemap := c.GetSiteMap()
+	if _, ok := sitemap.Get(server.URL + "/c"); ok || sitemap.Len() != 3 {
+		t.Errorf("Unexpected pages: %v\n", sitemap.outLinks())
+	}
+
+	if !visited.Contains(server.URL+"/a") || !visited.Contains(server.URL+"/b") {
+		t.Errorf("Crawled URLs not added to the set\n")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+// warmTimeout bounds how long the crawl waits for the connections to be opened before it starts
+const warmTimeout = 5 * time.Second
+
+// Warmer interface abstracts opening keep-alive connections to the host of the URL ahead of the requests to it,
+// so that they do not wait for the TCP and TLS handshakes.
+type Warmer interface {
+	Warm(ctx context.Context, url string, n int) error
+}
+
+// Warm opens n connections to the origin of the URL by sending as many HEAD requests to it at once,
+// which are kept alive in the pool of the downloader. Only one is kept over HTTP/2, which multiplexes
+// the requests anyway, and at most WarmConnections of DownloaderOptions (or 2) are kept idle.
+func (d *defaultDownloader) Warm(ctx context.Context, url string, n int) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+
+	origin := (&neturl.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := d.warm(ctx, origin); err != nil {
+				mu.Lock()
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return first
+}
+
+func (d *defaultDownloader) warm(ctx context.Context, origin string) error {
+	req, err := d.newRequest(ctx, http.MethodHead, origin, "")
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.do(req)
+	if err != nil {
+		return err
+	}
+
+	// The connection is only reused once the body is read to the end and closed
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// warmUp opens the connections to the host of the root URL before the first requests of the crawl,
+// if the downloader implements Warmer. The errors are left to the requests of the crawl to report.
+func (c *Crawler) warmUp() {
+	w, ok := c.downloader.(Warmer)
+	if c.warmConnections == 0 || !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, warmTimeout)
+	defer cancel()
+
+	w.Warm(ctx, c.url, c.warmConnections)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// connectionCounter counts the connections accepted by the server
+type connectionCounter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *connectionCounter) track(conn net.Conn, state http.ConnState) {
+	if state == http.StateNew {
+		c.mu.Lock()
+		c.n++
+		c.mu.Unlock()
+	}
+}
+
+func (c *connectionCounter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.n
+}
+
+func TestDownloaderWarmsConnections(t *testing.T) {
+	var (
+		counter connectionCounter
+		methods = make(map[string]int)
+		mu      sync.Mutex
+	)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+	}))
+	server.Config.ConnState = counter.track
+	server.Start()
+	defer server.Close()
+
+	d, err := NewDefaultDownloaderWithOptions(&DownloaderOptions{
+		Timeout:         2,
+		Pool:            NewBufferPool(1, 1024),
+		WarmConnections: 4,
+	})
+	if err != nil {
+		t.Fatalf("Downloader fails with error: %s\n", err.Error())
+	}
+
+	if err = d.(Warmer).Warm(context.Background(), server.URL+"/page?a=1", 4); err != nil {
+		t.Fatalf("Warm fails with error: %s\n", err.Error())
+	}
+
+	warmed := counter.count()
+	if warmed < 2 || warmed > 4 {
+		t.Errorf("Unexpected number of connections: %d\n", warmed)
+	}
+
+	// The warm connections are idle in the pool, so the requests sent at once do not open new ones
+	var wg sync.WaitGroup
+	for i := 0; i < warmed; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := d.Download(fmt.Sprintf("%s/%d", server.URL, i)); err != nil {
+				t.Errorf("Downloader fails with error: %s\n", err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := counter.count(); n > 4 {
+		t.Errorf("Unexpected number of connections after the downloads: %d\n", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if methods["HEAD /"] != 4 {
+		t.Errorf("Unexpected requests: %v\n", methods)
+	}
+}
+
+func TestCrawlerWarmsConnections(t *testing.T) {
+	var (
+		counter connectionCounter
+		server  *httptest.Server
+		heads   int
+		mu      sync.Mutex
+	)
+
+	server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			mu.Lock()
+			heads++
+			mu.Unlock()
+			return
+		}
+
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/a\n%s/b\n", server.URL, server.URL)
+		}
+	}))
+	server.Config.ConnState = counter.track
+	server.Start()
+	defer server.Close()
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:      2,
+		MaxRetries:      1,
+		Extractor:       lineExtractor{},
+		IgnoreRobots:    true,
+		WarmConnections: 2,
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, errs := c.Crawl()
+	for err := range errs {
+		t.Errorf("Crawl fails with error: %s\n", err.Error())
+	}
+	<-done
+
+	if l := c.GetSiteMap().Len(); l != 3 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if heads != 2 {
+		t.Errorf("Unexpected number of warming requests: %d\n", heads)
+	}
+}
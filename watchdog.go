@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of tasks, the goroutines downloading the pages and the ones processing them
+const (
+	taskFetcher = "fetcher"
+	taskWorker  = "worker"
+)
+
+// task struct represents a fetcher or a worker, along with the URL it is busy with. A task busy with the same URL
+// for longer than the stall timeout is considered stuck: its request is cancelled and a goroutine taking over its
+// duties is started, while the stuck one exits once it is done with the URL. The fields are guarded with mutask.
+// A task is identified by its kind and number, e.g. fetcher-3, the replacements of stuck ones getting new numbers.
+type task struct {
+	kind    string
+	n       int
+	url     string
+	since   time.Time
+	step    time.Time
+	waiting int
+	done    int
+	cancel  context.CancelFunc
+	restart func()
+	stalled bool
+}
+
+func (t *task) id() string {
+	return fmt.Sprintf("%s-%d", t.kind, t.n)
+}
+
+// WorkerStatus struct represents a fetcher or a worker of the crawl. ID identifies it in the errors it reports
+// (see CrawlError), Url is the URL it has been busy with for Busy, empty if it is idle, and Done the number of URLs
+// it is done with. Stalled is set once it was replaced after being busy with Url for longer than the stall timeout.
+type WorkerStatus struct {
+	ID      string        `json:"id"`
+	Url     string        `json:"url,omitempty"`
+	Busy    time.Duration `json:"busy,omitempty"`
+	Done    int           `json:"done"`
+	Stalled bool          `json:"stalled,omitempty"`
+}
+
+// Workers returns the status of the fetchers and workers of the running crawl, e.g. to find the one a stuck crawl
+// waits for. It is safe to call while the crawl is running and empty once it is done.
+func (c *Crawler) Workers() []WorkerStatus {
+	c.mutask.Lock()
+	tasks := make([]*task, 0, len(c.live))
+	for t := range c.live {
+		tasks = append(tasks, t)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].kind != tasks[j].kind {
+			return tasks[i].kind < tasks[j].kind
+		}
+
+		return tasks[i].n < tasks[j].n
+	})
+
+	workers := make([]WorkerStatus, 0, len(tasks))
+	for _, t := range tasks {
+		w := WorkerStatus{ID: t.id(), Url: t.url, Done: t.done, Stalled: t.stalled}
+		if t.url != "" {
+			w.Busy = time.Since(t.since)
+		}
+
+		workers = append(workers, w)
+	}
+	c.mutask.Unlock()
+
+	return workers
+}
+
+// newTask registers a task of the kind with the next number of the kind, restart starting its replacement
+func (c *Crawler) newTask(kind string, restart func()) *task {
+	c.mutask.Lock()
+	defer c.mutask.Unlock()
+
+	c.taskCounts[kind]++
+
+	t := &task{kind: kind, n: c.taskCounts[kind], restart: restart}
+	c.live[t] = struct{}{}
+
+	return t
+}
+
+// endTask unregisters the task once its goroutine exits
+func (c *Crawler) endTask(t *task) {
+	c.mutask.Lock()
+	delete(c.live, t)
+	c.mutask.Unlock()
+}
+
+// watch marks the task busy with the URL, cancel being the function cancelling its request, if it has any
+func (c *Crawler) watch(t *task, url string, cancel context.CancelFunc) {
+	c.mutask.Lock()
+	t.url, t.since, t.step, t.cancel = url, time.Now(), time.Now(), cancel
+	c.tasks[t] = struct{}{}
+	c.mutask.Unlock()
+}
+
+// pauseTask stops the stall timeout of the task (if any) while it waits for a host, e.g. for its politeness delay
+// or a slot of it, until the returned function is called: the time waited does not count towards the timeout.
+func (c *Crawler) pauseTask(t *task) func() {
+	if t == nil {
+		return func() {}
+	}
+
+	c.mutask.Lock()
+	t.waiting++
+	c.mutask.Unlock()
+
+	start := time.Now()
+
+	return func() {
+		c.mutask.Lock()
+		t.waiting--
+		t.step = t.step.Add(time.Since(start))
+		c.mutask.Unlock()
+	}
+}
+
+// nextStep restarts the stall timeout of the task (if any) as it starts another request on behalf of its URL,
+// e.g. fetching an asset of the page, so that a page with many assets is not taken for a stalled one.
+func (c *Crawler) nextStep(t *task) {
+	if t == nil {
+		return
+	}
+
+	c.mutask.Lock()
+	t.step = time.Now()
+	c.mutask.Unlock()
+}
+
+func (c *Crawler) unwatch(t *task) {
+	c.mutask.Lock()
+	delete(c.tasks, t)
+	t.url, t.cancel = "", nil
+	t.done++
+	c.mutask.Unlock()
+}
+
+// retired reports whether the task was replaced after stalling, in which case its goroutine must exit
+func (c *Crawler) retired(t *task) bool {
+	c.mutask.Lock()
+	defer c.mutask.Unlock()
+
+	return t.stalled
+}
+
+// watchTasks checks for stalled tasks every quarter of the stall timeout until the stop channel is closed
+func (c *Crawler) watchTasks(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.stallTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.checkStalled()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkStalled reports the tasks busy with the same URL (or the same step of its work, see nextStep) for longer
+// than the stall timeout, cancels their requests and replaces them. The time the crawl is paused for and the time
+// the tasks wait for the hosts (see pauseTask) do not count.
+func (c *Crawler) checkStalled() {
+	c.mupause.Lock()
+	paused := c.paused
+	c.mupause.Unlock()
+
+	c.mutask.Lock()
+	stalled := make([]*task, 0)
+	reports := make([]CrawlError, 0)
+	for t := range c.tasks {
+		if paused {
+			t.step = time.Now()
+		} else if !t.stalled && t.waiting == 0 && time.Since(t.step) > c.stallTimeout {
+			t.stalled = true
+			stalled = append(stalled, t)
+
+			// Only the fetchers have requests to cancel
+			if t.cancel != nil {
+				t.cancel()
+				reports = append(reports, CrawlError{Url: t.url, Phase: PhaseDownload, Worker: t.id(), Err: ErrStalled})
+			} else {
+				reports = append(reports, CrawlError{Url: t.url, Phase: PhaseExtract, Worker: t.id(), Err: ErrStalled})
+			}
+		}
+	}
+	c.mutask.Unlock()
+
+	for i, t := range stalled {
+		c.reportError(reports[i])
+		t.restart()
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawlerCancelsStalledDownloads(t *testing.T) {
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests int
+	)
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, "%s/hung\n", server.URL)
+			return
+		}
+
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+
+		// Only the first request hangs, until it is cancelled
+		if first {
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mue     sync.Mutex
+		stalled []string
+	)
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   2,
+		MaxFetchers:  1,
+		MaxRetries:   1,
+		Extractor:    lineExtractor{},
+		IgnoreRobots: true,
+		StallTimeout: 100 * time.Millisecond,
+		OnError: func(err CrawlError) {
+			if errors.Is(err.Err, ErrStalled) {
+				mue.Lock()
+				stalled = append(stalled, err.Url+" "+err.Worker)
+				mue.Unlock()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, _ := c.Crawl()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Crawl stalled\n")
+	}
+
+	if len(stalled) != 1 || stalled[0] != server.URL+"/hung fetcher-1" {
+		t.Errorf("Unexpected stalled URLs: %v\n", stalled)
+	}
+
+	if _, ok := c.GetSiteMap().Get(server.URL + "/hung"); !ok {
+		t.Errorf("Stalled page not crawled after retry\n")
+	}
+}
+
+// blockingExtractor blocks extracting the page whose body is block until released, closing blocked once it does
+type blockingExtractor struct {
+	lineExtractor
+	block   string
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (e blockingExtractor) Extract(body []byte) (string, []string, []*Asset, error) {
+	if string(body) == e.block {
+		close(e.blocked)
+		<-e.release
+	}
+
+	return e.lineExtractor.Extract(body)
+}
+
+func TestCrawlerReplacesStalledWorkers(t *testing.T) {
+	var (
+		server  *httptest.Server
+		blocked = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	// The other pages are only served once the worker is stuck on /slow, so that they are left to its replacement
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, "%s/slow\n%s/a\n%s/b\n", server.URL, server.URL, server.URL)
+		case "/slow":
+			fmt.Fprint(w, "block")
+		default:
+			<-blocked
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mu      sync.Mutex
+		stalled int
+		worker  string
+	)
+
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:   1,
+		MaxRetries:   1,
+		Downloader:   unpooledDownloader{},
+		Extractor:    blockingExtractor{block: "block", blocked: blocked, release: release},
+		IgnoreRobots: true,
+		StallTimeout: 100 * time.Millisecond,
+		OnError: func(err CrawlError) {
+			if errors.Is(err.Err, ErrStalled) {
+				mu.Lock()
+				stalled++
+				worker = err.Worker
+				mu.Unlock()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, _ := c.Crawl()
+
+	// The only worker gets stuck on /slow, so the pages after it are only processed by its replacement,
+	// which counts them once done with each of them
+	var workers []WorkerStatus
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		replaced := stalled > 0
+		mu.Unlock()
+
+		if replaced && c.hasVisited(server.URL+"/a") && c.hasVisited(server.URL+"/b") {
+			workers = c.Workers()
+			if replacementDone(workers) {
+				break
+			}
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Pages not processed while the worker is stuck\n")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	<-done
+
+	if stalled != 1 || worker != "worker-1" {
+		t.Errorf("Unexpected stalled workers: %d (%s)\n", stalled, worker)
+	}
+
+	// The stuck worker is still busy with /slow while its replacement took over the other pages
+	ids := make(map[string]WorkerStatus)
+	for _, w := range workers {
+		ids[w.ID] = w
+	}
+
+	if w := ids["worker-1"]; w.Url != server.URL+"/slow" || !w.Stalled || w.Busy < 100*time.Millisecond {
+		t.Errorf("Unexpected status of the stuck worker: %+v\n", w)
+	}
+
+	if w := ids["worker-2"]; w.Stalled || w.Done == 0 {
+		t.Errorf("Unexpected status of the replacement: %+v\n", w)
+	}
+
+	if _, ok := ids["fetcher-1"]; !ok {
+		t.Errorf("Fetchers not reported: %v\n", workers)
+	}
+
+	if w := c.Workers(); len(w) != 0 {
+		t.Errorf("Workers reported after the crawl: %v\n", w)
+	}
+
+	if l := c.GetSiteMap().Len(); l != 4 {
+		t.Errorf("Unexpected sitemap length: %d\n", l)
+	}
+}
+
+// replacementDone reports whether the replacement of the stuck worker is done with a page
+func replacementDone(workers []WorkerStatus) bool {
+	for _, w := range workers {
+		if w.ID == "worker-2" && w.Done > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestCrawlerDoesNotStallWaitingForHosts(t *testing.T) {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "image %s/logo.png\nimage %s/banner.png\n", server.URL, server.URL)
+		default:
+			w.Header().Set("Content-Type", "image/png")
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mue     sync.Mutex
+		stalled []string
+	)
+
+	// Each page and asset waits longer for the host than the stall timeout, its HEAD and GET requests included
+	c, err := NewCrawlerWithOptions(server.URL+"/", &Options{
+		MaxWorkers:      1,
+		MaxRetries:      1,
+		Extractor:       assetExtractor{},
+		AssetPolicies:   map[AssetType]AssetPolicy{Image: PolicyVerify},
+		HeadFirst:       true,
+		IgnoreRobots:    true,
+		PolitenessDelay: 300 * time.Millisecond,
+		StallTimeout:    100 * time.Millisecond,
+		OnError: func(err CrawlError) {
+			if errors.Is(err.Err, ErrStalled) {
+				mue.Lock()
+				stalled = append(stalled, err.Url+" "+err.Worker)
+				mue.Unlock()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Crawler fails with error: %s\n", err.Error())
+	}
+
+	done, _ := c.Crawl()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Crawl stalled\n")
+	}
+
+	if len(stalled) != 0 {
+		t.Errorf("Unexpected stalled URLs: %v\n", stalled)
+	}
+
+	page, ok := c.GetSiteMap().Get(server.URL + "/")
+	if !ok || len(page.Assets) != 2 || !page.Assets[0].Verified || !page.Assets[1].Verified {
+		t.Errorf("Unexpected page: %v\n", page)
+	}
+}